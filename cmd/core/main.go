@@ -6,6 +6,7 @@ import (
 
 	"github.com/stagely-dev/stagely/internal/config"
 	"github.com/stagely-dev/stagely/internal/db"
+	"github.com/stagely-dev/stagely/internal/providers"
 )
 
 func main() {
@@ -30,6 +31,14 @@ func main() {
 	}
 	log.Println("Database health check passed")
 
+	// Cloud provider types teams can connect (the registry using them starts in Phase 2)
+	if _, err := providers.NewFactoryFromConfig(cfg); err != nil {
+		log.Fatalf("Failed to set up cloud providers: %v", err)
+	}
+	if cfg.Docker.Enabled {
+		log.Println("Docker provider enabled (development and CI only)")
+	}
+
 	// Phase 0 complete - server starts in Phase 2
 	fmt.Printf(`
 ╔═══════════════════════════════════════════╗
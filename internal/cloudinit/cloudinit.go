@@ -0,0 +1,358 @@
+// Package cloudinit renders the cloud-config user data that bootstraps the Stagely agent on a new VM
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Header is the first line of every cloud-config document
+const Header = "#cloud-config"
+
+// MaxSize is the largest user data accepted by every supported provider
+// (AWS allows 16 KiB, Hetzner 32 KiB, DigitalOcean 64 KiB)
+const MaxSize = 16 * 1024
+
+// Where the bootstrap puts the agent on the VM
+const (
+	AgentBinaryPath  = "/usr/local/bin/stagely-agent"
+	AgentConfigPath  = "/etc/stagely/config.json"
+	AgentUnitPath    = "/etc/systemd/system/stagely-agent.service"
+	AgentLogPath     = "/var/log/stagely-agent.log"
+	DockerConfigPath = "/root/.docker/config.json"
+	SwapFilePath     = "/swapfile"
+)
+
+var (
+	// ErrInvalidConfig is returned when the bootstrap inputs are incomplete or malformed
+	ErrInvalidConfig = errors.New("invalid cloud-init config")
+
+	// ErrTooLarge is returned when the rendered document exceeds MaxSize
+	ErrTooLarge = errors.New("cloud-init user data too large")
+)
+
+// basePackages are installed on every VM: the agent drives Docker, curl downloads the agent
+var basePackages = []string{"ca-certificates", "curl", "docker.io"}
+
+var (
+	sha256Pattern  = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	packagePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+.\-]*(=[A-Za-z0-9.+:~\-]+)?$`)
+	devicePattern  = regexp.MustCompile(`^/dev/[a-zA-Z0-9][a-zA-Z0-9/_\-]*$`)
+)
+
+// Filesystems that Disk accepts
+var filesystems = map[string]bool{"ext4": true, "xfs": true}
+
+// Config holds the typed inputs of the agent bootstrap
+type Config struct {
+	AgentID    string // Agent identity presented in HELLO (e.g., "agt_8jk2n9s7d6f5g4h3")
+	AgentToken string // One-time token the agent authenticates with
+	StageletID string // Environment the VM serves (empty for build VMs)
+	CoreURL    string // Core WebSocket endpoint (e.g., "wss://api.stagely.dev/v1/agent/connect")
+
+	Agent AgentBinary // Agent release to install
+
+	Registries []RegistryCredential // Registries Docker logs in to (pulls of private images)
+	Packages   []string             // Extra apt packages, optionally pinned ("jq", "git=1:2.34.1-1")
+	SwapMB     int                  // Swap file size in MiB (0 = no swap)
+	Disks      []Disk               // Extra volumes to format and mount
+}
+
+// AgentBinary is a downloadable agent release
+type AgentBinary struct {
+	URL    string // Download URL of the linux binary matching the VM architecture
+	SHA256 string // Hex-encoded SHA-256 of the binary; the download is discarded on mismatch
+}
+
+// RegistryCredential logs Docker in to a container registry
+type RegistryCredential struct {
+	Server   string // Registry host (e.g., "ghcr.io", "123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	Username string
+	Password string
+}
+
+// Disk is a volume formatted and mounted at boot. Existing filesystems are kept.
+type Disk struct {
+	Device     string // Block device (e.g., "/dev/nvme1n1")
+	MountPoint string // Absolute mount path (e.g., "/var/lib/docker")
+	Filesystem string // "ext4" (default) or "xfs"
+}
+
+// document is the cloud-config rendered by Render. Field order is the output order.
+type document struct {
+	PackageUpdate bool           `yaml:"package_update"`
+	Packages      []string       `yaml:"packages"`
+	Swap          *swapConfig    `yaml:"swap,omitempty"`
+	FSSetup       []fsSetupEntry `yaml:"fs_setup,omitempty"`
+	Mounts        [][]string     `yaml:"mounts,omitempty"`
+	WriteFiles    []writeFile    `yaml:"write_files"`
+	RunCmd        []string       `yaml:"runcmd"`
+}
+
+type swapConfig struct {
+	Filename string `yaml:"filename"`
+	Size     int64  `yaml:"size"`
+}
+
+type fsSetupEntry struct {
+	Device     string `yaml:"device"`
+	Filesystem string `yaml:"filesystem"`
+	Partition  string `yaml:"partition"`
+	Overwrite  bool   `yaml:"overwrite"`
+}
+
+type writeFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Owner       string `yaml:"owner"`
+	Content     string `yaml:"content"`
+}
+
+// agentConfig is the agent's config file (see docs/architecture/02-agent-protocol.md)
+type agentConfig struct {
+	AgentID    string `json:"agent_id"`
+	Token      string `json:"token"`
+	CoreURL    string `json:"core_url"`
+	StageletID string `json:"stagelet_id,omitempty"`
+}
+
+// agentUnit runs the agent under systemd once Docker is up
+const agentUnit = `[Unit]
+Description=Stagely Agent
+After=network-online.target docker.service
+Wants=network-online.target docker.service
+
+[Service]
+ExecStart=` + AgentBinaryPath + `
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// startAgent starts the agent through systemd, or directly on hosts without it (containers)
+const startAgent = `if [ -d /run/systemd/system ]; then systemctl daemon-reload && systemctl enable --now stagely-agent; ` +
+	`else nohup ` + AgentBinaryPath + ` >> ` + AgentLogPath + ` 2>&1 & fi`
+
+// Render validates cfg and returns the cloud-config document for InstanceSpec.UserData
+func Render(cfg Config) (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+
+	doc, err := cfg.document()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(Header + "\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return "", fmt.Errorf("encode cloud-config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("encode cloud-config: %w", err)
+	}
+
+	userData := buf.String()
+	if err := Validate(userData); err != nil {
+		return "", err
+	}
+	return userData, nil
+}
+
+// Validate checks the inputs before anything is rendered
+func (c *Config) Validate() error {
+	if c.AgentID == "" {
+		return fmt.Errorf("%w: agent ID is required", ErrInvalidConfig)
+	}
+	if c.AgentToken == "" {
+		return fmt.Errorf("%w: agent token is required", ErrInvalidConfig)
+	}
+	if err := checkURL("core URL", c.CoreURL, "wss", "ws"); err != nil {
+		return err
+	}
+	if err := checkURL("agent download URL", c.Agent.URL, "https", "http"); err != nil {
+		return err
+	}
+	if !sha256Pattern.MatchString(c.Agent.SHA256) {
+		return fmt.Errorf("%w: agent checksum must be a lowercase hex SHA-256, got %q", ErrInvalidConfig, c.Agent.SHA256)
+	}
+
+	for _, registry := range c.Registries {
+		if registry.Server == "" || strings.ContainsAny(registry.Server, " /") {
+			return fmt.Errorf("%w: invalid registry server %q", ErrInvalidConfig, registry.Server)
+		}
+		if registry.Username == "" || registry.Password == "" {
+			return fmt.Errorf("%w: registry %s needs a username and password", ErrInvalidConfig, registry.Server)
+		}
+	}
+
+	for _, pkg := range c.Packages {
+		if !packagePattern.MatchString(pkg) {
+			return fmt.Errorf("%w: invalid package name %q", ErrInvalidConfig, pkg)
+		}
+	}
+
+	if c.SwapMB < 0 {
+		return fmt.Errorf("%w: swap size must not be negative", ErrInvalidConfig)
+	}
+
+	mountPoints := make(map[string]bool, len(c.Disks))
+	for _, disk := range c.Disks {
+		if !devicePattern.MatchString(disk.Device) {
+			return fmt.Errorf("%w: invalid disk device %q", ErrInvalidConfig, disk.Device)
+		}
+		if !path.IsAbs(disk.MountPoint) || path.Clean(disk.MountPoint) != disk.MountPoint || disk.MountPoint == "/" {
+			return fmt.Errorf("%w: invalid mount point %q for %s", ErrInvalidConfig, disk.MountPoint, disk.Device)
+		}
+		if mountPoints[disk.MountPoint] {
+			return fmt.Errorf("%w: mount point %s is used twice", ErrInvalidConfig, disk.MountPoint)
+		}
+		mountPoints[disk.MountPoint] = true
+		if disk.Filesystem != "" && !filesystems[disk.Filesystem] {
+			return fmt.Errorf("%w: unsupported filesystem %q for %s", ErrInvalidConfig, disk.Filesystem, disk.Device)
+		}
+	}
+
+	return nil
+}
+
+// checkURL checks that raw is an absolute URL with one of the given schemes
+func checkURL(name, raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: %s must be an absolute URL, got %q", ErrInvalidConfig, name, raw)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s must use %s, got %q", ErrInvalidConfig, name, strings.Join(schemes, " or "), u.Scheme)
+}
+
+// document assembles the cloud-config. Modules run in cloud-init's order (disks and swap
+// at init, then files, packages and runcmd), so Docker is installed onto mounted volumes.
+func (c *Config) document() (*document, error) {
+	agentJSON, err := json.MarshalIndent(agentConfig{
+		AgentID:    c.AgentID,
+		Token:      c.AgentToken,
+		CoreURL:    c.CoreURL,
+		StageletID: c.StageletID,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode agent config: %w", err)
+	}
+
+	doc := &document{
+		PackageUpdate: true,
+		Packages:      append(append([]string{}, basePackages...), c.Packages...),
+		WriteFiles: []writeFile{
+			{Path: AgentConfigPath, Permissions: "0600", Owner: "root:root", Content: string(agentJSON) + "\n"},
+			{Path: AgentUnitPath, Permissions: "0644", Owner: "root:root", Content: agentUnit},
+		},
+	}
+
+	if c.SwapMB > 0 {
+		doc.Swap = &swapConfig{Filename: SwapFilePath, Size: int64(c.SwapMB) << 20}
+	}
+
+	for _, disk := range c.Disks {
+		filesystem := disk.Filesystem
+		if filesystem == "" {
+			filesystem = "ext4"
+		}
+		doc.FSSetup = append(doc.FSSetup, fsSetupEntry{
+			Device:     disk.Device,
+			Filesystem: filesystem,
+			Partition:  "none",
+			Overwrite:  false,
+		})
+		doc.Mounts = append(doc.Mounts, []string{disk.Device, disk.MountPoint, filesystem, "defaults,nofail", "0", "2"})
+	}
+
+	if len(c.Registries) > 0 {
+		dockerConfig, err := registryConfig(c.Registries)
+		if err != nil {
+			return nil, err
+		}
+		doc.WriteFiles = append(doc.WriteFiles, writeFile{
+			Path: DockerConfigPath, Permissions: "0600", Owner: "root:root", Content: dockerConfig,
+		})
+	}
+
+	download := AgentBinaryPath + ".download"
+	doc.RunCmd = []string{
+		// A failed download or checksum leaves no agent binary behind
+		fmt.Sprintf("curl -fsSL --retry 5 -o %s %s && echo '%s  %s' | sha256sum -c - && install -m 0755 %s %s; rm -f %s",
+			download, shellQuote(c.Agent.URL), c.Agent.SHA256, download, download, AgentBinaryPath, download),
+		startAgent,
+	}
+
+	return doc, nil
+}
+
+// registryConfig renders a Docker client config.json holding the registry logins
+func registryConfig(registries []RegistryCredential) (string, error) {
+	type auth struct {
+		Auth string `json:"auth"`
+	}
+	auths := make(map[string]auth, len(registries))
+	for _, registry := range registries {
+		auths[registry.Server] = auth{
+			Auth: base64.StdEncoding.EncodeToString([]byte(registry.Username + ":" + registry.Password)),
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"auths": auths}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode docker config: %w", err)
+	}
+	return string(data) + "\n", nil
+}
+
+// Validate checks a rendered document: the header, MaxSize, and that it parses as a
+// cloud-config whose modules have the expected shapes
+func Validate(userData string) error {
+	if len(userData) > MaxSize {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrTooLarge, len(userData), MaxSize)
+	}
+	if !strings.HasPrefix(userData, Header+"\n") {
+		return fmt.Errorf("%w: user data must start with %q", ErrInvalidConfig, Header)
+	}
+
+	var doc document
+	decoder := yaml.NewDecoder(strings.NewReader(userData))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: parse cloud-config: %v", ErrInvalidConfig, err)
+	}
+
+	for _, file := range doc.WriteFiles {
+		if !path.IsAbs(file.Path) {
+			return fmt.Errorf("%w: write_files path %q is not absolute", ErrInvalidConfig, file.Path)
+		}
+	}
+	if len(doc.RunCmd) == 0 {
+		return fmt.Errorf("%w: cloud-config does not start the agent", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,64 @@
+package cloudinit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Script turns user data into a shell script for hosts without cloud-init (containers).
+// Scripts ("#!") are used as-is and empty user data yields an empty script. A cloud-config
+// must be one Render produces (see Validate); it is replayed in cloud-init's module order:
+// files, packages, then runcmd. Swap and disks are left to the host, which containers share.
+func Script(userData string) (string, error) {
+	switch {
+	case strings.TrimSpace(userData) == "":
+		return "", nil
+	case strings.HasPrefix(userData, "#!"):
+		return userData, nil
+	}
+
+	if err := Validate(userData); err != nil {
+		return "", err
+	}
+	var doc document
+	if err := yaml.Unmarshal([]byte(userData), &doc); err != nil {
+		return "", fmt.Errorf("%w: parse cloud-config: %v", ErrInvalidConfig, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+
+	// Content is embedded as base64, so it needs no escaping
+	for _, file := range doc.WriteFiles {
+		path := shellQuote(file.Path)
+		fmt.Fprintf(&b, "mkdir -p \"$(dirname %s)\"\n", path)
+		fmt.Fprintf(&b, "printf '%%s' %s | base64 -d > %s\n", shellQuote(base64.StdEncoding.EncodeToString([]byte(file.Content))), path)
+		if file.Permissions != "" {
+			fmt.Fprintf(&b, "chmod %s %s\n", shellQuote(file.Permissions), path)
+		}
+		if file.Owner != "" {
+			fmt.Fprintf(&b, "chown %s %s\n", shellQuote(file.Owner), path)
+		}
+	}
+
+	if doc.PackageUpdate || len(doc.Packages) > 0 {
+		b.WriteString("apt-get update\n")
+	}
+	if len(doc.Packages) > 0 {
+		quoted := make([]string, len(doc.Packages))
+		for i, pkg := range doc.Packages {
+			quoted[i] = shellQuote(pkg)
+		}
+		b.WriteString("DEBIAN_FRONTEND=noninteractive apt-get install -y " + strings.Join(quoted, " ") + "\n")
+	}
+
+	// Like cloud-init, a failing command does not stop the rest
+	for _, cmd := range doc.RunCmd {
+		b.WriteString(cmd + "\n")
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+package cloudinit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript_Formats(t *testing.T) {
+	script, err := Script("")
+	require.NoError(t, err)
+	assert.Empty(t, script)
+
+	script, err = Script("#!/bin/bash\necho hi\n")
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/bash\necho hi\n", script)
+
+	_, err = Script("#include https://example.com/user-data")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = Script(Header + "\nruncmd: {not: [a list")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	// Only the modules Render emits can be replayed
+	_, err = Script(Header + "\nbootcmd: [echo boot]\nruncmd: [echo hi]\n")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestScript_Render(t *testing.T) {
+	// Given
+	cfg := baseConfig()
+	cfg.Packages = []string{"jq"}
+	cfg.SwapMB = 1024
+	userData, err := Render(cfg)
+	require.NoError(t, err)
+
+	// When
+	script, err := Script(userData)
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(script, "#!/bin/sh\n"))
+	assert.Contains(t, script, "base64 -d > '"+AgentConfigPath+"'\nchmod '0600' '"+AgentConfigPath+"'\nchown 'root:root' '"+AgentConfigPath+"'\n")
+	assert.Contains(t, script, "apt-get install -y 'ca-certificates' 'curl' 'docker.io' 'jq'\n")
+	assert.NotContains(t, script, SwapFilePath)
+
+	// Module order: write_files, packages, runcmd
+	order := []string{AgentUnitPath, "apt-get update", "sha256sum", "systemctl"}
+	last := -1
+	for _, marker := range order {
+		index := strings.Index(script, marker)
+		require.Greater(t, index, last, marker)
+		last = index
+	}
+}
+
+func TestScript_RunsWriteFiles(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 is not installed")
+	}
+
+	// Given
+	dir := t.TempDir()
+	script, err := Script(Header + `
+write_files:
+  - path: ` + dir + `/plain/it's.txt
+    permissions: "0600"
+    content: |
+      line 1
+      $HOME "quoted"
+runcmd:
+  - echo done > ` + dir + `/done
+`)
+	require.NoError(t, err)
+
+	// When
+	output, err := exec.Command("/bin/sh", "-c", script).CombinedOutput()
+
+	// Then
+	require.NoError(t, err, string(output))
+	data, err := os.ReadFile(filepath.Join(dir, "plain", "it's.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\n$HOME \"quoted\"\n", string(data))
+	info, err := os.Stat(filepath.Join(dir, "plain", "it's.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err = os.ReadFile(filepath.Join(dir, "done"))
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(data))
+}
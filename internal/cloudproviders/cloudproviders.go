@@ -0,0 +1,259 @@
+// Package cloudproviders builds team-owned CloudProviders from encrypted cloud_providers rows
+package cloudproviders
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stagely-dev/stagely/internal/providers"
+	"gorm.io/gorm"
+)
+
+// Errors returned when a cloud_providers row cannot be used
+var (
+	ErrNotFound  = errors.New("cloud provider not found")
+	ErrInactive  = errors.New("cloud provider is inactive")
+	ErrUnhealthy = errors.New("cloud provider is unhealthy")
+)
+
+// Record is a row of the cloud_providers table
+type Record struct {
+	ID                   string
+	TeamID               string
+	Name                 string
+	ProviderType         string // "aws", "digitalocean", "hetzner", ...
+	EncryptedCredentials string // Credential JSON encrypted with the team data key, bound to TeamID and ID
+	Region               string // Default region (empty if unset)
+	Config               string // Provider-specific JSON settings
+	IsActive             bool
+	LastValidatedAt      *time.Time
+	ValidationError      *string
+	CredentialsValid     *bool // Result of the last conclusive validation (nil if never validated)
+	ValidationFailures   int   // Consecutive failed validations
+	IsHealthy            bool  // False once the validator's failure threshold is reached
+	UpdatedAt            time.Time
+}
+
+// Store reads and updates cloud_providers rows
+type Store interface {
+	// Get returns the row with the given ID owned by teamID (ErrNotFound if none)
+	Get(ctx context.Context, teamID, providerID string) (Record, error)
+
+	// UpdateCredentials replaces the encrypted credentials of a row (ErrNotFound if none)
+	UpdateCredentials(ctx context.Context, teamID, providerID, encryptedCredentials string) error
+}
+
+// DBStore reads cloud_providers rows from PostgreSQL
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a store backed by the given database
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Get returns the row with the given ID owned by teamID
+func (s *DBStore) Get(ctx context.Context, teamID, providerID string) (Record, error) {
+	var records []Record
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT id, team_id, name, provider_type, encrypted_credentials,
+			COALESCE(region, '') AS region, COALESCE(config, '{}') AS config,
+			COALESCE(is_active, true) AS is_active, last_validated_at, validation_error,
+			credentials_valid, validation_failures, is_healthy, updated_at
+		FROM cloud_providers
+		WHERE id = ? AND team_id = ?
+	`, providerID, teamID).Scan(&records).Error
+	if err != nil {
+		return Record{}, fmt.Errorf("query cloud provider: %w", err)
+	}
+	if len(records) == 0 {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, providerID)
+	}
+	return records[0], nil
+}
+
+// UpdateCredentials replaces the encrypted credentials of a row
+func (s *DBStore) UpdateCredentials(ctx context.Context, teamID, providerID, encryptedCredentials string) error {
+	result := s.db.WithContext(ctx).Exec(`
+		UPDATE cloud_providers
+		SET encrypted_credentials = ?, last_validated_at = NULL, validation_error = NULL,
+			credentials_valid = NULL, validation_failures = 0, is_healthy = true
+		WHERE id = ? AND team_id = ?
+	`, encryptedCredentials, providerID, teamID)
+	if result.Error != nil {
+		return fmt.Errorf("update cloud provider credentials: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, providerID)
+	}
+	return nil
+}
+
+// AssociatedData returns the data a row's encrypted credentials are bound to
+func AssociatedData(teamID, providerID string) []byte {
+	return aead.AssociatedData("cloud_providers", teamID, providerID)
+}
+
+// Registry builds and caches providers per team and cloud_providers row.
+// Every Get re-reads the row, so a cached provider is rebuilt as soon as its credentials,
+// region or config change, including changes made by another Core instance.
+type Registry struct {
+	store      Store
+	factory    *providers.Factory
+	envelope   *crypto.Envelope
+	decorators []providers.Decorator
+	cache      map[cacheKey]cacheEntry
+	mu         sync.Mutex
+}
+
+type cacheKey struct {
+	teamID     string
+	providerID string
+	region     string // Region override ("" for the row's own region)
+}
+
+type cacheEntry struct {
+	provider    providers.CloudProvider
+	fingerprint [sha256.Size]byte
+}
+
+// NewRegistry creates a registry that encrypts and decrypts credentials with the team data keys
+// of envelope (credentials written before data keys existed are read with its master keyring).
+// Decorators (e.g., providers.Resilience) are applied in order to every provider it builds.
+func NewRegistry(store Store, factory *providers.Factory, envelope *crypto.Envelope, decorators ...providers.Decorator) *Registry {
+	return &Registry{
+		store:      store,
+		factory:    factory,
+		envelope:   envelope,
+		decorators: decorators,
+		cache:      make(map[cacheKey]cacheEntry),
+	}
+}
+
+// Get returns the provider for a team's cloud_providers row, building it if needed
+func (r *Registry) Get(ctx context.Context, teamID, providerID string) (providers.CloudProvider, error) {
+	return r.GetForRegion(ctx, teamID, providerID, "")
+}
+
+// GetForRegion returns the provider for a team's cloud_providers row bound to region
+// instead of the row's default region (needed for region-bound providers such as AWS)
+func (r *Registry) GetForRegion(ctx context.Context, teamID, providerID, region string) (providers.CloudProvider, error) {
+	record, err := r.store.Get(ctx, teamID, providerID)
+	if err != nil {
+		return nil, err
+	}
+	if !record.IsActive {
+		return nil, fmt.Errorf("%w: %s", ErrInactive, providerID)
+	}
+	// The validator keeps checking unhealthy rows, so the row is usable again once it passes
+	if !record.IsHealthy {
+		return nil, fmt.Errorf("%w: %s", ErrUnhealthy, providerID)
+	}
+	return r.forRecord(ctx, record, region)
+}
+
+// forRecord returns the cached provider for a row, rebuilding it if the row changed
+func (r *Registry) forRecord(ctx context.Context, record Record, region string) (providers.CloudProvider, error) {
+	key := cacheKey{teamID: record.TeamID, providerID: record.ID, region: region}
+	if region != "" {
+		record.Region = region
+	}
+	fp := fingerprint(record)
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && entry.fingerprint == fp {
+		return entry.provider, nil
+	}
+
+	provider, err := r.Build(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another caller may have built the same version concurrently; keep a single instance
+	// so decorator state (rate limits, circuit breaker) is shared
+	if entry, ok := r.cache[key]; ok && entry.fingerprint == fp {
+		return entry.provider, nil
+	}
+	r.cache[key] = cacheEntry{provider: provider, fingerprint: fp}
+	return provider, nil
+}
+
+// Build decrypts a row's credentials and creates its provider without caching it
+func (r *Registry) Build(ctx context.Context, record Record) (providers.CloudProvider, error) {
+	plaintext, err := r.envelope.Decrypt(ctx, record.TeamID, record.EncryptedCredentials, AssociatedData(record.TeamID, record.ID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credentials for cloud provider %s: %w", record.ID, err)
+	}
+
+	creds, err := providers.ParseCredentials(record.ProviderType, []byte(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("cloud provider %s: %w", record.ID, err)
+	}
+
+	provider, err := r.factory.Build(record.ProviderType, creds, record.Region, json.RawMessage(record.Config))
+	if err != nil {
+		return nil, fmt.Errorf("build cloud provider %s: %w", record.ID, err)
+	}
+
+	for _, decorate := range r.decorators {
+		provider = decorate(provider)
+	}
+	return provider, nil
+}
+
+// UpdateCredentials encrypts and stores new credentials for a row and drops its cached provider
+func (r *Registry) UpdateCredentials(ctx context.Context, teamID, providerID string, creds providers.Credentials) error {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("encode credentials: %w", err)
+	}
+
+	encrypted, err := r.envelope.Encrypt(ctx, teamID, string(plaintext), AssociatedData(teamID, providerID))
+	if err != nil {
+		return fmt.Errorf("encrypt credentials: %w", err)
+	}
+
+	if err := r.store.UpdateCredentials(ctx, teamID, providerID, encrypted); err != nil {
+		return err
+	}
+
+	r.Invalidate(teamID, providerID)
+	return nil
+}
+
+// Invalidate drops the cached providers for a row, in every region (idempotent)
+func (r *Registry) Invalidate(teamID, providerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.cache {
+		if key.teamID == teamID && key.providerID == providerID {
+			delete(r.cache, key)
+		}
+	}
+}
+
+// fingerprint identifies the version of a row's provider-building inputs
+func fingerprint(record Record) [sha256.Size]byte {
+	h := sha256.New()
+	for _, field := range []string{record.ProviderType, record.EncryptedCredentials, record.Region, record.Config} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,389 @@
+package cloudproviders_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/cloudproviders"
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store keyed by provider ID
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]cloudproviders.Record
+	audits  []cloudproviders.Validation
+}
+
+func (s *memoryStore) Get(ctx context.Context, teamID, providerID string) (cloudproviders.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[providerID]
+	if !ok || record.TeamID != teamID {
+		return cloudproviders.Record{}, cloudproviders.ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *memoryStore) UpdateCredentials(ctx context.Context, teamID, providerID, encryptedCredentials string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[providerID]
+	if !ok || record.TeamID != teamID {
+		return cloudproviders.ErrNotFound
+	}
+	record.EncryptedCredentials = encryptedCredentials
+	s.records[providerID] = record
+	return nil
+}
+
+// setup returns a registry whose "mock" provider type records the credentials it was built with
+func setup(t *testing.T) (*cloudproviders.Registry, *memoryStore, *crypto.Keyring, *[]providers.Credentials) {
+	t.Helper()
+
+	key := newKeyring(t)
+
+	var built []providers.Credentials
+	factory := providers.NewFactory()
+	require.NoError(t, factory.Register("mock", func(creds providers.Credentials, region string, config json.RawMessage) (providers.CloudProvider, error) {
+		built = append(built, creds)
+		return providers.NewMockProvider(), nil
+	}))
+
+	store := &memoryStore{records: make(map[string]cloudproviders.Record)}
+	return cloudproviders.NewRegistry(store, factory, newEnvelope(key)), store, key, &built
+}
+
+// memoryDataKeys is an in-memory crypto.DataKeyStore
+type memoryDataKeys map[string]string
+
+func (s memoryDataKeys) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	wrapped, ok := s[teamID]
+	if !ok {
+		return "", crypto.ErrNoDataKey
+	}
+	return wrapped, nil
+}
+
+func (s memoryDataKeys) CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error) {
+	if existing, ok := s[teamID]; ok {
+		return existing, nil
+	}
+	s[teamID] = wrappedKey
+	return wrappedKey, nil
+}
+
+func (s memoryDataKeys) DeleteDataKey(ctx context.Context, teamID string) error {
+	delete(s, teamID)
+	return nil
+}
+
+// newEnvelope returns an envelope whose data keys are wrapped by keyring, which also
+// decrypts credentials written before data keys
+func newEnvelope(keyring *crypto.Keyring) *crypto.Envelope {
+	return crypto.NewEnvelope(keyring, keyring, memoryDataKeys{}, 0)
+}
+
+// newKeyring returns a keyring with a single random key
+func newKeyring(t *testing.T) *crypto.Keyring {
+	t.Helper()
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": key})
+	require.NoError(t, err)
+	return keyring
+}
+
+func encrypt(t *testing.T, plaintext string, keyring *crypto.Keyring) string {
+	t.Helper()
+	ciphertext, err := keyring.Encrypt(plaintext)
+	require.NoError(t, err)
+	return ciphertext
+}
+
+func TestRegistry_Get_BuildsAndCaches(t *testing.T) {
+	// Given
+	registry, store, key, built := setup(t)
+	store.records["cp-1"] = cloudproviders.Record{
+		ID:                   "cp-1",
+		TeamID:               "team-a",
+		ProviderType:         "mock",
+		EncryptedCredentials: encrypt(t, `{"api_token": "token-1"}`, key),
+		IsActive:             true,
+		IsHealthy:            true,
+	}
+	ctx := context.Background()
+
+	// When
+	first, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+	second, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// Then
+	assert.Same(t, first, second)
+	require.Len(t, *built, 1)
+	assert.Equal(t, "token-1", (*built)[0].APIToken)
+}
+
+func TestRegistry_Get_RebuildsWhenCredentialsChange(t *testing.T) {
+	// Given
+	registry, store, key, built := setup(t)
+	store.records["cp-1"] = cloudproviders.Record{
+		ID:                   "cp-1",
+		TeamID:               "team-a",
+		ProviderType:         "mock",
+		EncryptedCredentials: encrypt(t, `{"api_token": "token-1"}`, key),
+		IsActive:             true,
+		IsHealthy:            true,
+	}
+	ctx := context.Background()
+	first, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// When - credentials rotated behind the registry's back (e.g., by another Core instance)
+	record := store.records["cp-1"]
+	record.EncryptedCredentials = encrypt(t, `{"api_token": "token-2"}`, key)
+	store.records["cp-1"] = record
+
+	second, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// Then
+	assert.NotSame(t, first, second)
+	require.Len(t, *built, 2)
+	assert.Equal(t, "token-2", (*built)[1].APIToken)
+}
+
+func TestRegistry_UpdateCredentials(t *testing.T) {
+	// Given
+	registry, store, key, built := setup(t)
+	store.records["cp-1"] = cloudproviders.Record{
+		ID:                   "cp-1",
+		TeamID:               "team-a",
+		ProviderType:         "mock",
+		EncryptedCredentials: encrypt(t, `{"api_token": "token-1"}`, key),
+		IsActive:             true,
+		IsHealthy:            true,
+	}
+	ctx := context.Background()
+	_, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// When
+	err = registry.UpdateCredentials(ctx, "team-a", "cp-1", providers.Credentials{APIToken: "token-2"})
+	require.NoError(t, err)
+	_, err = registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// Then - stored with the team data key and bound to the row, and the provider was rebuilt with the new token
+	assert.True(t, crypto.IsDataKeyCiphertext(store.records["cp-1"].EncryptedCredentials))
+	assert.True(t, crypto.IsBound(store.records["cp-1"].EncryptedCredentials))
+	require.Len(t, *built, 2)
+	assert.Equal(t, "token-2", (*built)[1].APIToken)
+
+	assert.ErrorIs(t, registry.UpdateCredentials(ctx, "team-b", "cp-1", providers.Credentials{}), cloudproviders.ErrNotFound)
+}
+
+func TestRegistry_Get_Errors(t *testing.T) {
+	// Given
+	registry, store, key, _ := setup(t)
+	otherKey := newKeyring(t)
+
+	store.records["inactive"] = cloudproviders.Record{
+		ID: "inactive", TeamID: "team-a", ProviderType: "mock",
+		EncryptedCredentials: encrypt(t, `{}`, key),
+	}
+	store.records["wrong-key"] = cloudproviders.Record{
+		ID: "wrong-key", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true,
+		EncryptedCredentials: encrypt(t, `{}`, otherKey),
+	}
+	store.records["gcp"] = cloudproviders.Record{
+		ID: "gcp", TeamID: "team-a", ProviderType: "gcp", IsActive: true, IsHealthy: true,
+		EncryptedCredentials: encrypt(t, `{}`, key),
+	}
+	store.records["unhealthy"] = cloudproviders.Record{
+		ID: "unhealthy", TeamID: "team-a", ProviderType: "mock", IsActive: true,
+		EncryptedCredentials: encrypt(t, `{}`, key),
+	}
+	ctx := context.Background()
+
+	// When / Then
+	_, err := registry.Get(ctx, "team-a", "missing")
+	assert.ErrorIs(t, err, cloudproviders.ErrNotFound)
+
+	_, err = registry.Get(ctx, "team-b", "inactive")
+	assert.ErrorIs(t, err, cloudproviders.ErrNotFound, "rows of other teams must not be visible")
+
+	_, err = registry.Get(ctx, "team-a", "inactive")
+	assert.ErrorIs(t, err, cloudproviders.ErrInactive)
+
+	_, err = registry.Get(ctx, "team-a", "unhealthy")
+	assert.ErrorIs(t, err, cloudproviders.ErrUnhealthy)
+
+	_, err = registry.Get(ctx, "team-a", "wrong-key")
+	assert.ErrorContains(t, err, "decrypt credentials")
+
+	_, err = registry.Get(ctx, "team-a", "gcp")
+	assert.ErrorIs(t, err, providers.ErrNotSupported)
+}
+
+func TestRegistry_SwappedCredentials(t *testing.T) {
+	// Given - a row holds credentials copied from another row of the same team, so the
+	// team data key decrypts them but the associated data does not match
+	registry, store, _, built := setup(t)
+	ctx := context.Background()
+	store.records["cp-a"] = cloudproviders.Record{ID: "cp-a", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true}
+	store.records["cp-b"] = cloudproviders.Record{ID: "cp-b", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true}
+	require.NoError(t, registry.UpdateCredentials(ctx, "team-a", "cp-a", providers.Credentials{APIToken: "token-a"}))
+
+	swapped := store.records["cp-b"]
+	swapped.EncryptedCredentials = store.records["cp-a"].EncryptedCredentials
+	store.records["cp-b"] = swapped
+
+	// When
+	_, err := registry.Get(ctx, "team-a", "cp-b")
+
+	// Then
+	assert.ErrorContains(t, err, "authentication failed")
+	assert.Empty(t, *built)
+
+	// It still decrypts in its own row
+	_, err = registry.Get(ctx, "team-a", "cp-a")
+	require.NoError(t, err)
+}
+
+func TestRegistry_Decorators(t *testing.T) {
+	// Given
+	key := newKeyring(t)
+	factory := providers.NewFactory()
+	require.NoError(t, factory.Register("mock", func(providers.Credentials, string, json.RawMessage) (providers.CloudProvider, error) {
+		return providers.NewMockProvider(), nil
+	}))
+	store := &memoryStore{records: map[string]cloudproviders.Record{
+		"cp-1": {ID: "cp-1", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true, EncryptedCredentials: encrypt(t, `{}`, key)},
+	}}
+
+	var decorated int
+	registry := cloudproviders.NewRegistry(store, factory, newEnvelope(key), func(p providers.CloudProvider) providers.CloudProvider {
+		decorated++
+		return p
+	})
+
+	// When
+	_, err := registry.Get(context.Background(), "team-a", "cp-1")
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, 1, decorated)
+}
+
+func TestRegistry_GetForRegion(t *testing.T) {
+	// Given
+	key := newKeyring(t)
+
+	var regions []string
+	factory := providers.NewFactory()
+	require.NoError(t, factory.Register("mock", func(creds providers.Credentials, region string, config json.RawMessage) (providers.CloudProvider, error) {
+		regions = append(regions, region)
+		return providers.NewMockProvider(), nil
+	}))
+	store := &memoryStore{records: map[string]cloudproviders.Record{
+		"cp-1": {ID: "cp-1", TeamID: "team-a", ProviderType: "mock", Region: "us-east-1", IsActive: true, IsHealthy: true, EncryptedCredentials: encrypt(t, `{}`, key)},
+	}}
+	registry := cloudproviders.NewRegistry(store, factory, newEnvelope(key))
+	ctx := context.Background()
+
+	// When
+	defaultRegion, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+	west, err := registry.GetForRegion(ctx, "team-a", "cp-1", "us-west-2")
+	require.NoError(t, err)
+	westAgain, err := registry.GetForRegion(ctx, "team-a", "cp-1", "us-west-2")
+	require.NoError(t, err)
+
+	// Then - one provider per region, each cached
+	assert.NotSame(t, defaultRegion, west)
+	assert.Same(t, west, westAgain)
+	assert.Equal(t, []string{"us-east-1", "us-west-2"}, regions)
+}
+
+func TestRegistry_InvalidateAllRegions(t *testing.T) {
+	// Given - a row cached for its own region and for another one
+	registry, store, key, built := setup(t)
+	store.records["cp-1"] = cloudproviders.Record{
+		ID:                   "cp-1",
+		TeamID:               "team-a",
+		ProviderType:         "mock",
+		Region:               "us-east-1",
+		EncryptedCredentials: encrypt(t, `{"api_token": "token-1"}`, key),
+		IsActive:             true,
+		IsHealthy:            true,
+	}
+	ctx := context.Background()
+	defaultRegion, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+	west, err := registry.GetForRegion(ctx, "team-a", "cp-1", "us-west-2")
+	require.NoError(t, err)
+
+	// When
+	registry.Invalidate("team-a", "cp-1")
+
+	// Then - both are rebuilt
+	defaultAgain, err := registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+	westAgain, err := registry.GetForRegion(ctx, "team-a", "cp-1", "us-west-2")
+	require.NoError(t, err)
+	assert.NotSame(t, defaultRegion, defaultAgain)
+	assert.NotSame(t, west, westAgain)
+	assert.Len(t, *built, 4)
+}
+
+func TestRegistry_RetiredKey(t *testing.T) {
+	// Given - credentials written before k1 was rotated out, one of them before key IDs existed
+	k1, err := aead.GenerateKey()
+	require.NoError(t, err)
+	k2, err := aead.GenerateKey()
+	require.NoError(t, err)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	keyring, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+
+	legacy, err := aead.Encrypt(`{"api_token": "token-0"}`, k1)
+	require.NoError(t, err)
+	store := &memoryStore{records: map[string]cloudproviders.Record{
+		"cp-1": {ID: "cp-1", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true, EncryptedCredentials: encrypt(t, `{"api_token": "token-1"}`, before)},
+		"cp-0": {ID: "cp-0", TeamID: "team-a", ProviderType: "mock", IsActive: true, IsHealthy: true, EncryptedCredentials: legacy},
+	}}
+
+	var built []providers.Credentials
+	factory := providers.NewFactory()
+	require.NoError(t, factory.Register("mock", func(creds providers.Credentials, region string, config json.RawMessage) (providers.CloudProvider, error) {
+		built = append(built, creds)
+		return providers.NewMockProvider(), nil
+	}))
+	registry := cloudproviders.NewRegistry(store, factory, newEnvelope(keyring))
+	ctx := context.Background()
+
+	// When
+	_, err = registry.Get(ctx, "team-a", "cp-1")
+	require.NoError(t, err)
+	_, err = registry.Get(ctx, "team-a", "cp-0")
+	require.NoError(t, err)
+	require.NoError(t, registry.UpdateCredentials(ctx, "team-a", "cp-1", providers.Credentials{APIToken: "token-2"}))
+
+	// Then - old values still decrypt, new ones use the team data key
+	require.Len(t, built, 2)
+	assert.Equal(t, "token-1", built[0].APIToken)
+	assert.Equal(t, "token-0", built[1].APIToken)
+	assert.True(t, crypto.IsDataKeyCiphertext(store.records["cp-1"].EncryptedCredentials))
+}
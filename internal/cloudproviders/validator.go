@@ -0,0 +1,240 @@
+package cloudproviders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"gorm.io/gorm"
+)
+
+// DefaultFailureThreshold is how many consecutive failed validations mark a provider unhealthy
+const DefaultFailureThreshold = 3
+
+// validationTimeout bounds a single ValidateCredentials call
+const validationTimeout = 30 * time.Second
+
+// AuditActionCredentialsInvalid is the audit_logs action written when credentials stop working
+const AuditActionCredentialsInvalid = "cloud_provider.credentials_invalid"
+
+// Validation is the outcome of validating one cloud_providers row
+type Validation struct {
+	Record           Record    // Row as it was before validation
+	ValidatedAt      time.Time // When the validation ran
+	Error            string    // Validation error ("" if the credentials are valid)
+	CredentialsValid *bool     // New credentials_valid value (nil leaves it unchanged, e.g. on network errors)
+	Failures         int       // New consecutive failure count
+	Healthy          bool      // Whether the provider is still usable
+	BecameInvalid    bool      // Credentials went from valid to invalid (an audit entry is written)
+}
+
+// ValidationStore lists providers to validate and persists the results
+type ValidationStore interface {
+	// ListActive returns every active cloud_providers row
+	ListActive(ctx context.Context) ([]Record, error)
+
+	// SaveValidation records a validation result (and its audit entry, if any).
+	// The result is discarded if the row's credentials changed since it was read.
+	SaveValidation(ctx context.Context, validation Validation) error
+}
+
+// ListActive returns every active cloud_providers row
+func (s *DBStore) ListActive(ctx context.Context) ([]Record, error) {
+	var records []Record
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT id, team_id, name, provider_type, encrypted_credentials,
+			COALESCE(region, '') AS region, COALESCE(config, '{}') AS config,
+			COALESCE(is_active, true) AS is_active, last_validated_at, validation_error,
+			credentials_valid, validation_failures, is_healthy, updated_at
+		FROM cloud_providers
+		WHERE is_active IS NOT FALSE
+		ORDER BY team_id, id
+	`).Scan(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("query active cloud providers: %w", err)
+	}
+	return records, nil
+}
+
+// SaveValidation records a validation result and, when credentials became invalid,
+// an audit_logs entry in the same transaction.
+// A result for credentials that were replaced while validating is discarded, so it cannot
+// mark the new credentials invalid (UpdateCredentials already reset the validation state).
+func (s *DBStore) SaveValidation(ctx context.Context, validation Validation) error {
+	var validationError *string
+	if validation.Error != "" {
+		validationError = &validation.Error
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`
+			UPDATE cloud_providers
+			SET last_validated_at = ?, validation_error = ?,
+				credentials_valid = COALESCE(?, credentials_valid),
+				validation_failures = ?, is_healthy = ?
+			WHERE id = ? AND encrypted_credentials = ?
+		`, validation.ValidatedAt, validationError, validation.CredentialsValid,
+			validation.Failures, validation.Healthy, validation.Record.ID, validation.Record.EncryptedCredentials)
+		if result.Error != nil {
+			return fmt.Errorf("update cloud provider validation: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 || !validation.BecameInvalid {
+			return nil
+		}
+
+		metadata, err := json.Marshal(map[string]string{
+			"name":          validation.Record.Name,
+			"provider_type": validation.Record.ProviderType,
+			"error":         validation.Error,
+		})
+		if err != nil {
+			return fmt.Errorf("encode audit metadata: %w", err)
+		}
+
+		err = tx.Exec(`
+			INSERT INTO audit_logs (action, resource_type, resource_id, team_id, metadata, timestamp)
+			VALUES (?, 'cloud_provider', ?, ?, ?, ?)
+		`, AuditActionCredentialsInvalid, validation.Record.ID, validation.Record.TeamID,
+			string(metadata), validation.ValidatedAt).Error
+		if err != nil {
+			return fmt.Errorf("insert audit log: %w", err)
+		}
+		return nil
+	})
+}
+
+// ValidationResult summarizes a single validation pass
+type ValidationResult struct {
+	Valid         []string // Providers whose credentials were accepted
+	Failed        []string // Providers whose validation failed
+	BecameInvalid []string // Providers whose credentials went from valid to invalid
+	Unhealthy     []string // Providers at or above the failure threshold
+}
+
+// Validator periodically checks the credentials of every active cloud provider
+type Validator struct {
+	registry  *Registry
+	store     ValidationStore
+	threshold int
+	now       func() time.Time
+}
+
+// NewValidator creates a validator that builds providers through registry
+// A non-positive failureThreshold falls back to DefaultFailureThreshold
+func NewValidator(registry *Registry, store ValidationStore, failureThreshold int) *Validator {
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+	return &Validator{
+		registry:  registry,
+		store:     store,
+		threshold: failureThreshold,
+		now:       time.Now,
+	}
+}
+
+// ValidateAll validates every active provider and records the results.
+// Failures of individual providers are reported in the result, not as an error.
+func (v *Validator) ValidateAll(ctx context.Context) (ValidationResult, error) {
+	var result ValidationResult
+
+	records, err := v.store.ListActive(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, record := range records {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		validation := v.validate(ctx, record)
+		if err := v.store.SaveValidation(ctx, validation); err != nil {
+			log.Printf("validator: failed to save validation for cloud provider %s: %v", record.ID, err)
+		}
+
+		if validation.Error == "" {
+			result.Valid = append(result.Valid, record.ID)
+		} else {
+			result.Failed = append(result.Failed, record.ID)
+		}
+		if validation.BecameInvalid {
+			log.Printf("validator: credentials of cloud provider %s (%s) are no longer valid: %s", record.ID, record.ProviderType, validation.Error)
+			result.BecameInvalid = append(result.BecameInvalid, record.ID)
+		}
+		if !validation.Healthy {
+			result.Unhealthy = append(result.Unhealthy, record.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// validate checks one row's credentials and computes its new validation state
+func (v *Validator) validate(ctx context.Context, record Record) Validation {
+	err := v.check(ctx, record)
+	validation := Validation{Record: record, ValidatedAt: v.now()}
+
+	if err == nil {
+		valid := true
+		validation.CredentialsValid = &valid
+		validation.Healthy = true
+		return validation
+	}
+
+	validation.Error = err.Error()
+	validation.Failures = record.ValidationFailures + 1
+	validation.Healthy = validation.Failures < v.threshold
+
+	// Transient failures say nothing about the credentials themselves
+	if !isTransient(err) {
+		invalid := false
+		validation.CredentialsValid = &invalid
+		validation.BecameInvalid = record.CredentialsValid != nil && *record.CredentialsValid
+	}
+	return validation
+}
+
+// check builds the row's provider and calls ValidateCredentials
+func (v *Validator) check(ctx context.Context, record Record) error {
+	provider, err := v.registry.forRecord(ctx, record, "")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+	return provider.ValidateCredentials(ctx)
+}
+
+// isTransient reports whether a validation error is likely to clear up on its own
+func isTransient(err error) bool {
+	return providers.IsRetryable(err) ||
+		errors.Is(err, providers.ErrNetworkFailure) ||
+		errors.Is(err, providers.ErrRateLimited) ||
+		errors.Is(err, providers.ErrCircuitOpen) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// Run validates on every tick until the context is cancelled
+func (v *Validator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := v.ValidateAll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("validator: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
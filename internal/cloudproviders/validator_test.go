@@ -0,0 +1,203 @@
+package cloudproviders_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/cloudproviders"
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *memoryStore) ListActive(ctx context.Context) ([]cloudproviders.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []cloudproviders.Record
+	for _, record := range s.records {
+		if record.IsActive {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *memoryStore) SaveValidation(ctx context.Context, validation cloudproviders.Validation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[validation.Record.ID]
+	if record.EncryptedCredentials != validation.Record.EncryptedCredentials {
+		return nil
+	}
+	record.LastValidatedAt = &validation.ValidatedAt
+	record.ValidationError = nil
+	if validation.Error != "" {
+		record.ValidationError = &validation.Error
+	}
+	if validation.CredentialsValid != nil {
+		record.CredentialsValid = validation.CredentialsValid
+	}
+	record.ValidationFailures = validation.Failures
+	record.IsHealthy = validation.Healthy
+	s.records[record.ID] = record
+
+	if validation.BecameInvalid {
+		s.audits = append(s.audits, validation)
+	}
+	return nil
+}
+
+// setupValidator returns a validator over a single active row backed by provider
+func setupValidator(t *testing.T, provider providers.CloudProvider) (*cloudproviders.Validator, *memoryStore) {
+	t.Helper()
+
+	key := newKeyring(t)
+
+	factory := providers.NewFactory()
+	require.NoError(t, factory.Register("mock", func(providers.Credentials, string, json.RawMessage) (providers.CloudProvider, error) {
+		return provider, nil
+	}))
+
+	store := &memoryStore{records: map[string]cloudproviders.Record{
+		"cp-1": {
+			ID: "cp-1", TeamID: "team-a", Name: "prod", ProviderType: "mock", IsActive: true, IsHealthy: true,
+			EncryptedCredentials: encrypt(t, `{"api_token": "token"}`, key),
+		},
+		"cp-off": {ID: "cp-off", TeamID: "team-a", ProviderType: "mock"},
+	}}
+
+	registry := cloudproviders.NewRegistry(store, factory, newEnvelope(key))
+	return cloudproviders.NewValidator(registry, store, 2), store
+}
+
+func TestValidator_RecordsSuccess(t *testing.T) {
+	// Given
+	validator, store := setupValidator(t, providers.NewMockProvider())
+
+	// When
+	result, err := validator.ValidateAll(context.Background())
+
+	// Then - inactive rows are skipped
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cp-1"}, result.Valid)
+	assert.Empty(t, result.Failed)
+
+	record := store.records["cp-1"]
+	require.NotNil(t, record.LastValidatedAt)
+	assert.Nil(t, record.ValidationError)
+	require.NotNil(t, record.CredentialsValid)
+	assert.True(t, *record.CredentialsValid)
+	assert.Nil(t, store.records["cp-off"].LastValidatedAt)
+}
+
+func TestValidator_AuditsWhenCredentialsBecomeInvalid(t *testing.T) {
+	// Given - valid on the first pass, revoked afterwards
+	provider := providers.NewMockProvider()
+	provider.FailNth(providers.MockOpValidateCredentials, 2, providers.ErrInvalidCredentials)
+	provider.FailNth(providers.MockOpValidateCredentials, 3, providers.ErrInvalidCredentials)
+	validator, store := setupValidator(t, provider)
+	ctx := context.Background()
+
+	_, err := validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// When
+	result, err := validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// Then - one audit entry, still healthy below the threshold
+	assert.Equal(t, []string{"cp-1"}, result.BecameInvalid)
+	assert.Empty(t, result.Unhealthy)
+	require.Len(t, store.audits, 1)
+	assert.Contains(t, store.audits[0].Error, "invalid or expired credentials")
+
+	record := store.records["cp-1"]
+	require.NotNil(t, record.ValidationError)
+	assert.False(t, *record.CredentialsValid)
+	assert.Equal(t, 1, record.ValidationFailures)
+
+	// When - failing again
+	result, err = validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// Then - no second audit entry, but the provider is now unhealthy
+	assert.Empty(t, result.BecameInvalid)
+	assert.Equal(t, []string{"cp-1"}, result.Unhealthy)
+	assert.Len(t, store.audits, 1)
+	assert.False(t, store.records["cp-1"].IsHealthy)
+
+	// When - recovered
+	_, err = validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// Then
+	record = store.records["cp-1"]
+	assert.True(t, record.IsHealthy)
+	assert.Zero(t, record.ValidationFailures)
+	assert.Nil(t, record.ValidationError)
+}
+
+func TestValidator_TransientFailuresDoNotInvalidateCredentials(t *testing.T) {
+	// Given
+	provider := providers.NewMockProvider()
+	provider.FailNth(providers.MockOpValidateCredentials, 2, providers.ErrNetworkFailure)
+	provider.FailNth(providers.MockOpValidateCredentials, 3, providers.ErrNetworkFailure)
+	validator, store := setupValidator(t, provider)
+	ctx := context.Background()
+
+	_, err := validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// When
+	_, err = validator.ValidateAll(ctx)
+	require.NoError(t, err)
+	result, err := validator.ValidateAll(ctx)
+	require.NoError(t, err)
+
+	// Then - unhealthy after repeated failures, but credentials are not reported invalid
+	assert.Equal(t, []string{"cp-1"}, result.Unhealthy)
+	assert.Empty(t, store.audits)
+	record := store.records["cp-1"]
+	assert.True(t, *record.CredentialsValid)
+	assert.Equal(t, 2, record.ValidationFailures)
+}
+
+// rotatingProvider replaces the row's credentials while they are being validated, then rejects them
+type rotatingProvider struct {
+	*providers.MockProvider
+	rotate func()
+}
+
+func (p *rotatingProvider) ValidateCredentials(ctx context.Context) error {
+	p.rotate()
+	return providers.ErrInvalidCredentials
+}
+
+func TestValidator_DiscardsResultForReplacedCredentials(t *testing.T) {
+	// Given - the credentials are replaced while the old ones are being rejected
+	provider := &rotatingProvider{MockProvider: providers.NewMockProvider()}
+	validator, store := setupValidator(t, provider)
+	valid := true
+	record := store.records["cp-1"]
+	record.CredentialsValid = &valid
+	store.records["cp-1"] = record
+	provider.rotate = func() {
+		record := store.records["cp-1"]
+		record.EncryptedCredentials = "replaced"
+		store.records["cp-1"] = record
+	}
+
+	// When
+	_, err := validator.ValidateAll(context.Background())
+
+	// Then - the new credentials are neither marked invalid nor audited
+	require.NoError(t, err)
+	record = store.records["cp-1"]
+	assert.True(t, *record.CredentialsValid)
+	assert.Zero(t, record.ValidationFailures)
+	assert.Nil(t, record.LastValidatedAt)
+	assert.Empty(t, store.audits)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -12,6 +14,8 @@ type Config struct {
 	Redis    RedisConfig
 	Server   ServerConfig
 	Security SecurityConfig
+	Edge     EdgeConfig
+	Docker   DockerProviderConfig
 }
 
 // DatabaseConfig holds database connection settings
@@ -33,8 +37,53 @@ type ServerConfig struct {
 
 // SecurityConfig holds security-related settings
 type SecurityConfig struct {
-	JWTSecret     string
-	EncryptionKey string
+	JWTSecret             string
+	EncryptionKey         string   // Active master key (hex)
+	EncryptionKeyID       string   // ID stored in ciphertexts; change it whenever the key changes
+	RetiredEncryptionKeys []string // "id:hexkey" entries that still decrypt until re-encryption is done
+	// EncryptionStrict rejects encrypted values not bound to their row. Enable it once the
+	// re-encryption job reports nothing stale.
+	EncryptionStrict bool
+	KMS              KMSConfig
+}
+
+// KMS backends that wrap the team data keys
+const (
+	KMSBackendEnv    = "env"    // keyring from ENCRYPTION_KEY
+	KMSBackendFile   = "file"   // keyring from a local keystore file
+	KMSBackendVault  = "vault"  // HashiCorp Vault Transit
+	KMSBackendAWSKMS = "awskms" // AWS KMS
+)
+
+// KMSConfig selects where the master key that wraps the team data keys lives.
+// With the vault and awskms backends, ENCRYPTION_KEY is only needed to migrate values
+// written under it and can be removed once re-encryption is done.
+type KMSConfig struct {
+	Backend         string
+	KeystorePath    string
+	VaultAddr       string
+	VaultToken      string
+	VaultTransitKey string
+	AWSKeyID        string // Key ID, ARN or alias
+	AWSRegion       string
+	AWSEndpoint     string // Overrides the AWS endpoint, e.g. for a KMS-compatible mock
+}
+
+// EdgeConfig holds settings of the edge proxy in front of preview VMs
+type EdgeConfig struct {
+	// ProxyCIDRs are the networks the edge proxy connects from; VMs only accept inbound traffic from them,
+	// so no environment is placed while it is empty
+	ProxyCIDRs []string
+}
+
+// DockerProviderConfig enables the docker provider, which runs workloads as containers on the
+// server's own Docker Engine. It is meant for development and CI and refused in production.
+type DockerProviderConfig struct {
+	Enabled           bool
+	Host              string // Docker Engine endpoint (default: DOCKER_HOST, then the local socket)
+	Network           string // Network the containers join (default "bridge")
+	Privileged        bool   // Run containers privileged (Docker-in-Docker builds)
+	MountDockerSocket bool   // Share the engine's socket with every container
 }
 
 // Load reads configuration from environment variables
@@ -45,6 +94,9 @@ func Load() (*Config, error) {
 	v.SetDefault("PORT", 8080)
 	v.SetDefault("ENVIRONMENT", "development")
 	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("ENCRYPTION_KEY_ID", "k1")
+	v.SetDefault("KMS_BACKEND", KMSBackendEnv)
+	v.SetDefault("VAULT_TRANSIT_KEY", "stagely")
 
 	// Bind environment variables
 	v.AutomaticEnv()
@@ -63,8 +115,31 @@ func Load() (*Config, error) {
 			LogLevel:    v.GetString("LOG_LEVEL"),
 		},
 		Security: SecurityConfig{
-			JWTSecret:     v.GetString("JWT_SECRET"),
-			EncryptionKey: v.GetString("ENCRYPTION_KEY"),
+			JWTSecret:             v.GetString("JWT_SECRET"),
+			EncryptionKey:         v.GetString("ENCRYPTION_KEY"),
+			EncryptionKeyID:       v.GetString("ENCRYPTION_KEY_ID"),
+			RetiredEncryptionKeys: splitList(v.GetString("ENCRYPTION_RETIRED_KEYS")),
+			EncryptionStrict:      v.GetBool("ENCRYPTION_STRICT"),
+			KMS: KMSConfig{
+				Backend:         v.GetString("KMS_BACKEND"),
+				KeystorePath:    v.GetString("KMS_KEYSTORE_PATH"),
+				VaultAddr:       v.GetString("VAULT_ADDR"),
+				VaultToken:      v.GetString("VAULT_TOKEN"),
+				VaultTransitKey: v.GetString("VAULT_TRANSIT_KEY"),
+				AWSKeyID:        v.GetString("AWS_KMS_KEY_ID"),
+				AWSRegion:       v.GetString("AWS_KMS_REGION"),
+				AWSEndpoint:     v.GetString("AWS_KMS_ENDPOINT"),
+			},
+		},
+		Edge: EdgeConfig{
+			ProxyCIDRs: splitList(v.GetString("EDGE_PROXY_CIDRS")),
+		},
+		Docker: DockerProviderConfig{
+			Enabled:           v.GetBool("DOCKER_PROVIDER_ENABLED"),
+			Host:              v.GetString("DOCKER_PROVIDER_HOST"),
+			Network:           v.GetString("DOCKER_PROVIDER_NETWORK"),
+			Privileged:        v.GetBool("DOCKER_PROVIDER_PRIVILEGED"),
+			MountDockerSocket: v.GetBool("DOCKER_PROVIDER_MOUNT_SOCKET"),
 		},
 	}
 
@@ -84,5 +159,46 @@ func (c *Config) Validate() error {
 	if c.Redis.URL == "" {
 		return fmt.Errorf("REDIS_URL is required")
 	}
+	for _, cidr := range c.Edge.ProxyCIDRs {
+		if prefix, err := netip.ParsePrefix(cidr); err != nil || prefix != prefix.Masked() {
+			return fmt.Errorf("EDGE_PROXY_CIDRS: invalid network %q", cidr)
+		}
+	}
+	if c.Docker.Enabled && c.Server.Environment == "production" {
+		return fmt.Errorf("DOCKER_PROVIDER_ENABLED is not allowed in production")
+	}
+	return c.Security.KMS.Validate()
+}
+
+// Validate checks that the settings of the selected backend are present
+func (k KMSConfig) Validate() error {
+	switch k.Backend {
+	case KMSBackendEnv:
+	case KMSBackendFile:
+		if k.KeystorePath == "" {
+			return fmt.Errorf("KMS_KEYSTORE_PATH is required for KMS_BACKEND=file")
+		}
+	case KMSBackendVault:
+		if k.VaultAddr == "" || k.VaultToken == "" {
+			return fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required for KMS_BACKEND=vault")
+		}
+	case KMSBackendAWSKMS:
+		if k.AWSKeyID == "" {
+			return fmt.Errorf("AWS_KMS_KEY_ID is required for KMS_BACKEND=awskms")
+		}
+	default:
+		return fmt.Errorf("unknown KMS_BACKEND %q (expected env, file, vault or awskms)", k.Backend)
+	}
 	return nil
 }
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
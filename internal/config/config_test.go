@@ -61,3 +61,116 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.Equal(t, "development", cfg.Server.Environment) // default
 	assert.Equal(t, "info", cfg.Server.LogLevel)           // default
 }
+
+func TestLoad_EdgeProxyCIDRs(t *testing.T) {
+	// Given
+	os.Clearenv()
+	require.NoError(t, os.Setenv("DATABASE_URL", "postgres://localhost/test"))
+	require.NoError(t, os.Setenv("REDIS_URL", "redis://localhost:6379"))
+	require.NoError(t, os.Setenv("EDGE_PROXY_CIDRS", "203.0.113.10/32, 2001:db8::/64,"))
+	defer os.Clearenv()
+
+	// When
+	cfg, err := config.Load()
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"203.0.113.10/32", "2001:db8::/64"}, cfg.Edge.ProxyCIDRs)
+
+	// When - a network is malformed
+	require.NoError(t, os.Setenv("EDGE_PROXY_CIDRS", "203.0.113.10"))
+	_, err = config.Load()
+
+	// Then
+	assert.ErrorContains(t, err, "EDGE_PROXY_CIDRS")
+}
+
+func TestLoad_EncryptionKeys(t *testing.T) {
+	// Given
+	os.Clearenv()
+	require.NoError(t, os.Setenv("DATABASE_URL", "postgres://localhost/test"))
+	require.NoError(t, os.Setenv("REDIS_URL", "redis://localhost:6379"))
+	require.NoError(t, os.Setenv("ENCRYPTION_KEY", "aa"))
+	require.NoError(t, os.Setenv("ENCRYPTION_RETIRED_KEYS", "k1:bb,k0:cc"))
+	require.NoError(t, os.Setenv("ENCRYPTION_STRICT", "true"))
+	defer os.Clearenv()
+
+	// When
+	cfg, err := config.Load()
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "aa", cfg.Security.EncryptionKey)
+	assert.Equal(t, "k1", cfg.Security.EncryptionKeyID) // default
+	assert.Equal(t, []string{"k1:bb", "k0:cc"}, cfg.Security.RetiredEncryptionKeys)
+	assert.True(t, cfg.Security.EncryptionStrict)
+}
+
+func TestLoad_KMSBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		backend string
+		wantErr string
+	}{
+		{"default", nil, "env", ""},
+		{"file", map[string]string{"KMS_BACKEND": "file", "KMS_KEYSTORE_PATH": "/etc/stagely/keystore.json"}, "file", ""},
+		{"file without path", map[string]string{"KMS_BACKEND": "file"}, "", "KMS_KEYSTORE_PATH"},
+		{"vault", map[string]string{"KMS_BACKEND": "vault", "VAULT_ADDR": "http://vault:8200", "VAULT_TOKEN": "s.token"}, "vault", ""},
+		{"vault without token", map[string]string{"KMS_BACKEND": "vault", "VAULT_ADDR": "http://vault:8200"}, "", "VAULT_TOKEN"},
+		{"awskms", map[string]string{"KMS_BACKEND": "awskms", "AWS_KMS_KEY_ID": "alias/stagely"}, "awskms", ""},
+		{"awskms without key", map[string]string{"KMS_BACKEND": "awskms"}, "", "AWS_KMS_KEY_ID"},
+		{"unknown", map[string]string{"KMS_BACKEND": "gcpkms"}, "", "unknown KMS_BACKEND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			os.Clearenv()
+			require.NoError(t, os.Setenv("DATABASE_URL", "postgres://localhost/test"))
+			require.NoError(t, os.Setenv("REDIS_URL", "redis://localhost:6379"))
+			for key, value := range tt.env {
+				require.NoError(t, os.Setenv(key, value))
+			}
+			defer os.Clearenv()
+
+			// When
+			cfg, err := config.Load()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.backend, cfg.Security.KMS.Backend)
+			assert.Equal(t, "stagely", cfg.Security.KMS.VaultTransitKey) // default
+		})
+	}
+}
+
+func TestLoad_DockerProvider(t *testing.T) {
+	// Given
+	os.Clearenv()
+	require.NoError(t, os.Setenv("DATABASE_URL", "postgres://localhost/test"))
+	require.NoError(t, os.Setenv("REDIS_URL", "redis://localhost:6379"))
+	require.NoError(t, os.Setenv("DOCKER_PROVIDER_ENABLED", "true"))
+	require.NoError(t, os.Setenv("DOCKER_PROVIDER_PRIVILEGED", "true"))
+	defer os.Clearenv()
+
+	// When
+	cfg, err := config.Load()
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, cfg.Docker.Enabled)
+	assert.True(t, cfg.Docker.Privileged)
+	assert.False(t, cfg.Docker.MountDockerSocket)
+
+	// When - in production
+	require.NoError(t, os.Setenv("ENVIRONMENT", "production"))
+	_, err = config.Load()
+
+	// Then
+	assert.ErrorContains(t, err, "DOCKER_PROVIDER_ENABLED")
+}
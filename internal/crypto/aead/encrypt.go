@@ -1,11 +1,14 @@
-// Package crypto provides encryption utilities using AES-256-GCM
-package crypto
+// Package aead encrypts values (Encrypt) and large values as chunked streams (NewEncryptWriter)
+// with AES-256-GCM. It only depends on the standard library, so the agent can decrypt file
+// secrets without pulling in Core's database and KMS clients.
+package aead
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"io"
 )
@@ -13,6 +16,12 @@ import (
 // Encrypt encrypts plaintext using AES-256-GCM with the provided key
 // Returns base64-encoded ciphertext (format: nonce+ciphertext+tag)
 func Encrypt(plaintext string, key []byte) (string, error) {
+	return EncryptWithAD(plaintext, key, nil)
+}
+
+// EncryptWithAD encrypts plaintext like Encrypt and authenticates associatedData with it.
+// The ciphertext only decrypts with the same associated data (see AssociatedData).
+func EncryptWithAD(plaintext string, key, associatedData []byte) (string, error) {
 	if len(key) != 32 {
 		return "", errors.New("encryption key must be 32 bytes")
 	}
@@ -36,7 +45,7 @@ func Encrypt(plaintext string, key []byte) (string, error) {
 	}
 
 	// Encrypt (nonce is prepended automatically by Seal)
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), associatedData)
 
 	// Encode to base64 for storage
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
@@ -45,6 +54,12 @@ func Encrypt(plaintext string, key []byte) (string, error) {
 // Decrypt decrypts base64-encoded ciphertext using AES-256-GCM with the provided key
 // Returns plaintext or error if authentication fails (wrong key or tampered data)
 func Decrypt(ciphertext string, key []byte) (string, error) {
+	return DecryptWithAD(ciphertext, key, nil)
+}
+
+// DecryptWithAD decrypts a ciphertext produced by EncryptWithAD
+// Authentication fails if associatedData differs from the one used to encrypt.
+func DecryptWithAD(ciphertext string, key, associatedData []byte) (string, error) {
 	if len(key) != 32 {
 		return "", errors.New("encryption key must be 32 bytes")
 	}
@@ -76,7 +91,7 @@ func Decrypt(ciphertext string, key []byte) (string, error) {
 	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
 
 	// Decrypt and verify authentication tag
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, associatedData)
 	if err != nil {
 		return "", errors.New("decryption failed: authentication failed (wrong key or tampered data)")
 	}
@@ -84,6 +99,19 @@ func Decrypt(ciphertext string, key []byte) (string, error) {
 	return string(plaintext), nil
 }
 
+// AssociatedData encodes the fields identifying where a ciphertext is stored, so that a value
+// copied into another row fails authentication. By convention the first field is the table name
+// (e.g., "secrets", projectID, key, scope). Fields are length-prefixed, so ("a", "bc") and
+// ("ab", "c") differ.
+func AssociatedData(fields ...string) []byte {
+	var ad []byte
+	for _, field := range fields {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
+
 // GenerateKey generates a new 32-byte (256-bit) encryption key
 // Uses crypto/rand for cryptographically secure random generation
 func GenerateKey() ([]byte, error) {
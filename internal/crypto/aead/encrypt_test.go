@@ -0,0 +1,161 @@
+package aead_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	// Given
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	plaintext := "my-secret-database-password"
+
+	// When
+	ciphertext, err := aead.Encrypt(plaintext, key)
+	require.NoError(t, err)
+
+	decrypted, err := aead.Decrypt(ciphertext, key)
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, plaintext, decrypted)
+	assert.NotEqual(t, plaintext, ciphertext, "Ciphertext should not equal plaintext")
+}
+
+func TestEncrypt_DifferentCiphertexts(t *testing.T) {
+	// Given
+	key, _ := aead.GenerateKey()
+	plaintext := "same-plaintext"
+
+	// When - Encrypt twice
+	ciphertext1, _ := aead.Encrypt(plaintext, key)
+	ciphertext2, _ := aead.Encrypt(plaintext, key)
+
+	// Then - Should be different due to random nonce
+	assert.NotEqual(t, ciphertext1, ciphertext2, "Each encryption should use a unique nonce")
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	// Given
+	key1, _ := aead.GenerateKey()
+	key2, _ := aead.GenerateKey()
+	plaintext := "secret-data"
+
+	ciphertext, err := aead.Encrypt(plaintext, key1)
+	require.NoError(t, err)
+
+	// When - Decrypt with wrong key
+	_, err = aead.Decrypt(ciphertext, key2)
+
+	// Then
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "authentication failed")
+}
+
+func TestDecrypt_TamperedData(t *testing.T) {
+	// Given
+	key, _ := aead.GenerateKey()
+	plaintext := "important-data"
+
+	ciphertext, err := aead.Encrypt(plaintext, key)
+	require.NoError(t, err)
+
+	// When - Tamper with ciphertext (flip a bit in the decoded data)
+	decoded, _ := base64.StdEncoding.DecodeString(ciphertext)
+	if len(decoded) > 10 {
+		decoded[10] ^= 0xFF // Flip bits
+	}
+	tampered := base64.StdEncoding.EncodeToString(decoded)
+
+	_, err = aead.Decrypt(tampered, key)
+
+	// Then
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "authentication failed")
+}
+
+func TestEncrypt_EmptyString(t *testing.T) {
+	// Given
+	key, _ := aead.GenerateKey()
+
+	// When
+	ciphertext, err := aead.Encrypt("", key)
+	require.NoError(t, err)
+
+	decrypted, err := aead.Decrypt(ciphertext, key)
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, "", decrypted)
+}
+
+func TestEncrypt_LongText(t *testing.T) {
+	// Given
+	key, _ := aead.GenerateKey()
+	// Create 1KB of text
+	plaintext := string(make([]byte, 1024))
+	for i := range plaintext {
+		plaintext = plaintext[:i] + "a"
+	}
+
+	// When
+	ciphertext, err := aead.Encrypt(plaintext, key)
+	require.NoError(t, err)
+
+	decrypted, err := aead.Decrypt(ciphertext, key)
+	require.NoError(t, err)
+
+	// Then
+	assert.Len(t, decrypted, len(plaintext))
+}
+
+func TestEncryptWithAD_SwappedRow(t *testing.T) {
+	// Given - a value bound to one secrets row
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	row := aead.AssociatedData("secrets", "project-1", "DB_PASSWORD", "global")
+	ciphertext, err := aead.EncryptWithAD("db-password", key, row)
+	require.NoError(t, err)
+
+	// When
+	plaintext, err := aead.DecryptWithAD(ciphertext, key, row)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+
+	// Read back as another row, another project, or without associated data, it fails
+	for _, other := range [][]byte{
+		aead.AssociatedData("secrets", "project-1", "API_KEY", "global"),
+		aead.AssociatedData("secrets", "project-2", "DB_PASSWORD", "global"),
+		aead.AssociatedData("secrets", "project-1", "DB_PASSWORD", "backend"),
+		nil,
+	} {
+		_, err := aead.DecryptWithAD(ciphertext, key, other)
+		assert.ErrorContains(t, err, "authentication failed")
+	}
+}
+
+func TestAssociatedData_Unambiguous(t *testing.T) {
+	assert.NotEqual(t, aead.AssociatedData("a", "bc"), aead.AssociatedData("ab", "c"))
+	assert.NotEqual(t, aead.AssociatedData("a", ""), aead.AssociatedData("a"))
+	assert.Equal(t, aead.AssociatedData("secrets", "p", "k"), aead.AssociatedData("secrets", "p", "k"))
+}
+
+func TestGenerateKey(t *testing.T) {
+	// When
+	key1, err1 := aead.GenerateKey()
+	key2, err2 := aead.GenerateKey()
+
+	// Then
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	assert.Len(t, key1, 32, "Key should be 32 bytes (256 bits)")
+	assert.Len(t, key2, 32)
+	assert.NotEqual(t, key1, key2, "Keys should be unique")
+}
@@ -0,0 +1,279 @@
+package aead
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Streams encrypt large values (e.g., file secrets) chunk by chunk, so neither side holds them in memory:
+//
+//	header = "SGST" || version (1 byte) || salt (32 bytes)
+//	chunk  = AES-256-GCM(plaintext chunk, stream key, nonce, associated data)
+//	nonce  = 0x000000 || chunk index (uint64 BE) || 0x01 for the last chunk, else 0x00
+//
+// The stream key is derived from the key and the random salt (HKDF-SHA256), so nonces never
+// repeat across streams. Every chunk holds StreamChunkSize bytes of plaintext except the last,
+// which may be shorter or empty. The index in the nonce detects reordered chunks, and the
+// last-chunk flag detects truncation.
+const (
+	// StreamChunkSize is the plaintext size of every chunk but the last
+	StreamChunkSize = 64 << 10
+
+	streamMagic   = "SGST"
+	streamVersion = 1
+	streamSaltLen = 32
+	streamInfo    = "stagely stream v1"
+)
+
+// ErrStreamTruncated is returned when an encrypted stream ends before its last chunk
+var ErrStreamTruncated = errors.New("encrypted stream is truncated")
+
+// NewEncryptWriter returns a writer that encrypts everything written to it to dst.
+// Close must be called to write the last chunk; without it the stream fails to decrypt.
+// Close does not close dst.
+func NewEncryptWriter(dst io.Writer, key, associatedData []byte) (io.WriteCloser, error) {
+	salt := make([]byte, streamSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := streamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(streamMagic), streamVersion)
+	if _, err := dst.Write(append(header, salt...)); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		dst:            dst,
+		aead:           aead,
+		associatedData: associatedData,
+		buf:            make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// NewDecryptReader returns a reader that decrypts a stream written by NewEncryptWriter.
+// Each chunk is authenticated before any of its plaintext is returned, but a stream cut short or
+// tampered with is only detected when that point is reached: callers writing to disk should
+// discard the output on error (see DecryptToFile).
+func NewDecryptReader(src io.Reader, key, associatedData []byte) (io.Reader, error) {
+	header := make([]byte, len(streamMagic)+1+streamSaltLen)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, errors.New("not an encrypted stream: header too short")
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, errors.New("not an encrypted stream")
+	}
+	if version := header[len(streamMagic)]; version != streamVersion {
+		return nil, fmt.Errorf("unsupported encrypted stream version %d", version)
+	}
+
+	aead, err := streamAEAD(key, header[len(streamMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		src:            bufio.NewReader(src),
+		aead:           aead,
+		associatedData: associatedData,
+		chunk:          make([]byte, StreamChunkSize+aead.Overhead()),
+		buf:            make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// DecryptToFile decrypts a stream into the file at path with the given permissions.
+// The plaintext is written to a temporary file next to path, which replaces path only once the
+// whole stream has been authenticated; on error path is left untouched.
+func DecryptToFile(src io.Reader, key, associatedData []byte, path string, perm os.FileMode) (err error) {
+	reader, err := NewDecryptReader(src, key, associatedData)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	// Restrict permissions before any plaintext is written
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, reader); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// streamAEAD returns the cipher of a stream, keyed with a key derived from key and salt
+func streamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+
+	streamKey, err := hkdf.Key(sha256.New, key, salt, streamInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamNonce returns the nonce of a chunk
+func streamNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// streamWriter encrypts chunks as they fill up
+type streamWriter struct {
+	dst            io.Writer
+	aead           cipher.AEAD
+	associatedData []byte
+	buf            []byte // Plaintext of the current chunk
+	sealed         []byte
+	index          uint64
+	closed         bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypted stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only written once more data arrives, as the last chunk may be full too
+		if len(w.buf) == StreamChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(len(p), StreamChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk
+func (w *streamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// flush encrypts and writes the current chunk
+func (w *streamWriter) flush(last bool) error {
+	w.sealed = w.aead.Seal(w.sealed[:0], streamNonce(w.index, last), w.buf, w.associatedData)
+	if _, err := w.dst.Write(w.sealed); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+// streamReader decrypts one chunk at a time
+type streamReader struct {
+	src            *bufio.Reader
+	aead           cipher.AEAD
+	associatedData []byte
+	chunk          []byte // Ciphertext of the current chunk
+	buf            []byte // Plaintext of the current chunk
+	plaintext      []byte // Part of buf not returned yet
+	index          uint64
+	done           bool
+	err            error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// next reads and decrypts the next chunk
+func (r *streamReader) next() error {
+	n, err := io.ReadFull(r.src, r.chunk)
+	switch {
+	case errors.Is(err, io.EOF):
+		// The previous chunk was full and not the last one
+		return ErrStreamTruncated
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// Shorter than a full chunk: only the last one can be
+	case err != nil:
+		return err
+	}
+
+	// A full chunk is the last one if nothing follows it
+	last := n < len(r.chunk)
+	if !last {
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := r.aead.Open(r.buf[:0], streamNonce(r.index, last), r.chunk[:n], r.associatedData)
+	if err != nil {
+		// The stream was cut right after a full chunk
+		if last && n == len(r.chunk) {
+			if _, err := r.aead.Open(r.buf[:0], streamNonce(r.index, false), r.chunk[:n], r.associatedData); err == nil {
+				return ErrStreamTruncated
+			}
+		}
+		return errors.New("decryption failed: authentication failed (wrong key or tampered data)")
+	}
+
+	r.plaintext = plaintext
+	r.index++
+	r.done = last
+	return nil
+}
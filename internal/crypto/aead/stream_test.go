@@ -0,0 +1,218 @@
+package aead_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fileAD is the associated data of the file secret the test streams belong to
+var fileAD = aead.AssociatedData("secrets", "project-1", "TLS_BUNDLE", "backend")
+
+// chunkLen is the size of an encrypted full chunk (plaintext and GCM tag)
+const chunkLen = aead.StreamChunkSize + 16
+
+// headerLen is the size of the stream header (magic, version, salt)
+const headerLen = 4 + 1 + 32
+
+// encryptStream encrypts plaintext as a stream, written in writes of writeSize bytes
+func encryptStream(t *testing.T, key, plaintext []byte, writeSize int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := aead.NewEncryptWriter(&out, key, fileAD)
+	require.NoError(t, err)
+	for rest := plaintext; len(rest) > 0; {
+		n := min(writeSize, len(rest))
+		_, err := w.Write(rest[:n])
+		require.NoError(t, err)
+		rest = rest[n:]
+	}
+	require.NoError(t, w.Close())
+	return out.Bytes()
+}
+
+// decryptStream decrypts a whole stream
+func decryptStream(key, stream []byte, associatedData []byte) ([]byte, error) {
+	r, err := aead.NewDecryptReader(bytes.NewReader(stream), key, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	return key
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	key := newKey(t)
+
+	tests := []struct {
+		name      string
+		size      int
+		writeSize int
+		chunks    int
+	}{
+		{"empty", 0, 1, 1},
+		{"small", 100, 7, 1},
+		{"one full chunk", aead.StreamChunkSize, aead.StreamChunkSize, 1},
+		{"one byte over", aead.StreamChunkSize + 1, 4096, 2},
+		{"several chunks", 3*aead.StreamChunkSize + 5, 10000, 4},
+		{"exact multiple", 2 * aead.StreamChunkSize, 3 * aead.StreamChunkSize, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			plaintext := randomBytes(t, tt.size)
+
+			// When
+			stream := encryptStream(t, key, plaintext, tt.writeSize)
+			r, err := aead.NewDecryptReader(iotest.HalfReader(bytes.NewReader(stream)), key, fileAD)
+			require.NoError(t, err)
+			decrypted, err := io.ReadAll(r)
+
+			// Then
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, decrypted)
+			assert.Len(t, stream, headerLen+tt.size+tt.chunks*16)
+		})
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	// Given - a stream of three full chunks and a short last one
+	key := newKey(t)
+	stream := encryptStream(t, key, randomBytes(t, 3*aead.StreamChunkSize+100), aead.StreamChunkSize)
+	chunk := func(i int) []byte {
+		start := headerLen + i*chunkLen
+		return stream[start:min(start+chunkLen, len(stream))]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	header := stream[:headerLen]
+
+	flipped := bytes.Clone(stream)
+	flipped[headerLen+chunkLen+10] ^= 1
+
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr error
+	}{
+		{"cut at a chunk boundary", concat(header, chunk(0), chunk(1)), aead.ErrStreamTruncated},
+		{"cut after the header", header, aead.ErrStreamTruncated},
+		{"cut inside a chunk", stream[:headerLen+chunkLen+500], nil},
+		{"last chunk dropped", concat(header, chunk(0), chunk(1), chunk(2)), aead.ErrStreamTruncated},
+		{"chunks reordered", concat(header, chunk(1), chunk(0), chunk(2), chunk(3)), nil},
+		{"chunk duplicated", concat(header, chunk(0), chunk(0), chunk(2), chunk(3)), nil},
+		{"data appended", concat(stream, []byte("x")), nil},
+		{"bit flipped", flipped, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			_, err := decryptStream(key, tt.stream, fileAD)
+
+			// Then
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.ErrorContains(t, err, "authentication failed")
+			}
+		})
+	}
+}
+
+func TestStream_WrongKeyOrAD(t *testing.T) {
+	// Given
+	key := newKey(t)
+	stream := encryptStream(t, key, []byte("-----BEGIN CERTIFICATE-----"), 64)
+
+	// When
+	_, errKey := decryptStream(newKey(t), stream, fileAD)
+	_, errAD := decryptStream(key, stream, aead.AssociatedData("secrets", "project-2", "TLS_BUNDLE", "backend"))
+	_, errFormat := decryptStream(key, []byte("k1:ad:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"), fileAD)
+
+	// Then
+	assert.ErrorContains(t, errKey, "authentication failed")
+	assert.ErrorContains(t, errAD, "authentication failed")
+	assert.ErrorContains(t, errFormat, "not an encrypted stream")
+}
+
+func TestStream_WriteAfterClose(t *testing.T) {
+	// Given
+	w, err := aead.NewEncryptWriter(io.Discard, newKey(t), fileAD)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// When
+	_, err = w.Write([]byte("late"))
+
+	// Then
+	assert.Error(t, err)
+	assert.NoError(t, w.Close())
+}
+
+func TestDecryptToFile(t *testing.T) {
+	// Given
+	key := newKey(t)
+	plaintext := randomBytes(t, 2*aead.StreamChunkSize+1)
+	stream := encryptStream(t, key, plaintext, 32<<10)
+	path := filepath.Join(t.TempDir(), "tls.pem")
+
+	// When
+	err := aead.DecryptToFile(bytes.NewReader(stream), key, fileAD, path, 0o600)
+
+	// Then
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, content)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestDecryptToFile_Truncated(t *testing.T) {
+	// Given - an existing file and a stream cut short
+	key := newKey(t)
+	stream := encryptStream(t, key, randomBytes(t, 2*aead.StreamChunkSize+1), 32<<10)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tls.pem")
+	require.NoError(t, os.WriteFile(path, []byte("previous"), 0o600))
+
+	// When
+	err := aead.DecryptToFile(bytes.NewReader(stream[:headerLen+2*chunkLen]), key, fileAD, path, 0o600)
+
+	// Then - the previous file is untouched and no partial file is left behind
+	assert.ErrorIs(t, err, aead.ErrStreamTruncated)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", string(content))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,160 @@
+package crypto_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// fakeKMS is an in-memory crypto.KMSAPI that checks the key ID and encryption context
+type fakeKMS struct {
+	mu      sync.Mutex
+	entries []*kms.EncryptInput
+}
+
+func (f *fakeKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, params)
+	return &kms.EncryptOutput{CiphertextBlob: []byte(strconv.Itoa(len(f.entries) - 1))}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index, err := strconv.Atoi(string(params.CiphertextBlob))
+	if err != nil || index >= len(f.entries) {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	entry := f.entries[index]
+	if aws.ToString(entry.KeyId) != aws.ToString(params.KeyId) {
+		return nil, errors.New("IncorrectKeyException")
+	}
+	for key, value := range entry.EncryptionContext {
+		if params.EncryptionContext[key] != value {
+			return nil, errors.New("InvalidCiphertextException")
+		}
+	}
+	return &kms.DecryptOutput{Plaintext: entry.Plaintext}, nil
+}
+
+func TestAWSKMS_RoundTrip(t *testing.T) {
+	// Given
+	client := &fakeKMS{}
+	wrapper := crypto.NewAWSKMSWithClient(client, "alias/stagely")
+	ctx := context.Background()
+	key := newKey(t)
+	ad := aead.AssociatedData("team_data_keys", "team-a")
+
+	// When
+	wrapped, err := wrapper.WrapKey(ctx, key, ad)
+	require.NoError(t, err)
+	unwrapped, err := wrapper.UnwrapKey(ctx, wrapped, ad)
+
+	// Then - the associated data travels as encryption context
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+	assert.True(t, strings.HasPrefix(wrapped, wrapper.CurrentPrefix()))
+	require.Len(t, client.entries, 1)
+	assert.Equal(t, map[string]string{"stagely:associated_data": base64.StdEncoding.EncodeToString(ad)}, client.entries[0].EncryptionContext)
+
+	_, err = wrapper.UnwrapKey(ctx, wrapped, aead.AssociatedData("team_data_keys", "team-b"))
+	assert.ErrorContains(t, err, "kms decrypt")
+
+	_, err = wrapper.UnwrapKey(ctx, "vault:v1:AAAA", ad)
+	assert.ErrorContains(t, err, "not a kms ciphertext")
+}
+
+func TestEnvelope_MoveToKMS(t *testing.T) {
+	// Given - a value whose data key is wrapped by the local master key
+	master := newMaster(t)
+	store := newMemoryDataKeys()
+	ctx := context.Background()
+	ciphertext, err := newEnvelope(master, store, 0).Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+
+	// When - KMS becomes the key wrapper and the data key is re-wrapped
+	wrapper := crypto.NewAWSKMSWithClient(&fakeKMS{}, "alias/stagely")
+	rewrapped, err := crypto.NewEnvelope(wrapper, master, store, 0).Rewrap(ctx, "team-a", store.keys["team-a"])
+	require.NoError(t, err)
+	store.keys["team-a"] = rewrapped
+
+	// Then - the value decrypts without the local master key
+	assert.True(t, strings.HasPrefix(rewrapped, wrapper.CurrentPrefix()))
+	plaintext, err := crypto.NewEnvelope(wrapper, nil, store, 0).Decrypt(ctx, "team-a", ciphertext, rowAD)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+func TestAWSKMS_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	// Given - a KMS-compatible mock and a key created in it
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "nsmithuk/local-kms:3",
+			ExposedPorts: []string{"8080/tcp"},
+			Env:          map[string]string{"KMS_REGION": "eu-west-1"},
+			WaitingFor:   wait.ForListeningPort("8080/tcp").WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, container.Terminate(ctx))
+	}()
+
+	endpoint, err := container.PortEndpoint(ctx, "8080/tcp", "http")
+	require.NoError(t, err)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
+	require.NoError(t, err)
+	client := kms.NewFromConfig(cfg, func(o *kms.Options) { o.BaseEndpoint = aws.String(endpoint) })
+	created, err := client.CreateKey(ctx, &kms.CreateKeyInput{})
+	require.NoError(t, err)
+
+	wrapper, err := crypto.NewAWSKMS(ctx, aws.ToString(created.KeyMetadata.KeyId), "eu-west-1", endpoint)
+	require.NoError(t, err)
+	key := newKey(t)
+	ad := aead.AssociatedData("team_data_keys", "team-a")
+
+	// When
+	wrapped, err := wrapper.WrapKey(ctx, key, ad)
+	require.NoError(t, err)
+	unwrapped, err := wrapper.UnwrapKey(ctx, wrapped, ad)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	_, err = wrapper.UnwrapKey(ctx, wrapped, aead.AssociatedData("team_data_keys", "team-b"))
+	assert.Error(t, err, "encryption context must be authenticated")
+
+	// It serves as the envelope's key wrapper
+	envelope := crypto.NewEnvelope(wrapper, nil, newMemoryDataKeys(), time.Minute)
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	plaintext, err := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
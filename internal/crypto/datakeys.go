@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DBDataKeyStore persists wrapped data keys in the team_data_keys table
+type DBDataKeyStore struct {
+	db *gorm.DB
+}
+
+// NewDBDataKeyStore creates a store backed by the given database
+func NewDBDataKeyStore(db *gorm.DB) *DBDataKeyStore {
+	return &DBDataKeyStore{db: db}
+}
+
+// GetDataKey returns a team's wrapped data key
+func (s *DBDataKeyStore) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	var keys []string
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT wrapped_key FROM team_data_keys WHERE team_id = ?
+	`, teamID).Scan(&keys).Error
+	if err != nil {
+		return "", fmt.Errorf("query team data key: %w", err)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoDataKey, teamID)
+	}
+	return keys[0], nil
+}
+
+// CreateDataKey stores a wrapped data key unless the team already has one
+func (s *DBDataKeyStore) CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error) {
+	var keys []string
+	err := s.db.WithContext(ctx).Raw(`
+		WITH inserted AS (
+			INSERT INTO team_data_keys (team_id, wrapped_key)
+			VALUES (?, ?)
+			ON CONFLICT (team_id) DO NOTHING
+			RETURNING wrapped_key
+		)
+		SELECT wrapped_key FROM inserted
+		UNION ALL
+		SELECT wrapped_key FROM team_data_keys WHERE team_id = ?
+		LIMIT 1
+	`, teamID, wrappedKey, teamID).Scan(&keys).Error
+	if err != nil {
+		return "", fmt.Errorf("insert team data key: %w", err)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoDataKey, teamID)
+	}
+	return keys[0], nil
+}
+
+// DeleteDataKey removes a team's data key
+func (s *DBDataKeyStore) DeleteDataKey(ctx context.Context, teamID string) error {
+	err := s.db.WithContext(ctx).Exec(`DELETE FROM team_data_keys WHERE team_id = ?`, teamID).Error
+	if err != nil {
+		return fmt.Errorf("delete team data key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+)
+
+// DataKeyPrefix marks values encrypted with a team data key ("dek:ad:base64...", or "dek:base64..." if unbound)
+// "dek" is reserved, so it cannot be mistaken for a master key ID.
+const DataKeyPrefix = "dek" + keyIDSeparator
+
+// BoundDataKeyPrefix marks values encrypted with a team data key and bound to associated data
+const BoundDataKeyPrefix = DataKeyPrefix + boundMarker
+
+// dataKeysTable is the table wrapped data keys are stored in, and bound to
+const dataKeysTable = "team_data_keys"
+
+// DefaultDataKeyTTL is how long an unwrapped data key stays in memory
+const DefaultDataKeyTTL = 5 * time.Minute
+
+// ErrNoDataKey is returned when a team has no data key (never created, or shredded)
+var ErrNoDataKey = errors.New("team has no data key")
+
+// DataKeyStore persists wrapped team data keys (team_data_keys)
+type DataKeyStore interface {
+	// GetDataKey returns a team's wrapped data key (ErrNoDataKey if it has none)
+	GetDataKey(ctx context.Context, teamID string) (string, error)
+
+	// CreateDataKey stores a wrapped data key unless the team already has one,
+	// and returns the team's key (the existing one if another caller won the race)
+	CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error)
+
+	// DeleteDataKey removes a team's data key (idempotent)
+	DeleteDataKey(ctx context.Context, teamID string) error
+}
+
+// Envelope encrypts team-owned values with per-team data keys (DEKs).
+// Each team gets a random data key, wrapped by the master key (the KEK, see KeyWrapper) and
+// stored in team_data_keys, so one leaked data key only exposes one team, and deleting a team's
+// data key makes its values unrecoverable (crypto-shredding).
+// Wrapped keys are bound to their team, so a wrapped key copied to another team fails to unwrap.
+// Unwrapped keys are cached for the TTL; a shredded key can live that long in other instances.
+type Envelope struct {
+	wrapper KeyWrapper
+	legacy  *Keyring // Decrypts values and data keys from before wrapper was used (nil if none)
+	store   DataKeyStore
+	ttl     time.Duration
+	strict  bool // Reject values not bound to associated data
+	now     func() time.Time
+	cache   map[string]cachedDataKey
+	mu      sync.Mutex
+}
+
+type cachedDataKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// NewEnvelope creates an envelope whose data keys are wrapped by wrapper.
+// legacy is the master keyring values were encrypted with before data keys existed, and data keys
+// were wrapped with before moving to a KMS; it may be nil, or the wrapper itself.
+// A non-positive ttl falls back to DefaultDataKeyTTL.
+func NewEnvelope(wrapper KeyWrapper, legacy *Keyring, store DataKeyStore, ttl time.Duration) *Envelope {
+	if ttl <= 0 {
+		ttl = DefaultDataKeyTTL
+	}
+	return &Envelope{
+		wrapper: wrapper,
+		legacy:  legacy,
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		cache:   make(map[string]cachedDataKey),
+	}
+}
+
+// SetStrict makes Decrypt reject values not bound to associated data with ErrUnbound,
+// whether encrypted with a data key or the master keyring (see Keyring.SetStrict).
+// It must be called before the envelope is used.
+func (e *Envelope) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+// Encrypt encrypts plaintext with the team's data key, bound to associatedData (see aead.AssociatedData),
+// creating the key on first use
+func (e *Envelope) Encrypt(ctx context.Context, teamID, plaintext string, associatedData []byte) (string, error) {
+	key, err := e.dataKey(ctx, teamID, true)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := aead.EncryptWithAD(plaintext, key, associatedData)
+	if err != nil {
+		return "", err
+	}
+	return BoundDataKeyPrefix + ciphertext, nil
+}
+
+// Decrypt decrypts a value of the team. Values written before data keys existed
+// (encrypted with the master keyring) are decrypted with the master keyring.
+// In strict mode, unbound values are rejected (ErrUnbound).
+func (e *Envelope) Decrypt(ctx context.Context, teamID, ciphertext string, associatedData []byte) (string, error) {
+	data, ok := strings.CutPrefix(ciphertext, DataKeyPrefix)
+	if !ok {
+		if e.legacy == nil {
+			return "", errors.New("value is not encrypted with a data key and no master keyring is configured")
+		}
+		return e.legacy.decryptWithAD(ciphertext, associatedData, e.strict || e.legacy.strict)
+	}
+
+	key, err := e.dataKey(ctx, teamID, false)
+	if err != nil {
+		return "", err
+	}
+	return decryptMaybeBound(data, key, associatedData, e.strict)
+}
+
+// EncryptStream returns a writer encrypting to dst with the team's data key, bound to
+// associatedData (see aead.NewEncryptWriter), creating the key on first use.
+// The stream carries no prefix: callers know which columns or files hold streams.
+func (e *Envelope) EncryptStream(ctx context.Context, teamID string, dst io.Writer, associatedData []byte) (io.WriteCloser, error) {
+	key, err := e.dataKey(ctx, teamID, true)
+	if err != nil {
+		return nil, err
+	}
+	return aead.NewEncryptWriter(dst, key, associatedData)
+}
+
+// DecryptStream returns a reader decrypting a stream written by EncryptStream
+func (e *Envelope) DecryptStream(ctx context.Context, teamID string, src io.Reader, associatedData []byte) (io.Reader, error) {
+	key, err := e.dataKey(ctx, teamID, false)
+	if err != nil {
+		return nil, err
+	}
+	return aead.NewDecryptReader(src, key, associatedData)
+}
+
+// Shred deletes the team's data key, making every value encrypted with it unrecoverable
+func (e *Envelope) Shred(ctx context.Context, teamID string) error {
+	e.mu.Lock()
+	delete(e.cache, teamID)
+	e.mu.Unlock()
+
+	return e.store.DeleteDataKey(ctx, teamID)
+}
+
+// Rewrap re-wraps a team's data key with the current master key and returns the new wrapped key.
+// The caller stores it; values encrypted with the data key are unaffected.
+func (e *Envelope) Rewrap(ctx context.Context, teamID, wrapped string) (string, error) {
+	key, err := e.unwrap(ctx, teamID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key of team %s: %w", teamID, err)
+	}
+	return e.wrap(ctx, teamID, key)
+}
+
+// CurrentPrefix returns the prefix of data keys wrapped with the current master key
+func (e *Envelope) CurrentPrefix() string {
+	return e.wrapper.CurrentPrefix()
+}
+
+// dataKey returns the team's unwrapped data key, from the cache when possible
+func (e *Envelope) dataKey(ctx context.Context, teamID string, create bool) ([]byte, error) {
+	e.mu.Lock()
+	cached, ok := e.cache[teamID]
+	e.mu.Unlock()
+	if ok && e.now().Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	wrapped, err := e.store.GetDataKey(ctx, teamID)
+	if errors.Is(err, ErrNoDataKey) && create {
+		wrapped, err = e.createDataKey(ctx, teamID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := e.unwrap(ctx, teamID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key of team %s: %w", teamID, err)
+	}
+
+	e.mu.Lock()
+	e.cache[teamID] = cachedDataKey{key: key, expiresAt: e.now().Add(e.ttl)}
+	e.mu.Unlock()
+
+	return key, nil
+}
+
+// createDataKey generates, wraps and stores a new data key for the team
+func (e *Envelope) createDataKey(ctx context.Context, teamID string) (string, error) {
+	key, err := aead.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := e.wrap(ctx, teamID, key)
+	if err != nil {
+		return "", err
+	}
+	return e.store.CreateDataKey(ctx, teamID, wrapped)
+}
+
+// wrap encrypts a team's data key with the master key
+func (e *Envelope) wrap(ctx context.Context, teamID string, key []byte) (string, error) {
+	wrapped, err := e.wrapper.WrapKey(ctx, key, aead.AssociatedData(dataKeysTable, teamID))
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+	return wrapped, nil
+}
+
+// unwrap decrypts a team's wrapped data key, with the legacy keyring if it holds the key it names
+func (e *Envelope) unwrap(ctx context.Context, teamID, wrapped string) ([]byte, error) {
+	unwrapKey := e.wrapper.UnwrapKey
+	if e.legacy != nil && e.legacy.hasKey(wrapped) {
+		unwrapKey = e.legacy.UnwrapKey
+	}
+
+	key, err := unwrapKey(ctx, wrapped, aead.AssociatedData(dataKeysTable, teamID))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("malformed data key")
+	}
+	return key, nil
+}
+
+// IsDataKeyCiphertext reports whether a value is encrypted with a team data key
+func IsDataKeyCiphertext(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, DataKeyPrefix)
+}
@@ -0,0 +1,280 @@
+package crypto_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowAD is the associated data of the row the test values are stored in
+var rowAD = aead.AssociatedData("secrets", "project-1", "DB_PASSWORD", "global")
+
+// memoryDataKeys is an in-memory DataKeyStore that counts lookups
+type memoryDataKeys struct {
+	mu   sync.Mutex
+	keys map[string]string
+	gets int
+}
+
+func newMemoryDataKeys() *memoryDataKeys {
+	return &memoryDataKeys{keys: make(map[string]string)}
+}
+
+func (s *memoryDataKeys) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	wrapped, ok := s.keys[teamID]
+	if !ok {
+		return "", crypto.ErrNoDataKey
+	}
+	return wrapped, nil
+}
+
+func (s *memoryDataKeys) CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.keys[teamID]; ok {
+		return existing, nil
+	}
+	s.keys[teamID] = wrappedKey
+	return wrappedKey, nil
+}
+
+func (s *memoryDataKeys) DeleteDataKey(ctx context.Context, teamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, teamID)
+	return nil
+}
+
+func newMaster(t *testing.T) *crypto.Keyring {
+	t.Helper()
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": newKey(t)})
+	require.NoError(t, err)
+	return keyring
+}
+
+// newEnvelope returns an envelope wrapping data keys with master
+func newEnvelope(master *crypto.Keyring, store crypto.DataKeyStore, ttl time.Duration) *crypto.Envelope {
+	return crypto.NewEnvelope(master, master, store, ttl)
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	// Given
+	store := newMemoryDataKeys()
+	envelope := newEnvelope(newMaster(t), store, 0)
+	ctx := context.Background()
+
+	// When
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	plaintext, err := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.True(t, strings.HasPrefix(ciphertext, crypto.DataKeyPrefix))
+	assert.True(t, crypto.IsDataKeyCiphertext(ciphertext))
+	assert.True(t, crypto.IsBound(ciphertext))
+	assert.Equal(t, "k1", crypto.KeyID(store.keys["team-a"]), "data keys are wrapped by the master key")
+	assert.True(t, crypto.IsBound(store.keys["team-a"]))
+}
+
+func TestEnvelope_SwappedRow(t *testing.T) {
+	// Given - a value of another secret of the same team
+	envelope := newEnvelope(newMaster(t), newMemoryDataKeys(), 0)
+	ctx := context.Background()
+	other, err := envelope.Encrypt(ctx, "team-a", "other-password", aead.AssociatedData("secrets", "project-1", "API_KEY", "global"))
+	require.NoError(t, err)
+
+	// When - it is copied into this row
+	_, err = envelope.Decrypt(ctx, "team-a", other, rowAD)
+
+	// Then
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestEnvelope_SwappedDataKey(t *testing.T) {
+	// Given - team B's data key row is replaced with team A's wrapped key
+	store := newMemoryDataKeys()
+	ctx := context.Background()
+	envelope := newEnvelope(newMaster(t), store, 0)
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	store.keys["team-b"] = store.keys["team-a"]
+
+	// When - team A's value is read as team B's
+	_, err = envelope.Decrypt(ctx, "team-b", ciphertext, rowAD)
+
+	// Then
+	assert.ErrorContains(t, err, "unwrap data key of team team-b")
+}
+
+func TestEnvelope_TeamsAreIsolated(t *testing.T) {
+	// Given
+	envelope := newEnvelope(newMaster(t), newMemoryDataKeys(), 0)
+	ctx := context.Background()
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	_, err = envelope.Encrypt(ctx, "team-b", "other", rowAD)
+	require.NoError(t, err)
+
+	// When
+	_, err = envelope.Decrypt(ctx, "team-b", ciphertext, rowAD)
+
+	// Then
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestEnvelope_MasterKeyFallback(t *testing.T) {
+	// Given - a value encrypted with the master key before data keys existed
+	master := newMaster(t)
+	store := newMemoryDataKeys()
+	envelope := newEnvelope(master, store, 0)
+	legacy, err := master.Encrypt("db-password")
+	require.NoError(t, err)
+
+	// When
+	plaintext, err := envelope.Decrypt(context.Background(), "team-a", legacy, rowAD)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.Empty(t, store.keys, "reading a legacy value does not create a data key")
+}
+
+func TestEnvelope_Strict(t *testing.T) {
+	// Given - unbound values of another row, under the team's data key and the master key
+	master := newMaster(t)
+	store := newMemoryDataKeys()
+	envelope := newEnvelope(master, store, 0)
+	ctx := context.Background()
+	_, err := envelope.Encrypt(ctx, "team-a", "api-key", rowAD)
+	require.NoError(t, err)
+	dataKey, err := master.UnwrapKey(ctx, store.keys["team-a"], aead.AssociatedData("team_data_keys", "team-a"))
+	require.NoError(t, err)
+	unbound, err := aead.Encrypt("other-password", dataKey)
+	require.NoError(t, err)
+	legacy, err := master.Encrypt("other-password")
+	require.NoError(t, err)
+
+	for _, ciphertext := range []string{crypto.DataKeyPrefix + unbound, legacy} {
+		// When - it is copied into this row
+		plaintext, lenientErr := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+		envelope.SetStrict(true)
+		_, strictErr := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+		envelope.SetStrict(false)
+
+		// Then - it only decrypts outside strict mode
+		require.NoError(t, lenientErr)
+		assert.Equal(t, "other-password", plaintext)
+		assert.ErrorIs(t, strictErr, crypto.ErrUnbound)
+	}
+}
+
+func TestEnvelope_Cache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("within TTL", func(t *testing.T) {
+		store := newMemoryDataKeys()
+		envelope := newEnvelope(newMaster(t), store, time.Hour)
+		for i := 0; i < 3; i++ {
+			_, err := envelope.Encrypt(ctx, "team-a", "value", rowAD)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, store.gets)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store := newMemoryDataKeys()
+		envelope := newEnvelope(newMaster(t), store, time.Nanosecond)
+		for i := 0; i < 3; i++ {
+			_, err := envelope.Encrypt(ctx, "team-a", "value", rowAD)
+			require.NoError(t, err)
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, 3, store.gets)
+	})
+}
+
+func TestEnvelope_ConcurrentCreate(t *testing.T) {
+	// Given - another instance created the team's data key first
+	master := newMaster(t)
+	store := newMemoryDataKeys()
+	ctx := context.Background()
+	other := newEnvelope(master, store, 0)
+	ciphertext, err := other.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	winner := store.keys["team-a"]
+
+	// When - this instance races it, having missed the key on lookup
+	envelope := newEnvelope(master, &missingOnce{memoryDataKeys: store}, 0)
+	_, err = envelope.Encrypt(ctx, "team-a", "other", rowAD)
+	require.NoError(t, err)
+
+	// Then - both use the stored key
+	assert.Equal(t, winner, store.keys["team-a"])
+	plaintext, err := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+// missingOnce reports the first lookup as missing, as if the key was created right after it
+type missingOnce struct {
+	*memoryDataKeys
+	missed bool
+}
+
+func (s *missingOnce) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	if !s.missed {
+		s.missed = true
+		return "", crypto.ErrNoDataKey
+	}
+	return s.memoryDataKeys.GetDataKey(ctx, teamID)
+}
+
+func TestEnvelope_Shred(t *testing.T) {
+	// Given
+	store := newMemoryDataKeys()
+	envelope := newEnvelope(newMaster(t), store, time.Hour)
+	ctx := context.Background()
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+
+	// When
+	require.NoError(t, envelope.Shred(ctx, "team-a"))
+
+	// Then - the value is unrecoverable, even though the key was cached
+	_, err = envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+	assert.ErrorIs(t, err, crypto.ErrNoDataKey)
+	assert.NotContains(t, store.keys, "team-a")
+}
+
+func TestEnvelope_MasterKeyRotation(t *testing.T) {
+	// Given - a data key wrapped by k1
+	k1, k2 := newKey(t), newKey(t)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	store := newMemoryDataKeys()
+	ctx := context.Background()
+	ciphertext, err := newEnvelope(before, store, 0).Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+
+	// When - k2 becomes active and k1 is retired
+	after, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+	plaintext, err := newEnvelope(after, store, 0).Decrypt(ctx, "team-a", ciphertext, rowAD)
+
+	// Then - the data key still unwraps; only it needs re-wrapping, not the values
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.True(t, after.NeedsRotation(store.keys["team-a"]))
+}
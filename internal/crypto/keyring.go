@@ -0,0 +1,246 @@
+// Package crypto manages the keys values are encrypted with: master keyrings, KMS backends
+// and per-team data keys. The ciphers themselves live in package aead.
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+)
+
+// ErrUnknownKey is returned when a ciphertext names a key the keyring does not hold
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// ErrUnbound is returned in strict mode for ciphertexts not bound to associated data
+var ErrUnbound = errors.New("ciphertext is not bound to associated data")
+
+// keyIDPattern restricts key IDs to characters that cannot appear in base64 or the separator
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// reservedKeyIDs are prefixes of ciphertexts not produced by a keyring
+var reservedKeyIDs = map[string]bool{
+	"dek":   true, // DataKeyPrefix
+	"vault": true, // VaultTransit
+	"kms":   true, // AWSKMS
+}
+
+// keyIDSeparator separates the key ID from the base64 ciphertext ("k2:base64...")
+const keyIDSeparator = ":"
+
+// boundMarker follows the key ID of ciphertexts bound to associated data ("k2:ad:base64...")
+// Base64 has no ":", so it cannot be mistaken for the start of an unbound ciphertext.
+const boundMarker = "ad" + keyIDSeparator
+
+// Keyring encrypts with an active key and decrypts with any key it holds, so the master key
+// can be rotated without making existing ciphertexts unreadable.
+// Ciphertexts are prefixed with the ID of their key ("k2:" + aead.Encrypt output).
+// Ciphertexts written before key IDs existed (no prefix) are tried with every key.
+// Ciphertexts from EncryptWithAD carry a marker after the key ID ("k2:ad:" + ...).
+// In strict mode, only those decrypt (see SetStrict).
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+	strict bool
+}
+
+// NewKeyring creates a keyring that encrypts with keys[activeID]
+// The other keys are retired: they only decrypt.
+func NewKeyring(activeID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeID)
+	}
+
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid key ID %q: use 1-32 letters, digits, '-' or '_'", id)
+		}
+		if reservedKeyIDs[id] {
+			return nil, fmt.Errorf("key ID %q is reserved", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes", id)
+		}
+		copied[id] = append([]byte(nil), key...)
+	}
+
+	return &Keyring{active: activeID, keys: copied}, nil
+}
+
+// ParseKeyring creates a keyring from hex-encoded keys (e.g., ENCRYPTION_KEY).
+// Each retired entry has the form "id:hexkey".
+func ParseKeyring(activeID, activeKeyHex string, retired []string) (*Keyring, error) {
+	keys := make(map[string][]byte, len(retired)+1)
+
+	key, err := hex.DecodeString(activeKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("key %q is not valid hex", activeID)
+	}
+	keys[activeID] = key
+
+	for _, entry := range retired {
+		id, keyHex, ok := strings.Cut(entry, keyIDSeparator)
+		if !ok {
+			return nil, fmt.Errorf("retired key %q must have the form id:hexkey", entry)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", id)
+		}
+		if keys[id], err = hex.DecodeString(keyHex); err != nil {
+			return nil, fmt.Errorf("key %q is not valid hex", id)
+		}
+	}
+
+	return NewKeyring(activeID, keys)
+}
+
+// ActiveKeyID returns the ID of the key new ciphertexts are encrypted with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// KeyIDs returns the IDs of every key in the keyring, sorted
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetStrict makes the keyring reject ciphertexts not bound to associated data, unprefixed ones
+// included, with ErrUnbound. Enable it once the re-encryption job has bound every value
+// (keyrotation.Result.Done): an unbound value can be copied to another row and still decrypt.
+// It must be called before the keyring is used.
+func (k *Keyring) SetStrict(strict bool) {
+	k.strict = strict
+}
+
+// Encrypt encrypts plaintext with the active key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	ciphertext, err := aead.Encrypt(plaintext, k.keys[k.active])
+	if err != nil {
+		return "", err
+	}
+	return k.active + keyIDSeparator + ciphertext, nil
+}
+
+// EncryptWithAD encrypts plaintext with the active key, bound to associatedData
+func (k *Keyring) EncryptWithAD(plaintext string, associatedData []byte) (string, error) {
+	ciphertext, err := aead.EncryptWithAD(plaintext, k.keys[k.active], associatedData)
+	if err != nil {
+		return "", err
+	}
+	return k.active + keyIDSeparator + boundMarker + ciphertext, nil
+}
+
+// Decrypt decrypts a ciphertext with the key it names
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	return k.DecryptWithAD(ciphertext, nil)
+}
+
+// DecryptWithAD decrypts a ciphertext with the key it names.
+// Bound ciphertexts must match associatedData; unbound ones (written before associated data
+// was used) decrypt regardless until re-encryption binds them, unless the keyring is strict.
+func (k *Keyring) DecryptWithAD(ciphertext string, associatedData []byte) (string, error) {
+	return k.decryptWithAD(ciphertext, associatedData, k.strict)
+}
+
+// decryptWithAD decrypts a ciphertext, rejecting unbound ones if strict
+func (k *Keyring) decryptWithAD(ciphertext string, associatedData []byte, strict bool) (string, error) {
+	id, data, ok := strings.Cut(ciphertext, keyIDSeparator)
+	if !ok {
+		if strict {
+			return "", ErrUnbound
+		}
+		return k.decryptLegacy(ciphertext)
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return decryptMaybeBound(data, key, associatedData, strict)
+}
+
+// decryptMaybeBound decrypts the part of a ciphertext after its key ID.
+// Unbound data is rejected if strict.
+func decryptMaybeBound(data string, key, associatedData []byte, strict bool) (string, error) {
+	if bound, ok := strings.CutPrefix(data, boundMarker); ok {
+		return aead.DecryptWithAD(bound, key, associatedData)
+	}
+	if strict {
+		return "", ErrUnbound
+	}
+	return aead.Decrypt(data, key)
+}
+
+// decryptLegacy decrypts an unprefixed ciphertext with whichever key authenticates it
+func (k *Keyring) decryptLegacy(ciphertext string) (string, error) {
+	var err error
+	for _, id := range k.KeyIDs() {
+		var plaintext string
+		if plaintext, err = aead.Decrypt(ciphertext, k.keys[id]); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", err
+}
+
+// WrapKey encrypts a data key with the active key, bound to associatedData
+func (k *Keyring) WrapKey(ctx context.Context, key, associatedData []byte) (string, error) {
+	return k.EncryptWithAD(base64.StdEncoding.EncodeToString(key), associatedData)
+}
+
+// UnwrapKey decrypts a data key returned by WrapKey
+func (k *Keyring) UnwrapKey(ctx context.Context, wrapped string, associatedData []byte) ([]byte, error) {
+	encoded, err := k.DecryptWithAD(wrapped, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// CurrentPrefix returns the prefix of keys wrapped with the active key
+func (k *Keyring) CurrentPrefix() string {
+	return BoundPrefix(k.active)
+}
+
+// hasKey reports whether the keyring holds the key a ciphertext names
+func (k *Keyring) hasKey(ciphertext string) bool {
+	_, ok := k.keys[KeyID(ciphertext)]
+	return ok
+}
+
+// NeedsRotation reports whether a ciphertext is not encrypted with the active key
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	return KeyID(ciphertext) != k.active
+}
+
+// IsBound reports whether a ciphertext is bound to associated data
+func IsBound(ciphertext string) bool {
+	_, data, ok := strings.Cut(ciphertext, keyIDSeparator)
+	return ok && strings.HasPrefix(data, boundMarker)
+}
+
+// BoundPrefix returns the prefix of ciphertexts encrypted with keyID and bound to associated data
+func BoundPrefix(keyID string) string {
+	return keyID + keyIDSeparator + boundMarker
+}
+
+// KeyID returns the key ID a ciphertext is prefixed with ("" for unprefixed ciphertexts)
+func KeyID(ciphertext string) string {
+	id, _, ok := strings.Cut(ciphertext, keyIDSeparator)
+	if !ok {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,220 @@
+package crypto_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	// Given
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": newKey(t)})
+	require.NoError(t, err)
+
+	// When
+	ciphertext, err := keyring.Encrypt("db-password")
+	require.NoError(t, err)
+	plaintext, err := keyring.Decrypt(ciphertext)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.True(t, strings.HasPrefix(ciphertext, "k1:"))
+	assert.Equal(t, "k1", crypto.KeyID(ciphertext))
+	assert.False(t, keyring.NeedsRotation(ciphertext))
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	// Given - a ciphertext written before the key was rotated
+	oldKey, newKeyBytes := newKey(t), newKey(t)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	require.NoError(t, err)
+	ciphertext, err := before.Encrypt("db-password")
+	require.NoError(t, err)
+
+	// When - k2 becomes active and k1 is retired
+	after, err := crypto.NewKeyring("k2", map[string][]byte{"k1": oldKey, "k2": newKeyBytes})
+	require.NoError(t, err)
+
+	// Then - old ciphertexts still decrypt, new ones use k2
+	plaintext, err := after.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.True(t, after.NeedsRotation(ciphertext))
+
+	rotated, err := after.Encrypt(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", crypto.KeyID(rotated))
+	assert.False(t, after.NeedsRotation(rotated))
+
+	// Once k1 is dropped, its ciphertexts are unreadable
+	dropped, err := crypto.NewKeyring("k2", map[string][]byte{"k2": newKeyBytes})
+	require.NoError(t, err)
+	_, err = dropped.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, crypto.ErrUnknownKey)
+}
+
+func TestKeyring_LegacyCiphertext(t *testing.T) {
+	// Given - a ciphertext from before key IDs, encrypted with what is now a retired key
+	legacyKey := newKey(t)
+	legacy, err := aead.Encrypt("db-password", legacyKey)
+	require.NoError(t, err)
+
+	keyring, err := crypto.NewKeyring("k2", map[string][]byte{"k1": legacyKey, "k2": newKey(t)})
+	require.NoError(t, err)
+
+	// When
+	plaintext, err := keyring.Decrypt(legacy)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.Equal(t, "", crypto.KeyID(legacy))
+	assert.True(t, keyring.NeedsRotation(legacy))
+
+	// A legacy ciphertext under an unknown key fails authentication
+	other, err := crypto.NewKeyring("k3", map[string][]byte{"k3": newKey(t)})
+	require.NoError(t, err)
+	_, err = other.Decrypt(legacy)
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestKeyring_AssociatedData(t *testing.T) {
+	// Given
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": newKey(t)})
+	require.NoError(t, err)
+	row := aead.AssociatedData("cloud_providers", "team-1", "provider-1")
+
+	// When
+	ciphertext, err := keyring.EncryptWithAD(`{"api_token": "a"}`, row)
+	require.NoError(t, err)
+	plaintext, err := keyring.DecryptWithAD(ciphertext, row)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, `{"api_token": "a"}`, plaintext)
+	assert.True(t, strings.HasPrefix(ciphertext, "k1:ad:"))
+	assert.Equal(t, "k1", crypto.KeyID(ciphertext))
+	assert.True(t, crypto.IsBound(ciphertext))
+
+	// A value swapped in from another row fails authentication
+	_, err = keyring.DecryptWithAD(ciphertext, aead.AssociatedData("cloud_providers", "team-2", "provider-2"))
+	assert.ErrorContains(t, err, "authentication failed")
+	_, err = keyring.Decrypt(ciphertext)
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestKeyring_UnboundCiphertext(t *testing.T) {
+	// Given - values written before associated data was used
+	key := newKey(t)
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": key})
+	require.NoError(t, err)
+	unbound, err := keyring.Encrypt("db-password")
+	require.NoError(t, err)
+	legacy, err := aead.Encrypt("db-password", key)
+	require.NoError(t, err)
+
+	// When / Then - they still decrypt until they are re-encrypted
+	for _, ciphertext := range []string{unbound, legacy} {
+		assert.False(t, crypto.IsBound(ciphertext))
+		plaintext, err := keyring.DecryptWithAD(ciphertext, aead.AssociatedData("secrets", "project-1"))
+		require.NoError(t, err)
+		assert.Equal(t, "db-password", plaintext)
+	}
+}
+
+func TestKeyring_Strict(t *testing.T) {
+	// Given - unbound values, and a bound one
+	key := newKey(t)
+	keyring, err := crypto.NewKeyring("k1", map[string][]byte{"k1": key})
+	require.NoError(t, err)
+	unbound, err := keyring.Encrypt("db-password")
+	require.NoError(t, err)
+	legacy, err := aead.Encrypt("db-password", key)
+	require.NoError(t, err)
+	row := aead.AssociatedData("secrets", "project-1")
+	bound, err := keyring.EncryptWithAD("db-password", row)
+	require.NoError(t, err)
+
+	// When
+	keyring.SetStrict(true)
+
+	// Then - only the bound value decrypts
+	for _, ciphertext := range []string{unbound, legacy} {
+		_, err := keyring.DecryptWithAD(ciphertext, row)
+		assert.ErrorIs(t, err, crypto.ErrUnbound)
+	}
+	plaintext, err := keyring.DecryptWithAD(bound, row)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+func TestNewKeyring_Errors(t *testing.T) {
+	key := newKey(t)
+
+	_, err := crypto.NewKeyring("k2", map[string][]byte{"k1": key})
+	assert.ErrorContains(t, err, "not in the keyring")
+
+	_, err = crypto.NewKeyring("k:1", map[string][]byte{"k:1": key})
+	assert.ErrorContains(t, err, "invalid key ID")
+
+	_, err = crypto.NewKeyring("k1", map[string][]byte{"k1": key[:16]})
+	assert.ErrorContains(t, err, "32 bytes")
+
+	_, err = crypto.NewKeyring("dek", map[string][]byte{"dek": key})
+	assert.ErrorContains(t, err, "reserved")
+}
+
+func TestParseKeyring(t *testing.T) {
+	// Given
+	active, retired := newKey(t), newKey(t)
+
+	// When
+	keyring, err := crypto.ParseKeyring("2025-06", hex.EncodeToString(active), []string{"2024-01:" + hex.EncodeToString(retired)})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "2025-06", keyring.ActiveKeyID())
+	assert.Equal(t, []string{"2024-01", "2025-06"}, keyring.KeyIDs())
+
+	legacy, err := aead.Encrypt("secret", retired)
+	require.NoError(t, err)
+	plaintext, err := keyring.Decrypt(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+}
+
+func TestParseKeyring_Errors(t *testing.T) {
+	keyHex := hex.EncodeToString(newKey(t))
+
+	tests := []struct {
+		name    string
+		active  string
+		retired []string
+	}{
+		{"active not hex", "zz", nil},
+		{"active too short", keyHex[:32], nil},
+		{"retired without ID", keyHex, []string{keyHex}},
+		{"retired not hex", keyHex, []string{"k0:zz"}},
+		{"duplicate ID", keyHex, []string{"k1:" + keyHex}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := crypto.ParseKeyring("k1", tt.active, tt.retired)
+			assert.Error(t, err)
+		})
+	}
+}
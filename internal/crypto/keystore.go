@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// keystoreFile is the format of a keystore file:
+//
+//	{"active": "k2", "keys": {"k1": "<hex>", "k2": "<hex>"}}
+type keystoreFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// LoadKeystore reads a keyring from a local keystore file (e.g., a mounted Kubernetes secret),
+// so the master key is not passed through the environment.
+// The file must not be readable by group or others.
+func LoadKeystore(path string) (*Keyring, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("keystore %s must not be accessible by group or others (mode %04o)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("keystore %s: invalid JSON: %w", path, err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, keyHex := range file.Keys {
+		if keys[id], err = hex.DecodeString(keyHex); err != nil {
+			return nil, fmt.Errorf("keystore %s: key %q is not valid hex", path, id)
+		}
+	}
+
+	keyring, err := NewKeyring(file.Active, keys)
+	if err != nil {
+		return nil, fmt.Errorf("keystore %s: %w", path, err)
+	}
+	return keyring, nil
+}
@@ -0,0 +1,75 @@
+package crypto_test
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeystore writes a keystore file with the given content and mode
+func writeKeystore(t *testing.T, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), mode))
+	require.NoError(t, os.Chmod(path, mode))
+	return path
+}
+
+func TestLoadKeystore(t *testing.T) {
+	// Given - a keystore with a retired and an active key
+	k1, k2 := newKey(t), newKey(t)
+	path := writeKeystore(t, `{"active": "k2", "keys": {"k1": "`+hex.EncodeToString(k1)+`", "k2": "`+hex.EncodeToString(k2)+`"}}`, 0o600)
+	retired, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	old, err := retired.Encrypt("db-password")
+	require.NoError(t, err)
+
+	// When
+	keyring, err := crypto.LoadKeystore(path)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyring.ActiveKeyID())
+	assert.Equal(t, []string{"k1", "k2"}, keyring.KeyIDs())
+	plaintext, err := keyring.Decrypt(old)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+func TestLoadKeystore_Errors(t *testing.T) {
+	key := hex.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		wantErr string
+	}{
+		{"readable by others", `{"active": "k1", "keys": {"k1": "` + key + `"}}`, 0o644, "must not be accessible by group or others"},
+		{"invalid JSON", `{"active": `, 0o600, "invalid JSON"},
+		{"invalid hex", `{"active": "k1", "keys": {"k1": "zz"}}`, 0o600, "not valid hex"},
+		{"missing active key", `{"active": "k2", "keys": {"k1": "` + key + `"}}`, 0o600, "k2"},
+		{"reserved key ID", `{"active": "kms", "keys": {"kms": "` + key + `"}}`, 0o600, "reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			path := writeKeystore(t, tt.content, tt.mode)
+
+			// When
+			_, err := crypto.LoadKeystore(path)
+
+			// Then
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := crypto.LoadKeystore(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
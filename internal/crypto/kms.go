@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/stagely-dev/stagely/internal/config"
+)
+
+// KeyWrapper protects team data keys with a master key (the KEK).
+// Implementations: Keyring (ENCRYPTION_KEY or a keystore file, see LoadKeystore),
+// VaultTransit and AWSKMS. With the latter two, the master key never leaves the KMS.
+type KeyWrapper interface {
+	// WrapKey encrypts a data key, bound to associatedData
+	WrapKey(ctx context.Context, key, associatedData []byte) (string, error)
+
+	// UnwrapKey decrypts a data key returned by WrapKey
+	UnwrapKey(ctx context.Context, wrapped string, associatedData []byte) ([]byte, error)
+
+	// CurrentPrefix returns the prefix of keys wrapped with the current master key.
+	// The re-encryption job re-wraps data keys without it.
+	CurrentPrefix() string
+}
+
+// OpenKMS returns the key wrapper of the configured KMS backend, and the keyring that decrypts
+// values and data keys written under a local master key (nil if there is none).
+// With the vault and awskms backends, the keyring comes from ENCRYPTION_KEY when it is still set,
+// so the re-encryption job can move existing values to the KMS. With the file backend, the
+// ENCRYPTION_KEY keys are added to the keystore's as retired keys, for the same reason.
+// Keyrings are strict if cfg.EncryptionStrict is set; so must the envelope be (Envelope.SetStrict).
+func OpenKMS(ctx context.Context, cfg config.SecurityConfig) (KeyWrapper, *Keyring, error) {
+	var legacy *Keyring
+	if cfg.EncryptionKey != "" {
+		keyring, err := ParseKeyring(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.RetiredEncryptionKeys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ENCRYPTION_KEY: %w", err)
+		}
+		keyring.SetStrict(cfg.EncryptionStrict)
+		legacy = keyring
+	}
+
+	switch cfg.KMS.Backend {
+	case config.KMSBackendEnv:
+		if legacy == nil {
+			return nil, nil, fmt.Errorf("ENCRYPTION_KEY is required for KMS_BACKEND=env")
+		}
+		return legacy, legacy, nil
+	case config.KMSBackendFile:
+		keyring, err := LoadKeystore(cfg.KMS.KeystorePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if legacy != nil {
+			if keyring, err = withRetiredKeys(keyring, legacy); err != nil {
+				return nil, nil, fmt.Errorf("KMS_KEYSTORE_PATH and ENCRYPTION_KEY: %w", err)
+			}
+		}
+		keyring.SetStrict(cfg.EncryptionStrict)
+		return keyring, keyring, nil
+	case config.KMSBackendVault:
+		vault, err := NewVaultTransit(cfg.KMS.VaultAddr, cfg.KMS.VaultToken, cfg.KMS.VaultTransitKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return vault, legacy, nil
+	case config.KMSBackendAWSKMS:
+		kms, err := NewAWSKMS(ctx, cfg.KMS.AWSKeyID, cfg.KMS.AWSRegion, cfg.KMS.AWSEndpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		return kms, legacy, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown KMS backend %q", cfg.KMS.Backend)
+	}
+}
+
+// withRetiredKeys returns keyring with the keys of retired added as decrypt-only keys.
+// A key ID both keyrings hold must name the same key.
+func withRetiredKeys(keyring, retired *Keyring) (*Keyring, error) {
+	keys := make(map[string][]byte, len(keyring.keys)+len(retired.keys))
+	for id, key := range keyring.keys {
+		keys[id] = key
+	}
+	for id, key := range retired.keys {
+		if existing, ok := keys[id]; ok && !bytes.Equal(existing, key) {
+			return nil, fmt.Errorf("key %q differs between the keyrings", id)
+		}
+		keys[id] = key
+	}
+	return NewKeyring(keyring.active, keys)
+}
@@ -0,0 +1,113 @@
+package crypto_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/config"
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenKMS(t *testing.T) {
+	// Given
+	key := hex.EncodeToString(make([]byte, 32))
+	keystore := writeKeystore(t, `{"active": "f1", "keys": {"f1": "`+key+`"}}`, 0o600)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	tests := []struct {
+		name       string
+		cfg        config.SecurityConfig
+		wantLegacy bool
+		wantPrefix string
+		wantErr    string
+	}{
+		{
+			name:       "env",
+			cfg:        config.SecurityConfig{EncryptionKey: key, EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "env"}},
+			wantLegacy: true,
+			wantPrefix: "k1:ad:",
+		},
+		{
+			name:    "env without key",
+			cfg:     config.SecurityConfig{EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "env"}},
+			wantErr: "ENCRYPTION_KEY is required",
+		},
+		{
+			name:       "file",
+			cfg:        config.SecurityConfig{KMS: config.KMSConfig{Backend: "file", KeystorePath: keystore}},
+			wantLegacy: true,
+			wantPrefix: "f1:ad:",
+		},
+		{
+			name:       "file keeps the local key for migration",
+			cfg:        config.SecurityConfig{EncryptionKey: key, EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "file", KeystorePath: keystore}},
+			wantLegacy: true,
+			wantPrefix: "f1:ad:",
+		},
+		{
+			name:    "file with a conflicting local key",
+			cfg:     config.SecurityConfig{EncryptionKey: hex.EncodeToString(bytes.Repeat([]byte{1}, 32)), EncryptionKeyID: "f1", KMS: config.KMSConfig{Backend: "file", KeystorePath: keystore}},
+			wantErr: `key "f1" differs`,
+		},
+		{
+			name:       "vault without a local key",
+			cfg:        config.SecurityConfig{KMS: config.KMSConfig{Backend: "vault", VaultAddr: "http://vault:8200", VaultToken: "root", VaultTransitKey: "stagely"}},
+			wantPrefix: "vault:",
+		},
+		{
+			name:       "awskms keeps the local key for migration",
+			cfg:        config.SecurityConfig{EncryptionKey: key, EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "awskms", AWSKeyID: "alias/stagely", AWSRegion: "eu-west-1"}},
+			wantLegacy: true,
+			wantPrefix: "kms:",
+		},
+		{
+			name:    "invalid local key",
+			cfg:     config.SecurityConfig{EncryptionKey: "zz", EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "vault"}},
+			wantErr: "ENCRYPTION_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			wrapper, legacy, err := crypto.OpenKMS(context.Background(), tt.cfg)
+
+			// Then
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrefix, wrapper.CurrentPrefix())
+			assert.Equal(t, tt.wantLegacy, legacy != nil)
+		})
+	}
+}
+
+func TestOpenKMS_FileDecryptsLocalKeyValues(t *testing.T) {
+	// Given - a value encrypted under ENCRYPTION_KEY before the keystore was introduced
+	localKey := hex.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	local, err := crypto.ParseKeyring("k1", localKey, nil)
+	require.NoError(t, err)
+	ciphertext, err := local.Encrypt("db-password")
+	require.NoError(t, err)
+
+	keystore := writeKeystore(t, `{"active": "f1", "keys": {"f1": "`+hex.EncodeToString(make([]byte, 32))+`"}}`, 0o600)
+	cfg := config.SecurityConfig{EncryptionKey: localKey, EncryptionKeyID: "k1", KMS: config.KMSConfig{Backend: "file", KeystorePath: keystore}}
+
+	// When
+	_, keyring, err := crypto.OpenKMS(context.Background(), cfg)
+	require.NoError(t, err)
+
+	// Then - the local key only decrypts, and values move to the keystore's key
+	assert.Equal(t, "f1", keyring.ActiveKeyID())
+	plaintext, err := keyring.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+	assert.True(t, keyring.NeedsRotation(ciphertext))
+}
@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vaultPrefix starts every Vault Transit ciphertext ("vault:v1:base64...")
+const vaultPrefix = "vault" + keyIDSeparator
+
+// VaultTransit wraps data keys with a HashiCorp Vault Transit key.
+// The key must be of an AEAD type (e.g., aes256-gcm96) so associated data is authenticated.
+// Rotating the key in Vault needs no re-wrapping: older versions keep decrypting until
+// min_decryption_version is raised.
+type VaultTransit struct {
+	addr       string
+	token      string
+	keyName    string
+	httpClient *http.Client
+}
+
+// NewVaultTransit creates a wrapper using the Transit key keyName of the Vault at addr
+// (e.g., "https://vault.internal:8200"), mounted at "transit/"
+func NewVaultTransit(addr, token, keyName string) (*VaultTransit, error) {
+	if addr == "" || token == "" || keyName == "" {
+		return nil, errors.New("vault address, token and transit key are required")
+	}
+	return &VaultTransit{
+		addr:       strings.TrimSuffix(addr, "/"),
+		token:      token,
+		keyName:    keyName,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// VaultError is an error returned by the Vault API
+type VaultError struct {
+	StatusCode int
+	Errors     []string `json:"errors"`
+}
+
+func (e *VaultError) Error() string {
+	return fmt.Sprintf("vault: %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+// WrapKey encrypts a data key with the Transit key
+func (v *VaultTransit) WrapKey(ctx context.Context, key, associatedData []byte) (string, error) {
+	in := map[string]string{
+		"plaintext":       base64.StdEncoding.EncodeToString(key),
+		"associated_data": base64.StdEncoding.EncodeToString(associatedData),
+	}
+	var out struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, "encrypt", in, &out); err != nil {
+		return "", err
+	}
+	return out.Data.Ciphertext, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey
+func (v *VaultTransit) UnwrapKey(ctx context.Context, wrapped string, associatedData []byte) ([]byte, error) {
+	if !strings.HasPrefix(wrapped, vaultPrefix) {
+		return nil, errors.New("not a vault transit ciphertext")
+	}
+	in := map[string]string{
+		"ciphertext":      wrapped,
+		"associated_data": base64.StdEncoding.EncodeToString(associatedData),
+	}
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, "decrypt", in, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Plaintext)
+}
+
+// CurrentPrefix returns the prefix of every Transit ciphertext: key versions are managed by Vault
+func (v *VaultTransit) CurrentPrefix() string {
+	return vaultPrefix
+}
+
+// do calls a Transit operation ("encrypt" or "decrypt") on the key
+func (v *VaultTransit) do(ctx context.Context, operation string, in, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	endpoint := v.addr + "/v1/transit/" + operation + "/" + url.PathEscape(v.keyName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		vaultErr := &VaultError{}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = json.Unmarshal(body, vaultErr)
+		vaultErr.StatusCode = resp.StatusCode
+		return vaultErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("vault: decode response: %w", err)
+	}
+	return nil
+}
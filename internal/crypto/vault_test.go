@@ -0,0 +1,191 @@
+package crypto_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// fakeTransit is an in-memory Vault Transit engine serving the "stagely" key
+type fakeTransit struct {
+	mu      sync.Mutex
+	entries []transitEntry
+}
+
+type transitEntry struct {
+	plaintext      string
+	associatedData string
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+		return
+	}
+
+	var in map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/v1/transit/encrypt/stagely":
+		f.entries = append(f.entries, transitEntry{plaintext: in["plaintext"], associatedData: in["associated_data"]})
+		_, _ = w.Write([]byte(`{"data": {"ciphertext": "vault:v1:` + strconv.Itoa(len(f.entries)-1) + `"}}`))
+	case "/v1/transit/decrypt/stagely":
+		index, err := strconv.Atoi(strings.TrimPrefix(in["ciphertext"], "vault:v1:"))
+		if err != nil || index >= len(f.entries) || f.entries[index].associatedData != in["associated_data"] {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors": ["cipher: message authentication failed"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"plaintext": "` + f.entries[index].plaintext + `"}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors": []}`))
+	}
+}
+
+func TestVaultTransit_RoundTrip(t *testing.T) {
+	// Given
+	server := httptest.NewServer(&fakeTransit{})
+	defer server.Close()
+	vault, err := crypto.NewVaultTransit(server.URL+"/", "root", "stagely")
+	require.NoError(t, err)
+	ctx := context.Background()
+	key := newKey(t)
+	ad := aead.AssociatedData("team_data_keys", "team-a")
+
+	// When
+	wrapped, err := vault.WrapKey(ctx, key, ad)
+	require.NoError(t, err)
+	unwrapped, err := vault.UnwrapKey(ctx, wrapped, ad)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+	assert.True(t, strings.HasPrefix(wrapped, vault.CurrentPrefix()))
+
+	_, err = vault.UnwrapKey(ctx, wrapped, aead.AssociatedData("team_data_keys", "team-b"))
+	var vaultErr *crypto.VaultError
+	require.ErrorAs(t, err, &vaultErr)
+	assert.Equal(t, http.StatusBadRequest, vaultErr.StatusCode)
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestVaultTransit_Errors(t *testing.T) {
+	// Given
+	server := httptest.NewServer(&fakeTransit{})
+	defer server.Close()
+	ctx := context.Background()
+
+	_, err := crypto.NewVaultTransit(server.URL, "", "stagely")
+	assert.Error(t, err)
+
+	// When - the token is rejected
+	vault, err := crypto.NewVaultTransit(server.URL, "expired", "stagely")
+	require.NoError(t, err)
+	_, err = vault.WrapKey(ctx, newKey(t), nil)
+
+	// Then
+	var vaultErr *crypto.VaultError
+	require.ErrorAs(t, err, &vaultErr)
+	assert.Equal(t, http.StatusForbidden, vaultErr.StatusCode)
+	assert.Equal(t, []string{"permission denied"}, vaultErr.Errors)
+
+	// Values of other wrappers are not sent to Vault
+	_, err = vault.UnwrapKey(ctx, "kms:AAAA", nil)
+	assert.ErrorContains(t, err, "not a vault transit ciphertext")
+}
+
+func TestVaultTransit_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	// Given - a dev-mode Vault with the Transit engine and an AEAD key
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "hashicorp/vault:1.15",
+			ExposedPorts: []string{"8200/tcp"},
+			Env: map[string]string{
+				"VAULT_DEV_ROOT_TOKEN_ID": "root",
+				"SKIP_SETCAP":             "true",
+			},
+			WaitingFor: wait.ForHTTP("/v1/sys/health").WithPort("8200/tcp").WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, container.Terminate(ctx))
+	}()
+
+	endpoint, err := container.PortEndpoint(ctx, "8200/tcp", "http")
+	require.NoError(t, err)
+	vaultRequest(t, endpoint, "/v1/sys/mounts/transit", `{"type": "transit"}`)
+	vaultRequest(t, endpoint, "/v1/transit/keys/stagely", `{"type": "aes256-gcm96"}`)
+
+	vault, err := crypto.NewVaultTransit(endpoint, "root", "stagely")
+	require.NoError(t, err)
+	key := newKey(t)
+	ad := aead.AssociatedData("team_data_keys", "team-a")
+
+	// When
+	wrapped, err := vault.WrapKey(ctx, key, ad)
+	require.NoError(t, err)
+	unwrapped, err := vault.UnwrapKey(ctx, wrapped, ad)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+	assert.True(t, strings.HasPrefix(wrapped, "vault:v1:"))
+
+	_, err = vault.UnwrapKey(ctx, wrapped, aead.AssociatedData("team_data_keys", "team-b"))
+	assert.Error(t, err, "associated data must be authenticated")
+
+	// Rotating the key in Vault keeps older data keys unwrapping
+	vaultRequest(t, endpoint, "/v1/transit/keys/stagely/rotate", `{}`)
+	unwrapped, err = vault.UnwrapKey(ctx, wrapped, ad)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	// It serves as the envelope's key wrapper
+	envelope := crypto.NewEnvelope(vault, nil, newMemoryDataKeys(), time.Minute)
+	ciphertext, err := envelope.Encrypt(ctx, "team-a", "db-password", rowAD)
+	require.NoError(t, err)
+	plaintext, err := envelope.Decrypt(ctx, "team-a", ciphertext, rowAD)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+// vaultRequest sends an administrative request to a dev-mode Vault
+func vaultRequest(t *testing.T, endpoint, path, body string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, endpoint+path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("X-Vault-Token", "root")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Less(t, resp.StatusCode, 300, "vault %s", path)
+}
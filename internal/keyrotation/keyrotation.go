@@ -0,0 +1,301 @@
+// Package keyrotation re-wraps team data keys with the current master key and moves older
+// values to team data keys, bound to their row
+package keyrotation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"gorm.io/gorm"
+)
+
+// DefaultBatchSize is how many values are re-encrypted per transaction
+const DefaultBatchSize = 100
+
+// Column is a column holding encrypted values
+type Column struct {
+	Table   string
+	Column  string
+	Binding string // SQL expressions whose values, after the table name, are the associated data of a value
+	TeamID  string // SQL expression for the owning team
+}
+
+// Encrypted columns
+// Bindings must match the associated data used by the code writing each column.
+var (
+	TeamDataKeys = Column{
+		Table: "team_data_keys", Column: "wrapped_key",
+		Binding: "team_id::text",
+		TeamID:  "team_id::text",
+	}
+	SecretValues = Column{
+		Table: "secrets", Column: "encrypted_value",
+		Binding: "project_id::text, key, scope",
+		TeamID:  "(SELECT projects.team_id::text FROM projects WHERE projects.id = secrets.project_id)",
+	}
+	CloudProviderCredentials = Column{
+		Table: "cloud_providers", Column: "encrypted_credentials",
+		Binding: "team_id::text, id::text",
+		TeamID:  "team_id::text",
+	}
+)
+
+// Columns lists every encrypted column, in rotation order.
+// Data keys come first: they are re-wrapped when the master key changes, while values
+// only need re-encrypting once, to move them to their team's data key.
+// secrets.encrypted_file is not listed: file secret streams have always been written with
+// their team's data key, bound to their row, so re-wrapping the data keys is all they need.
+var Columns = []Column{TeamDataKeys, SecretValues, CloudProviderCredentials}
+
+// String returns "table.column"
+func (c Column) String() string {
+	return c.Table + "." + c.Column
+}
+
+// Row is an encrypted value and the data identifying its row
+type Row struct {
+	ID         string
+	Ciphertext string
+	TeamID     string   // Owning team
+	Binding    []string // Values of the column's Binding
+}
+
+// AssociatedData returns the associated data a value of the row is bound to
+func (r Row) AssociatedData(column Column) []byte {
+	return aead.AssociatedData(append([]string{column.Table}, r.Binding...)...)
+}
+
+// Replacement swaps the ciphertext of a row
+type Replacement struct {
+	ID  string
+	Old string // Ciphertext the row must still hold; rows changed in the meantime are left alone
+	New string
+}
+
+// Store finds and rewrites values that are not in their final form
+type Store interface {
+	// ListStale returns up to limit rows whose ciphertext starts with none of skipPrefixes,
+	// ordered by ID and starting after afterID ("" for the first batch)
+	ListStale(ctx context.Context, column Column, skipPrefixes []string, afterID string, limit int) ([]Row, error)
+
+	// Replace stores new ciphertexts in one transaction and returns how many rows were updated
+	Replace(ctx context.Context, column Column, replacements []Replacement) (int, error)
+}
+
+// DBStore reads and rewrites encrypted columns in PostgreSQL
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a store backed by the given database
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// ListStale returns up to limit rows whose ciphertext starts with none of skipPrefixes
+// Table and column names come from Columns, never from user input.
+func (s *DBStore) ListStale(ctx context.Context, column Column, skipPrefixes []string, afterID string, limit int) ([]Row, error) {
+	query := fmt.Sprintf(`
+		SELECT id::text AS id, %[2]s AS ciphertext, %[3]s AS team_id, json_build_array(%[4]s)::text AS binding
+		FROM %[1]s
+		WHERE %[2]s IS NOT NULL`, column.Table, column.Column, column.TeamID, column.Binding)
+	var args []any
+	for _, prefix := range skipPrefixes {
+		query += fmt.Sprintf(` AND LEFT(%s, ?) <> ?`, column.Column)
+		args = append(args, len(prefix), prefix)
+	}
+	if afterID != "" {
+		query += ` AND id > ?::uuid`
+		args = append(args, afterID)
+	}
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, limit)
+
+	var results []struct {
+		ID         string
+		Ciphertext string
+		TeamID     string
+		Binding    string
+	}
+	if err := s.db.WithContext(ctx).Raw(query, args...).Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("query %s: %w", column, err)
+	}
+
+	rows := make([]Row, len(results))
+	for i, result := range results {
+		rows[i] = Row{ID: result.ID, Ciphertext: result.Ciphertext, TeamID: result.TeamID}
+		if err := json.Unmarshal([]byte(result.Binding), &rows[i].Binding); err != nil {
+			return nil, fmt.Errorf("decode binding of %s %s: %w", column, result.ID, err)
+		}
+	}
+	return rows, nil
+}
+
+// Replace stores new ciphertexts for rows that still hold their old one
+func (s *DBStore) Replace(ctx context.Context, column Column, replacements []Replacement) (int, error) {
+	query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = ? WHERE id = ? AND %[2]s = ?`, column.Table, column.Column)
+
+	var updated int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updated = 0
+		for _, r := range replacements {
+			result := tx.Exec(query, r.New, r.ID, r.Old)
+			if result.Error != nil {
+				return fmt.Errorf("update %s of %s: %w", column, r.ID, result.Error)
+			}
+			updated += int(result.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// Result summarizes a single rotation pass
+type Result struct {
+	Rotated int      // Data keys re-wrapped, and values moved to their team's data key
+	Skipped int      // Values changed while being re-encrypted (their new value is already in its final form)
+	Failed  []string // Values that could not be decrypted ("table/id"), e.g. under a key no longer configured
+}
+
+// Done reports whether the pass found nothing stale: every value is bound to its row and every
+// data key is wrapped with the current master key. Retired keys can then be removed and
+// ENCRYPTION_STRICT enabled.
+func (r Result) Done() bool {
+	return r.Rotated == 0 && r.Skipped == 0 && len(r.Failed) == 0
+}
+
+// Rotator works in batches: it re-wraps team data keys not wrapped with the envelope's current
+// master key, and re-encrypts values written before data keys or associated data were used with
+// their team's data key, bound to their row.
+// Once a pass reports nothing stale (Result.Done), retired keys can be removed.
+type Rotator struct {
+	store     Store
+	envelope  *crypto.Envelope
+	batchSize int
+}
+
+// New creates a rotator
+// A non-positive batchSize falls back to DefaultBatchSize
+func New(store Store, envelope *crypto.Envelope, batchSize int) *Rotator {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Rotator{
+		store:     store,
+		envelope:  envelope,
+		batchSize: batchSize,
+	}
+}
+
+// RotateAll re-wraps or re-encrypts every value of every column that is not in its final form.
+// Values that cannot be decrypted are reported in the result, not as an error.
+func (r *Rotator) RotateAll(ctx context.Context) (Result, error) {
+	var result Result
+	for _, column := range Columns {
+		if err := r.rotateColumn(ctx, column, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// rotateColumn re-encrypts one column batch by batch
+func (r *Rotator) rotateColumn(ctx context.Context, column Column, result *Result) error {
+	skip := []string{crypto.BoundDataKeyPrefix}
+	if column == TeamDataKeys {
+		skip = []string{r.envelope.CurrentPrefix()}
+	}
+	afterID := ""
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rows, err := r.store.ListStale(ctx, column, skip, afterID, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		replacements := make([]Replacement, 0, len(rows))
+		for _, row := range rows {
+			if column == TeamDataKeys {
+				wrapped, err := r.envelope.Rewrap(ctx, row.TeamID, row.Ciphertext)
+				if err != nil {
+					log.Printf("keyrotation: cannot re-wrap %s of %s: %v", column, row.ID, err)
+					result.Failed = append(result.Failed, column.Table+"/"+row.ID)
+					continue
+				}
+				replacements = append(replacements, Replacement{ID: row.ID, Old: row.Ciphertext, New: wrapped})
+				continue
+			}
+
+			associatedData := row.AssociatedData(column)
+			plaintext, err := r.envelope.Decrypt(ctx, row.TeamID, row.Ciphertext, associatedData)
+			if err != nil {
+				log.Printf("keyrotation: cannot decrypt %s of %s: %v", column, row.ID, err)
+				result.Failed = append(result.Failed, column.Table+"/"+row.ID)
+				continue
+			}
+			ciphertext, err := r.envelope.Encrypt(ctx, row.TeamID, plaintext, associatedData)
+			if err != nil {
+				return fmt.Errorf("encrypt %s of %s: %w", column, row.ID, err)
+			}
+			replacements = append(replacements, Replacement{ID: row.ID, Old: row.Ciphertext, New: ciphertext})
+		}
+
+		if len(replacements) > 0 {
+			updated, err := r.store.Replace(ctx, column, replacements)
+			if err != nil {
+				return err
+			}
+			result.Rotated += updated
+			result.Skipped += len(replacements) - updated
+		}
+
+		if len(rows) < r.batchSize {
+			return nil
+		}
+		afterID = rows[len(rows)-1].ID
+	}
+}
+
+// Run rotates on every tick until the context is cancelled
+func (r *Rotator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := false
+	for {
+		result, err := r.RotateAll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("keyrotation: %v", err)
+		}
+		if result.Rotated > 0 {
+			log.Printf("keyrotation: re-encrypted %d values", result.Rotated)
+		}
+		if done := err == nil && result.Done(); done != reported {
+			if done {
+				log.Printf("keyrotation: nothing stale; retired keys can be removed and ENCRYPTION_STRICT enabled")
+			}
+			reported = done
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package keyrotation_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/cloudproviders"
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stagely-dev/stagely/internal/keyrotation"
+	"github.com/stagely-dev/stagely/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store keyed by column and row ID
+type memoryStore struct {
+	values    map[keyrotation.Column]map[string]string
+	bindings  map[string][]string // Binding values by row ID
+	teams     map[string]string   // Owning team by row ID
+	onReplace func()              // Called before each Replace (e.g., to simulate concurrent writes)
+	failList  bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		values:   make(map[keyrotation.Column]map[string]string),
+		bindings: make(map[string][]string),
+		teams:    make(map[string]string),
+	}
+}
+
+// put stores a value with the data identifying its row
+func (s *memoryStore) put(column keyrotation.Column, id, ciphertext, teamID string, binding ...string) {
+	if s.values[column] == nil {
+		s.values[column] = make(map[string]string)
+	}
+	s.values[column][id] = ciphertext
+	s.bindings[id] = binding
+	s.teams[id] = teamID
+}
+
+func (s *memoryStore) ListStale(ctx context.Context, column keyrotation.Column, skipPrefixes []string, afterID string, limit int) ([]keyrotation.Row, error) {
+	if s.failList {
+		return nil, errors.New("connection refused")
+	}
+
+	var rows []keyrotation.Row
+	for id, ciphertext := range s.values[column] {
+		if id > afterID && !hasAnyPrefix(ciphertext, skipPrefixes) {
+			rows = append(rows, keyrotation.Row{ID: id, Ciphertext: ciphertext, TeamID: s.teams[id], Binding: s.bindings[id]})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) Replace(ctx context.Context, column keyrotation.Column, replacements []keyrotation.Replacement) (int, error) {
+	if s.onReplace != nil {
+		s.onReplace()
+	}
+
+	updated := 0
+	for _, r := range replacements {
+		if s.values[column][r.ID] == r.Old {
+			s.values[column][r.ID] = r.New
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// dataKeyStore is an in-memory crypto.DataKeyStore
+type dataKeyStore struct {
+	keys map[string]string
+}
+
+func (s *dataKeyStore) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	wrapped, ok := s.keys[teamID]
+	if !ok {
+		return "", crypto.ErrNoDataKey
+	}
+	return wrapped, nil
+}
+
+func (s *dataKeyStore) CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error) {
+	if existing, ok := s.keys[teamID]; ok {
+		return existing, nil
+	}
+	s.keys[teamID] = wrappedKey
+	return wrappedKey, nil
+}
+
+func (s *dataKeyStore) DeleteDataKey(ctx context.Context, teamID string) error {
+	delete(s.keys, teamID)
+	return nil
+}
+
+// keys returns two random master keys
+func keys(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	k1, err := aead.GenerateKey()
+	require.NoError(t, err)
+	k2, err := aead.GenerateKey()
+	require.NoError(t, err)
+	return k1, k2
+}
+
+// keyrings returns a keyring before (k1 active) and after (k2 active, k1 retired) a rotation
+func keyrings(t *testing.T) (*crypto.Keyring, *crypto.Keyring) {
+	t.Helper()
+	k1, k2 := keys(t)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	after, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+	return before, after
+}
+
+func encrypt(t *testing.T, keyring *crypto.Keyring, plaintext string) string {
+	t.Helper()
+	ciphertext, err := keyring.Encrypt(plaintext)
+	require.NoError(t, err)
+	return ciphertext
+}
+
+func newRotator(store keyrotation.Store, keyring *crypto.Keyring, dataKeys crypto.DataKeyStore, batchSize int) *keyrotation.Rotator {
+	return keyrotation.New(store, crypto.NewEnvelope(keyring, keyring, dataKeys, 0), batchSize)
+}
+
+func TestRotateAll_ReencryptsEveryColumn(t *testing.T) {
+	// Given - 5 secrets and 2 credentials under the master key k1, one credential already
+	// under its team's data key
+	before, after := keyrings(t)
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	envelope := crypto.NewEnvelope(after, after, dataKeys, 0)
+	ctx := context.Background()
+	store := newMemoryStore()
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("s-%d", i)
+		store.put(keyrotation.SecretValues, id, encrypt(t, before, fmt.Sprintf("secret-%d", i)), "team-a", "project-1", fmt.Sprintf("KEY_%d", i), "global")
+	}
+	store.put(keyrotation.CloudProviderCredentials, "cp-1", encrypt(t, before, `{"api_token": "a"}`), "team-a", "team-a", "cp-1")
+	store.put(keyrotation.CloudProviderCredentials, "cp-2", encrypt(t, before, `{"api_token": "b"}`), "team-a", "team-a", "cp-2")
+	current, err := envelope.Encrypt(ctx, "team-a", `{"api_token": "c"}`, cloudproviders.AssociatedData("team-a", "cp-3"))
+	require.NoError(t, err)
+	store.put(keyrotation.CloudProviderCredentials, "cp-3", current, "team-a", "team-a", "cp-3")
+
+	// When
+	result, err := keyrotation.New(store, envelope, 2).RotateAll(ctx)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 7, result.Rotated)
+	assert.Zero(t, result.Skipped)
+	assert.Empty(t, result.Failed)
+
+	// Values move to the team's data key, bound to their row
+	cipher := secrets.NewCipher(envelope)
+	for i := 0; i < 5; i++ {
+		ciphertext := store.values[keyrotation.SecretValues][fmt.Sprintf("s-%d", i)]
+		assert.True(t, crypto.IsDataKeyCiphertext(ciphertext))
+		secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: fmt.Sprintf("KEY_%d", i), Scope: "global"}
+		plaintext, err := cipher.Decrypt(ctx, secret, ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("secret-%d", i), plaintext)
+	}
+
+	ciphertext := store.values[keyrotation.CloudProviderCredentials]["cp-1"]
+	assert.True(t, crypto.IsDataKeyCiphertext(ciphertext))
+	plaintext, err := envelope.Decrypt(ctx, "team-a", ciphertext, cloudproviders.AssociatedData("team-a", "cp-1"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"api_token": "a"}`, plaintext)
+	assert.Equal(t, current, store.values[keyrotation.CloudProviderCredentials]["cp-3"], "values under a data key are left alone")
+
+	// A second pass has nothing to do
+	result, err = keyrotation.New(store, envelope, 2).RotateAll(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, result.Rotated)
+}
+
+func TestRotateAll_LegacyCiphertexts(t *testing.T) {
+	// Given - a value from before key IDs, under what is now the retired key k1
+	k1, k2 := keys(t)
+	keyring, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+
+	legacy, err := aead.Encrypt(`{"api_token": "a"}`, k1)
+	require.NoError(t, err)
+	store := newMemoryStore()
+	store.put(keyrotation.CloudProviderCredentials, "cp-1", legacy, "team-a", "team-a", "cp-1")
+
+	// When
+	result, err := newRotator(store, keyring, &dataKeyStore{keys: map[string]string{}}, 0).RotateAll(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Rotated)
+	assert.True(t, crypto.IsDataKeyCiphertext(store.values[keyrotation.CloudProviderCredentials]["cp-1"]))
+}
+
+func TestRotateAll_BindsUnboundValues(t *testing.T) {
+	// Given - credentials of two rows, written before associated data was used
+	_, keyring := keyrings(t)
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	store := newMemoryStore()
+	store.put(keyrotation.CloudProviderCredentials, "cp-1", encrypt(t, keyring, `{"api_token": "a"}`), "team-a", "team-a", "cp-1")
+	store.put(keyrotation.CloudProviderCredentials, "cp-2", encrypt(t, keyring, `{"api_token": "b"}`), "team-a", "team-a", "cp-2")
+
+	// When
+	result, err := newRotator(store, keyring, dataKeys, 0).RotateAll(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Rotated)
+	assert.False(t, result.Done())
+	bound := store.values[keyrotation.CloudProviderCredentials]["cp-1"]
+	assert.True(t, crypto.IsBound(bound))
+
+	// The next pass finds nothing stale
+	result, err = newRotator(store, keyring, dataKeys, 0).RotateAll(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Done())
+
+	// Copied into the other row, the credentials no longer decrypt
+	envelope := crypto.NewEnvelope(keyring, keyring, dataKeys, 0)
+	_, err = envelope.Decrypt(context.Background(), "team-a", bound, cloudproviders.AssociatedData("team-a", "cp-2"))
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestRotateAll_TeamDataKeys(t *testing.T) {
+	// Given - a secret encrypted with a team data key wrapped by k1
+	k1, k2 := keys(t)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	after, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	ctx := context.Background()
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "global"}
+	encrypted, err := secrets.NewCipher(crypto.NewEnvelope(before, before, dataKeys, 0)).Encrypt(ctx, secret, "db-password")
+	require.NoError(t, err)
+	store := newMemoryStore()
+	store.put(keyrotation.TeamDataKeys, "dk-1", dataKeys.keys["team-a"], "team-a", "team-a")
+	store.put(keyrotation.SecretValues, "s-1", encrypted, "team-a", "project-1", "DB_PASSWORD", "global")
+
+	// When
+	result, err := newRotator(store, after, dataKeys, 0).RotateAll(ctx)
+
+	// Then - only the data key is re-wrapped
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Rotated)
+	assert.Equal(t, "k2", crypto.KeyID(store.values[keyrotation.TeamDataKeys]["dk-1"]))
+	assert.Equal(t, encrypted, store.values[keyrotation.SecretValues]["s-1"])
+
+	// The secret still decrypts once k1 is gone
+	k2Only, err := crypto.NewKeyring("k2", map[string][]byte{"k2": k2})
+	require.NoError(t, err)
+	dataKeys.keys["team-a"] = store.values[keyrotation.TeamDataKeys]["dk-1"]
+	plaintext, err := secrets.NewCipher(crypto.NewEnvelope(k2Only, k2Only, dataKeys, 0)).Decrypt(ctx, secret, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+func TestRotateAll_FileSecretStreams(t *testing.T) {
+	// Given - a file secret streamed with a team data key wrapped by k1
+	k1, k2 := keys(t)
+	before, err := crypto.NewKeyring("k1", map[string][]byte{"k1": k1})
+	require.NoError(t, err)
+	after, err := crypto.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	require.NoError(t, err)
+
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	ctx := context.Background()
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "TLS_BUNDLE", Scope: "global"}
+	content := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\n"), 5000)
+	var stream bytes.Buffer
+	w, err := secrets.NewCipher(crypto.NewEnvelope(before, before, dataKeys, 0)).EncryptFile(ctx, secret, &stream)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	store := newMemoryStore()
+	store.put(keyrotation.TeamDataKeys, "dk-1", dataKeys.keys["team-a"], "team-a", "team-a")
+
+	// When - the master key is rotated and k1 removed
+	_, err = newRotator(store, after, dataKeys, 0).RotateAll(ctx)
+	require.NoError(t, err)
+	k2Only, err := crypto.NewKeyring("k2", map[string][]byte{"k2": k2})
+	require.NoError(t, err)
+	dataKeys.keys["team-a"] = store.values[keyrotation.TeamDataKeys]["dk-1"]
+
+	// Then - the stream, which rotation never rewrites, still decrypts
+	r, err := secrets.NewCipher(crypto.NewEnvelope(k2Only, k2Only, dataKeys, 0)).DecryptFile(ctx, secret, bytes.NewReader(stream.Bytes()))
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content, plaintext)
+}
+
+func TestRotateAll_MovesDataKeysToKMS(t *testing.T) {
+	// Given - a data key wrapped by the local keyring, and a new master key held elsewhere
+	local, _ := keyrings(t)
+	kmsKey, _ := keys(t)
+	kms, err := crypto.NewKeyring("m1", map[string][]byte{"m1": kmsKey})
+	require.NoError(t, err)
+
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	ctx := context.Background()
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "global"}
+	encrypted, err := secrets.NewCipher(crypto.NewEnvelope(local, local, dataKeys, 0)).Encrypt(ctx, secret, "db-password")
+	require.NoError(t, err)
+	store := newMemoryStore()
+	store.put(keyrotation.TeamDataKeys, "dk-1", dataKeys.keys["team-a"], "team-a", "team-a")
+
+	// When - the envelope wraps with the KMS and still reads the local keyring
+	result, err := keyrotation.New(store, crypto.NewEnvelope(kms, local, dataKeys, 0), 0).RotateAll(ctx)
+
+	// Then - the local keyring is no longer needed
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Rotated)
+	dataKeys.keys["team-a"] = store.values[keyrotation.TeamDataKeys]["dk-1"]
+	plaintext, err := secrets.NewCipher(crypto.NewEnvelope(kms, nil, dataKeys, 0)).Decrypt(ctx, secret, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "db-password", plaintext)
+}
+
+func TestRotateAll_UndecryptableAndConcurrentWrites(t *testing.T) {
+	// Given - one value under a key that is no longer configured
+	before, after := keyrings(t)
+	stranger, _ := keyrings(t)
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+	envelope := crypto.NewEnvelope(after, after, dataKeys, 0)
+	store := newMemoryStore()
+	store.put(keyrotation.CloudProviderCredentials, "cp-1", encrypt(t, before, "one"), "team-a", "team-a", "cp-1")
+	store.put(keyrotation.CloudProviderCredentials, "cp-2", encrypt(t, stranger, "lost"), "team-a", "team-a", "cp-2")
+	store.put(keyrotation.CloudProviderCredentials, "cp-3", encrypt(t, before, "three"), "team-a", "team-a", "cp-3")
+	stranded := store.values[keyrotation.CloudProviderCredentials]["cp-2"]
+
+	// cp-3 is updated by a user between listing and replacing
+	updatedByUser, err := envelope.Encrypt(context.Background(), "team-a", "three-v2", cloudproviders.AssociatedData("team-a", "cp-3"))
+	require.NoError(t, err)
+	store.onReplace = func() {
+		store.values[keyrotation.CloudProviderCredentials]["cp-3"] = updatedByUser
+	}
+
+	// When
+	result, err := keyrotation.New(store, envelope, 10).RotateAll(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Rotated)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, []string{"cloud_providers/cp-2"}, result.Failed)
+	assert.Equal(t, stranded, store.values[keyrotation.CloudProviderCredentials]["cp-2"])
+	assert.Equal(t, updatedByUser, store.values[keyrotation.CloudProviderCredentials]["cp-3"], "concurrent writes must not be overwritten")
+}
+
+func TestRotateAll_Errors(t *testing.T) {
+	_, after := keyrings(t)
+	dataKeys := &dataKeyStore{keys: map[string]string{}}
+
+	_, err := newRotator(&memoryStore{failList: true}, after, dataKeys, 0).RotateAll(context.Background())
+	assert.ErrorContains(t, err, "connection refused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = newRotator(newMemoryStore(), after, dataKeys, 0).RotateAll(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
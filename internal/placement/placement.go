@@ -0,0 +1,259 @@
+// Package placement provisions VMs across an ordered list of provider/region candidates
+package placement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoCapacity is returned when every candidate was out of quota or capacity, unavailable,
+	// or unable to enforce the VM's firewall
+	ErrNoCapacity = errors.New("no candidate had capacity")
+
+	// ErrNoEdgeProxy is returned when an environment would be placed without the edge proxy
+	// networks (EDGE_PROXY_CIDRS) to restrict its inbound traffic to
+	ErrNoEdgeProxy = errors.New("edge proxy networks are not configured")
+)
+
+// Candidate is a place a VM may be provisioned: a team's cloud_providers row and a region
+type Candidate struct {
+	CloudProviderID string
+	Region          string
+}
+
+// Attempt records a candidate that was tried and why it was skipped
+type Attempt struct {
+	Candidate Candidate
+	Err       error
+}
+
+// Placement describes where a VM landed
+type Placement struct {
+	Candidate  Candidate // Candidate the VM was provisioned on
+	InstanceID string
+	PublicIP   string
+	Skipped    []Attempt // Earlier candidates that failed over
+}
+
+// ProviderSource returns a team's provider bound to a region
+// Implemented by cloudproviders.Registry
+type ProviderSource interface {
+	GetForRegion(ctx context.Context, teamID, providerID, region string) (providers.CloudProvider, error)
+}
+
+// Recorder persists where a VM landed
+type Recorder interface {
+	// RecordBuildJob stores the placement of a build job's VM
+	RecordBuildJob(ctx context.Context, buildJobID string, placement Placement) error
+
+	// RecordEnvironment stores the placement of an environment's VM
+	RecordEnvironment(ctx context.Context, environmentID string, placement Placement) error
+}
+
+// DBRecorder writes placements to the build_jobs and environments tables
+type DBRecorder struct {
+	db *gorm.DB
+}
+
+// NewDBRecorder creates a recorder backed by the given database
+func NewDBRecorder(db *gorm.DB) *DBRecorder {
+	return &DBRecorder{db: db}
+}
+
+// restartMetering is the SET clause that restarts cost metering (see pricing.Meter) when a row
+// of t gets a new VM n.vm_id: the previous VM's cost so far moves to replaced_vms_cost_usd
+// (NULL if it was metered but not priced), and the new VM is priced from its own launch.
+const restartMetering = `
+	replaced_vms_cost_usd = CASE
+		WHEN t.vm_id IS NOT DISTINCT FROM n.vm_id THEN t.replaced_vms_cost_usd
+		WHEN t.cost_final THEN t.estimated_cost_usd
+		WHEN t.vm_launched_at IS NULL THEN t.replaced_vms_cost_usd
+		ELSE t.replaced_vms_cost_usd + t.hourly_rate_usd * GREATEST(EXTRACT(EPOCH FROM NOW() - t.vm_launched_at), 0) / 3600
+	END,
+	vm_launched_at = CASE WHEN t.vm_id IS NOT DISTINCT FROM n.vm_id THEN t.vm_launched_at END,
+	vm_instance_type = CASE WHEN t.vm_id IS NOT DISTINCT FROM n.vm_id THEN t.vm_instance_type END,
+	vm_spot = CASE WHEN t.vm_id IS NOT DISTINCT FROM n.vm_id THEN t.vm_spot END,
+	hourly_rate_usd = CASE WHEN t.vm_id IS NOT DISTINCT FROM n.vm_id THEN t.hourly_rate_usd END,
+	cost_final = t.cost_final AND t.vm_id IS NOT DISTINCT FROM n.vm_id`
+
+// RecordBuildJob stores the VM ID, provider and region of a build job
+// A new VM restarts cost metering (see restartMetering).
+func (r *DBRecorder) RecordBuildJob(ctx context.Context, buildJobID string, placement Placement) error {
+	err := r.db.WithContext(ctx).Exec(`
+		UPDATE build_jobs t SET vm_id = n.vm_id, cloud_provider_id = ?, vm_region = ?,`+restartMetering+`
+		FROM (SELECT ?::varchar AS vm_id) n
+		WHERE t.id = ?
+	`, placement.Candidate.CloudProviderID, placement.Candidate.Region, placement.InstanceID, buildJobID).Error
+	if err != nil {
+		return fmt.Errorf("record build job placement: %w", err)
+	}
+	return nil
+}
+
+// RecordEnvironment stores the VM ID, IP, provider and region of an environment
+// A new VM restarts cost metering (see restartMetering).
+func (r *DBRecorder) RecordEnvironment(ctx context.Context, environmentID string, placement Placement) error {
+	err := r.db.WithContext(ctx).Exec(`
+		UPDATE environments t SET vm_id = n.vm_id, vm_ip = NULLIF(?, '')::inet, cloud_provider_id = ?, vm_region = ?,`+restartMetering+`
+		FROM (SELECT ?::varchar AS vm_id) n
+		WHERE t.id = ?
+	`, placement.PublicIP, placement.Candidate.CloudProviderID, placement.Candidate.Region, placement.InstanceID, environmentID).Error
+	if err != nil {
+		return fmt.Errorf("record environment placement: %w", err)
+	}
+	return nil
+}
+
+// Placer provisions VMs on the first candidate with capacity
+type Placer struct {
+	source     ProviderSource
+	recorder   Recorder
+	proxyCIDRs []string // Networks the edge proxy connects from (config.EdgeConfig)
+}
+
+// New creates a placer whose environment VMs only accept inbound traffic from proxyCIDRs
+func New(source ProviderSource, recorder Recorder, proxyCIDRs []string) *Placer {
+	return &Placer{source: source, recorder: recorder, proxyCIDRs: proxyCIDRs}
+}
+
+// Place creates an instance on the first candidate that accepts it.
+// Candidates whose provider cannot be loaded (e.g., inactive, unhealthy or undecryptable) or
+// that fail with ErrQuotaExceeded or ErrInsufficientCapacity are skipped; any other launch
+// error stops placement. spec.Region is replaced by each candidate's region, and the workload
+// tags are stamped on the instance; the team, project and creator are required. Without a
+// spec.Firewall, the instance accepts no inbound traffic. Since every instance gets a firewall,
+// candidates whose provider cannot enforce one (see providers.FirewallManager) are skipped too.
+// If the VM was launched but never became ready, the placement is returned with the error
+// so the caller can record and clean up the instance.
+func (p *Placer) Place(ctx context.Context, workload providers.WorkloadTags, candidates []Candidate, spec providers.InstanceSpec) (Placement, error) {
+	var placement Placement
+	if len(candidates) == 0 {
+		return placement, errors.New("no placement candidates")
+	}
+	if err := requireTags(workload, providers.TagTeam, providers.TagProject, providers.TagCreatedBy); err != nil {
+		return placement, err
+	}
+	teamID := workload.TeamID
+	spec = withWorkloadTags(spec, workload)
+	if spec.Firewall == nil {
+		spec.Firewall = &providers.Firewall{}
+	}
+
+	for _, candidate := range candidates {
+		provider, err := p.source.GetForRegion(ctx, teamID, candidate.CloudProviderID, candidate.Region)
+		if err != nil {
+			if ctx.Err() != nil {
+				return placement, ctx.Err()
+			}
+			log.Printf("placement: %s/%s is unavailable, trying next candidate: %v", candidate.CloudProviderID, candidate.Region, err)
+			placement.Skipped = append(placement.Skipped, Attempt{Candidate: candidate, Err: fmt.Errorf("cloud provider %s: %w", candidate.CloudProviderID, err)})
+			continue
+		}
+		if _, ok := providers.AsFirewallManager(provider); !ok {
+			err := fmt.Errorf("%w: %s cannot enforce firewalls", providers.ErrNotSupported, provider.Name())
+			log.Printf("placement: %s/%s is skipped, trying next candidate: %v", candidate.CloudProviderID, candidate.Region, err)
+			placement.Skipped = append(placement.Skipped, Attempt{Candidate: candidate, Err: err})
+			continue
+		}
+
+		spec.Region = candidate.Region
+		instanceID, publicIP, err := provider.CreateInstance(ctx, spec)
+		if err == nil || instanceID != "" {
+			placement.Candidate = candidate
+			placement.InstanceID = instanceID
+			placement.PublicIP = publicIP
+			return placement, err
+		}
+
+		if !errors.Is(err, providers.ErrQuotaExceeded) && !errors.Is(err, providers.ErrInsufficientCapacity) {
+			return placement, fmt.Errorf("create instance on %s/%s: %w", candidate.CloudProviderID, candidate.Region, err)
+		}
+
+		log.Printf("placement: %s/%s has no capacity, trying next candidate: %v", candidate.CloudProviderID, candidate.Region, err)
+		placement.Skipped = append(placement.Skipped, Attempt{Candidate: candidate, Err: err})
+	}
+
+	reasons := make([]error, 0, len(placement.Skipped))
+	for _, attempt := range placement.Skipped {
+		reasons = append(reasons, attempt.Err)
+	}
+	return placement, fmt.Errorf("%w (%d candidates): %w", ErrNoCapacity, len(candidates), errors.Join(reasons...))
+}
+
+// PlaceBuildJob places a build job's VM and records where it landed.
+// Build VMs must name their build job and workflow run and carry an expiry, after which the
+// reconciler terminates them.
+func (p *Placer) PlaceBuildJob(ctx context.Context, workload providers.WorkloadTags, candidates []Candidate, spec providers.InstanceSpec) (Placement, error) {
+	if err := requireTags(workload, providers.TagBuildJob, providers.TagWorkflowRun, providers.TagExpiresAt); err != nil {
+		return Placement{}, err
+	}
+	return p.placeAndRecord(ctx, workload, candidates, spec, func(placement Placement) error {
+		return p.recorder.RecordBuildJob(ctx, workload.BuildJobID, placement)
+	})
+}
+
+// PlaceEnvironment places an environment's VM and records where it landed.
+// An expiry is optional, since environments live until they are torn down. Inbound traffic
+// is only allowed from the edge proxy; without its networks nothing is placed.
+func (p *Placer) PlaceEnvironment(ctx context.Context, workload providers.WorkloadTags, candidates []Candidate, spec providers.InstanceSpec) (Placement, error) {
+	if err := requireTags(workload, providers.TagEnvironment); err != nil {
+		return Placement{}, err
+	}
+	if len(p.proxyCIDRs) == 0 {
+		return Placement{}, ErrNoEdgeProxy
+	}
+
+	firewall := providers.EdgeFirewall(p.proxyCIDRs)
+	if spec.Firewall != nil {
+		firewall.Outbound = spec.Firewall.Outbound
+	}
+	spec.Firewall = firewall
+	return p.placeAndRecord(ctx, workload, candidates, spec, func(placement Placement) error {
+		return p.recorder.RecordEnvironment(ctx, workload.EnvironmentID, placement)
+	})
+}
+
+// placeAndRecord records any placement that produced an instance, even one that failed to
+// become ready, so the VM stays tracked (and is not mistaken for an orphan)
+func (p *Placer) placeAndRecord(ctx context.Context, workload providers.WorkloadTags, candidates []Candidate, spec providers.InstanceSpec, record func(Placement) error) (Placement, error) {
+	placement, err := p.Place(ctx, workload, candidates, spec)
+	if placement.InstanceID == "" {
+		return placement, err
+	}
+
+	if recordErr := record(placement); recordErr != nil {
+		return placement, errors.Join(err, recordErr)
+	}
+	return placement, err
+}
+
+// requireTags checks that the workload sets the given tags
+func requireTags(workload providers.WorkloadTags, keys ...string) error {
+	tags := workload.Tags()
+	for _, key := range keys {
+		if tags[key] == "" {
+			return fmt.Errorf("%w: workload tag %q is required", providers.ErrInvalidInput, key)
+		}
+	}
+	return nil
+}
+
+// withWorkloadTags returns spec with the given workload tags stamped over the caller's tags,
+// since Core knows which team and workload a VM belongs to better than the caller does
+func withWorkloadTags(spec providers.InstanceSpec, workload providers.WorkloadTags) providers.InstanceSpec {
+	tags := make(map[string]string, len(spec.Tags)+7)
+	for k, v := range spec.Tags {
+		tags[k] = v
+	}
+	for k, v := range workload.Tags() {
+		tags[k] = v
+	}
+	spec.Tags = tags
+	return spec
+}
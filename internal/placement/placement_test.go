@@ -0,0 +1,316 @@
+package placement
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSource hands out one mock provider per provider/region pair
+type staticSource struct {
+	providers   map[Candidate]*providers.MockProvider
+	errs        map[string]error // Provider ID -> error returned instead of a provider
+	noFirewalls map[string]bool  // Provider IDs whose provider cannot enforce firewalls
+}
+
+func (s *staticSource) GetForRegion(ctx context.Context, teamID, providerID, region string) (providers.CloudProvider, error) {
+	if err := s.errs[providerID]; err != nil {
+		return nil, err
+	}
+	provider := s.providers[Candidate{CloudProviderID: providerID, Region: region}]
+	if s.noFirewalls[providerID] {
+		return provider, nil
+	}
+	return &firewalledProvider{provider}, nil
+}
+
+// firewalledProvider gives a mock provider the firewall capability, as AWS has
+type firewalledProvider struct {
+	*providers.MockProvider
+}
+
+func (p *firewalledProvider) EnsureFirewall(ctx context.Context, region string, firewall providers.Firewall) (string, error) {
+	return "sg-mock", nil
+}
+
+type memoryRecorder struct {
+	buildJobs    map[string]Placement
+	environments map[string]Placement
+}
+
+func (r *memoryRecorder) RecordBuildJob(ctx context.Context, buildJobID string, placement Placement) error {
+	r.buildJobs[buildJobID] = placement
+	return nil
+}
+
+func (r *memoryRecorder) RecordEnvironment(ctx context.Context, environmentID string, placement Placement) error {
+	r.environments[environmentID] = placement
+	return nil
+}
+
+var (
+	awsEast = Candidate{CloudProviderID: "cp-aws", Region: "us-east-1"}
+	awsWest = Candidate{CloudProviderID: "cp-aws", Region: "us-west-2"}
+	hetzner = Candidate{CloudProviderID: "cp-hetzner", Region: "fsn1"}
+)
+
+var spec = providers.InstanceSpec{
+	Size:         providers.SizeSmall,
+	Architecture: providers.ArchAMD64,
+	Region:       "ignored",
+}
+
+// workload is the team, project and creator every placement needs
+var workload = providers.WorkloadTags{TeamID: "team-a", ProjectID: "project-1", CreatedBy: "user-1"}
+
+func buildJob(id string) providers.WorkloadTags {
+	job := workload
+	job.BuildJobID = id
+	job.WorkflowRunID = "run-1"
+	job.ExpiresAt = time.Unix(1900000000, 0)
+	return job
+}
+
+func environment(id string) providers.WorkloadTags {
+	env := workload
+	env.EnvironmentID = id
+	return env
+}
+
+func setup() (*Placer, *staticSource, *memoryRecorder) {
+	source := &staticSource{providers: map[Candidate]*providers.MockProvider{
+		awsEast: providers.NewMockProvider(),
+		awsWest: providers.NewMockProvider(),
+		hetzner: providers.NewMockProvider(),
+	}}
+	recorder := &memoryRecorder{buildJobs: map[string]Placement{}, environments: map[string]Placement{}}
+	return New(source, recorder, []string{"203.0.113.10/32"}), source, recorder
+}
+
+func TestPlace_FirstCandidate(t *testing.T) {
+	placer, source, _ := setup()
+
+	placement, err := placer.Place(context.Background(), workload, []Candidate{awsEast, awsWest}, spec)
+	require.NoError(t, err)
+	assert.Equal(t, awsEast, placement.Candidate)
+	assert.NotEmpty(t, placement.InstanceID)
+	assert.NotEmpty(t, placement.PublicIP)
+	assert.Empty(t, placement.Skipped)
+
+	calls := source.providers[awsEast].Calls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "us-east-1", calls[0].Spec.Region)
+	assert.Equal(t, map[string]string{
+		providers.TagTeam:      "team-a",
+		providers.TagProject:   "project-1",
+		providers.TagCreatedBy: "user-1",
+	}, calls[0].Spec.Tags)
+	assert.Zero(t, source.providers[awsWest].CallCount(providers.MockOpCreateInstance))
+}
+
+func TestPlace_FailsOverOnQuotaAndCapacity(t *testing.T) {
+	placer, source, _ := setup()
+	source.providers[awsEast].FailNth(providers.MockOpCreateInstance, 1, providers.ErrInsufficientCapacity)
+	source.providers[awsWest].FailNth(providers.MockOpCreateInstance, 1, providers.ErrQuotaExceeded)
+
+	placement, err := placer.Place(context.Background(), workload, []Candidate{awsEast, awsWest, hetzner}, spec)
+	require.NoError(t, err)
+	assert.Equal(t, hetzner, placement.Candidate)
+	require.Len(t, placement.Skipped, 2)
+	assert.Equal(t, awsEast, placement.Skipped[0].Candidate)
+	assert.ErrorIs(t, placement.Skipped[1].Err, providers.ErrQuotaExceeded)
+
+	assert.Equal(t, "fsn1", source.providers[hetzner].Calls()[0].Spec.Region)
+}
+
+func TestPlace_AllCandidatesExhausted(t *testing.T) {
+	placer, source, _ := setup()
+	source.providers[awsEast].FailNth(providers.MockOpCreateInstance, 1, providers.ErrInsufficientCapacity)
+	source.providers[awsWest].FailNth(providers.MockOpCreateInstance, 1, providers.ErrInsufficientCapacity)
+
+	_, err := placer.Place(context.Background(), workload, []Candidate{awsEast, awsWest}, spec)
+	assert.ErrorIs(t, err, ErrNoCapacity)
+	assert.ErrorIs(t, err, providers.ErrInsufficientCapacity)
+}
+
+func TestPlace_StopsOnOtherErrors(t *testing.T) {
+	placer, source, _ := setup()
+	source.providers[awsEast].FailNth(providers.MockOpCreateInstance, 1, providers.ErrInvalidCredentials)
+
+	_, err := placer.Place(context.Background(), workload, []Candidate{awsEast, awsWest}, spec)
+	assert.ErrorIs(t, err, providers.ErrInvalidCredentials)
+	assert.Zero(t, source.providers[awsWest].CallCount(providers.MockOpCreateInstance))
+
+	_, err = placer.Place(context.Background(), workload, nil, spec)
+	assert.Error(t, err)
+}
+
+func TestPlace_SkipsUnavailableProviders(t *testing.T) {
+	// Given - the AWS row cannot be loaded
+	placer, source, _ := setup()
+	unhealthy := errors.New("cloud provider is unhealthy")
+	source.errs = map[string]error{"cp-aws": unhealthy}
+
+	// When
+	placement, err := placer.Place(context.Background(), workload, []Candidate{awsEast, hetzner}, spec)
+
+	// Then - placement moves on and records why
+	require.NoError(t, err)
+	assert.Equal(t, hetzner, placement.Candidate)
+	require.Len(t, placement.Skipped, 1)
+	assert.Equal(t, awsEast, placement.Skipped[0].Candidate)
+	assert.ErrorIs(t, placement.Skipped[0].Err, unhealthy)
+
+	// When - no candidate is left
+	source.providers[hetzner].FailNth(providers.MockOpCreateInstance, 2, providers.ErrQuotaExceeded)
+	_, err = placer.Place(context.Background(), workload, []Candidate{awsEast, hetzner}, spec)
+
+	// Then - every reason is reported
+	assert.ErrorIs(t, err, ErrNoCapacity)
+	assert.ErrorIs(t, err, unhealthy)
+	assert.ErrorIs(t, err, providers.ErrQuotaExceeded)
+}
+
+func TestPlaceBuildJobAndEnvironment_RecordPlacement(t *testing.T) {
+	placer, source, recorder := setup()
+	source.providers[awsEast].FailNth(providers.MockOpCreateInstance, 1, providers.ErrQuotaExceeded)
+	ctx := context.Background()
+
+	placement, err := placer.PlaceBuildJob(ctx, buildJob("job-1"), []Candidate{awsEast, awsWest}, spec)
+	require.NoError(t, err)
+	assert.Equal(t, placement, recorder.buildJobs["job-1"])
+	assert.Equal(t, awsWest, recorder.buildJobs["job-1"].Candidate)
+	jobTags := source.providers[awsWest].Calls()[0].Spec.Tags
+	assert.Equal(t, "job-1", jobTags[providers.TagBuildJob])
+	assert.Equal(t, "run-1", jobTags[providers.TagWorkflowRun])
+	assert.Equal(t, "1900000000", jobTags[providers.TagExpiresAt])
+
+	placement, err = placer.PlaceEnvironment(ctx, environment("env-1"), []Candidate{awsEast, awsWest}, spec)
+	require.NoError(t, err)
+	assert.Equal(t, awsEast, recorder.environments["env-1"].Candidate)
+	assert.Equal(t, placement.PublicIP, recorder.environments["env-1"].PublicIP)
+	tags := source.providers[awsEast].Calls()[1].Spec.Tags
+	assert.Equal(t, "env-1", tags[providers.TagEnvironment])
+	assert.Equal(t, "team-a", tags[providers.TagTeam])
+
+	// Nothing is recorded when no VM was created
+	source.providers[awsEast].FailNth(providers.MockOpCreateInstance, 3, providers.ErrQuotaExceeded)
+	_, err = placer.PlaceBuildJob(ctx, buildJob("job-2"), []Candidate{awsEast}, spec)
+	assert.ErrorIs(t, err, ErrNoCapacity)
+	assert.NotContains(t, recorder.buildJobs, "job-2")
+}
+
+func TestPlace_RequiresCompleteWorkloadTags(t *testing.T) {
+	placer, source, _ := setup()
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		place func() error
+	}{
+		{"no project", func() error {
+			_, err := placer.Place(ctx, providers.WorkloadTags{TeamID: "team-a", CreatedBy: "user-1"}, []Candidate{awsEast}, spec)
+			return err
+		}},
+		{"no creator", func() error {
+			_, err := placer.Place(ctx, providers.WorkloadTags{TeamID: "team-a", ProjectID: "project-1"}, []Candidate{awsEast}, spec)
+			return err
+		}},
+		{"build job without expiry", func() error {
+			job := buildJob("job-1")
+			job.ExpiresAt = time.Time{}
+			_, err := placer.PlaceBuildJob(ctx, job, []Candidate{awsEast}, spec)
+			return err
+		}},
+		{"build job without workflow run", func() error {
+			job := buildJob("job-1")
+			job.WorkflowRunID = ""
+			_, err := placer.PlaceBuildJob(ctx, job, []Candidate{awsEast}, spec)
+			return err
+		}},
+		{"environment without ID", func() error {
+			_, err := placer.PlaceEnvironment(ctx, workload, []Candidate{awsEast}, spec)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.place(), providers.ErrInvalidInput)
+		})
+	}
+	assert.Zero(t, source.providers[awsEast].CallCount(providers.MockOpCreateInstance))
+}
+
+func TestPlace_Firewalls(t *testing.T) {
+	placer, source, _ := setup()
+	ctx := context.Background()
+	egress := []providers.FirewallRule{{Protocol: providers.ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}}}
+
+	// When
+	_, err := placer.PlaceBuildJob(ctx, buildJob("job-1"), []Candidate{awsEast}, spec)
+	require.NoError(t, err)
+	envSpec := spec
+	envSpec.Firewall = &providers.Firewall{
+		Inbound:  []providers.FirewallRule{{Protocol: providers.ProtocolTCP, CIDRs: []string{"0.0.0.0/0"}}},
+		Outbound: egress,
+	}
+	_, err = placer.PlaceEnvironment(ctx, environment("env-1"), []Candidate{awsEast}, envSpec)
+	require.NoError(t, err)
+
+	// Then - build VMs accept no inbound traffic, environments only the edge proxy's
+	calls := source.providers[awsEast].Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, &providers.Firewall{}, calls[0].Spec.Firewall)
+	assert.Equal(t, &providers.Firewall{
+		Inbound:  []providers.FirewallRule{{Protocol: providers.ProtocolTCP, CIDRs: []string{"203.0.113.10/32"}}},
+		Outbound: egress,
+	}, calls[1].Spec.Firewall)
+}
+
+func TestPlace_SkipsProvidersWithoutFirewalls(t *testing.T) {
+	// Given - the Hetzner candidate cannot enforce firewalls
+	placer, source, recorder := setup()
+	source.noFirewalls = map[string]bool{"cp-hetzner": true}
+	ctx := context.Background()
+
+	// When
+	placement, err := placer.PlaceEnvironment(ctx, environment("env-1"), []Candidate{hetzner, awsEast}, spec)
+
+	// Then - the environment lands on AWS, and nothing is launched without its firewall
+	require.NoError(t, err)
+	assert.Equal(t, awsEast, placement.Candidate)
+	require.Len(t, placement.Skipped, 1)
+	assert.ErrorIs(t, placement.Skipped[0].Err, providers.ErrNotSupported)
+	assert.Zero(t, source.providers[hetzner].CallCount(providers.MockOpCreateInstance))
+	assert.Equal(t, placement, recorder.environments["env-1"])
+
+	// When - no candidate can enforce firewalls
+	_, err = placer.PlaceBuildJob(ctx, buildJob("job-1"), []Candidate{hetzner}, spec)
+
+	// Then
+	assert.ErrorIs(t, err, ErrNoCapacity)
+	assert.ErrorIs(t, err, providers.ErrNotSupported)
+	assert.Zero(t, source.providers[hetzner].CallCount(providers.MockOpCreateInstance))
+	assert.Empty(t, recorder.buildJobs)
+}
+
+func TestPlaceEnvironment_RequiresEdgeProxy(t *testing.T) {
+	// Given - no EDGE_PROXY_CIDRS
+	source := &staticSource{providers: map[Candidate]*providers.MockProvider{awsEast: providers.NewMockProvider()}}
+	recorder := &memoryRecorder{buildJobs: map[string]Placement{}, environments: map[string]Placement{}}
+	placer := New(source, recorder, nil)
+
+	// When
+	_, err := placer.PlaceEnvironment(context.Background(), environment("env-1"), []Candidate{awsEast}, spec)
+
+	// Then - nothing is launched
+	assert.ErrorIs(t, err, ErrNoEdgeProxy)
+	assert.Zero(t, source.providers[awsEast].CallCount(providers.MockOpCreateInstance))
+	assert.Empty(t, recorder.environments)
+}
@@ -0,0 +1,291 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"gorm.io/gorm"
+)
+
+// Usage kinds
+const (
+	KindEnvironment = "environment"
+	KindBuildJob    = "build_job"
+)
+
+// Usage is the metered VM of one environment or build job
+type Usage struct {
+	Kind             string // KindEnvironment or KindBuildJob
+	ID               string // Environment or build job ID
+	TeamID           string
+	ProjectID        string
+	ProjectName      string
+	CloudProviderID  string
+	ProviderType     string
+	Region           string
+	InstanceID       string     // vm_id
+	InstanceType     string     // Reported by the provider on first metering
+	Spot             bool       // Whether the VM runs on spot capacity
+	LaunchedAt       *time.Time // nil until first metered
+	EndedAt          *time.Time // Environment terminated_at or build job completed_at (nil while running)
+	HourlyRateUSD    *float64   // nil until first priced
+	ReplacedCostUSD  float64    // Cost of the VMs InstanceID replaced
+	ReplacedUnpriced bool       // Whether a replaced VM could not be priced (ReplacedCostUSD is then unknown)
+	CostUSD          float64    // Accrued cost, replaced VMs included (unknown while HourlyRateUSD is nil or ReplacedUnpriced)
+}
+
+// Store lists VMs that still accrue cost and persists what they cost so far
+type Store interface {
+	// ListOpen returns every environment and build job with a VM whose cost is not final
+	ListOpen(ctx context.Context) ([]Usage, error)
+
+	// SaveUsage stores the metering inputs and accrued cost; final stops further metering
+	SaveUsage(ctx context.Context, usage Usage, final bool) error
+}
+
+// DBStore reads and writes usage in the environments and build_jobs tables
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a store backed by the given database
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// environmentEndedAt is when an environment's VM stopped accruing cost
+const environmentEndedAt = `COALESCE(e.terminated_at, CASE WHEN e.vm_status = 'terminated' THEN e.updated_at END)`
+
+// usageQuery selects usage rows from both tables, filtered by the given conditions
+// (written against e/b and p, the environment or build job and its project)
+func usageQuery(environmentWhere, buildJobWhere string) string {
+	return `
+		SELECT 'environment' AS kind, e.id, p.team_id, p.id AS project_id, p.name AS project_name,
+			cp.id AS cloud_provider_id, cp.provider_type, COALESCE(e.vm_region, cp.region, '') AS region,
+			e.vm_id AS instance_id, COALESCE(e.vm_instance_type, '') AS instance_type,
+			COALESCE(e.vm_spot, false) AS spot, e.vm_launched_at AS launched_at,
+			` + environmentEndedAt + ` AS ended_at,
+			e.hourly_rate_usd, COALESCE(e.replaced_vms_cost_usd, 0) AS replaced_cost_usd,
+			e.replaced_vms_cost_usd IS NULL AS replaced_unpriced, COALESCE(e.estimated_cost_usd, 0) AS cost_usd
+		FROM environments e
+		JOIN projects p ON p.id = e.project_id
+		JOIN cloud_providers cp ON cp.id = COALESCE(e.cloud_provider_id, p.cloud_provider_id)
+		WHERE e.vm_id IS NOT NULL AND ` + environmentWhere + `
+		UNION ALL
+		SELECT 'build_job' AS kind, b.id, p.team_id, p.id AS project_id, p.name AS project_name,
+			cp.id AS cloud_provider_id, cp.provider_type, COALESCE(b.vm_region, cp.region, '') AS region,
+			b.vm_id AS instance_id, COALESCE(b.vm_instance_type, '') AS instance_type,
+			COALESCE(b.vm_spot, false) AS spot, b.vm_launched_at AS launched_at,
+			b.completed_at AS ended_at,
+			b.hourly_rate_usd, COALESCE(b.replaced_vms_cost_usd, 0) AS replaced_cost_usd,
+			b.replaced_vms_cost_usd IS NULL AS replaced_unpriced, COALESCE(b.estimated_cost_usd, 0) AS cost_usd
+		FROM build_jobs b
+		JOIN workflow_runs w ON w.id = b.workflow_run_id
+		JOIN environments e ON e.id = w.environment_id
+		JOIN projects p ON p.id = e.project_id
+		JOIN cloud_providers cp ON cp.id = b.cloud_provider_id
+		WHERE b.vm_id IS NOT NULL AND ` + buildJobWhere
+}
+
+// ListOpen returns every environment and build job with a VM whose cost is not final
+func (s *DBStore) ListOpen(ctx context.Context) ([]Usage, error) {
+	var usages []Usage
+	err := s.db.WithContext(ctx).Raw(usageQuery("NOT e.cost_final", "NOT b.cost_final")).Scan(&usages).Error
+	if err != nil {
+		return nil, fmt.Errorf("query open usage: %w", err)
+	}
+	return usages, nil
+}
+
+// SaveUsage stores the metering inputs and accrued cost of an environment or build job.
+// The cost of an unpriced row (or of one whose replaced VMs were unpriced) is stored as NULL
+// (unknown), not as zero.
+func (s *DBStore) SaveUsage(ctx context.Context, usage Usage, final bool) error {
+	table := "environments"
+	if usage.Kind == KindBuildJob {
+		table = "build_jobs"
+	}
+
+	var cost *float64
+	if usage.HourlyRateUSD != nil && !usage.ReplacedUnpriced {
+		cost = &usage.CostUSD
+	}
+
+	err := s.db.WithContext(ctx).Exec(`
+		UPDATE `+table+`
+		SET vm_launched_at = ?, vm_instance_type = NULLIF(?, ''), vm_spot = ?, hourly_rate_usd = ?,
+			estimated_cost_usd = ?, cost_final = ?, cost_updated_at = NOW()
+		WHERE id = ?
+	`, usage.LaunchedAt, usage.InstanceType, usage.Spot, usage.HourlyRateUSD,
+		cost, final, usage.ID).Error
+	if err != nil {
+		return fmt.Errorf("save %s cost: %w", usage.Kind, err)
+	}
+	return nil
+}
+
+// ProviderSource returns a team's provider bound to a region
+// Implemented by cloudproviders.Registry
+type ProviderSource interface {
+	GetForRegion(ctx context.Context, teamID, providerID, region string) (providers.CloudProvider, error)
+}
+
+// MeterResult summarizes a single metering pass
+type MeterResult struct {
+	Metered   []string // Environments and build jobs whose cost was updated
+	Finalized []string // Rows whose cost will no longer change
+	Skipped   []string // Rows that could not be priced this pass
+	Unpriced  []string // Rows missing from the price table (or gone before being priced); their cost is unknown
+}
+
+// Meter periodically accrues VM cost into environments and build_jobs.
+// A VM accrues from its launch until the row ends, at its on-demand or spot rate; time spent
+// stopped is billed as running, since the meter does not observe stops. When a row's VM is
+// replaced, metering restarts for the new VM (see placement.DBRecorder) and the cost of the
+// previous ones is carried in ReplacedCostUSD.
+type Meter struct {
+	source ProviderSource
+	table  *Table
+	store  Store
+	now    func() time.Time
+}
+
+// NewMeter creates a meter that prices VMs with table
+func NewMeter(source ProviderSource, table *Table, store Store) *Meter {
+	return &Meter{
+		source: source,
+		table:  table,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// MeterAll updates the accrued cost of every open VM.
+// Rows that cannot be priced are reported in the result, not as an error.
+func (m *Meter) MeterAll(ctx context.Context) (MeterResult, error) {
+	var result MeterResult
+
+	usages, err := m.store.ListOpen(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	now := m.now()
+	for _, usage := range usages {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		if usage.HourlyRateUSD == nil || usage.LaunchedAt == nil {
+			if err := m.resolve(ctx, &usage); err != nil {
+				m.skip(ctx, &result, usage, err)
+				continue
+			}
+		}
+
+		end, final := now, false
+		if usage.EndedAt != nil {
+			end, final = *usage.EndedAt, true
+		}
+		usage.CostUSD = usage.ReplacedCostUSD + Accrued(*usage.HourlyRateUSD, *usage.LaunchedAt, end)
+
+		if err := m.store.SaveUsage(ctx, usage, final); err != nil {
+			log.Printf("pricing: %v", err)
+			result.Skipped = append(result.Skipped, usage.ID)
+			continue
+		}
+		result.Metered = append(result.Metered, usage.ID)
+		if final {
+			result.Finalized = append(result.Finalized, usage.ID)
+		}
+		if usage.ReplacedUnpriced {
+			result.Unpriced = append(result.Unpriced, usage.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// resolve asks the provider when and on what the VM was launched (unless already known)
+// and looks up its rate
+func (m *Meter) resolve(ctx context.Context, usage *Usage) error {
+	if usage.LaunchedAt == nil || usage.InstanceType == "" {
+		provider, err := m.source.GetForRegion(ctx, usage.TeamID, usage.CloudProviderID, usage.Region)
+		if err != nil {
+			return err
+		}
+
+		status, err := provider.GetInstanceStatus(ctx, usage.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		launchedAt := status.LaunchedAt
+		usage.InstanceType = status.InstanceType
+		usage.Spot = status.Spot
+		usage.LaunchedAt = &launchedAt
+	}
+
+	price, err := m.table.Lookup(usage.ProviderType, usage.Region, usage.InstanceType)
+	if err != nil {
+		return err
+	}
+
+	rate := price.Hourly(usage.Spot)
+	usage.HourlyRateUSD = &rate
+	return nil
+}
+
+// skip records a row that could not be priced.
+//
+// A row missing from the price table keeps what the provider reported and stays open, so it is
+// priced (including time already accrued) once the table covers it. A finished row whose VM is
+// gone before it was ever observed can never be priced: it is finalized with an unknown cost
+// rather than retried forever. Both are reported as unpriced, never as free.
+func (m *Meter) skip(ctx context.Context, result *MeterResult, usage Usage, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownPrice):
+		log.Printf("pricing: %s %s is not billed: %v (add it to prices.json)", usage.Kind, usage.ID, err)
+		if saveErr := m.store.SaveUsage(ctx, usage, false); saveErr != nil {
+			log.Printf("pricing: %v", saveErr)
+		}
+		result.Unpriced = append(result.Unpriced, usage.ID)
+
+	case usage.EndedAt != nil && errors.Is(err, providers.ErrInstanceNotFound):
+		if saveErr := m.store.SaveUsage(ctx, usage, true); saveErr != nil {
+			log.Printf("pricing: %v", saveErr)
+			break
+		}
+		log.Printf("pricing: %s %s is not billed: VM %s was gone before it could be priced", usage.Kind, usage.ID, usage.InstanceID)
+		result.Finalized = append(result.Finalized, usage.ID)
+		result.Unpriced = append(result.Unpriced, usage.ID)
+		return
+
+	default:
+		log.Printf("pricing: cannot price %s %s (%s/%s): %v", usage.Kind, usage.ID, usage.ProviderType, usage.InstanceID, err)
+	}
+
+	result.Skipped = append(result.Skipped, usage.ID)
+}
+
+// Run meters on every tick until the context is cancelled
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.MeterAll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("pricing: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// singleSource hands out the same provider for every team and region
+type singleSource struct {
+	provider providers.CloudProvider
+}
+
+func (s *singleSource) GetForRegion(ctx context.Context, teamID, providerID, region string) (providers.CloudProvider, error) {
+	return s.provider, nil
+}
+
+type memoryStore struct {
+	usages map[string]Usage
+	final  map[string]bool
+}
+
+func (s *memoryStore) ListOpen(ctx context.Context) ([]Usage, error) {
+	var usages []Usage
+	for id, usage := range s.usages {
+		if !s.final[id] {
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+func (s *memoryStore) SaveUsage(ctx context.Context, usage Usage, final bool) error {
+	s.usages[usage.ID] = usage
+	s.final[usage.ID] = final
+	return nil
+}
+
+// setupMeter returns a meter over a mock Hetzner provider whose instances report real server types
+func setupMeter(t *testing.T) (*Meter, *providers.MockProvider, *memoryStore) {
+	t.Helper()
+
+	provider := providers.NewMockProvider()
+	provider.SetCatalog(providers.DefaultCatalog("hetzner"))
+
+	table, err := ParseTable([]byte(`{"hetzner": {"*": {"cx22": {"on_demand": 0.01}, "cx42": {"on_demand": 0.04}}}}`))
+	require.NoError(t, err)
+
+	store := &memoryStore{usages: map[string]Usage{}, final: map[string]bool{}}
+	return NewMeter(&singleSource{provider: provider}, table, store), provider, store
+}
+
+func launch(t *testing.T, provider *providers.MockProvider, size string) string {
+	t.Helper()
+	instanceID, _, err := provider.CreateInstance(context.Background(), providers.InstanceSpec{
+		Size: size, Architecture: providers.ArchAMD64, Region: "fsn1",
+	})
+	require.NoError(t, err)
+	return instanceID
+}
+
+func TestMeter_AccruesUntilTermination(t *testing.T) {
+	meter, provider, store := setupMeter(t)
+	ctx := context.Background()
+
+	store.usages["env-1"] = Usage{
+		Kind: KindEnvironment, ID: "env-1", ProviderType: "hetzner", Region: "fsn1",
+		InstanceID: launch(t, provider, providers.SizeSmall),
+	}
+	store.usages["job-1"] = Usage{
+		Kind: KindBuildJob, ID: "job-1", ProviderType: "hetzner", Region: "fsn1",
+		InstanceID: launch(t, provider, providers.SizeLarge),
+	}
+
+	// When - two hours in
+	start := time.Now()
+	meter.now = func() time.Time { return start.Add(2 * time.Hour) }
+	result, err := meter.MeterAll(ctx)
+
+	// Then
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"env-1", "job-1"}, result.Metered)
+	assert.Empty(t, result.Finalized)
+
+	env := store.usages["env-1"]
+	assert.Equal(t, "cx22", env.InstanceType)
+	require.NotNil(t, env.HourlyRateUSD)
+	assert.Equal(t, 0.01, *env.HourlyRateUSD)
+	assert.InDelta(t, 0.02, env.CostUSD, 0.001)
+	assert.InDelta(t, 0.08, store.usages["job-1"].CostUSD, 0.001)
+
+	// When - the build job completed after three hours; the rate is not looked up again
+	endedAt := store.usages["job-1"].LaunchedAt.Add(3 * time.Hour)
+	job := store.usages["job-1"]
+	job.EndedAt = &endedAt
+	store.usages["job-1"] = job
+	require.NoError(t, provider.TerminateInstance(ctx, job.InstanceID))
+	statusCalls := provider.CallCount(providers.MockOpGetInstanceStatus)
+
+	meter.now = func() time.Time { return start.Add(5 * time.Hour) }
+	result, err = meter.MeterAll(ctx)
+
+	// Then - the job is finalized at three hours, the environment keeps accruing
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1"}, result.Finalized)
+	assert.InDelta(t, 0.12, store.usages["job-1"].CostUSD, 0.001)
+	assert.InDelta(t, 0.05, store.usages["env-1"].CostUSD, 0.001)
+	assert.Equal(t, statusCalls, provider.CallCount(providers.MockOpGetInstanceStatus))
+
+	result, err = meter.MeterAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env-1"}, result.Metered)
+}
+
+func TestMeter_SpotRate(t *testing.T) {
+	provider := providers.NewMockProvider()
+	provider.SetCatalog(providers.DefaultCatalog("aws"))
+	instanceID, _, err := provider.CreateInstance(context.Background(), providers.InstanceSpec{
+		Size: providers.SizeMedium, Architecture: providers.ArchAMD64, Region: "us-east-1", SpotInstance: true,
+	})
+	require.NoError(t, err)
+
+	store := &memoryStore{usages: map[string]Usage{
+		"env-1": {Kind: KindEnvironment, ID: "env-1", ProviderType: "aws", Region: "us-east-1", InstanceID: instanceID},
+	}, final: map[string]bool{}}
+	meter := NewMeter(&singleSource{provider: provider}, DefaultTable(), store)
+
+	_, err = meter.MeterAll(context.Background())
+	require.NoError(t, err)
+
+	usage := store.usages["env-1"]
+	assert.True(t, usage.Spot)
+	assert.Equal(t, "c5.xlarge", usage.InstanceType)
+	price, err := DefaultTable().Lookup("aws", "us-east-1", "c5.xlarge")
+	require.NoError(t, err)
+	assert.Equal(t, price.Spot, *usage.HourlyRateUSD)
+}
+
+func TestMeter_SkipsUnpriceable(t *testing.T) {
+	meter, provider, store := setupMeter(t)
+	ctx := context.Background()
+	endedAt := time.Now()
+
+	store.usages["env-unknown-type"] = Usage{
+		Kind: KindEnvironment, ID: "env-unknown-type", ProviderType: "hetzner", Region: "fsn1",
+		InstanceID: launch(t, provider, providers.SizeMedium),
+	}
+	store.usages["env-gone"] = Usage{
+		Kind: KindEnvironment, ID: "env-gone", ProviderType: "hetzner", Region: "fsn1", InstanceID: "mock-404",
+	}
+	store.usages["job-gone"] = Usage{
+		Kind: KindBuildJob, ID: "job-gone", ProviderType: "hetzner", Region: "fsn1", InstanceID: "mock-405", EndedAt: &endedAt,
+	}
+
+	// When
+	result, err := meter.MeterAll(ctx)
+
+	// Then - a finished job whose VM is gone is finalized with an unknown cost, never as free
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"env-unknown-type", "env-gone"}, result.Skipped)
+	assert.ElementsMatch(t, []string{"env-unknown-type", "job-gone"}, result.Unpriced)
+	assert.Equal(t, []string{"job-gone"}, result.Finalized)
+	assert.Empty(t, result.Metered)
+	assert.Nil(t, store.usages["job-gone"].HourlyRateUSD)
+	assert.False(t, store.final["env-gone"])
+
+	// Then - the unpriced environment stays open with what the provider reported
+	env := store.usages["env-unknown-type"]
+	assert.False(t, store.final["env-unknown-type"])
+	assert.Equal(t, "cx32", env.InstanceType)
+	require.NotNil(t, env.LaunchedAt)
+	assert.Nil(t, env.HourlyRateUSD)
+
+	// When - the VM is gone by the time its type is added to the price table
+	require.NoError(t, provider.TerminateInstance(ctx, env.InstanceID))
+	terminatedAt := env.LaunchedAt.Add(2 * time.Hour)
+	env.EndedAt = &terminatedAt
+	store.usages["env-unknown-type"] = env
+	meter.table, err = ParseTable([]byte(`{"hetzner": {"*": {"cx32": {"on_demand": 0.02}}}}`))
+	require.NoError(t, err)
+	result, err = meter.MeterAll(ctx)
+
+	// Then - it is priced for its whole lifetime
+	require.NoError(t, err)
+	assert.Contains(t, result.Finalized, "env-unknown-type")
+	assert.InDelta(t, 0.04, store.usages["env-unknown-type"].CostUSD, 0.001)
+}
+
+func TestMeter_ReplacedVM(t *testing.T) {
+	// Given - environments moved to a new VM; the recorder restarted metering and kept what
+	// the previous VMs cost, unknown for env-2
+	meter, provider, store := setupMeter(t)
+	store.usages["env-1"] = Usage{
+		Kind: KindEnvironment, ID: "env-1", ProviderType: "hetzner", Region: "fsn1",
+		InstanceID: launch(t, provider, providers.SizeSmall), ReplacedCostUSD: 0.5,
+	}
+	store.usages["env-2"] = Usage{
+		Kind: KindEnvironment, ID: "env-2", ProviderType: "hetzner", Region: "fsn1",
+		InstanceID: launch(t, provider, providers.SizeSmall), ReplacedUnpriced: true,
+	}
+
+	// When - two hours into the new VMs
+	start := time.Now()
+	meter.now = func() time.Time { return start.Add(2 * time.Hour) }
+	result, err := meter.MeterAll(context.Background())
+
+	// Then - the new VM is priced from its own launch, on top of the replaced ones
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"env-1", "env-2"}, result.Metered)
+	assert.Equal(t, []string{"env-2"}, result.Unpriced)
+	env := store.usages["env-1"]
+	assert.Equal(t, "cx22", env.InstanceType)
+	assert.WithinDuration(t, start, *env.LaunchedAt, time.Minute)
+	assert.InDelta(t, 0.52, env.CostUSD, 0.001)
+}
+
+func TestMeter_StoppedVMsAreBilledAsRunning(t *testing.T) {
+	// Given - two VMs, one of them stopped
+	meter, provider, store := setupMeter(t)
+	ctx := context.Background()
+	running := launch(t, provider, providers.SizeSmall)
+	stopped := launch(t, provider, providers.SizeSmall)
+	require.NoError(t, provider.StopInstance(ctx, stopped))
+	store.usages["env-1"] = Usage{Kind: KindEnvironment, ID: "env-1", ProviderType: "hetzner", Region: "fsn1", InstanceID: running}
+	store.usages["env-2"] = Usage{Kind: KindEnvironment, ID: "env-2", ProviderType: "hetzner", Region: "fsn1", InstanceID: stopped}
+
+	// When
+	start := time.Now()
+	meter.now = func() time.Time { return start.Add(2 * time.Hour) }
+	_, err := meter.MeterAll(ctx)
+
+	// Then - both accrue from launch: the meter does not observe stops
+	require.NoError(t, err)
+	assert.InDelta(t, 0.02, store.usages["env-1"].CostUSD, 0.001)
+	assert.InDelta(t, 0.02, store.usages["env-2"].CostUSD, 0.001)
+}
@@ -0,0 +1,90 @@
+// Package pricing estimates what Stagely VMs cost and accrues it into environments and build_jobs
+package pricing
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownPrice is returned when the price table has no entry for an instance type
+var ErrUnknownPrice = errors.New("unknown price")
+
+// AnyRegion is the region key for providers that price an instance type the same everywhere
+const AnyRegion = "*"
+
+//go:embed prices.json
+var bundledPrices []byte
+
+// Price is the hourly list price of an instance type in USD
+type Price struct {
+	OnDemand float64 `json:"on_demand"`      // 0 for local containers (docker)
+	Spot     float64 `json:"spot,omitempty"` // 0 if the provider has no spot market
+}
+
+// Hourly returns the hourly rate for the given capacity market
+// Providers without spot pricing are charged the on-demand rate.
+func (p Price) Hourly(spot bool) float64 {
+	if spot && p.Spot > 0 {
+		return p.Spot
+	}
+	return p.OnDemand
+}
+
+// Table holds prices by provider type, region and instance type
+type Table struct {
+	prices map[string]map[string]map[string]Price // Provider type -> region -> instance type -> price
+}
+
+// ParseTable decodes a price table:
+//
+//	{"aws": {"us-east-1": {"c5.xlarge": {"on_demand": 0.17, "spot": 0.068}}}, "hetzner": {"*": {...}}}
+func ParseTable(data []byte) (*Table, error) {
+	var prices map[string]map[string]map[string]Price
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("decode price table: %w", err)
+	}
+
+	for providerType, regions := range prices {
+		for region, instanceTypes := range regions {
+			for instanceType, price := range instanceTypes {
+				if price.OnDemand < 0 || price.Spot < 0 {
+					return nil, fmt.Errorf("price table: %s/%s/%s: on_demand and spot must be non-negative", providerType, region, instanceType)
+				}
+			}
+		}
+	}
+	return &Table{prices: prices}, nil
+}
+
+// DefaultTable returns the price table bundled with Stagely (approximate list prices)
+func DefaultTable() *Table {
+	table, err := ParseTable(bundledPrices)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+// Lookup returns the price of an instance type in a region, falling back to the provider's
+// AnyRegion entry
+func (t *Table) Lookup(providerType, region, instanceType string) (Price, error) {
+	regions := t.prices[providerType]
+	if price, ok := regions[region][instanceType]; ok {
+		return price, nil
+	}
+	if price, ok := regions[AnyRegion][instanceType]; ok {
+		return price, nil
+	}
+	return Price{}, fmt.Errorf("%w: %s/%s/%s", ErrUnknownPrice, providerType, region, instanceType)
+}
+
+// Accrued returns the cost of running at hourlyRate from launchedAt until end
+func Accrued(hourlyRate float64, launchedAt, end time.Time) float64 {
+	if !end.After(launchedAt) {
+		return 0
+	}
+	return hourlyRate * end.Sub(launchedAt).Hours()
+}
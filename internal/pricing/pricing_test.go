@@ -0,0 +1,90 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable_Lookup(t *testing.T) {
+	table, err := ParseTable([]byte(`{
+		"aws": {"us-east-1": {"c5.xlarge": {"on_demand": 0.17, "spot": 0.068}}},
+		"hetzner": {"*": {"cx22": {"on_demand": 0.008}}, "ash": {"cx22": {"on_demand": 0.009}}}
+	}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		providerType string
+		region       string
+		instanceType string
+		spot         bool
+		expected     float64
+		expectError  bool
+	}{
+		{"aws on-demand", "aws", "us-east-1", "c5.xlarge", false, 0.17, false},
+		{"aws spot", "aws", "us-east-1", "c5.xlarge", true, 0.068, false},
+		{"aws unknown region", "aws", "eu-west-1", "c5.xlarge", false, 0, true},
+		{"any region", "hetzner", "fsn1", "cx22", false, 0.008, false},
+		{"region overrides any region", "hetzner", "ash", "cx22", false, 0.009, false},
+		{"no spot market", "hetzner", "fsn1", "cx22", true, 0.008, false},
+		{"unknown instance type", "hetzner", "fsn1", "cx99", false, 0, true},
+		{"unknown provider", "gcp", "us-central1", "e2-small", false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := table.Lookup(tt.providerType, tt.region, tt.instanceType)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrUnknownPrice)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, price.Hourly(tt.spot))
+		})
+	}
+}
+
+func TestParseTable_Errors(t *testing.T) {
+	_, err := ParseTable([]byte(`not json`))
+	assert.Error(t, err)
+
+	_, err = ParseTable([]byte(`{"aws": {"us-east-1": {"c5.xlarge": {"on_demand": -0.17}}}}`))
+	assert.ErrorContains(t, err, "c5.xlarge")
+}
+
+func TestDefaultTable_CoversDefaultCatalogs(t *testing.T) {
+	table := DefaultTable()
+	regions := map[string][]string{"digitalocean": {"nyc3"}, "docker": {"local"}, "hetzner": {"fsn1"}}
+	// Every priced AWS region must price the whole catalog
+	for region := range table.prices["aws"] {
+		regions["aws"] = append(regions["aws"], region)
+	}
+
+	for providerType, providerRegions := range regions {
+		catalog := providers.DefaultCatalog(providerType)
+		for _, region := range providerRegions {
+			for _, size := range catalog.Sizes() {
+				for _, arch := range []string{providers.ArchAMD64, providers.ArchARM64} {
+					instanceType, err := catalog.Lookup(size, arch)
+					if err != nil {
+						continue
+					}
+					_, err = table.Lookup(providerType, region, instanceType.Name)
+					assert.NoError(t, err, "%s/%s/%s/%s", providerType, region, size, arch)
+				}
+			}
+		}
+	}
+}
+
+func TestAccrued(t *testing.T) {
+	launchedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.InDelta(t, 0.34, Accrued(0.17, launchedAt, launchedAt.Add(2*time.Hour)), 1e-9)
+	assert.InDelta(t, 0.0425, Accrued(0.17, launchedAt, launchedAt.Add(15*time.Minute)), 1e-9)
+	assert.Zero(t, Accrued(0.17, launchedAt, launchedAt.Add(-time.Hour)))
+}
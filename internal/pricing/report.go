@@ -0,0 +1,211 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProjectCost is the cost of one project
+type ProjectCost struct {
+	ProjectID   string  `json:"project_id"`
+	ProjectName string  `json:"project_name"`
+	Cost        float64 `json:"cost"`
+}
+
+// ProviderCost is the cost on one provider type
+type ProviderCost struct {
+	ProviderType string  `json:"provider_type"`
+	Cost         float64 `json:"cost"`
+}
+
+// TeamCost is the cost of one team
+type TeamCost struct {
+	TeamID string  `json:"team_id"`
+	Cost   float64 `json:"cost"`
+}
+
+// CostBreakdown is one day of costs (matches the web CostAnalytics page)
+type CostBreakdown struct {
+	Date       string         `json:"date"` // UTC day, YYYY-MM-DD
+	TotalCost  float64        `json:"total_cost"`
+	ByProject  []ProjectCost  `json:"by_project"`
+	ByProvider []ProviderCost `json:"by_provider"`
+}
+
+// Report rolls up VM cost over a time window
+type Report struct {
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	TotalCost  float64         `json:"total_cost"`
+	ByTeam     []TeamCost      `json:"by_team"`
+	ByProject  []ProjectCost   `json:"by_project"`
+	ByProvider []ProviderCost  `json:"by_provider"`
+	Daily      []CostBreakdown `json:"daily"`
+}
+
+// ReportStore lists metered usage for reporting
+type ReportStore interface {
+	// ListUsage returns every metered VM of a team (all teams if teamID is "")
+	// that ran at some point in [from, to)
+	ListUsage(ctx context.Context, teamID string, from, to time.Time) ([]Usage, error)
+}
+
+// ListUsage returns every metered VM of a team (all teams if teamID is "") that ran in [from, to)
+func (s *DBStore) ListUsage(ctx context.Context, teamID string, from, to time.Time) ([]Usage, error) {
+	where := func(alias, ended string) string {
+		return alias + `.vm_launched_at IS NOT NULL AND ` + alias + `.hourly_rate_usd IS NOT NULL
+			AND ` + alias + `.vm_launched_at < @to AND (` + ended + ` IS NULL OR ` + ended + ` >= @from)
+			AND (@team = '' OR p.team_id::text = @team)`
+	}
+
+	var usages []Usage
+	err := s.db.WithContext(ctx).Raw(
+		usageQuery(where("e", environmentEndedAt), where("b", "b.completed_at")),
+		map[string]any{"team": teamID, "from": from, "to": to},
+	).Scan(&usages).Error
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	return usages, nil
+}
+
+// Reporter builds cost reports for finance and the CostAnalytics page
+type Reporter struct {
+	store ReportStore
+	now   func() time.Time
+}
+
+// NewReporter creates a reporter over the given usage store
+func NewReporter(store ReportStore) *Reporter {
+	return &Reporter{store: store, now: time.Now}
+}
+
+// Report rolls up cost for a team (all teams if teamID is "") over [from, to).
+// VMs that are still running are counted until now.
+func (r *Reporter) Report(ctx context.Context, teamID string, from, to time.Time) (Report, error) {
+	if !to.After(from) {
+		return Report{}, fmt.Errorf("report window is empty: %s - %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	usages, err := r.store.ListUsage(ctx, teamID, from, to)
+	if err != nil {
+		return Report{}, err
+	}
+	return BuildReport(usages, from, to, r.now()), nil
+}
+
+// BuildReport splits the cost of each usage across the UTC days of [from, to)
+// and rolls it up by team, project and provider type
+func BuildReport(usages []Usage, from, to, now time.Time) Report {
+	report := Report{From: from, To: to}
+	byTeam := map[string]float64{}
+	byProject := map[string]float64{}
+	byProvider := map[string]float64{}
+	projectNames := map[string]string{}
+
+	from, to = from.UTC(), to.UTC()
+	for day := truncateDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		start, end := maxTime(day, from), minTime(day.AddDate(0, 0, 1), to)
+		breakdown := CostBreakdown{Date: day.Format("2006-01-02")}
+		dayProject := map[string]float64{}
+		dayProvider := map[string]float64{}
+
+		for _, usage := range usages {
+			cost := usageCost(usage, start, end, now)
+			if cost == 0 {
+				continue
+			}
+			breakdown.TotalCost += cost
+			dayProject[usage.ProjectID] += cost
+			dayProvider[usage.ProviderType] += cost
+			byTeam[usage.TeamID] += cost
+			projectNames[usage.ProjectID] = usage.ProjectName
+		}
+
+		breakdown.ByProject = projectCosts(dayProject, projectNames)
+		breakdown.ByProvider = providerCosts(dayProvider)
+		for id, cost := range dayProject {
+			byProject[id] += cost
+		}
+		for providerType, cost := range dayProvider {
+			byProvider[providerType] += cost
+		}
+		report.TotalCost += breakdown.TotalCost
+		report.Daily = append(report.Daily, breakdown)
+	}
+
+	for teamID, cost := range byTeam {
+		report.ByTeam = append(report.ByTeam, TeamCost{TeamID: teamID, Cost: cost})
+	}
+	sort.Slice(report.ByTeam, func(i, j int) bool {
+		return costBefore(report.ByTeam[i].Cost, report.ByTeam[j].Cost, report.ByTeam[i].TeamID, report.ByTeam[j].TeamID)
+	})
+	report.ByProject = projectCosts(byProject, projectNames)
+	report.ByProvider = providerCosts(byProvider)
+	return report
+}
+
+// usageCost returns what a usage cost within [start, end)
+func usageCost(usage Usage, start, end, now time.Time) float64 {
+	if usage.LaunchedAt == nil || usage.HourlyRateUSD == nil {
+		return 0
+	}
+
+	stoppedAt := now
+	if usage.EndedAt != nil {
+		stoppedAt = *usage.EndedAt
+	}
+	return Accrued(*usage.HourlyRateUSD, maxTime(*usage.LaunchedAt, start), minTime(stoppedAt, end))
+}
+
+// projectCosts flattens per-project costs, most expensive first
+func projectCosts(costs map[string]float64, names map[string]string) []ProjectCost {
+	result := make([]ProjectCost, 0, len(costs))
+	for id, cost := range costs {
+		result = append(result, ProjectCost{ProjectID: id, ProjectName: names[id], Cost: cost})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return costBefore(result[i].Cost, result[j].Cost, result[i].ProjectID, result[j].ProjectID)
+	})
+	return result
+}
+
+// providerCosts flattens per-provider costs, most expensive first
+func providerCosts(costs map[string]float64) []ProviderCost {
+	result := make([]ProviderCost, 0, len(costs))
+	for providerType, cost := range costs {
+		result = append(result, ProviderCost{ProviderType: providerType, Cost: cost})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return costBefore(result[i].Cost, result[j].Cost, result[i].ProviderType, result[j].ProviderType)
+	})
+	return result
+}
+
+// costBefore orders by descending cost, then by key for stable output
+func costBefore(costA, costB float64, keyA, keyB string) bool {
+	if costA != costB {
+		return costA > costB
+	}
+	return keyA < keyB
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
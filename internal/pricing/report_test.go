@@ -0,0 +1,98 @@
+package pricing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+type staticUsage []Usage
+
+func (s staticUsage) ListUsage(ctx context.Context, teamID string, from, to time.Time) ([]Usage, error) {
+	var usages []Usage
+	for _, usage := range s {
+		if teamID == "" || usage.TeamID == teamID {
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+var (
+	day1 = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 = day1.AddDate(0, 0, 1)
+	day3 = day1.AddDate(0, 0, 2)
+)
+
+var usages = staticUsage{
+	// 22:00 on day 1 until 02:00 on day 2, $1/h
+	{
+		Kind: KindEnvironment, ID: "env-1", TeamID: "team-a", ProjectID: "proj-api", ProjectName: "API",
+		ProviderType: "aws", LaunchedAt: ptr(day1.Add(22 * time.Hour)), EndedAt: ptr(day2.Add(2 * time.Hour)),
+		HourlyRateUSD: ptr(1.0),
+	},
+	// Day 2 from 10:00, still running, $0.5/h
+	{
+		Kind: KindBuildJob, ID: "job-1", TeamID: "team-a", ProjectID: "proj-web", ProjectName: "Web",
+		ProviderType: "hetzner", LaunchedAt: ptr(day2.Add(10 * time.Hour)), HourlyRateUSD: ptr(0.5),
+	},
+	// Another team, 1 hour on day 1
+	{
+		Kind: KindEnvironment, ID: "env-2", TeamID: "team-b", ProjectID: "proj-b", ProjectName: "B",
+		ProviderType: "aws", LaunchedAt: ptr(day1.Add(12 * time.Hour)), EndedAt: ptr(day1.Add(13 * time.Hour)),
+		HourlyRateUSD: ptr(3.0),
+	},
+	// Never metered
+	{Kind: KindEnvironment, ID: "env-3", TeamID: "team-a", ProjectID: "proj-api", ProviderType: "aws"},
+}
+
+func TestReporter_TeamReport(t *testing.T) {
+	reporter := NewReporter(usages)
+	reporter.now = func() time.Time { return day2.Add(14 * time.Hour) }
+
+	report, err := reporter.Report(context.Background(), "team-a", day1, day3)
+	require.NoError(t, err)
+
+	// $2 + $2 for env-1, 4h * $0.5 for job-1
+	assert.InDelta(t, 6.0, report.TotalCost, 1e-9)
+	require.Len(t, report.Daily, 2)
+	assert.Equal(t, "2026-03-01", report.Daily[0].Date)
+	assert.InDelta(t, 2.0, report.Daily[0].TotalCost, 1e-9)
+	assert.InDelta(t, 4.0, report.Daily[1].TotalCost, 1e-9)
+	require.Len(t, report.Daily[1].ByProvider, 2)
+	assert.Equal(t, "aws", report.Daily[1].ByProvider[0].ProviderType)
+
+	require.Len(t, report.ByProject, 2)
+	assert.Equal(t, ProjectCost{ProjectID: "proj-api", ProjectName: "API", Cost: 4.0}, report.ByProject[0])
+	assert.Equal(t, "Web", report.ByProject[1].ProjectName)
+	assert.Equal(t, []TeamCost{{TeamID: "team-a", Cost: 6.0}}, report.ByTeam)
+}
+
+func TestReporter_AllTeamsAndPartialWindow(t *testing.T) {
+	reporter := NewReporter(usages)
+	reporter.now = func() time.Time { return day2.Add(14 * time.Hour) }
+
+	// 23:00 on day 1 until 01:00 on day 2
+	report, err := reporter.Report(context.Background(), "", day1.Add(23*time.Hour), day2.Add(time.Hour))
+	require.NoError(t, err)
+
+	require.Len(t, report.Daily, 2)
+	assert.InDelta(t, 1.0, report.Daily[0].TotalCost, 1e-9)
+	assert.InDelta(t, 1.0, report.Daily[1].TotalCost, 1e-9)
+	assert.Equal(t, []TeamCost{{TeamID: "team-a", Cost: 2.0}}, report.ByTeam)
+
+	report, err = reporter.Report(context.Background(), "", day1, day2)
+	require.NoError(t, err)
+	assert.Equal(t, "team-b", report.ByTeam[0].TeamID)
+	assert.InDelta(t, 5.0, report.TotalCost, 1e-9)
+
+	_, err = reporter.Report(context.Background(), "", day2, day1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// canonicalOwnerID is the AWS account that publishes official Ubuntu images
+const canonicalOwnerID = "099720109477"
+
+// Ubuntu 22.04 LTS image name patterns per architecture
+var amiNamePatterns = map[string]string{
+	ArchAMD64: "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*",
+	ArchARM64: "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-*",
+}
+
+// EC2 architecture names per architecture
+var ec2ArchMap = map[string]string{
+	ArchAMD64: "x86_64",
+	ArchARM64: "arm64",
+}
+
+// amiResolver finds the AMI to launch for a region and architecture.
+// Pinned images (from cloud_providers.config) take precedence; otherwise the latest
+// Canonical Ubuntu 22.04 image is looked up via DescribeImages and cached per region.
+type amiResolver struct {
+	client EC2API
+	region string            // Region bare "arch" pins apply to
+	pinned map[string]string // "region/arch" or "arch" -> AMI ID
+	cache  map[string]string // "region/arch" -> AMI ID
+	mu     sync.Mutex
+}
+
+// newAMIResolver creates a resolver backed by the given EC2 client.
+// Pins keyed by architecture alone apply to the provider's own region only, since AMI IDs are regional.
+func newAMIResolver(client EC2API, region string, pinned map[string]string) *amiResolver {
+	return &amiResolver{
+		client: client,
+		region: region,
+		pinned: pinned,
+		cache:  make(map[string]string),
+	}
+}
+
+// Resolve returns the AMI ID for the given region and architecture.
+func (r *amiResolver) Resolve(ctx context.Context, region, arch string) (string, error) {
+	namePattern, ok := amiNamePatterns[arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture: %s", arch)
+	}
+
+	key := region + "/" + arch
+	if ami, ok := r.pinned[key]; ok {
+		return ami, nil
+	}
+	if ami, ok := r.pinned[arch]; ok && region == r.region {
+		return ami, nil
+	}
+
+	r.mu.Lock()
+	ami, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return ami, nil
+	}
+
+	result, err := r.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		Owners: []string{canonicalOwnerID},
+		Filters: []types.Filter{
+			{Name: aws.String("name"), Values: []string{namePattern}},
+			{Name: aws.String("architecture"), Values: []string{ec2ArchMap[arch]}},
+			{Name: aws.String("state"), Values: []string{"available"}},
+		},
+	}, withRegion(region))
+	if err != nil {
+		return "", classifyAWSError("describe images", err)
+	}
+
+	ami = latestImage(result.Images)
+	if ami == "" {
+		return "", fmt.Errorf("no %s image found in region %s", arch, region)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = ami
+	r.mu.Unlock()
+
+	return ami, nil
+}
+
+// latestImage returns the ID of the most recently created image (empty if none).
+func latestImage(images []types.Image) string {
+	var latestID, latestDate string
+	for _, img := range images {
+		// CreationDate is ISO 8601, so lexical order is chronological order
+		if date := aws.ToString(img.CreationDate); latestID == "" || date > latestDate {
+			latestID = aws.ToString(img.ImageId)
+			latestDate = date
+		}
+	}
+	return latestID
+}
+
+// withRegion overrides the client's region for a single EC2 call.
+func withRegion(region string) func(*ec2.Options) {
+	return func(o *ec2.Options) {
+		if region != "" {
+			o.Region = region
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describeImagesByRegion returns a DescribeImages stub that serves images per region and counts calls.
+func describeImagesByRegion(images map[string][]types.Image, calls *int) func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	return func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+		*calls++
+		opts := ec2.Options{}
+		for _, fn := range optFns {
+			fn(&opts)
+		}
+		return &ec2.DescribeImagesOutput{Images: images[opts.Region]}, nil
+	}
+}
+
+func TestAMIResolver_ResolvesPerRegion(t *testing.T) {
+	calls := 0
+	client := &mockEC2Client{
+		describeImagesFunc: describeImagesByRegion(map[string][]types.Image{
+			"us-east-1": {
+				{ImageId: aws.String("ami-use1-old"), CreationDate: aws.String("2024-01-01T00:00:00.000Z")},
+				{ImageId: aws.String("ami-use1-new"), CreationDate: aws.String("2025-03-01T00:00:00.000Z")},
+			},
+			"eu-west-1": {
+				{ImageId: aws.String("ami-euw1"), CreationDate: aws.String("2025-02-01T00:00:00.000Z")},
+			},
+		}, &calls),
+	}
+	resolver := newAMIResolver(client, "us-east-1", nil)
+	ctx := context.Background()
+
+	ami, err := resolver.Resolve(ctx, "us-east-1", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-use1-new", ami)
+
+	ami, err = resolver.Resolve(ctx, "eu-west-1", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-euw1", ami)
+
+	// Second lookup for the same region is served from cache
+	_, err = resolver.Resolve(ctx, "eu-west-1", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestAMIResolver_Filters(t *testing.T) {
+	client := &mockEC2Client{
+		describeImagesFunc: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+			assert.Equal(t, []string{canonicalOwnerID}, params.Owners)
+
+			filters := make(map[string][]string)
+			for _, f := range params.Filters {
+				filters[aws.ToString(f.Name)] = f.Values
+			}
+			assert.Equal(t, []string{"arm64"}, filters["architecture"])
+			assert.Equal(t, []string{amiNamePatterns[ArchARM64]}, filters["name"])
+			assert.Equal(t, []string{"available"}, filters["state"])
+
+			return &ec2.DescribeImagesOutput{
+				Images: []types.Image{{ImageId: aws.String("ami-arm"), CreationDate: aws.String("2025-01-01T00:00:00.000Z")}},
+			}, nil
+		},
+	}
+
+	ami, err := newAMIResolver(client, "us-east-1", nil).Resolve(context.Background(), "us-west-2", ArchARM64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-arm", ami)
+}
+
+func TestAMIResolver_PinnedImages(t *testing.T) {
+	calls := 0
+	client := &mockEC2Client{
+		describeImagesFunc: describeImagesByRegion(nil, &calls),
+	}
+	resolver := newAMIResolver(client, "us-east-1", map[string]string{
+		"amd64":           "ami-golden",
+		"eu-west-1/amd64": "ami-golden-eu",
+	})
+	ctx := context.Background()
+
+	ami, err := resolver.Resolve(ctx, "us-east-1", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-golden", ami)
+
+	ami, err = resolver.Resolve(ctx, "eu-west-1", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-golden-eu", ami)
+
+	assert.Zero(t, calls)
+}
+
+func TestAMIResolver_BarePinOnlyInOwnRegion(t *testing.T) {
+	calls := 0
+	client := &mockEC2Client{
+		describeImagesFunc: describeImagesByRegion(map[string][]types.Image{
+			"us-west-2": {{ImageId: aws.String("ami-usw2"), CreationDate: aws.String("2025-01-01T00:00:00.000Z")}},
+		}, &calls),
+	}
+	resolver := newAMIResolver(client, "us-east-1", map[string]string{"amd64": "ami-golden"})
+
+	// The us-east-1 image ID does not exist in us-west-2, so the latest image is looked up there
+	ami, err := resolver.Resolve(context.Background(), "us-west-2", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-usw2", ami)
+	assert.Equal(t, 1, calls)
+}
+
+func TestAMIResolver_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := newAMIResolver(&mockEC2Client{}, "us-east-1", nil).Resolve(ctx, "us-east-1", "invalid")
+	assert.Error(t, err)
+
+	// No matching images in region
+	_, err = newAMIResolver(&mockEC2Client{}, "us-east-1", nil).Resolve(ctx, "ap-south-2", ArchAMD64)
+	assert.Error(t, err)
+
+	failing := &mockEC2Client{
+		describeImagesFunc: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+			return nil, errors.New("api error")
+		},
+	}
+	_, err = newAMIResolver(failing, "us-east-1", nil).Resolve(ctx, "us-east-1", ArchAMD64)
+	assert.Error(t, err)
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -15,27 +14,25 @@ import (
 	"github.com/aws/smithy-go"
 )
 
-// Instance type mapping: size + architecture -> EC2 instance type
-var instanceTypeMap = map[string]map[string]string{
+// Default EC2 catalog: size + architecture -> EC2 instance type
+var awsCatalog = mustCatalog(map[string]map[string]InstanceType{
 	SizeSmall: {
-		ArchAMD64: "t3.small",
-		ArchARM64: "t4g.small",
+		ArchAMD64: {Name: "t3.small", VCPUs: 2, MemoryGB: 2},
+		ArchARM64: {Name: "t4g.small", VCPUs: 2, MemoryGB: 2},
 	},
 	SizeMedium: {
-		ArchAMD64: "c5.xlarge",
-		ArchARM64: "c6g.xlarge",
+		ArchAMD64: {Name: "c5.xlarge", VCPUs: 4, MemoryGB: 8},
+		ArchARM64: {Name: "c6g.xlarge", VCPUs: 4, MemoryGB: 8},
 	},
 	SizeLarge: {
-		ArchAMD64: "c5.2xlarge",
-		ArchARM64: "c6g.2xlarge",
+		ArchAMD64: {Name: "c5.2xlarge", VCPUs: 8, MemoryGB: 16},
+		ArchARM64: {Name: "c6g.2xlarge", VCPUs: 8, MemoryGB: 16},
 	},
-}
-
-// AMI mapping: architecture -> Ubuntu 22.04 LTS AMI ID (us-east-1)
-var amiMap = map[string]string{
-	ArchAMD64: "ami-0c7217cdde317cfec", // Ubuntu 22.04 LTS AMD64
-	ArchARM64: "ami-0c7a8e3f05e4e5f0c", // Ubuntu 22.04 LTS ARM64
-}
+	SizeXLarge: {
+		ArchAMD64: {Name: "c5.4xlarge", VCPUs: 16, MemoryGB: 32},
+		ArchARM64: {Name: "c6g.4xlarge", VCPUs: 16, MemoryGB: 32},
+	},
+})
 
 // EC2API defines the EC2 operations used by the provider (interface for mocking)
 type EC2API interface {
@@ -43,16 +40,69 @@ type EC2API interface {
 	RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
 	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
 	TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error)
+	CreateSecurityGroup(ctx context.Context, params *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error)
+	DeleteSecurityGroup(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error)
+	AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	AuthorizeSecurityGroupEgress(ctx context.Context, params *ec2.AuthorizeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error)
+	RevokeSecurityGroupEgress(ctx context.Context, params *ec2.RevokeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error)
+}
+
+// ec2SpotTerminationReason is the state reason EC2 reports for instances reclaimed by the spot market
+const ec2SpotTerminationReason = "Server.SpotInstanceTermination"
+
+// AWSConfig holds the AWS-specific settings stored in cloud_providers.config
+type AWSConfig struct {
+	CatalogConfig
+	TagConfig
+
+	// AMIs pins custom (golden) images, keyed by architecture ("amd64", the provider's region only)
+	// or by region and architecture ("eu-west-1/amd64")
+	AMIs map[string]string `json:"amis,omitempty"`
+
+	// Networks places instances in a VPC and subnet, keyed by region ("us-east-1")
+	// Regions without an entry launch into their default VPC
+	Networks map[string]AWSNetwork `json:"networks,omitempty"`
+
+	// Egress limits the outbound traffic of instances (empty = allow all)
+	Egress []FirewallRule `json:"egress,omitempty"`
+
+	// KeyPairs names the EC2 key pair instances launch with, keyed by region ("us-east-1")
+	// Regions without an entry launch without one: Stagely reaches VMs through the agent, not SSH.
+	KeyPairs map[string]string `json:"key_pairs,omitempty"`
+}
+
+// AWSNetwork is the VPC and subnet instances of a region launch into
+type AWSNetwork struct {
+	VPCID    string `json:"vpc_id"`
+	SubnetID string `json:"subnet_id"`
 }
 
 // AWSProvider implements CloudProvider for AWS EC2
 type AWSProvider struct {
-	client EC2API
-	region string
+	client  EC2API
+	region  string
+	images  *amiResolver
+	catalog *Catalog   // nil means awsCatalog
+	tags    *TagPolicy // nil means the default policy
+
+	securityGroups *securityGroupManager
+	networks       map[string]AWSNetwork // region -> VPC and subnet
+	egress         []FirewallRule
+	keyPairs       map[string]string // region -> key pair name
 }
 
 // NewAWSProvider creates a new AWS provider with the given credentials and region.
 func NewAWSProvider(accessKey, secretKey, region string) (*AWSProvider, error) {
+	return NewAWSProviderWithConfig(accessKey, secretKey, region, AWSConfig{})
+}
+
+// NewAWSProviderWithConfig creates a new AWS provider with provider-specific settings.
+func NewAWSProviderWithConfig(accessKey, secretKey, region string, awsConfig AWSConfig) (*AWSProvider, error) {
 	if accessKey == "" {
 		return nil, fmt.Errorf("access key is required")
 	}
@@ -63,6 +113,28 @@ func NewAWSProvider(accessKey, secretKey, region string) (*AWSProvider, error) {
 		return nil, fmt.Errorf("region is required")
 	}
 
+	catalog, err := awsCatalog.WithOverrides(awsConfig.InstanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NewTagPolicy("aws", awsConfig.TagConfig)
+	if err != nil {
+		return nil, err
+	}
+	for region, network := range awsConfig.Networks {
+		if network.VPCID == "" || network.SubnetID == "" {
+			return nil, fmt.Errorf("%w: network for region %s needs both vpc_id and subnet_id", ErrInvalidInput, region)
+		}
+	}
+	if err := (Firewall{Outbound: awsConfig.Egress}).Validate(); err != nil {
+		return nil, err
+	}
+	for region, keyPair := range awsConfig.KeyPairs {
+		if keyPair == "" {
+			return nil, fmt.Errorf("%w: empty key pair for region %s", ErrInvalidInput, region)
+		}
+	}
+
 	cfg, err := config.LoadDefaultConfig(context.Background(),
 		config.WithRegion(region),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
@@ -75,9 +147,19 @@ func NewAWSProvider(accessKey, secretKey, region string) (*AWSProvider, error) {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
+	client := ec2.NewFromConfig(cfg)
+
 	return &AWSProvider{
-		client: ec2.NewFromConfig(cfg),
-		region: region,
+		client:  client,
+		region:  region,
+		images:  newAMIResolver(client, region, awsConfig.AMIs),
+		catalog: catalog,
+		tags:    tags,
+
+		securityGroups: newSecurityGroupManager(client),
+		networks:       awsConfig.Networks,
+		egress:         awsConfig.Egress,
+		keyPairs:       awsConfig.KeyPairs,
 	}, nil
 }
 
@@ -86,46 +168,82 @@ func (a *AWSProvider) Name() string {
 	return "aws"
 }
 
+// Catalog returns the sizes this provider can launch.
+func (a *AWSProvider) Catalog() *Catalog {
+	if a.catalog == nil {
+		return awsCatalog
+	}
+	return a.catalog
+}
+
+// TagPolicy returns the policy that stamps and checks the tags of new instances.
+func (a *AWSProvider) TagPolicy() *TagPolicy {
+	if a.tags == nil {
+		return mustTagPolicy("aws")
+	}
+	return a.tags
+}
+
+// EnsureFirewall creates or reuses the Stagely-managed security group for the rules
+// in the region's configured VPC (or its default VPC).
+func (a *AWSProvider) EnsureFirewall(ctx context.Context, region string, firewall Firewall) (string, error) {
+	return a.securityGroups.Ensure(ctx, region, a.networks[region].VPCID, firewall)
+}
+
 // ValidateCredentials verifies that the AWS credentials are valid.
 func (a *AWSProvider) ValidateCredentials(ctx context.Context) error {
 	_, err := a.client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
-	if err != nil {
-		return ErrInvalidCredentials
-	}
-	return nil
+	return classifyAWSError("describe regions", err)
 }
 
-// CreateInstance provisions a new EC2 instance.
+// CreateInstance provisions a new EC2 instance and waits for its public IP.
 func (a *AWSProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
-	if err := spec.Validate(); err != nil {
-		return "", "", err
+	return launchAndWait(ctx, a, spec, DefaultWaitOptions())
+}
+
+// LaunchInstance starts a new EC2 instance without waiting for it to boot.
+func (a *AWSProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	catalog := a.Catalog()
+	if err := spec.ValidateFor(catalog); err != nil {
+		return "", err
+	}
+	instanceType, err := catalog.Lookup(spec.Size, spec.Architecture)
+	if err != nil {
+		return "", err
+	}
+
+	// The client is bound to the provider's region, so every later call on the instance
+	// (status, stop, terminate) goes there; launching elsewhere would orphan it
+	region := spec.Region
+	if region != a.region {
+		return "", fmt.Errorf("%w: region %s does not match provider region %s", ErrInvalidInput, region, a.region)
 	}
 
-	instanceType, err := getInstanceType(spec.Size, spec.Architecture)
+	ami, err := a.images.Resolve(ctx, region, spec.Architecture)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	ami, err := getAMI(spec.Architecture)
+	labels, err := a.TagPolicy().Apply(spec.Tags)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	tags := make([]types.Tag, 0, len(spec.Tags)+1)
+	tags := make([]types.Tag, 0, len(labels)+1)
 	tags = append(tags, types.Tag{
 		Key:   aws.String("Name"),
-		Value: aws.String("stagely-vm"),
+		Value: aws.String(instanceName(labels)),
 	})
-	for k, v := range spec.Tags {
+	for _, k := range sortedKeys(labels) {
 		tags = append(tags, types.Tag{
 			Key:   aws.String(k),
-			Value: aws.String(v),
+			Value: aws.String(labels[k]),
 		})
 	}
 
 	input := &ec2.RunInstancesInput{
 		ImageId:      aws.String(ami),
-		InstanceType: types.InstanceType(instanceType),
+		InstanceType: types.InstanceType(instanceType.Name),
 		MinCount:     aws.Int32(1),
 		MaxCount:     aws.Int32(1),
 		TagSpecifications: []types.TagSpecification{
@@ -136,6 +254,40 @@ func (a *AWSProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (st
 		},
 	}
 
+	// Instances get a managed security group when the spec or the config restricts traffic;
+	// otherwise EC2 applies the VPC's default group
+	var groups []string
+	firewall := Firewall{Outbound: a.egress}
+	if spec.Firewall != nil {
+		firewall.Inbound = spec.Firewall.Inbound
+		if len(spec.Firewall.Outbound) > 0 {
+			firewall.Outbound = spec.Firewall.Outbound
+		}
+	}
+	if spec.Firewall != nil || len(firewall.Outbound) > 0 {
+		groupID, err := a.EnsureFirewall(ctx, region, firewall)
+		if err != nil {
+			return "", err
+		}
+		groups = []string{groupID}
+	}
+
+	if subnetID := a.networks[region].SubnetID; subnetID != "" {
+		// A public IP is requested explicitly, since the subnet may not assign one by default
+		input.NetworkInterfaces = []types.InstanceNetworkInterfaceSpecification{{
+			DeviceIndex:              aws.Int32(0),
+			SubnetId:                 aws.String(subnetID),
+			Groups:                   groups,
+			AssociatePublicIpAddress: aws.Bool(true),
+		}}
+	} else {
+		input.SecurityGroupIds = groups
+	}
+
+	if keyPair := a.keyPairs[region]; keyPair != "" {
+		input.KeyName = aws.String(keyPair)
+	}
+
 	if spec.UserData != "" {
 		encoded := base64.StdEncoding.EncodeToString([]byte(spec.UserData))
 		input.UserData = aws.String(encoded)
@@ -149,52 +301,14 @@ func (a *AWSProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (st
 
 	result, err := a.client.RunInstances(ctx, input)
 	if err != nil {
-		return "", "", fmt.Errorf("run instances: %w", err)
+		return "", classifyAWSError("run instances", err)
 	}
 
 	if len(result.Instances) == 0 {
-		return "", "", fmt.Errorf("no instance created")
-	}
-
-	instanceID := aws.ToString(result.Instances[0].InstanceId)
-
-	publicIP, err := a.waitForPublicIP(ctx, instanceID)
-	if err != nil {
-		return instanceID, "", fmt.Errorf("wait for public IP: %w", err)
+		return "", fmt.Errorf("no instance created")
 	}
 
-	return instanceID, publicIP, nil
-}
-
-// waitForPublicIP polls DescribeInstances until a public IP is assigned or timeout occurs.
-func (a *AWSProvider) waitForPublicIP(ctx context.Context, instanceID string) (string, error) {
-	// Quick initial check before starting the ticker to avoid needless delay.
-	status, err := a.GetInstanceStatus(ctx, instanceID)
-	if err == nil && status.PublicIP != "" {
-		return status.PublicIP, nil
-	}
-
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	timeout := time.After(5 * time.Minute)
-
-	for {
-		select {
-		case <-timeout:
-			return "", fmt.Errorf("timeout waiting for public IP after 5 minutes")
-		case <-ctx.Done():
-			return "", ctx.Err()
-		case <-ticker.C:
-			currentStatus, statusErr := a.GetInstanceStatus(ctx, instanceID)
-			if statusErr != nil {
-				continue
-			}
-			if currentStatus.PublicIP != "" {
-				return currentStatus.PublicIP, nil
-			}
-		}
-	}
+	return aws.ToString(result.Instances[0].InstanceId), nil
 }
 
 // GetInstanceStatus returns the current status of an EC2 instance.
@@ -203,7 +317,10 @@ func (a *AWSProvider) GetInstanceStatus(ctx context.Context, instanceID string)
 		InstanceIds: []string{instanceID},
 	})
 	if err != nil {
-		return InstanceStatus{}, fmt.Errorf("describe instances: %w", err)
+		if isEC2InstanceNotFound(err) {
+			return InstanceStatus{}, ErrInstanceNotFound
+		}
+		return InstanceStatus{}, classifyAWSError("describe instances", err)
 	}
 
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
@@ -212,12 +329,65 @@ func (a *AWSProvider) GetInstanceStatus(ctx context.Context, instanceID string)
 
 	instance := result.Reservations[0].Instances[0]
 
-	return InstanceStatus{
-		State:      mapEC2State(instance.State.Name),
-		PublicIP:   aws.ToString(instance.PublicIpAddress),
-		PrivateIP:  aws.ToString(instance.PrivateIpAddress),
-		LaunchedAt: aws.ToTime(instance.LaunchTime),
-	}, nil
+	status := InstanceStatus{
+		State:        mapEC2State(instance.State.Name),
+		PublicIP:     aws.ToString(instance.PublicIpAddress),
+		PrivateIP:    aws.ToString(instance.PrivateIpAddress),
+		LaunchedAt:   aws.ToTime(instance.LaunchTime),
+		InstanceType: string(instance.InstanceType),
+		Spot:         instance.InstanceLifecycle == types.InstanceLifecycleTypeSpot,
+	}
+	if instance.StateReason != nil && aws.ToString(instance.StateReason.Code) == ec2SpotTerminationReason {
+		status.Reclaimed = true
+	}
+
+	return status, nil
+}
+
+// ListInstances returns all EC2 instances carrying every tag in tagFilter.
+func (a *AWSProvider) ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	filters := make([]types.Filter, 0, len(tagFilter))
+	for k, v := range tagFilter {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + k),
+			Values: []string{v},
+		})
+	}
+
+	var summaries []InstanceSummary
+	input := &ec2.DescribeInstancesInput{Filters: filters}
+	for {
+		result, err := a.client.DescribeInstances(ctx, input)
+		if err != nil {
+			return nil, classifyAWSError("describe instances", err)
+		}
+
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				tags := make(map[string]string, len(instance.Tags))
+				for _, tag := range instance.Tags {
+					tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+
+				var state types.InstanceStateName
+				if instance.State != nil {
+					state = instance.State.Name
+				}
+
+				summaries = append(summaries, InstanceSummary{
+					ID:         aws.ToString(instance.InstanceId),
+					State:      mapEC2State(state),
+					Tags:       tags,
+					LaunchedAt: aws.ToTime(instance.LaunchTime),
+				})
+			}
+		}
+
+		if aws.ToString(result.NextToken) == "" {
+			return summaries, nil
+		}
+		input.NextToken = result.NextToken
+	}
 }
 
 func mapEC2State(ec2State types.InstanceStateName) string {
@@ -241,35 +411,43 @@ func (a *AWSProvider) TerminateInstance(ctx context.Context, instanceID string)
 		InstanceIds: []string{instanceID},
 	})
 	if err != nil {
-		var apiErr smithy.APIError
-		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidInstanceID.NotFound" {
+		if isEC2InstanceNotFound(err) {
 			return nil
 		}
-		return fmt.Errorf("terminate instance: %w", err)
+		return classifyAWSError("terminate instance", err)
 	}
 	return nil
 }
 
-// getInstanceType returns the EC2 instance type for the given size and architecture.
-func getInstanceType(size, arch string) (string, error) {
-	archMap, ok := instanceTypeMap[size]
-	if !ok {
-		return "", fmt.Errorf("unsupported size: %s", size)
+// StopInstance stops a running EC2 instance (idempotent).
+func (a *AWSProvider) StopInstance(ctx context.Context, instanceID string) error {
+	_, err := a.client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		if isEC2InstanceNotFound(err) {
+			return ErrInstanceNotFound
+		}
+		return classifyAWSError("stop instance", err)
 	}
+	return nil
+}
 
-	instanceType, ok := archMap[arch]
-	if !ok {
-		return "", fmt.Errorf("unsupported architecture for size %s: %s", size, arch)
+// StartInstance starts a stopped EC2 instance (idempotent).
+func (a *AWSProvider) StartInstance(ctx context.Context, instanceID string) error {
+	_, err := a.client.StartInstances(ctx, &ec2.StartInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		if isEC2InstanceNotFound(err) {
+			return ErrInstanceNotFound
+		}
+		return classifyAWSError("start instance", err)
 	}
-
-	return instanceType, nil
+	return nil
 }
 
-// getAMI returns the Ubuntu 22.04 LTS AMI ID for the given architecture.
-func getAMI(arch string) (string, error) {
-	ami, ok := amiMap[arch]
-	if !ok {
-		return "", fmt.Errorf("unsupported architecture: %s", arch)
-	}
-	return ami, nil
+func isEC2InstanceNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidInstanceID.NotFound"
 }
@@ -4,6 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,14 +20,28 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// Compile-time interface compliance check
-var _ CloudProvider = (*AWSProvider)(nil)
+// Compile-time interface compliance checks
+var (
+	_ CloudProvider   = (*AWSProvider)(nil)
+	_ InstanceStopper = (*AWSProvider)(nil)
+	_ FirewallManager = (*AWSProvider)(nil)
+)
 
 type mockEC2Client struct {
-	describeRegionsFunc    func(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
-	runInstancesFunc       func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
-	describeInstancesFunc  func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
-	terminateInstancesFunc func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	describeRegionsFunc               func(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+	runInstancesFunc                  func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
+	describeInstancesFunc             func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+	terminateInstancesFunc            func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	stopInstancesFunc                 func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	startInstancesFunc                func(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+	describeImagesFunc                func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+	describeVpcsFunc                  func(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	describeSecurityGroupsFunc        func(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error)
+	createSecurityGroupFunc           func(ctx context.Context, params *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error)
+	deleteSecurityGroupFunc           func(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error)
+	authorizeSecurityGroupIngressFunc func(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	authorizeSecurityGroupEgressFunc  func(ctx context.Context, params *ec2.AuthorizeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error)
+	revokeSecurityGroupEgressFunc     func(ctx context.Context, params *ec2.RevokeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error)
 }
 
 func (m *mockEC2Client) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
@@ -53,7 +72,205 @@ func (m *mockEC2Client) TerminateInstances(ctx context.Context, params *ec2.Term
 	return &ec2.TerminateInstancesOutput{}, nil
 }
 
-func TestGetInstanceType(t *testing.T) {
+func (m *mockEC2Client) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	if m.stopInstancesFunc != nil {
+		return m.stopInstancesFunc(ctx, params, optFns...)
+	}
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (m *mockEC2Client) StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	if m.startInstancesFunc != nil {
+		return m.startInstancesFunc(ctx, params, optFns...)
+	}
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+func (m *mockEC2Client) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	if m.describeImagesFunc != nil {
+		return m.describeImagesFunc(ctx, params, optFns...)
+	}
+	return &ec2.DescribeImagesOutput{}, nil
+}
+
+func (m *mockEC2Client) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	if m.describeVpcsFunc != nil {
+		return m.describeVpcsFunc(ctx, params, optFns...)
+	}
+	return &ec2.DescribeVpcsOutput{}, nil
+}
+
+func (m *mockEC2Client) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if m.describeSecurityGroupsFunc != nil {
+		return m.describeSecurityGroupsFunc(ctx, params, optFns...)
+	}
+	return &ec2.DescribeSecurityGroupsOutput{}, nil
+}
+
+func (m *mockEC2Client) CreateSecurityGroup(ctx context.Context, params *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+	if m.createSecurityGroupFunc != nil {
+		return m.createSecurityGroupFunc(ctx, params, optFns...)
+	}
+	return &ec2.CreateSecurityGroupOutput{}, nil
+}
+
+func (m *mockEC2Client) DeleteSecurityGroup(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+	if m.deleteSecurityGroupFunc != nil {
+		return m.deleteSecurityGroupFunc(ctx, params, optFns...)
+	}
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (m *mockEC2Client) AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	if m.authorizeSecurityGroupIngressFunc != nil {
+		return m.authorizeSecurityGroupIngressFunc(ctx, params, optFns...)
+	}
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *mockEC2Client) AuthorizeSecurityGroupEgress(ctx context.Context, params *ec2.AuthorizeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	if m.authorizeSecurityGroupEgressFunc != nil {
+		return m.authorizeSecurityGroupEgressFunc(ctx, params, optFns...)
+	}
+	return &ec2.AuthorizeSecurityGroupEgressOutput{}, nil
+}
+
+func (m *mockEC2Client) RevokeSecurityGroupEgress(ctx context.Context, params *ec2.RevokeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	if m.revokeSecurityGroupEgressFunc != nil {
+		return m.revokeSecurityGroupEgressFunc(ctx, params, optFns...)
+	}
+	return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+}
+
+// fakeEC2 is an in-memory stand-in for the EC2 instance API, wired into a mock client by install.
+// Instances are running with a public IP as soon as they launch, and stay listed once terminated.
+type fakeEC2 struct {
+	mu        sync.Mutex
+	nextID    int
+	instances map[string]*types.Instance
+}
+
+func newFakeEC2() *fakeEC2 {
+	return &fakeEC2{instances: make(map[string]*types.Instance)}
+}
+
+// install wires the fake into a mock EC2 client. Like the SDK, every call fails once ctx is done.
+func (f *fakeEC2) install(client *mockEC2Client) *mockEC2Client {
+	client.describeRegionsFunc = func(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+		return &ec2.DescribeRegionsOutput{}, ctx.Err()
+	}
+	client.describeImagesFunc = func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &ec2.DescribeImagesOutput{
+			Images: []types.Image{{ImageId: aws.String("ami-fake"), CreationDate: aws.String("2025-01-01T00:00:00.000Z")}},
+		}, nil
+	}
+	client.runInstancesFunc = func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.nextID++
+		instance := &types.Instance{
+			InstanceId:       aws.String(fmt.Sprintf("i-%017x", f.nextID)),
+			InstanceType:     params.InstanceType,
+			LaunchTime:       aws.Time(time.Now().UTC().Truncate(time.Second)),
+			PrivateIpAddress: aws.String("10.0.1.5"),
+		}
+		for _, spec := range params.TagSpecifications {
+			instance.Tags = append(instance.Tags, spec.Tags...)
+		}
+		f.setState(instance, types.InstanceStateNameRunning)
+		f.instances[aws.ToString(instance.InstanceId)] = instance
+		return &ec2.RunInstancesOutput{Instances: []types.Instance{*instance}}, nil
+	}
+	client.describeInstancesFunc = func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		var instances []types.Instance
+		if len(params.InstanceIds) > 0 {
+			for _, id := range params.InstanceIds {
+				instance, ok := f.instances[id]
+				if !ok {
+					return nil, &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}
+				}
+				instances = append(instances, *instance)
+			}
+		} else {
+			for _, instance := range f.instances {
+				if f.matches(instance, params.Filters) {
+					instances = append(instances, *instance)
+				}
+			}
+		}
+		return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: instances}}}, nil
+	}
+	client.terminateInstancesFunc = func(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+		return &ec2.TerminateInstancesOutput{}, f.transition(ctx, params.InstanceIds, types.InstanceStateNameTerminated)
+	}
+	client.stopInstancesFunc = func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+		return &ec2.StopInstancesOutput{}, f.transition(ctx, params.InstanceIds, types.InstanceStateNameStopped)
+	}
+	client.startInstancesFunc = func(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+		return &ec2.StartInstancesOutput{}, f.transition(ctx, params.InstanceIds, types.InstanceStateNameRunning)
+	}
+	return client
+}
+
+// transition moves instances to a new state; terminated instances cannot leave it
+func (f *fakeEC2) transition(ctx context.Context, ids []string, state types.InstanceStateName) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range ids {
+		instance, ok := f.instances[id]
+		if !ok {
+			return &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}
+		}
+		if instance.State.Name == types.InstanceStateNameTerminated && state != types.InstanceStateNameTerminated {
+			return &smithy.GenericAPIError{Code: "IncorrectInstanceState"}
+		}
+		f.setState(instance, state)
+	}
+	return nil
+}
+
+// setState updates an instance's state; only running instances have a public IP
+func (f *fakeEC2) setState(instance *types.Instance, state types.InstanceStateName) {
+	instance.State = &types.InstanceState{Name: state}
+	instance.PublicIpAddress = nil
+	if state == types.InstanceStateNameRunning {
+		instance.PublicIpAddress = aws.String("54.123.45.67")
+	}
+}
+
+// matches reports whether an instance carries every "tag:key" filter
+func (f *fakeEC2) matches(instance *types.Instance, filters []types.Filter) bool {
+	tags := make(map[string]string, len(instance.Tags))
+	for _, tag := range instance.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for _, filter := range filters {
+		key, ok := strings.CutPrefix(aws.ToString(filter.Name), "tag:")
+		if !ok || !slices.Contains(filter.Values, tags[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAWSCatalog(t *testing.T) {
 	tests := []struct {
 		name        string
 		size        string
@@ -67,45 +284,21 @@ func TestGetInstanceType(t *testing.T) {
 		{"medium arm64", SizeMedium, ArchARM64, "c6g.xlarge", false},
 		{"large amd64", SizeLarge, ArchAMD64, "c5.2xlarge", false},
 		{"large arm64", SizeLarge, ArchARM64, "c6g.2xlarge", false},
+		{"xlarge amd64", SizeXLarge, ArchAMD64, "c5.4xlarge", false},
+		{"xlarge arm64", SizeXLarge, ArchARM64, "c6g.4xlarge", false},
 		{"invalid size", "invalid", ArchAMD64, "", true},
 		{"invalid arch", SizeSmall, "invalid", "", true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := getInstanceType(tt.size, tt.arch)
+			result, err := awsCatalog.Lookup(tt.size, tt.arch)
 			if tt.expectError {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidInput)
 				assert.Empty(t, result)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expected, result)
-			}
-		})
-	}
-}
-
-func TestGetAMI(t *testing.T) {
-	tests := []struct {
-		name        string
-		arch        string
-		expected    string
-		expectError bool
-	}{
-		{"amd64", ArchAMD64, "ami-0c7217cdde317cfec", false},
-		{"arm64", ArchARM64, "ami-0c7a8e3f05e4e5f0c", false},
-		{"invalid", "invalid", "", true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result, err := getAMI(tt.arch)
-			if tt.expectError {
-				assert.Error(t, err)
-				assert.Empty(t, result)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tt.expected, result)
+				assert.Equal(t, tt.expected, result.Name)
 			}
 		})
 	}
@@ -145,6 +338,7 @@ func TestValidateCredentials(t *testing.T) {
 		name        string
 		mockError   error
 		expectError bool
+		expectedErr error
 	}{
 		{
 			name:        "valid credentials",
@@ -153,8 +347,15 @@ func TestValidateCredentials(t *testing.T) {
 		},
 		{
 			name:        "invalid credentials",
-			mockError:   errors.New("UnauthorizedOperation"),
+			mockError:   &smithy.GenericAPIError{Code: "AuthFailure"},
 			expectError: true,
+			expectedErr: ErrInvalidCredentials,
+		},
+		{
+			name:        "network failure is not a credential problem",
+			mockError:   &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			expectError: true,
+			expectedErr: ErrNetworkFailure,
 		},
 	}
 
@@ -171,7 +372,7 @@ func TestValidateCredentials(t *testing.T) {
 
 			err := provider.ValidateCredentials(context.Background())
 			if tt.expectError {
-				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				assert.ErrorIs(t, err, tt.expectedErr)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -191,6 +392,9 @@ func TestGetInstanceStatus(t *testing.T) {
 		expectedState  string
 		expectedPubIP  string
 		expectedPrivIP string
+		expectedType   string
+		expectedSpot   bool
+		expectReclaim  bool
 	}{
 		{
 			name: "running instance",
@@ -203,6 +407,7 @@ func TestGetInstanceStatus(t *testing.T) {
 								State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
 								PublicIpAddress:  aws.String("54.123.45.67"),
 								PrivateIpAddress: aws.String("10.0.0.1"),
+								InstanceType:     types.InstanceTypeC5Xlarge,
 								LaunchTime:       aws.Time(launchTime),
 							},
 						},
@@ -212,6 +417,7 @@ func TestGetInstanceStatus(t *testing.T) {
 			expectedState:  StateRunning,
 			expectedPubIP:  "54.123.45.67",
 			expectedPrivIP: "10.0.0.1",
+			expectedType:   "c5.xlarge",
 			expectError:    false,
 		},
 		{
@@ -232,6 +438,27 @@ func TestGetInstanceStatus(t *testing.T) {
 			expectedState: StateStopped,
 			expectError:   false,
 		},
+		{
+			name: "reclaimed spot instance",
+			response: &ec2.DescribeInstancesOutput{
+				Reservations: []types.Reservation{
+					{
+						Instances: []types.Instance{
+							{
+								InstanceId:        aws.String("i-789"),
+								State:             &types.InstanceState{Name: types.InstanceStateNameTerminated},
+								InstanceLifecycle: types.InstanceLifecycleTypeSpot,
+								StateReason:       &types.StateReason{Code: aws.String("Server.SpotInstanceTermination")},
+								LaunchTime:        aws.Time(launchTime),
+							},
+						},
+					},
+				},
+			},
+			expectedState: StateTerminated,
+			expectedSpot:  true,
+			expectReclaim: true,
+		},
 		{
 			name:        "instance not found",
 			response:    &ec2.DescribeInstancesOutput{},
@@ -270,6 +497,9 @@ func TestGetInstanceStatus(t *testing.T) {
 			assert.Equal(t, tt.expectedState, status.State)
 			assert.Equal(t, tt.expectedPubIP, status.PublicIP)
 			assert.Equal(t, tt.expectedPrivIP, status.PrivateIP)
+			assert.Equal(t, tt.expectedType, status.InstanceType)
+			assert.Equal(t, tt.expectedSpot, status.Spot)
+			assert.Equal(t, tt.expectReclaim, status.Reclaimed)
 			assert.True(t, status.LaunchedAt.Equal(launchTime))
 		})
 	}
@@ -349,6 +579,118 @@ func TestTerminateInstance(t *testing.T) {
 	}
 }
 
+func TestListInstances(t *testing.T) {
+	launchTime := time.Now()
+	calls := 0
+
+	provider := &AWSProvider{
+		client: &mockEC2Client{
+			describeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+				calls++
+				require.Len(t, params.Filters, 1)
+				assert.Equal(t, "tag:managed-by", aws.ToString(params.Filters[0].Name))
+				assert.Equal(t, []string{"stagely"}, params.Filters[0].Values)
+
+				if calls == 1 {
+					assert.Nil(t, params.NextToken)
+					return &ec2.DescribeInstancesOutput{
+						Reservations: []types.Reservation{{
+							Instances: []types.Instance{{
+								InstanceId: aws.String("i-1"),
+								State:      &types.InstanceState{Name: types.InstanceStateNameRunning},
+								Tags:       []types.Tag{{Key: aws.String("managed-by"), Value: aws.String("stagely")}},
+								LaunchTime: aws.Time(launchTime),
+							}},
+						}},
+						NextToken: aws.String("page-2"),
+					}, nil
+				}
+
+				assert.Equal(t, "page-2", aws.ToString(params.NextToken))
+				return &ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{
+						Instances: []types.Instance{{
+							InstanceId: aws.String("i-2"),
+							State:      &types.InstanceState{Name: types.InstanceStateNameShuttingDown},
+							Tags:       []types.Tag{{Key: aws.String("managed-by"), Value: aws.String("stagely")}},
+							LaunchTime: aws.Time(launchTime),
+						}},
+					}},
+				}, nil
+			},
+		},
+		region: "us-east-1",
+	}
+
+	instances, err := provider.ListInstances(context.Background(), map[string]string{TagManagedBy: ManagedByStagely})
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	assert.Equal(t, "i-1", instances[0].ID)
+	assert.Equal(t, StateRunning, instances[0].State)
+	assert.Equal(t, "stagely", instances[0].Tags[TagManagedBy])
+	assert.Equal(t, "i-2", instances[1].ID)
+	assert.Equal(t, StateTerminated, instances[1].State)
+	assert.Equal(t, 2, calls)
+}
+
+func TestStopStartInstance(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockError   error
+		expectError bool
+		expectedErr error
+	}{
+		{
+			name:        "success",
+			mockError:   nil,
+			expectError: false,
+		},
+		{
+			name:        "instance not found",
+			mockError:   &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"},
+			expectError: true,
+			expectedErr: ErrInstanceNotFound,
+		},
+		{
+			name:        "other error",
+			mockError:   errors.New("IncorrectInstanceState"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &AWSProvider{
+				client: &mockEC2Client{
+					stopInstancesFunc: func(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+						assert.Equal(t, []string{"i-123"}, params.InstanceIds)
+						return &ec2.StopInstancesOutput{}, tt.mockError
+					},
+					startInstancesFunc: func(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+						assert.Equal(t, []string{"i-123"}, params.InstanceIds)
+						return &ec2.StartInstancesOutput{}, tt.mockError
+					},
+				},
+				region: "us-east-1",
+			}
+
+			for _, err := range []error{
+				provider.StopInstance(context.Background(), "i-123"),
+				provider.StartInstance(context.Background(), "i-123"),
+			} {
+				if tt.expectError {
+					assert.Error(t, err)
+					if tt.expectedErr != nil {
+						assert.ErrorIs(t, err, tt.expectedErr)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+}
+
 func TestCreateInstance(t *testing.T) {
 	launchTime := time.Now()
 
@@ -403,64 +745,73 @@ func TestCreateInstance(t *testing.T) {
 			runInstancesCalled := false
 			describeCalled := false
 
-			provider := &AWSProvider{
-				client: &mockEC2Client{
-					runInstancesFunc: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
-						runInstancesCalled = true
-
-						expectedType, _ := getInstanceType(tt.spec.Size, tt.spec.Architecture)
-						expectedAMI, _ := getAMI(tt.spec.Architecture)
-
-						assert.Equal(t, expectedType, string(params.InstanceType))
-						assert.Equal(t, expectedAMI, aws.ToString(params.ImageId))
-
-						if tt.spec.SpotInstance {
-							require.NotNil(t, params.InstanceMarketOptions)
-							assert.Equal(t, types.MarketTypeSpot, params.InstanceMarketOptions.MarketType)
-						}
-
-						if len(tt.spec.Tags) > 0 {
-							assert.NotEmpty(t, params.TagSpecifications)
-							require.NotEmpty(t, params.TagSpecifications[0].Tags)
-						}
-
-						if tt.spec.UserData != "" {
-							require.NotNil(t, params.UserData)
-							decoded, decodeErr := base64.StdEncoding.DecodeString(aws.ToString(params.UserData))
-							require.NoError(t, decodeErr)
-							assert.Equal(t, tt.spec.UserData, string(decoded))
-						}
-
-						return &ec2.RunInstancesOutput{
-							Instances: []types.Instance{
-								{
-									InstanceId: aws.String("i-test123"),
-									State:      &types.InstanceState{Name: types.InstanceStateNamePending},
-									LaunchTime: aws.Time(launchTime),
-								},
+			client := &mockEC2Client{
+				describeImagesFunc: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+					return &ec2.DescribeImagesOutput{
+						Images: []types.Image{
+							{ImageId: aws.String("ami-" + tt.spec.Architecture), CreationDate: aws.String("2025-01-01T00:00:00.000Z")},
+						},
+					}, nil
+				},
+				runInstancesFunc: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+					runInstancesCalled = true
+
+					expectedType, _ := awsCatalog.Lookup(tt.spec.Size, tt.spec.Architecture)
+					expectedAMI := "ami-" + tt.spec.Architecture
+
+					assert.Equal(t, expectedType.Name, string(params.InstanceType))
+					assert.Equal(t, expectedAMI, aws.ToString(params.ImageId))
+
+					if tt.spec.SpotInstance {
+						require.NotNil(t, params.InstanceMarketOptions)
+						assert.Equal(t, types.MarketTypeSpot, params.InstanceMarketOptions.MarketType)
+					}
+
+					if len(tt.spec.Tags) > 0 {
+						assert.NotEmpty(t, params.TagSpecifications)
+						require.NotEmpty(t, params.TagSpecifications[0].Tags)
+					}
+
+					if tt.spec.UserData != "" {
+						require.NotNil(t, params.UserData)
+						decoded, decodeErr := base64.StdEncoding.DecodeString(aws.ToString(params.UserData))
+						require.NoError(t, decodeErr)
+						assert.Equal(t, tt.spec.UserData, string(decoded))
+					}
+
+					return &ec2.RunInstancesOutput{
+						Instances: []types.Instance{
+							{
+								InstanceId: aws.String("i-test123"),
+								State:      &types.InstanceState{Name: types.InstanceStateNamePending},
+								LaunchTime: aws.Time(launchTime),
 							},
-						}, nil
-					},
-					describeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
-						describeCalled = true
-						return &ec2.DescribeInstancesOutput{
-							Reservations: []types.Reservation{
-								{
-									Instances: []types.Instance{
-										{
-											InstanceId:       aws.String("i-test123"),
-											State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
-											PublicIpAddress:  aws.String("54.123.45.67"),
-											PrivateIpAddress: aws.String("10.0.1.5"),
-											LaunchTime:       aws.Time(launchTime),
-										},
+						},
+					}, nil
+				},
+				describeInstancesFunc: func(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+					describeCalled = true
+					return &ec2.DescribeInstancesOutput{
+						Reservations: []types.Reservation{
+							{
+								Instances: []types.Instance{
+									{
+										InstanceId:       aws.String("i-test123"),
+										State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
+										PublicIpAddress:  aws.String("54.123.45.67"),
+										PrivateIpAddress: aws.String("10.0.1.5"),
+										LaunchTime:       aws.Time(launchTime),
 									},
 								},
 							},
-						}, nil
-					},
+						},
+					}, nil
 				},
+			}
+			provider := &AWSProvider{
+				client: client,
 				region: "us-east-1",
+				images: newAMIResolver(client, "us-east-1", nil),
 			}
 
 			instanceID, publicIP, err := provider.CreateInstance(context.Background(), tt.spec)
@@ -480,3 +831,174 @@ func TestCreateInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestLaunchInstance_RejectsOtherRegion(t *testing.T) {
+	// Given - a provider bound to us-east-1
+	runCalled := false
+	client := &mockEC2Client{
+		runInstancesFunc: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+			runCalled = true
+			return &ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil
+		},
+	}
+	provider := &AWSProvider{
+		client: client,
+		region: "us-east-1",
+		images: newAMIResolver(client, "us-east-1", map[string]string{"amd64": "ami-golden"}),
+	}
+
+	// When
+	_, err := provider.LaunchInstance(context.Background(), InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "eu-west-1",
+	})
+
+	// Then - the instance would be invisible to status and terminate calls, so nothing is launched
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.False(t, runCalled)
+}
+
+func TestLaunchInstance_Network(t *testing.T) {
+	egress := []FirewallRule{{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}}}
+	edge := EdgeFirewall([]string{"203.0.113.10/32"})
+
+	tests := []struct {
+		name           string
+		firewall       *Firewall
+		networks       map[string]AWSNetwork
+		egress         []FirewallRule
+		expectGroup    bool
+		expectSubnet   string
+		expectRevoked  bool
+		expectVPCGroup string
+	}{
+		{"provider defaults", nil, nil, nil, false, "", false, ""},
+		{"edge firewall in default VPC", edge, nil, nil, true, "", false, "vpc-default"},
+		{"edge firewall in team subnet", edge, map[string]AWSNetwork{"us-east-1": {VPCID: "vpc-team", SubnetID: "subnet-team"}}, nil, true, "subnet-team", false, "vpc-team"},
+		{"configured egress only", nil, nil, egress, true, "", true, "vpc-default"},
+		{"subnet without firewall", nil, map[string]AWSNetwork{"us-east-1": {VPCID: "vpc-team", SubnetID: "subnet-team"}}, nil, false, "subnet-team", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeSecurityGroups()
+			var input *ec2.RunInstancesInput
+			client := fake.install(&mockEC2Client{
+				describeImagesFunc: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+					return &ec2.DescribeImagesOutput{
+						Images: []types.Image{{ImageId: aws.String("ami-1"), CreationDate: aws.String("2025-01-01T00:00:00.000Z")}},
+					}, nil
+				},
+				runInstancesFunc: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+					input = params
+					return &ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil
+				},
+			})
+			provider := &AWSProvider{
+				client:         client,
+				region:         "us-east-1",
+				images:         newAMIResolver(client, "us-east-1", nil),
+				securityGroups: newSecurityGroupManager(client),
+				networks:       tt.networks,
+				egress:         tt.egress,
+			}
+
+			_, err := provider.LaunchInstance(context.Background(), InstanceSpec{
+				Size:         SizeSmall,
+				Architecture: ArchAMD64,
+				Region:       "us-east-1",
+				Firewall:     tt.firewall,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, input)
+
+			var groups []string
+			if tt.expectSubnet != "" {
+				require.Len(t, input.NetworkInterfaces, 1)
+				assert.Equal(t, tt.expectSubnet, aws.ToString(input.NetworkInterfaces[0].SubnetId))
+				assert.True(t, aws.ToBool(input.NetworkInterfaces[0].AssociatePublicIpAddress))
+				assert.Empty(t, input.SecurityGroupIds)
+				groups = input.NetworkInterfaces[0].Groups
+			} else {
+				assert.Empty(t, input.NetworkInterfaces)
+				groups = input.SecurityGroupIds
+			}
+
+			if !tt.expectGroup {
+				assert.Empty(t, groups)
+				assert.Zero(t, fake.creates)
+				return
+			}
+			require.Len(t, groups, 1)
+			assert.Equal(t, tt.expectRevoked, fake.revoked[groups[0]])
+			found := false
+			for key, id := range fake.groups {
+				if id == groups[0] {
+					assert.Contains(t, key, tt.expectVPCGroup+"/stagely-")
+					found = true
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestNewAWSProviderWithConfig_Network(t *testing.T) {
+	_, err := NewAWSProviderWithConfig("AKIA", "secret", "us-east-1", AWSConfig{
+		Networks: map[string]AWSNetwork{"us-east-1": {SubnetID: "subnet-team"}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewAWSProviderWithConfig("AKIA", "secret", "us-east-1", AWSConfig{
+		Egress: []FirewallRule{{Protocol: ProtocolTCP, Ports: "443"}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewAWSProviderWithConfig("AKIA", "secret", "us-east-1", AWSConfig{
+		KeyPairs: map[string]string{"us-east-1": ""},
+	})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	provider, err := NewAWSProviderWithConfig("AKIA", "secret", "us-east-1", AWSConfig{
+		Networks: map[string]AWSNetwork{"us-east-1": {VPCID: "vpc-team", SubnetID: "subnet-team"}},
+		Egress:   []FirewallRule{{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}}},
+	})
+	require.NoError(t, err)
+	_, ok := AsFirewallManager(provider)
+	assert.True(t, ok)
+}
+
+func TestLaunchInstance_KeyPair(t *testing.T) {
+	// Given - a key pair configured for the provider's region only
+	var inputs []*ec2.RunInstancesInput
+	client := &mockEC2Client{
+		describeImagesFunc: func(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+			return &ec2.DescribeImagesOutput{
+				Images: []types.Image{{ImageId: aws.String("ami-1"), CreationDate: aws.String("2025-01-01T00:00:00.000Z")}},
+			}, nil
+		},
+		runInstancesFunc: func(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+			inputs = append(inputs, params)
+			return &ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil
+		},
+	}
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	// When
+	east := &AWSProvider{client: client, region: "us-east-1", images: newAMIResolver(client, "us-east-1", nil),
+		keyPairs: map[string]string{"us-east-1": "stagely-ops"}}
+	_, err := east.LaunchInstance(context.Background(), spec)
+	require.NoError(t, err)
+
+	spec.Region = "eu-west-1"
+	west := &AWSProvider{client: client, region: "eu-west-1", images: newAMIResolver(client, "eu-west-1", nil),
+		keyPairs: map[string]string{"us-east-1": "stagely-ops"}}
+	_, err = west.LaunchInstance(context.Background(), spec)
+	require.NoError(t, err)
+
+	// Then
+	require.Len(t, inputs, 2)
+	assert.Equal(t, "stagely-ops", aws.ToString(inputs[0].KeyName))
+	assert.Nil(t, inputs[1].KeyName)
+}
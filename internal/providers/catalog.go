@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InstanceType is a concrete provider machine type and its capacity
+type InstanceType struct {
+	Name     string  `json:"name"`      // Provider type name (e.g., "c5.xlarge", "cx32")
+	VCPUs    int     `json:"vcpus"`     // Virtual CPUs
+	MemoryGB float64 `json:"memory_gb"` // RAM in GB
+}
+
+// CatalogConfig is the instance-type section of cloud_providers.config, shared by all providers.
+// Entries are merged over the provider's default catalog, so a team can repoint an existing size
+// or add new ones without code changes:
+//
+//	{"instance_types": {"large-mem": {"amd64": {"name": "r6i.2xlarge", "vcpus": 8, "memory_gb": 64}}}}
+type CatalogConfig struct {
+	InstanceTypes map[string]map[string]InstanceType `json:"instance_types,omitempty"`
+}
+
+// sizePattern restricts size names to what projects.default_preview_size can hold
+var sizePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,19}$`)
+
+// Catalog maps logical sizes and architectures to concrete instance types
+// A Catalog is immutable; WithOverrides returns a new one.
+type Catalog struct {
+	types map[string]map[string]InstanceType // Size -> architecture -> instance type
+}
+
+// NewCatalog creates a catalog from size -> architecture -> instance type entries
+func NewCatalog(types map[string]map[string]InstanceType) (*Catalog, error) {
+	return (&Catalog{}).WithOverrides(types)
+}
+
+// mustCatalog is NewCatalog for the built-in catalogs
+func mustCatalog(types map[string]map[string]InstanceType) *Catalog {
+	catalog, err := NewCatalog(types)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// DefaultCatalog returns the built-in catalog of a provider type (empty for unknown types)
+func DefaultCatalog(providerType string) *Catalog {
+	switch providerType {
+	case "aws":
+		return awsCatalog
+	case "digitalocean":
+		return dropletCatalog
+	case "docker":
+		return dockerCatalog
+	case "hetzner":
+		return hetznerCatalog
+	default:
+		return &Catalog{}
+	}
+}
+
+// WithOverrides returns a copy of the catalog with the given entries added or replaced.
+// Overrides are per size and architecture: overriding "large"/"arm64" keeps "large"/"amd64".
+func (c *Catalog) WithOverrides(overrides map[string]map[string]InstanceType) (*Catalog, error) {
+	merged := &Catalog{types: make(map[string]map[string]InstanceType, len(c.types)+len(overrides))}
+	for size, archMap := range c.types {
+		merged.types[size] = make(map[string]InstanceType, len(archMap))
+		for arch, instanceType := range archMap {
+			merged.types[size][arch] = instanceType
+		}
+	}
+
+	for size, archMap := range overrides {
+		if !sizePattern.MatchString(size) {
+			return nil, fmt.Errorf("%w: size %q must be lowercase letters, digits and dashes (max 20)", ErrInvalidInput, size)
+		}
+		if merged.types[size] == nil {
+			merged.types[size] = make(map[string]InstanceType, len(archMap))
+		}
+		for arch, instanceType := range archMap {
+			if arch != ArchAMD64 && arch != ArchARM64 {
+				return nil, fmt.Errorf("%w: size %s: architecture must be amd64 or arm64", ErrInvalidInput, size)
+			}
+			if instanceType.Name == "" {
+				return nil, fmt.Errorf("%w: size %s/%s: instance type name is required", ErrInvalidInput, size, arch)
+			}
+			merged.types[size][arch] = instanceType
+		}
+		if len(merged.types[size]) == 0 {
+			delete(merged.types, size)
+		}
+	}
+
+	return merged, nil
+}
+
+// Lookup returns the instance type for a size and architecture
+// Returns ErrInvalidInput if the catalog has no such size, or not for that architecture
+func (c *Catalog) Lookup(size, arch string) (InstanceType, error) {
+	archMap, ok := c.types[size]
+	if !ok {
+		return InstanceType{}, fmt.Errorf("%w: unsupported size %q (available: %s)", ErrInvalidInput, size, strings.Join(c.Sizes(), ", "))
+	}
+
+	instanceType, ok := archMap[arch]
+	if !ok {
+		return InstanceType{}, fmt.Errorf("%w: unsupported architecture for size %s: %s", ErrInvalidInput, size, arch)
+	}
+
+	return instanceType, nil
+}
+
+// HasSize reports whether the catalog offers a size on any architecture
+func (c *Catalog) HasSize(size string) bool {
+	_, ok := c.types[size]
+	return ok
+}
+
+// Sizes returns the catalog's sizes in alphabetical order
+func (c *Catalog) Sizes() []string {
+	sizes := make([]string, 0, len(c.types))
+	for size := range c.types {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	return sizes
+}
+
+// knownSize reports whether any built-in catalog offers the size
+func knownSize(size string) bool {
+	return awsCatalog.HasSize(size) || dropletCatalog.HasSize(size) || dockerCatalog.HasSize(size) || hetznerCatalog.HasSize(size)
+}
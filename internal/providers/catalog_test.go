@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_WithOverrides(t *testing.T) {
+	catalog, err := awsCatalog.WithOverrides(map[string]map[string]InstanceType{
+		SizeLarge:   {ArchARM64: {Name: "m7g.2xlarge", VCPUs: 8, MemoryGB: 32}},
+		"large-mem": {ArchAMD64: {Name: "r6i.2xlarge", VCPUs: 8, MemoryGB: 64}},
+	})
+	require.NoError(t, err)
+
+	// Overrides replace single architectures
+	instanceType, err := catalog.Lookup(SizeLarge, ArchARM64)
+	require.NoError(t, err)
+	assert.Equal(t, "m7g.2xlarge", instanceType.Name)
+	instanceType, err = catalog.Lookup(SizeLarge, ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, "c5.2xlarge", instanceType.Name)
+
+	// New sizes only exist on the architectures they list
+	instanceType, err = catalog.Lookup("large-mem", ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, 64.0, instanceType.MemoryGB)
+	_, err = catalog.Lookup("large-mem", ArchARM64)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	assert.Equal(t, []string{"large", "large-mem", "medium", "small", "xlarge"}, catalog.Sizes())
+
+	// The default catalog is unchanged
+	instanceType, err = awsCatalog.Lookup(SizeLarge, ArchARM64)
+	require.NoError(t, err)
+	assert.Equal(t, "c6g.2xlarge", instanceType.Name)
+	assert.False(t, awsCatalog.HasSize("large-mem"))
+}
+
+func TestCatalog_InvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]map[string]InstanceType
+	}{
+		{"uppercase size", map[string]map[string]InstanceType{"Large": {ArchAMD64: {Name: "c5.2xlarge"}}}},
+		{"size too long", map[string]map[string]InstanceType{"memory-optimized-large": {ArchAMD64: {Name: "r6i.2xlarge"}}}},
+		{"unknown architecture", map[string]map[string]InstanceType{"large": {"x86": {Name: "c5.2xlarge"}}}},
+		{"missing name", map[string]map[string]InstanceType{"large": {ArchAMD64: {VCPUs: 8}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCatalog(tt.overrides)
+			assert.ErrorIs(t, err, ErrInvalidInput)
+		})
+	}
+}
+
+func TestInstanceSpec_ValidateFor(t *testing.T) {
+	catalog, err := NewCatalog(map[string]map[string]InstanceType{
+		"large-mem": {ArchAMD64: {Name: "r6i.2xlarge", VCPUs: 8, MemoryGB: 64}},
+	})
+	require.NoError(t, err)
+
+	spec := InstanceSpec{Size: "large-mem", Architecture: ArchAMD64, Region: "us-east-1"}
+	assert.NoError(t, spec.ValidateFor(catalog))
+	assert.ErrorIs(t, spec.Validate(), ErrInvalidInput, "custom sizes are unknown to the built-in catalogs")
+
+	spec.Architecture = ArchARM64
+	assert.ErrorIs(t, spec.ValidateFor(catalog), ErrInvalidInput)
+
+	// DigitalOcean has no ARM64 droplets
+	spec = InstanceSpec{Size: SizeSmall, Architecture: ArchARM64, Region: "nyc3"}
+	assert.NoError(t, spec.Validate())
+	assert.ErrorIs(t, spec.ValidateFor(DefaultCatalog("digitalocean")), ErrInvalidInput)
+
+	// Every size of a built-in catalog passes Validate
+	for _, providerType := range []string{"aws", "digitalocean", "docker", "hetzner"} {
+		for _, size := range DefaultCatalog(providerType).Sizes() {
+			spec := InstanceSpec{Size: size, Architecture: ArchAMD64, Region: "local"}
+			assert.NoError(t, spec.Validate(), "%s size %s", providerType, size)
+		}
+	}
+}
+
+func TestMockProvider_SetCatalog(t *testing.T) {
+	catalog, err := DefaultCatalog("hetzner").WithOverrides(map[string]map[string]InstanceType{
+		"large-mem": {ArchAMD64: {Name: "ccx33", VCPUs: 8, MemoryGB: 32}},
+	})
+	require.NoError(t, err)
+
+	provider := NewMockProvider()
+	spec := InstanceSpec{Size: "large-mem", Architecture: ArchAMD64, Region: "fsn1"}
+
+	_, err = provider.LaunchInstance(context.Background(), spec)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	provider.SetCatalog(catalog)
+	_, err = provider.LaunchInstance(context.Background(), spec)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,67 @@
+package providers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stagely-dev/stagely/internal/providers/providertest"
+)
+
+func TestMockProvider_Conformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providers.CloudProvider {
+		return providers.NewMockProviderWithDelay(20 * time.Millisecond)
+	})
+}
+
+func TestWithResilience_Conformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providers.CloudProvider {
+		return providers.WithResilience(providers.NewMockProvider(), providers.DefaultResilienceOptions())
+	})
+}
+
+func TestAWSProvider_Conformance(t *testing.T) {
+	providertest.RunConformanceWithConfig(t, providers.NewFakeAWSProvider, providertest.Config{
+		UnknownInstanceID: "i-0123456789abcdef0",
+	})
+}
+
+func TestDigitalOceanProvider_Conformance(t *testing.T) {
+	providertest.RunConformanceWithConfig(t, providers.NewFakeDigitalOceanProvider, providertest.Config{
+		Region:            "nyc3",
+		UnknownInstanceID: "999999",
+	})
+}
+
+func TestHetznerProvider_Conformance(t *testing.T) {
+	providertest.RunConformanceWithConfig(t, providers.NewFakeHetznerProvider, providertest.Config{
+		Region:            "fsn1",
+		UnknownInstanceID: "999999",
+	})
+}
+
+// TestDockerProvider_Conformance runs the suite against a real Docker Engine (skipped without one)
+func TestDockerProvider_Conformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Docker conformance in short mode")
+	}
+	probe, err := providers.NewDockerProvider()
+	if err == nil {
+		err = probe.ValidateCredentials(context.Background())
+	}
+	if err != nil {
+		t.Skipf("Docker is not available: %v", err)
+	}
+
+	providertest.RunConformanceWithConfig(t, func(t *testing.T) providers.CloudProvider {
+		p, err := providers.NewDockerProviderWithConfig(providers.DockerHostConfig{}, providers.DockerConfig{Image: "alpine:3.20"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}, providertest.Config{
+		Region: "local",
+		Wait:   providers.WaitOptions{PollInterval: 200 * time.Millisecond, Timeout: 2 * time.Minute},
+	})
+}
@@ -0,0 +1,458 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default droplet catalog: size + architecture -> DigitalOcean droplet slug
+// DigitalOcean does not offer ARM64 droplets, so only AMD64 slugs are listed.
+var dropletCatalog = mustCatalog(map[string]map[string]InstanceType{
+	SizeSmall: {
+		ArchAMD64: {Name: "s-2vcpu-4gb", VCPUs: 2, MemoryGB: 4},
+	},
+	SizeMedium: {
+		ArchAMD64: {Name: "c-4", VCPUs: 4, MemoryGB: 8},
+	},
+	SizeLarge: {
+		ArchAMD64: {Name: "c-8", VCPUs: 8, MemoryGB: 16},
+	},
+	SizeXLarge: {
+		ArchAMD64: {Name: "c-16", VCPUs: 16, MemoryGB: 32},
+	},
+})
+
+// Droplet image mapping: architecture -> Ubuntu 22.04 LTS image slug
+var dropletImageMap = map[string]string{
+	ArchAMD64: "ubuntu-22-04-x64",
+}
+
+const digitalOceanBaseURL = "https://api.digitalocean.com"
+
+// dropletTagPattern matches characters that DigitalOcean rejects in tag names
+var dropletTagPattern = regexp.MustCompile(`[^a-zA-Z0-9_:\-]`)
+
+// DropletAPI defines the DigitalOcean operations used by the provider (interface for mocking)
+type DropletAPI interface {
+	CreateDroplet(ctx context.Context, req *DropletCreateRequest) (*Droplet, error)
+	GetDroplet(ctx context.Context, dropletID int) (*Droplet, error)
+	DeleteDroplet(ctx context.Context, dropletID int) error
+	ListDroplets(ctx context.Context, tagName string, page, perPage int) ([]Droplet, error)
+	GetAccount(ctx context.Context) error
+}
+
+// DropletCreateRequest is the body of a DigitalOcean create-droplet call
+type DropletCreateRequest struct {
+	Name     string   `json:"name"`
+	Region   string   `json:"region"`
+	Size     string   `json:"size"`
+	Image    string   `json:"image"`
+	UserData string   `json:"user_data,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Droplet is the subset of the DigitalOcean droplet resource used by the provider
+type Droplet struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Status    string          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	SizeSlug  string          `json:"size_slug"`
+	Networks  DropletNetworks `json:"networks"`
+	Tags      []string        `json:"tags"`
+}
+
+// DropletNetworks lists the addresses attached to a droplet
+type DropletNetworks struct {
+	V4 []DropletNetwork `json:"v4"`
+}
+
+// DropletNetwork is a single droplet address ("public" or "private")
+type DropletNetwork struct {
+	IPAddress string `json:"ip_address"`
+	Type      string `json:"type"`
+}
+
+// address returns the first IPv4 address of the given network type.
+func (d *Droplet) address(networkType string) string {
+	for _, n := range d.Networks.V4 {
+		if n.Type == networkType {
+			return n.IPAddress
+		}
+	}
+	return ""
+}
+
+// DigitalOceanAPIError is returned by the droplet client for non-2xx responses
+type DigitalOceanAPIError struct {
+	StatusCode int
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+}
+
+func (e *DigitalOceanAPIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.ID, e.Message)
+}
+
+// HTTPStatus returns the response status code.
+func (e *DigitalOceanAPIError) HTTPStatus() int { return e.StatusCode }
+
+// ErrorCode returns the DigitalOcean error ID (e.g., "not_found").
+func (e *DigitalOceanAPIError) ErrorCode() string { return e.ID }
+
+// dropletClient is a minimal HTTP client for the DigitalOcean v2 API
+type dropletClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// newDropletClient creates a droplet client for the given API base URL.
+func newDropletClient(baseURL, token string, httpClient *http.Client) *dropletClient {
+	return &dropletClient{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+func (c *dropletClient) CreateDroplet(ctx context.Context, req *DropletCreateRequest) (*Droplet, error) {
+	var out struct {
+		Droplet Droplet `json:"droplet"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v2/droplets", req, &out); err != nil {
+		return nil, err
+	}
+	return &out.Droplet, nil
+}
+
+func (c *dropletClient) GetDroplet(ctx context.Context, dropletID int) (*Droplet, error) {
+	var out struct {
+		Droplet Droplet `json:"droplet"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v2/droplets/"+strconv.Itoa(dropletID), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Droplet, nil
+}
+
+func (c *dropletClient) DeleteDroplet(ctx context.Context, dropletID int) error {
+	return c.do(ctx, http.MethodDelete, "/v2/droplets/"+strconv.Itoa(dropletID), nil, nil)
+}
+
+func (c *dropletClient) ListDroplets(ctx context.Context, tagName string, page, perPage int) ([]Droplet, error) {
+	query := url.Values{}
+	if tagName != "" {
+		query.Set("tag_name", tagName)
+	}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	var out struct {
+		Droplets []Droplet `json:"droplets"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v2/droplets?"+query.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Droplets, nil
+}
+
+func (c *dropletClient) GetAccount(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/v2/account", nil, nil)
+}
+
+// do performs an authenticated JSON request and decodes the response into out (if non-nil).
+func (c *dropletClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &DigitalOceanAPIError{StatusCode: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// DigitalOceanProvider implements CloudProvider for DigitalOcean droplets
+type DigitalOceanProvider struct {
+	client  DropletAPI
+	catalog *Catalog   // nil means dropletCatalog
+	tags    *TagPolicy // nil means the default policy
+}
+
+// DigitalOceanConfig holds the DigitalOcean-specific settings stored in cloud_providers.config
+type DigitalOceanConfig struct {
+	CatalogConfig
+	TagConfig
+}
+
+// NewDigitalOceanProvider creates a new DigitalOcean provider with the given API token.
+func NewDigitalOceanProvider(apiToken string) (*DigitalOceanProvider, error) {
+	return NewDigitalOceanProviderWithConfig(apiToken, DigitalOceanConfig{})
+}
+
+// NewDigitalOceanProviderWithConfig creates a new DigitalOcean provider with provider-specific settings.
+func NewDigitalOceanProviderWithConfig(apiToken string, doConfig DigitalOceanConfig) (*DigitalOceanProvider, error) {
+	if apiToken == "" {
+		return nil, fmt.Errorf("api token is required")
+	}
+
+	catalog, err := dropletCatalog.WithOverrides(doConfig.InstanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NewTagPolicy("digitalocean", doConfig.TagConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DigitalOceanProvider{
+		client:  newDropletClient(digitalOceanBaseURL, apiToken, &http.Client{Timeout: 30 * time.Second}),
+		catalog: catalog,
+		tags:    tags,
+	}, nil
+}
+
+// Name returns the provider identifier.
+func (d *DigitalOceanProvider) Name() string {
+	return "digitalocean"
+}
+
+// Catalog returns the sizes this provider can launch.
+func (d *DigitalOceanProvider) Catalog() *Catalog {
+	if d.catalog == nil {
+		return dropletCatalog
+	}
+	return d.catalog
+}
+
+// TagPolicy returns the policy that stamps and checks the tags of new instances.
+func (d *DigitalOceanProvider) TagPolicy() *TagPolicy {
+	if d.tags == nil {
+		return mustTagPolicy("digitalocean")
+	}
+	return d.tags
+}
+
+// ValidateCredentials verifies that the DigitalOcean API token is valid.
+func (d *DigitalOceanProvider) ValidateCredentials(ctx context.Context) error {
+	return classifyHTTPError("digitalocean", "get account", d.client.GetAccount(ctx))
+}
+
+// CreateInstance provisions a new droplet and waits for its public IP.
+func (d *DigitalOceanProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+	return launchAndWait(ctx, d, spec, DefaultWaitOptions())
+}
+
+// LaunchInstance creates a new droplet without waiting for it to boot.
+func (d *DigitalOceanProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	catalog := d.Catalog()
+	if err := spec.ValidateFor(catalog); err != nil {
+		return "", err
+	}
+	size, err := catalog.Lookup(spec.Size, spec.Architecture)
+	if err != nil {
+		return "", err
+	}
+
+	image, err := getDropletImage(spec.Architecture)
+	if err != nil {
+		return "", err
+	}
+
+	labels, err := d.TagPolicy().Apply(spec.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	tags := make([]string, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		tags = append(tags, dropletTag(k, labels[k]))
+	}
+
+	droplet, err := d.client.CreateDroplet(ctx, &DropletCreateRequest{
+		Name:     instanceName(labels),
+		Region:   spec.Region,
+		Size:     size.Name,
+		Image:    image,
+		UserData: spec.UserData,
+		Tags:     tags,
+	})
+	if err != nil {
+		return "", classifyHTTPError("digitalocean", "create droplet", err)
+	}
+
+	return strconv.Itoa(droplet.ID), nil
+}
+
+// GetInstanceStatus returns the current status of a droplet.
+func (d *DigitalOceanProvider) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+	dropletID, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return InstanceStatus{}, ErrInstanceNotFound
+	}
+
+	droplet, err := d.client.GetDroplet(ctx, dropletID)
+	if err != nil {
+		if isDropletNotFound(err) {
+			return InstanceStatus{}, ErrInstanceNotFound
+		}
+		return InstanceStatus{}, classifyHTTPError("digitalocean", "get droplet", err)
+	}
+
+	return InstanceStatus{
+		State:        mapDropletState(droplet.Status),
+		PublicIP:     droplet.address("public"),
+		PrivateIP:    droplet.address("private"),
+		LaunchedAt:   droplet.CreatedAt,
+		InstanceType: droplet.SizeSlug,
+	}, nil
+}
+
+// ListInstances returns all droplets carrying every tag in tagFilter.
+func (d *DigitalOceanProvider) ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	// The API filters by a single tag; narrow by the first one and match the rest locally
+	var tagName string
+	for k, v := range tagFilter {
+		tagName = dropletTag(k, v)
+		break
+	}
+
+	const perPage = 200
+	var summaries []InstanceSummary
+	for page := 1; ; page++ {
+		droplets, err := d.client.ListDroplets(ctx, tagName, page, perPage)
+		if err != nil {
+			return nil, classifyHTTPError("digitalocean", "list droplets", err)
+		}
+
+		for _, droplet := range droplets {
+			tags := parseDropletTags(droplet.Tags)
+			if !matchesTags(tags, sanitizeDropletFilter(tagFilter)) {
+				continue
+			}
+			summaries = append(summaries, InstanceSummary{
+				ID:         strconv.Itoa(droplet.ID),
+				State:      mapDropletState(droplet.Status),
+				Tags:       tags,
+				LaunchedAt: droplet.CreatedAt,
+			})
+		}
+
+		if len(droplets) < perPage {
+			return summaries, nil
+		}
+	}
+}
+
+// parseDropletTags converts "key:value" droplet tags back into a map.
+func parseDropletTags(tags []string) map[string]string {
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		k, v, _ := strings.Cut(tag, ":")
+		parsed[k] = v
+	}
+	return parsed
+}
+
+// sanitizeDropletFilter applies the same character rules as dropletTag to a tag filter.
+func sanitizeDropletFilter(filter map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(filter))
+	for k, v := range filter {
+		sk, sv, _ := strings.Cut(dropletTag(k, v), ":")
+		sanitized[sk] = sv
+	}
+	return sanitized
+}
+
+func mapDropletState(status string) string {
+	switch status {
+	case "new":
+		return StatePending
+	case "active":
+		return StateRunning
+	case "off":
+		return StateStopped
+	case "archive":
+		return StateTerminated
+	default:
+		return StatePending
+	}
+}
+
+// TerminateInstance deletes a droplet (idempotent).
+func (d *DigitalOceanProvider) TerminateInstance(ctx context.Context, instanceID string) error {
+	dropletID, err := strconv.Atoi(instanceID)
+	if err != nil {
+		// Not a droplet ID, so there is nothing to delete
+		return nil
+	}
+
+	if err := d.client.DeleteDroplet(ctx, dropletID); err != nil {
+		if isDropletNotFound(err) {
+			return nil
+		}
+		return classifyHTTPError("digitalocean", "delete droplet", err)
+	}
+	return nil
+}
+
+func isDropletNotFound(err error) bool {
+	var apiErr *DigitalOceanAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// dropletTag converts a key/value tag into a DigitalOcean tag name ("key:value").
+func dropletTag(key, value string) string {
+	tag := key
+	if value != "" {
+		tag = key + ":" + value
+	}
+	return dropletTagPattern.ReplaceAllString(tag, "_")
+}
+
+// getDropletImage returns the Ubuntu 22.04 LTS image slug for the given architecture.
+func getDropletImage(arch string) (string, error) {
+	image, ok := dropletImageMap[arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture: %s", arch)
+	}
+	return image, nil
+}
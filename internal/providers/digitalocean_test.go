@@ -0,0 +1,312 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time interface compliance check
+var _ CloudProvider = (*DigitalOceanProvider)(nil)
+
+// fakeDigitalOcean is an in-memory stand-in for the DigitalOcean droplet API
+type fakeDigitalOcean struct {
+	mu       sync.Mutex
+	token    string
+	nextID   int
+	droplets map[int]*Droplet
+	created  []DropletCreateRequest
+}
+
+func newFakeDigitalOcean(t *testing.T, token string) (*fakeDigitalOcean, *httptest.Server) {
+	fake := &fakeDigitalOcean{
+		token:    token,
+		nextID:   1000,
+		droplets: make(map[int]*Droplet),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeDigitalOcean) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		writeDOError(w, http.StatusUnauthorized, "unauthorized", "Unable to authenticate you")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/account":
+		_ = json.NewEncoder(w).Encode(map[string]any{"account": map[string]any{"status": "active"}})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/droplets":
+		tagName := r.URL.Query().Get("tag_name")
+		droplets := make([]*Droplet, 0, len(f.droplets))
+		for _, d := range f.droplets {
+			if tagName == "" || slices.Contains(d.Tags, tagName) {
+				droplets = append(droplets, d)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"droplets": droplets})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/droplets":
+		var req DropletCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDOError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		f.created = append(f.created, req)
+		f.nextID++
+		droplet := &Droplet{
+			ID:        f.nextID,
+			Name:      req.Name,
+			Status:    "active",
+			Tags:      req.Tags,
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+			SizeSlug:  req.Size,
+			Networks: DropletNetworks{V4: []DropletNetwork{
+				{IPAddress: "10.10.0.5", Type: "private"},
+				{IPAddress: "203.0.113.10", Type: "public"},
+			}},
+		}
+		f.droplets[droplet.ID] = droplet
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]any{"droplet": droplet})
+
+	case strings.HasPrefix(r.URL.Path, "/v2/droplets/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/v2/droplets/"))
+		droplet, ok := f.droplets[id]
+		if !ok {
+			writeDOError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.droplets, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"droplet": droplet})
+
+	default:
+		writeDOError(w, http.StatusNotFound, "not_found", "unknown route")
+	}
+}
+
+func writeDOError(w http.ResponseWriter, status int, id, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"id": id, "message": message})
+}
+
+func newTestDigitalOceanProvider(server *httptest.Server, token string) *DigitalOceanProvider {
+	return &DigitalOceanProvider{
+		client: newDropletClient(server.URL, token, server.Client()),
+	}
+}
+
+func TestNewDigitalOceanProvider(t *testing.T) {
+	provider, err := NewDigitalOceanProvider("dop_v1_token")
+	require.NoError(t, err)
+	assert.Equal(t, "digitalocean", provider.Name())
+
+	provider, err = NewDigitalOceanProvider("")
+	assert.Error(t, err)
+	assert.Nil(t, provider)
+}
+
+func TestDropletCatalog(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		arch        string
+		expected    string
+		expectError bool
+	}{
+		{"small amd64", SizeSmall, ArchAMD64, "s-2vcpu-4gb", false},
+		{"medium amd64", SizeMedium, ArchAMD64, "c-4", false},
+		{"large amd64", SizeLarge, ArchAMD64, "c-8", false},
+		{"xlarge amd64", SizeXLarge, ArchAMD64, "c-16", false},
+		{"arm64 unsupported", SizeSmall, ArchARM64, "", true},
+		{"invalid size", "invalid", ArchAMD64, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dropletCatalog.Lookup(tt.size, tt.arch)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidInput)
+				assert.Empty(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Name)
+			}
+		})
+	}
+}
+
+func TestMapDropletState(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{"new", StatePending},
+		{"active", StateRunning},
+		{"off", StateStopped},
+		{"archive", StateTerminated},
+		{"weird", StatePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mapDropletState(tt.status))
+		})
+	}
+}
+
+func TestDropletTag(t *testing.T) {
+	assert.Equal(t, "env:test", dropletTag("env", "test"))
+	assert.Equal(t, "stagelet_id", dropletTag("stagelet_id", ""))
+	assert.Equal(t, "owner:a_b_c", dropletTag("owner", "a b.c"))
+}
+
+func TestDigitalOceanProvider_ValidateCredentials(t *testing.T) {
+	_, server := newFakeDigitalOcean(t, "good-token")
+
+	provider := newTestDigitalOceanProvider(server, "good-token")
+	assert.NoError(t, provider.ValidateCredentials(context.Background()))
+
+	provider = newTestDigitalOceanProvider(server, "bad-token")
+	assert.ErrorIs(t, provider.ValidateCredentials(context.Background()), ErrInvalidCredentials)
+}
+
+func TestDigitalOceanProvider_CreateInstance(t *testing.T) {
+	fake, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "token")
+
+	spec := InstanceSpec{
+		Size:         SizeMedium,
+		Architecture: ArchAMD64,
+		Region:       "nyc3",
+		UserData:     "#cloud-config\npackages: [docker.io]",
+		Tags:         map[string]string{"env": "test"},
+	}
+
+	instanceID, publicIP, err := provider.CreateInstance(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, "1001", instanceID)
+	assert.Equal(t, "203.0.113.10", publicIP)
+
+	require.Len(t, fake.created, 1)
+	req := fake.created[0]
+	assert.Equal(t, "nyc3", req.Region)
+	assert.Equal(t, "c-4", req.Size)
+	assert.Equal(t, "ubuntu-22-04-x64", req.Image)
+	assert.Equal(t, spec.UserData, req.UserData)
+	assert.ElementsMatch(t, []string{"managed-by:stagely", "env:test"}, req.Tags)
+}
+
+func TestDigitalOceanProvider_CreateInstance_InvalidSpec(t *testing.T) {
+	fake, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "token")
+
+	_, _, err := provider.CreateInstance(context.Background(), InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchARM64,
+		Region:       "nyc3",
+	})
+	assert.Error(t, err)
+	assert.Empty(t, fake.created)
+}
+
+func TestDigitalOceanProvider_GetInstanceStatus(t *testing.T) {
+	_, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "token")
+	ctx := context.Background()
+
+	instanceID, publicIP, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "ams3",
+	})
+	require.NoError(t, err)
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, status.State)
+	assert.Equal(t, publicIP, status.PublicIP)
+	assert.Equal(t, "10.10.0.5", status.PrivateIP)
+	assert.Equal(t, "s-2vcpu-4gb", status.InstanceType)
+	assert.False(t, status.LaunchedAt.IsZero())
+
+	_, err = provider.GetInstanceStatus(ctx, "999999")
+	assert.ErrorIs(t, err, ErrInstanceNotFound)
+
+	_, err = provider.GetInstanceStatus(ctx, "not-a-droplet")
+	assert.ErrorIs(t, err, ErrInstanceNotFound)
+}
+
+func TestDigitalOceanProvider_TerminateInstance(t *testing.T) {
+	fake, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "token")
+	ctx := context.Background()
+
+	instanceID, _, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "ams3",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+	assert.Empty(t, fake.droplets)
+
+	// Idempotent - deleting again is not an error
+	assert.NoError(t, provider.TerminateInstance(ctx, instanceID))
+}
+
+func TestDigitalOceanProvider_TerminateInstance_Error(t *testing.T) {
+	_, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "wrong-token")
+
+	err := provider.TerminateInstance(context.Background(), "1001")
+	assert.Error(t, err)
+}
+
+func TestDigitalOceanProvider_ListInstances(t *testing.T) {
+	_, server := newFakeDigitalOcean(t, "token")
+	provider := newTestDigitalOceanProvider(server, "token")
+	ctx := context.Background()
+
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "nyc3"}
+
+	spec.Tags = map[string]string{"env": "prod"}
+	prodID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	spec.Tags = map[string]string{"env": "dev"}
+	_, _, err = provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	all, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	prod, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely, "env": "prod"})
+	require.NoError(t, err)
+	require.Len(t, prod, 1)
+	assert.Equal(t, prodID, prod[0].ID)
+	assert.Equal(t, StateRunning, prod[0].State)
+	assert.Equal(t, "prod", prod[0].Tags["env"])
+}
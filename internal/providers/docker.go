@@ -0,0 +1,408 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stagely-dev/stagely/internal/cloudinit"
+	"github.com/stagely-dev/stagely/pkg/nanoid"
+)
+
+// Default container catalog: names only describe the resource limits, both architectures
+// use the same image (Docker pulls the matching variant)
+var dockerCatalog = mustCatalog(map[string]map[string]InstanceType{
+	SizeSmall: {
+		ArchAMD64: {Name: "2cpu-4gb", VCPUs: 2, MemoryGB: 4},
+		ArchARM64: {Name: "2cpu-4gb", VCPUs: 2, MemoryGB: 4},
+	},
+	SizeMedium: {
+		ArchAMD64: {Name: "4cpu-8gb", VCPUs: 4, MemoryGB: 8},
+		ArchARM64: {Name: "4cpu-8gb", VCPUs: 4, MemoryGB: 8},
+	},
+	SizeLarge: {
+		ArchAMD64: {Name: "8cpu-16gb", VCPUs: 8, MemoryGB: 16},
+		ArchARM64: {Name: "8cpu-16gb", VCPUs: 8, MemoryGB: 16},
+	},
+	SizeXLarge: {
+		ArchAMD64: {Name: "16cpu-32gb", VCPUs: 16, MemoryGB: 32},
+		ArchARM64: {Name: "16cpu-32gb", VCPUs: 16, MemoryGB: 32},
+	},
+})
+
+const (
+	dockerDefaultImage   = "ubuntu:22.04"
+	dockerDefaultNetwork = "bridge"
+
+	// dockerLabelInstanceType records the catalog instance type, since containers have none
+	dockerLabelInstanceType = "stagely.instance-type"
+
+	// dockerUserDataEnv carries the base64-encoded bootstrap script into the container
+	dockerUserDataEnv = "STAGELY_USER_DATA"
+
+	dockerStopTimeout = 10 // seconds
+)
+
+// dockerInitScript is the container's PID 1. It plays the part of cloud-init: the bootstrap
+// script runs once per container (not again after a stop/start), its output goes where
+// cloud-init would put it, and the shell then idles so the container keeps running.
+const dockerInitScript = `seed=/var/lib/cloud/instance
+if [ -n "${` + dockerUserDataEnv + `:-}" ] && [ ! -e "$seed/boot-finished" ]; then
+  mkdir -p "$seed"
+  printf '%s' "$` + dockerUserDataEnv + `" | base64 -d > "$seed/user-data"
+  chmod 0755 "$seed/user-data"
+  "$seed/user-data" >> /var/log/cloud-init-output.log 2>&1
+  touch "$seed/boot-finished"
+fi
+exec tail -f /dev/null`
+
+// DockerAPI defines the Docker Engine operations used by the provider (interface for mocking)
+// Satisfied by *client.Client
+type DockerAPI interface {
+	Ping(ctx context.Context) (types.Ping, error)
+	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}
+
+// DockerProvider implements CloudProvider with local Docker containers standing in for VMs.
+// It needs no cloud account and runs on the server's own Docker Engine, so it is only meant
+// for development and CI: the factory knows it only once the operator enables it (see
+// Factory.EnableDocker).
+type DockerProvider struct {
+	client  DockerAPI
+	host    DockerHostConfig
+	config  DockerConfig
+	catalog *Catalog   // nil means dockerCatalog
+	tags    *TagPolicy // nil means the default policy
+}
+
+// DockerHostConfig holds the settings of the Docker Engine the containers run on.
+// They give containers access to the server itself, so they come from the server's
+// configuration, never from cloud_providers.config.
+type DockerHostConfig struct {
+	// Host is the Docker Engine endpoint (e.g., "unix:///var/run/docker.sock").
+	// Defaults to DOCKER_HOST, then the local socket.
+	Host string
+
+	// Network the containers join (default "bridge"). The container IP is only reachable
+	// from the host when Docker runs natively (Linux), not inside a VM (Docker Desktop).
+	Network string
+
+	// Privileged runs containers privileged, which Docker-in-Docker builds need
+	Privileged bool
+
+	// MountDockerSocket shares the host's Docker socket with every container instead,
+	// so builds run on the host daemon
+	MountDockerSocket bool
+}
+
+// DockerConfig holds the Docker-specific settings stored in cloud_providers.config
+type DockerConfig struct {
+	CatalogConfig
+	TagConfig
+
+	// Image is the base image of every container (default "ubuntu:22.04")
+	// It needs /bin/sh, base64 and tail to run the bootstrap.
+	Image string `json:"image,omitempty"`
+
+	// LimitResources caps CPU and memory at the catalog instance type.
+	// Off by default, since the daemon rejects CPU limits above the host's core count.
+	LimitResources bool `json:"limit_resources,omitempty"`
+}
+
+// NewDockerProvider creates a Docker provider for the local Docker Engine.
+func NewDockerProvider() (*DockerProvider, error) {
+	return NewDockerProviderWithConfig(DockerHostConfig{}, DockerConfig{})
+}
+
+// NewDockerProviderWithConfig creates a Docker provider on the given engine with provider-specific settings.
+func NewDockerProviderWithConfig(hostConfig DockerHostConfig, dockerConfig DockerConfig) (*DockerProvider, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if hostConfig.Host != "" {
+		opts = append(opts, client.WithHost(hostConfig.Host))
+	}
+
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	return newDockerProviderWithClient(dockerClient, hostConfig, dockerConfig)
+}
+
+// newDockerProviderWithClient creates a Docker provider with an injected client (for testing).
+func newDockerProviderWithClient(dockerClient DockerAPI, hostConfig DockerHostConfig, dockerConfig DockerConfig) (*DockerProvider, error) {
+	catalog, err := dockerCatalog.WithOverrides(dockerConfig.InstanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NewTagPolicy("docker", dockerConfig.TagConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if dockerConfig.Image == "" {
+		dockerConfig.Image = dockerDefaultImage
+	}
+	if hostConfig.Network == "" {
+		hostConfig.Network = dockerDefaultNetwork
+	}
+
+	return &DockerProvider{
+		client:  dockerClient,
+		host:    hostConfig,
+		config:  dockerConfig,
+		catalog: catalog,
+		tags:    tags,
+	}, nil
+}
+
+// Name returns the provider identifier.
+func (d *DockerProvider) Name() string {
+	return "docker"
+}
+
+// Catalog returns the sizes this provider can launch.
+func (d *DockerProvider) Catalog() *Catalog {
+	if d.catalog == nil {
+		return dockerCatalog
+	}
+	return d.catalog
+}
+
+// TagPolicy returns the policy that stamps and checks the tags of new instances.
+func (d *DockerProvider) TagPolicy() *TagPolicy {
+	if d.tags == nil {
+		return mustTagPolicy("docker")
+	}
+	return d.tags
+}
+
+// ValidateCredentials verifies that the Docker Engine is reachable.
+func (d *DockerProvider) ValidateCredentials(ctx context.Context) error {
+	_, err := d.client.Ping(ctx)
+	return classifyDockerError("ping", err)
+}
+
+// CreateInstance starts a new container and waits for its IP.
+func (d *DockerProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+	return launchAndWait(ctx, d, spec, DefaultWaitOptions())
+}
+
+// LaunchInstance creates and starts a new container without waiting for its IP.
+// The image is pulled on first use.
+func (d *DockerProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	catalog := d.Catalog()
+	if err := spec.ValidateFor(catalog); err != nil {
+		return "", err
+	}
+	instanceType, err := catalog.Lookup(spec.Size, spec.Architecture)
+	if err != nil {
+		return "", err
+	}
+
+	bootstrap, err := cloudinit.Script(spec.UserData)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	labels, err := d.TagPolicy().Apply(spec.Tags)
+	if err != nil {
+		return "", err
+	}
+	labels[dockerLabelInstanceType] = instanceType.Name
+
+	config := &container.Config{
+		Image:      d.config.Image,
+		Entrypoint: []string{"/bin/sh", "-c", dockerInitScript},
+		Labels:     labels,
+	}
+	if bootstrap != "" {
+		config.Env = []string{dockerUserDataEnv + "=" + base64.StdEncoding.EncodeToString([]byte(bootstrap))}
+	}
+
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode(d.host.Network),
+		Privileged:  d.host.Privileged,
+	}
+	if d.config.LimitResources {
+		hostConfig.NanoCPUs = int64(instanceType.VCPUs) * 1e9
+		hostConfig.Memory = int64(instanceType.MemoryGB * (1 << 30))
+	}
+	if d.host.MountDockerSocket {
+		hostConfig.Binds = []string{"/var/run/docker.sock:/var/run/docker.sock"}
+	}
+
+	platform := &ocispec.Platform{OS: "linux", Architecture: spec.Architecture}
+	name := instanceName(labels) + "-" + nanoid.Generate()
+
+	created, err := d.client.ContainerCreate(ctx, config, hostConfig, nil, platform, name)
+	if cerrdefs.IsNotFound(err) {
+		// The image (or this architecture of it) is not available locally yet
+		if err := d.pullImage(ctx, spec.Architecture); err != nil {
+			return "", err
+		}
+		created, err = d.client.ContainerCreate(ctx, config, hostConfig, nil, platform, name)
+	}
+	if err != nil {
+		return "", classifyDockerError("create container", err)
+	}
+
+	if err := d.client.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		// Don't leave a container behind that the caller has no ID for
+		_ = d.client.ContainerRemove(context.WithoutCancel(ctx), created.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+		return "", classifyDockerError("start container", err)
+	}
+
+	return created.ID, nil
+}
+
+// pullImage pulls the configured image for the given architecture and waits for the pull to finish.
+func (d *DockerProvider) pullImage(ctx context.Context, arch string) error {
+	progress, err := d.client.ImagePull(ctx, d.config.Image, image.PullOptions{Platform: "linux/" + arch})
+	if err != nil {
+		return classifyDockerError("pull image", err)
+	}
+	defer func() { _ = progress.Close() }()
+
+	// Errors during the pull are reported in the progress stream, not by ImagePull
+	if err := jsonmessage.DisplayJSONMessagesStream(progress, io.Discard, 0, false, nil); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &ProviderError{Provider: "docker", Op: "pull image", Kind: ErrInvalidInput, Err: err}
+	}
+	return nil
+}
+
+// GetInstanceStatus returns the current status of a container.
+func (d *DockerProvider) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+	inspect, err := d.client.ContainerInspect(ctx, instanceID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return InstanceStatus{}, ErrInstanceNotFound
+		}
+		return InstanceStatus{}, classifyDockerError("inspect container", err)
+	}
+
+	status := InstanceStatus{State: StatePending}
+	if inspect.ContainerJSONBase != nil {
+		if inspect.State != nil {
+			status.State = mapDockerState(string(inspect.State.Status))
+		}
+		status.LaunchedAt, _ = time.Parse(time.RFC3339Nano, inspect.Created)
+	}
+	if inspect.Config != nil {
+		status.InstanceType = inspect.Config.Labels[dockerLabelInstanceType]
+	}
+
+	// A container only has an address while it runs; it is both public and private
+	if status.State == StateRunning {
+		ip := d.containerIP(inspect.NetworkSettings)
+		status.PublicIP = ip
+		status.PrivateIP = ip
+	}
+
+	return status, nil
+}
+
+// containerIP returns the container's address on the configured network, or on any network
+// if the configured one is not attached (empty if none).
+func (d *DockerProvider) containerIP(settings *container.NetworkSettings) string {
+	if settings == nil {
+		return ""
+	}
+	if endpoint := settings.Networks[d.host.Network]; endpoint != nil && endpoint.IPAddress != "" {
+		return endpoint.IPAddress
+	}
+
+	names := make([]string, 0, len(settings.Networks))
+	for name := range settings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if endpoint := settings.Networks[name]; endpoint != nil && endpoint.IPAddress != "" {
+			return endpoint.IPAddress
+		}
+	}
+	return ""
+}
+
+// ListInstances returns all containers carrying every label in tagFilter.
+func (d *DockerProvider) ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	args := filters.NewArgs()
+	for k, v := range tagFilter {
+		args.Add("label", k+"="+v)
+	}
+
+	containers, err := d.client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, classifyDockerError("list containers", err)
+	}
+
+	summaries := make([]InstanceSummary, 0, len(containers))
+	for _, c := range containers {
+		summaries = append(summaries, InstanceSummary{
+			ID:         c.ID,
+			State:      mapDockerState(string(c.State)),
+			Tags:       c.Labels,
+			LaunchedAt: time.Unix(c.Created, 0),
+		})
+	}
+	return summaries, nil
+}
+
+func mapDockerState(state string) string {
+	switch state {
+	case container.StateCreated, container.StateRestarting:
+		return StatePending
+	case container.StateRunning:
+		return StateRunning
+	case container.StatePaused, container.StateExited:
+		return StateStopped
+	case container.StateRemoving, container.StateDead:
+		return StateTerminated
+	default:
+		return StatePending
+	}
+}
+
+// TerminateInstance removes a container and its anonymous volumes (idempotent).
+func (d *DockerProvider) TerminateInstance(ctx context.Context, instanceID string) error {
+	err := d.client.ContainerRemove(ctx, instanceID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return classifyDockerError("remove container", err)
+	}
+	return nil
+}
+
+// StopInstance stops a running container, keeping its filesystem (idempotent).
+func (d *DockerProvider) StopInstance(ctx context.Context, instanceID string) error {
+	timeout := dockerStopTimeout
+	err := d.client.ContainerStop(ctx, instanceID, container.StopOptions{Timeout: &timeout})
+	return classifyDockerError("stop container", err)
+}
+
+// StartInstance restarts a stopped container (idempotent). The bootstrap does not run again.
+func (d *DockerProvider) StartInstance(ctx context.Context, instanceID string) error {
+	err := d.client.ContainerStart(ctx, instanceID, container.StartOptions{})
+	return classifyDockerError("start container", err)
+}
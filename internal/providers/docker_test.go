@@ -0,0 +1,443 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time interface compliance checks
+var (
+	_ CloudProvider   = (*DockerProvider)(nil)
+	_ InstanceStopper = (*DockerProvider)(nil)
+	_ DockerAPI       = (*client.Client)(nil)
+)
+
+// dockerVersionPrefix matches the API version prefix of Engine API paths (e.g., "/v1.47")
+var dockerVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+// fakeDockerContainer is a container held by fakeDocker
+type fakeDockerContainer struct {
+	id         string
+	name       string
+	platform   string
+	state      string
+	ip         string
+	created    time.Time
+	config     container.Config
+	hostConfig container.HostConfig
+}
+
+// fakeDocker is an in-memory stand-in for the Docker Engine API
+type fakeDocker struct {
+	mu          sync.Mutex
+	images      map[string]bool // Pulled image references
+	brokenImage string          // Pulls of this image fail mid-stream
+	nextID      int
+	containers  map[string]*fakeDockerContainer
+	pulls       []string
+}
+
+func newFakeDocker(t *testing.T) (*fakeDocker, *httptest.Server) {
+	fake := &fakeDocker{
+		images:     make(map[string]bool),
+		containers: make(map[string]*fakeDockerContainer),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("API-Version", "1.47")
+	path := dockerVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	query := r.URL.Query()
+
+	switch {
+	case path == "/_ping":
+		_, _ = w.Write([]byte("OK"))
+
+	case r.Method == http.MethodPost && path == "/images/create":
+		// The client sends normalized names (docker.io/library/ubuntu)
+		ref := strings.TrimPrefix(query.Get("fromImage"), "docker.io/library/") + ":" + query.Get("tag")
+		f.pulls = append(f.pulls, ref+"@"+query.Get("platform"))
+		if ref == f.brokenImage {
+			_ = json.NewEncoder(w).Encode(map[string]any{"errorDetail": map[string]string{"message": "manifest unknown"}, "error": "manifest unknown"})
+			return
+		}
+		f.images[ref] = true
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "Downloaded newer image for " + ref})
+
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var req container.CreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDockerError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !f.images[req.Image] {
+			writeDockerError(w, http.StatusNotFound, "No such image: "+req.Image)
+			return
+		}
+		f.nextID++
+		id := fmt.Sprintf("%064x", f.nextID)
+		f.containers[id] = &fakeDockerContainer{
+			id:         id,
+			name:       query.Get("name"),
+			platform:   query.Get("platform"),
+			state:      container.StateCreated,
+			created:    time.Now().UTC(),
+			config:     *req.Config,
+			hostConfig: *req.HostConfig,
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(container.CreateResponse{ID: id})
+
+	case r.Method == http.MethodGet && path == "/containers/json":
+		args, _ := filters.FromJSON(query.Get("filters"))
+		summaries := []container.Summary{}
+		for _, c := range f.containers {
+			if matchesDockerLabels(c.config.Labels, args.Get("label")) {
+				summaries = append(summaries, container.Summary{
+					ID:      c.id,
+					Created: c.created.Unix(),
+					State:   c.state,
+					Labels:  c.config.Labels,
+				})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(summaries)
+
+	case strings.HasPrefix(path, "/containers/"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/containers/"), "/")
+		c, ok := f.containers[id]
+		if !ok {
+			writeDockerError(w, http.StatusNotFound, "No such container: "+id)
+			return
+		}
+		f.containerAction(w, r, c, action)
+
+	default:
+		writeDockerError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (f *fakeDocker) containerAction(w http.ResponseWriter, r *http.Request, c *fakeDockerContainer, action string) {
+	switch {
+	case r.Method == http.MethodDelete && action == "":
+		delete(f.containers, c.id)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPost && action == "start":
+		if c.state == container.StateRunning {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.state = container.StateRunning
+		c.ip = fmt.Sprintf("172.17.0.%d", len(f.containers)+1)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPost && action == "stop":
+		if c.state != container.StateRunning {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.state = container.StateExited
+		c.ip = ""
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && action == "json":
+		networks := map[string]*network.EndpointSettings{}
+		if c.ip != "" {
+			networks[string(c.hostConfig.NetworkMode)] = &network.EndpointSettings{IPAddress: c.ip}
+		}
+		config := c.config
+		_ = json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				ID:      c.id,
+				Name:    "/" + c.name,
+				Created: c.created.Format(time.RFC3339Nano),
+				State:   &container.State{Status: c.state},
+			},
+			Config:          &config,
+			NetworkSettings: &container.NetworkSettings{Networks: networks},
+		})
+
+	default:
+		writeDockerError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func matchesDockerLabels(labels map[string]string, selectors []string) bool {
+	for _, selector := range selectors {
+		k, v, _ := strings.Cut(selector, "=")
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func writeDockerError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func newTestDockerProvider(t *testing.T, server *httptest.Server, hostConfig DockerHostConfig, config DockerConfig) *DockerProvider {
+	t.Helper()
+	dockerClient, err := client.NewClientWithOpts(
+		client.WithHost("tcp://"+server.Listener.Addr().String()),
+		client.WithAPIVersionNegotiation(),
+	)
+	require.NoError(t, err)
+
+	provider, err := newDockerProviderWithClient(dockerClient, hostConfig, config)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestNewDockerProvider(t *testing.T) {
+	provider, err := NewDockerProviderWithConfig(DockerHostConfig{Host: "tcp://127.0.0.1:2375"}, DockerConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "docker", provider.Name())
+	assert.Equal(t, dockerDefaultImage, provider.config.Image)
+	assert.Equal(t, dockerDefaultNetwork, provider.host.Network)
+
+	_, err = NewDockerProviderWithConfig(DockerHostConfig{Host: "not a host"}, DockerConfig{})
+	assert.Error(t, err)
+}
+
+func TestDockerCatalog(t *testing.T) {
+	for _, size := range []string{SizeSmall, SizeMedium, SizeLarge, SizeXLarge} {
+		amd64, err := dockerCatalog.Lookup(size, ArchAMD64)
+		require.NoError(t, err)
+		arm64, err := dockerCatalog.Lookup(size, ArchARM64)
+		require.NoError(t, err)
+		assert.Equal(t, amd64, arm64, size)
+	}
+}
+
+func TestMapDockerState(t *testing.T) {
+	tests := []struct {
+		state    string
+		expected string
+	}{
+		{"created", StatePending},
+		{"restarting", StatePending},
+		{"running", StateRunning},
+		{"paused", StateStopped},
+		{"exited", StateStopped},
+		{"removing", StateTerminated},
+		{"dead", StateTerminated},
+		{"unknown", StatePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mapDockerState(tt.state))
+		})
+	}
+}
+
+func TestDockerProvider_ValidateCredentials(t *testing.T) {
+	_, server := newFakeDocker(t)
+	provider := newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{})
+	assert.NoError(t, provider.ValidateCredentials(context.Background()))
+
+	server.Close()
+	err := provider.ValidateCredentials(context.Background())
+	assert.ErrorIs(t, err, ErrNetworkFailure)
+	assert.True(t, IsRetryable(err))
+}
+
+func TestDockerProvider_CreateInstance(t *testing.T) {
+	fake, server := newFakeDocker(t)
+	provider := newTestDockerProvider(t, server, DockerHostConfig{Privileged: true}, DockerConfig{LimitResources: true})
+
+	spec := InstanceSpec{
+		Size:         SizeMedium,
+		Architecture: ArchARM64,
+		Region:       "local",
+		UserData:     "#!/bin/sh\necho hello\n",
+		Tags:         map[string]string{"env": "test", "owner": "a b"},
+	}
+
+	instanceID, publicIP, err := provider.CreateInstance(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, "172.17.0.2", publicIP)
+
+	// The image was missing, so it was pulled for the requested architecture
+	assert.Equal(t, []string{"ubuntu:22.04@linux/arm64"}, fake.pulls)
+
+	require.Contains(t, fake.containers, instanceID)
+	c := fake.containers[instanceID]
+	assert.True(t, strings.HasPrefix(c.name, "stagely-vm-"))
+	assert.Equal(t, "linux/arm64", c.platform)
+	assert.Equal(t, "ubuntu:22.04", c.config.Image)
+	assert.Equal(t, []string{"/bin/sh", "-c", dockerInitScript}, []string(c.config.Entrypoint))
+	assert.Equal(t, map[string]string{
+		"env":                   "test",
+		"owner":                 "a b",
+		"managed-by":            "stagely",
+		"stagely.instance-type": "4cpu-8gb",
+	}, c.config.Labels)
+	assert.Equal(t, []string{"STAGELY_USER_DATA=" + base64.StdEncoding.EncodeToString([]byte(spec.UserData))}, c.config.Env)
+	assert.Equal(t, int64(4e9), c.hostConfig.NanoCPUs)
+	assert.Equal(t, int64(8<<30), c.hostConfig.Memory)
+	assert.True(t, c.hostConfig.Privileged)
+	assert.Empty(t, c.hostConfig.Binds)
+
+	// The second container reuses the pulled image
+	_, _, err = provider.CreateInstance(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Len(t, fake.pulls, 1)
+}
+
+func TestDockerProvider_CreateInstance_Defaults(t *testing.T) {
+	fake, server := newFakeDocker(t)
+	fake.images["alpine:3.20"] = true
+	provider := newTestDockerProvider(t, server, DockerHostConfig{MountDockerSocket: true}, DockerConfig{Image: "alpine:3.20"})
+
+	instanceID, _, err := provider.CreateInstance(context.Background(), InstanceSpec{
+		Size:         SizeXLarge,
+		Architecture: ArchAMD64,
+		Region:       "local",
+	})
+	require.NoError(t, err)
+
+	c := fake.containers[instanceID]
+	assert.Empty(t, fake.pulls)
+	assert.Empty(t, c.config.Env)
+	assert.Equal(t, container.NetworkMode("bridge"), c.hostConfig.NetworkMode)
+	assert.Zero(t, c.hostConfig.NanoCPUs)
+	assert.Zero(t, c.hostConfig.Memory)
+	assert.Equal(t, []string{"/var/run/docker.sock:/var/run/docker.sock"}, c.hostConfig.Binds)
+}
+
+func TestDockerProvider_CreateInstance_Errors(t *testing.T) {
+	fake, server := newFakeDocker(t)
+	fake.brokenImage = "ghcr.io/example/missing:latest"
+	ctx := context.Background()
+
+	provider := newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{Image: fake.brokenImage})
+	_, err := provider.LaunchInstance(ctx, InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "local"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorContains(t, err, "manifest unknown")
+
+	provider = newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{})
+	_, err = provider.LaunchInstance(ctx, InstanceSpec{Size: "tiny", Architecture: ArchAMD64, Region: "local"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = provider.LaunchInstance(ctx, InstanceSpec{
+		Size: SizeSmall, Architecture: ArchAMD64, Region: "local", UserData: "Content-Type: multipart/mixed",
+	})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.Empty(t, fake.containers)
+}
+
+func TestDockerProvider_GetInstanceStatus(t *testing.T) {
+	fake, server := newFakeDocker(t)
+	provider := newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{})
+	ctx := context.Background()
+
+	instanceID, _, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "local",
+	})
+	require.NoError(t, err)
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+	assert.Equal(t, "172.17.0.2", status.PublicIP)
+	assert.Equal(t, status.PublicIP, status.PrivateIP)
+	assert.Equal(t, "2cpu-4gb", status.InstanceType)
+	assert.WithinDuration(t, time.Now(), status.LaunchedAt, time.Minute)
+
+	// Stopped containers lose their address
+	fake.mu.Lock()
+	fake.containers[instanceID].state = container.StateExited
+	fake.mu.Unlock()
+
+	status, err = provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateStopped, status.State)
+	assert.Empty(t, status.PublicIP)
+
+	_, err = provider.GetInstanceStatus(ctx, "does-not-exist")
+	assert.ErrorIs(t, err, ErrInstanceNotFound)
+}
+
+func TestDockerProvider_StopStartTerminate(t *testing.T) {
+	fake, server := newFakeDocker(t)
+	provider := newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{})
+	ctx := context.Background()
+
+	instanceID, _, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "local",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.StopInstance(ctx, instanceID))
+	require.NoError(t, provider.StopInstance(ctx, instanceID))
+	assert.Equal(t, container.StateExited, fake.containers[instanceID].state)
+
+	require.NoError(t, provider.StartInstance(ctx, instanceID))
+	require.NoError(t, provider.StartInstance(ctx, instanceID))
+	assert.Equal(t, container.StateRunning, fake.containers[instanceID].state)
+
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+	assert.Empty(t, fake.containers)
+
+	// Removing a container that is already gone is treated as success
+	assert.NoError(t, provider.TerminateInstance(ctx, instanceID))
+	assert.ErrorIs(t, provider.StartInstance(ctx, instanceID), ErrInstanceNotFound)
+}
+
+func TestDockerProvider_ListInstances(t *testing.T) {
+	_, server := newFakeDocker(t)
+	provider := newTestDockerProvider(t, server, DockerHostConfig{}, DockerConfig{})
+	ctx := context.Background()
+
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "local"}
+
+	spec.Tags = map[string]string{"env": "prod"}
+	prodID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	spec.Tags = map[string]string{"env": "dev"}
+	_, _, err = provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	all, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	prod, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely, "env": "prod"})
+	require.NoError(t, err)
+	require.Len(t, prod, 1)
+	assert.Equal(t, prodID, prod[0].ID)
+	assert.Equal(t, StateRunning, prod[0].State)
+	assert.Equal(t, "prod", prod[0].Tags["env"])
+}
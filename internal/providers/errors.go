@@ -0,0 +1,276 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/client"
+)
+
+// Suggested delays before retrying, by failure kind
+const (
+	retryAfterCapacity    = 30 * time.Second
+	retryAfterRateLimited = 5 * time.Second
+	retryAfterNetwork     = 2 * time.Second
+)
+
+// ProviderError describes a failed provider API call.
+// It unwraps to both the normalized sentinel (Kind) and the underlying SDK/HTTP error,
+// so callers can use errors.Is(err, ErrQuotaExceeded) and still inspect the raw cause.
+type ProviderError struct {
+	Provider   string        // Provider identifier (e.g., "aws")
+	Op         string        // Operation that failed (e.g., "run instances")
+	Code       string        // Provider-specific error code (e.g., "InsufficientInstanceCapacity")
+	Kind       error         // Normalized sentinel (ErrQuotaExceeded, ErrNetworkFailure, ...), nil if unknown
+	Retryable  bool          // Whether retrying the same call may succeed
+	RetryAfter time.Duration // Suggested delay before retrying (0 if no suggestion)
+	Err        error         // Underlying error
+}
+
+func (e *ProviderError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Provider)
+	b.WriteString(": ")
+	b.WriteString(e.Op)
+	if e.Code != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Code)
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap exposes both the sentinel kind and the underlying error to errors.Is/As
+func (e *ProviderError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// IsRetryable reports whether err is a provider failure that may succeed on retry
+func IsRetryable(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && providerErr.Retryable
+}
+
+// RetryAfter returns the suggested delay before retrying err (0 if none)
+func RetryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// AWS error codes grouped by normalized kind
+var (
+	awsCapacityCodes = map[string]bool{
+		"InsufficientInstanceCapacity":         true,
+		"InsufficientHostCapacity":             true,
+		"InsufficientReservedInstanceCapacity": true,
+		"InsufficientCapacity":                 true,
+		"InsufficientFreeAddressesInSubnet":    true,
+		"InsufficientAddressCapacity":          true,
+	}
+	awsQuotaCodes = map[string]bool{
+		"InstanceLimitExceeded":        true,
+		"VcpuLimitExceeded":            true,
+		"MaxSpotInstanceCountExceeded": true,
+		"AddressLimitExceeded":         true,
+		"ResourceLimitExceeded":        true,
+		"SecurityGroupLimitExceeded":   true,
+		"VolumeLimitExceeded":          true,
+		"SpotMaxPriceTooLow":           true,
+		"RequestResourceCountExceeded": true,
+		"ReservationCapacityExceeded":  true,
+	}
+	awsCredentialCodes = map[string]bool{
+		"UnauthorizedOperation":       true,
+		"AuthFailure":                 true,
+		"InvalidClientTokenId":        true,
+		"SignatureDoesNotMatch":       true,
+		"OptInRequired":               true,
+		"UnrecognizedClientException": true,
+		"ExpiredToken":                true,
+		"ExpiredTokenException":       true,
+		"AccessDenied":                true,
+		"AccessDeniedException":       true,
+		"Blocked":                     true,
+	}
+	awsThrottleCodes = map[string]bool{
+		"RequestLimitExceeded":     true,
+		"Throttling":               true,
+		"ThrottlingException":      true,
+		"TooManyRequestsException": true,
+		"RequestThrottled":         true,
+		"SlowDown":                 true,
+	}
+	awsServerCodes = map[string]bool{
+		"InternalError":      true,
+		"InternalFailure":    true,
+		"ServiceUnavailable": true,
+		"Unavailable":        true,
+	}
+)
+
+// classifyAWSError converts an EC2 SDK error into a ProviderError.
+// Context cancellation is returned unchanged so callers can still detect it directly.
+func classifyAWSError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	providerErr := &ProviderError{Provider: "aws", Op: op, Err: err}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		providerErr.Code = code
+		switch {
+		case code == "InvalidInstanceID.NotFound":
+			providerErr.Kind = ErrInstanceNotFound
+		case awsCapacityCodes[code]:
+			providerErr.Kind = ErrInsufficientCapacity
+			providerErr.Retryable = true
+			providerErr.RetryAfter = retryAfterCapacity
+		case awsQuotaCodes[code]:
+			providerErr.Kind = ErrQuotaExceeded
+		case awsCredentialCodes[code]:
+			providerErr.Kind = ErrInvalidCredentials
+		case awsThrottleCodes[code]:
+			providerErr.Kind = ErrRateLimited
+			providerErr.Retryable = true
+			providerErr.RetryAfter = retryAfterRateLimited
+		case awsServerCodes[code]:
+			providerErr.Kind = ErrNetworkFailure
+			providerErr.Retryable = true
+			providerErr.RetryAfter = retryAfterNetwork
+		case strings.HasPrefix(code, "Invalid") || strings.HasPrefix(code, "Missing") || strings.HasSuffix(code, ".Malformed"):
+			providerErr.Kind = ErrInvalidInput
+		}
+		return providerErr
+	}
+
+	if isTransportError(err) {
+		providerErr.Kind = ErrNetworkFailure
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterNetwork
+	}
+	return providerErr
+}
+
+// httpAPIError is implemented by REST API error types that carry an HTTP status and error code
+type httpAPIError interface {
+	error
+	HTTPStatus() int
+	ErrorCode() string
+}
+
+// classifyHTTPError converts a REST API client error into a ProviderError.
+// Used by providers that talk to plain JSON APIs (DigitalOcean, Hetzner).
+func classifyHTTPError(provider, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	providerErr := &ProviderError{Provider: provider, Op: op, Err: err}
+
+	var apiErr httpAPIError
+	if !errors.As(err, &apiErr) {
+		if isTransportError(err) {
+			providerErr.Kind = ErrNetworkFailure
+			providerErr.Retryable = true
+			providerErr.RetryAfter = retryAfterNetwork
+		}
+		return providerErr
+	}
+
+	statusCode, code := apiErr.HTTPStatus(), apiErr.ErrorCode()
+	providerErr.Code = code
+
+	switch {
+	// Error codes are checked first: Hetzner reports quota problems as 403
+	case code == "resource_limit_exceeded":
+		providerErr.Kind = ErrQuotaExceeded
+	case code == "resource_unavailable":
+		providerErr.Kind = ErrInsufficientCapacity
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterCapacity
+	case statusCode == http.StatusNotFound:
+		providerErr.Kind = ErrInstanceNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		providerErr.Kind = ErrInvalidCredentials
+	case statusCode == http.StatusTooManyRequests:
+		providerErr.Kind = ErrRateLimited
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterRateLimited
+	case statusCode >= 500:
+		providerErr.Kind = ErrNetworkFailure
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterNetwork
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+		providerErr.Kind = ErrInvalidInput
+	}
+
+	return providerErr
+}
+
+// classifyDockerError converts a Docker Engine client error into a ProviderError.
+// Context cancellation is returned unchanged so callers can still detect it directly.
+func classifyDockerError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	providerErr := &ProviderError{Provider: "docker", Op: op, Err: err}
+	switch {
+	case cerrdefs.IsNotFound(err):
+		providerErr.Kind = ErrInstanceNotFound
+	case cerrdefs.IsUnauthorized(err) || cerrdefs.IsPermissionDenied(err):
+		providerErr.Kind = ErrInvalidCredentials
+	case cerrdefs.IsInvalidArgument(err) || cerrdefs.IsConflict(err):
+		providerErr.Kind = ErrInvalidInput
+	case cerrdefs.IsResourceExhausted(err):
+		providerErr.Kind = ErrInsufficientCapacity
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterCapacity
+	case client.IsErrConnectionFailed(err) || cerrdefs.IsUnavailable(err) || cerrdefs.IsInternal(err) || isTransportError(err):
+		providerErr.Kind = ErrNetworkFailure
+		providerErr.Retryable = true
+		providerErr.RetryAfter = retryAfterNetwork
+	}
+	return providerErr
+}
+
+// isTransportError reports whether err is a connection-level failure (DNS, TCP, TLS, timeout)
+func isTransportError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAWSError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		kind      error
+		retryable bool
+	}{
+		{"insufficient capacity", &smithy.GenericAPIError{Code: "InsufficientInstanceCapacity"}, ErrInsufficientCapacity, true},
+		{"instance limit", &smithy.GenericAPIError{Code: "InstanceLimitExceeded"}, ErrQuotaExceeded, false},
+		{"vcpu limit", &smithy.GenericAPIError{Code: "VcpuLimitExceeded"}, ErrQuotaExceeded, false},
+		{"unauthorized", &smithy.GenericAPIError{Code: "UnauthorizedOperation"}, ErrInvalidCredentials, false},
+		{"bad token", &smithy.GenericAPIError{Code: "InvalidClientTokenId"}, ErrInvalidCredentials, false},
+		{"throttled", &smithy.GenericAPIError{Code: "RequestLimitExceeded"}, ErrRateLimited, true},
+		{"internal error", &smithy.GenericAPIError{Code: "InternalError"}, ErrNetworkFailure, true},
+		{"bad parameter", &smithy.GenericAPIError{Code: "InvalidParameterValue"}, ErrInvalidInput, false},
+		{"bad AMI", &smithy.GenericAPIError{Code: "InvalidAMIID.NotFound"}, ErrInvalidInput, false},
+		{"instance not found", &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}, ErrInstanceNotFound, false},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrNetworkFailure, true},
+		{"deadline", context.DeadlineExceeded, ErrNetworkFailure, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyAWSError("run instances", fmt.Errorf("operation error: %w", tt.err))
+
+			var providerErr *ProviderError
+			require.True(t, errors.As(err, &providerErr))
+			assert.Equal(t, "aws", providerErr.Provider)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.ErrorIs(t, err, tt.err, "underlying error must stay reachable")
+			assert.Equal(t, tt.retryable, IsRetryable(err))
+			if tt.retryable {
+				assert.Positive(t, RetryAfter(err))
+			}
+		})
+	}
+}
+
+func TestClassifyAWSError_Passthrough(t *testing.T) {
+	assert.NoError(t, classifyAWSError("op", nil))
+	assert.Equal(t, context.Canceled, classifyAWSError("op", context.Canceled))
+
+	// Unknown failures are wrapped but carry no kind and are not retried
+	err := classifyAWSError("op", &smithy.GenericAPIError{Code: "SomethingNew"})
+	var providerErr *ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	assert.Nil(t, providerErr.Kind)
+	assert.Equal(t, "SomethingNew", providerErr.Code)
+	assert.False(t, IsRetryable(err))
+}
+
+func TestClassifyHTTPError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		kind      error
+		retryable bool
+	}{
+		{"not found", &HetznerAPIError{StatusCode: http.StatusNotFound, Code: "not_found"}, ErrInstanceNotFound, false},
+		{"unauthorized", &DigitalOceanAPIError{StatusCode: http.StatusUnauthorized, ID: "unauthorized"}, ErrInvalidCredentials, false},
+		{"rate limited", &HetznerAPIError{StatusCode: http.StatusTooManyRequests, Code: "rate_limit_exceeded"}, ErrRateLimited, true},
+		{"forbidden", &HetznerAPIError{StatusCode: http.StatusForbidden, Code: "forbidden"}, ErrInvalidCredentials, false},
+		{"quota 403", &HetznerAPIError{StatusCode: http.StatusForbidden, Code: "resource_limit_exceeded"}, ErrQuotaExceeded, false},
+		{"quota 422", &DigitalOceanAPIError{StatusCode: http.StatusUnprocessableEntity, ID: "resource_limit_exceeded"}, ErrQuotaExceeded, false},
+		{"capacity", &HetznerAPIError{StatusCode: http.StatusConflict, Code: "resource_unavailable"}, ErrInsufficientCapacity, true},
+		{"server error", &DigitalOceanAPIError{StatusCode: http.StatusBadGateway, ID: "server_error"}, ErrNetworkFailure, true},
+		{"bad request", &HetznerAPIError{StatusCode: http.StatusUnprocessableEntity, Code: "invalid_input"}, ErrInvalidInput, false},
+		{"transport", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrNetworkFailure, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyHTTPError("hetzner", "create server", tt.err)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.Equal(t, tt.retryable, IsRetryable(err))
+		})
+	}
+
+	assert.NoError(t, classifyHTTPError("hetzner", "op", nil))
+}
+
+func TestProviderError_Message(t *testing.T) {
+	err := &ProviderError{
+		Provider: "aws",
+		Op:       "run instances",
+		Code:     "InstanceLimitExceeded",
+		Kind:     ErrQuotaExceeded,
+		Err:      errors.New("you have requested more instances than allowed"),
+	}
+	assert.Equal(t, "aws: run instances: InstanceLimitExceeded: you have requested more instances than allowed", err.Error())
+	assert.False(t, IsRetryable(errors.New("plain")))
+	assert.Zero(t, RetryAfter(errors.New("plain")))
+}
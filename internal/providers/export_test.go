@@ -0,0 +1,27 @@
+package providers
+
+import "testing"
+
+// Providers backed by the in-memory API fakes, for the conformance suite in package providers_test
+
+// NewFakeAWSProvider returns an AWSProvider in us-east-1 backed by fakeEC2
+func NewFakeAWSProvider(t *testing.T) CloudProvider {
+	client := newFakeEC2().install(&mockEC2Client{})
+	return &AWSProvider{
+		client: client,
+		region: "us-east-1",
+		images: newAMIResolver(client, "us-east-1", nil),
+	}
+}
+
+// NewFakeDigitalOceanProvider returns a DigitalOceanProvider backed by fakeDigitalOcean
+func NewFakeDigitalOceanProvider(t *testing.T) CloudProvider {
+	_, server := newFakeDigitalOcean(t, "dop_v1_token")
+	return newTestDigitalOceanProvider(server, "dop_v1_token")
+}
+
+// NewFakeHetznerProvider returns a HetznerProvider backed by fakeHetzner
+func NewFakeHetznerProvider(t *testing.T) CloudProvider {
+	_, server := newFakeHetzner(t, "hcloud-token")
+	return newTestHetznerProvider(server, "hcloud-token")
+}
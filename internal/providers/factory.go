@@ -0,0 +1,170 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stagely-dev/stagely/internal/config"
+)
+
+// Credentials holds the decrypted contents of cloud_providers.encrypted_credentials
+type Credentials struct {
+	AccessKeyID     string `json:"access_key_id,omitempty"`     // AWS
+	SecretAccessKey string `json:"secret_access_key,omitempty"` // AWS
+	APIToken        string `json:"api_token,omitempty"`         // DigitalOcean, Hetzner
+	Region          string `json:"region,omitempty"`            // Used when no region is requested and cloud_providers.region is unset
+}
+
+// ParseCredentials decodes decrypted credential JSON for the given provider type.
+// Both flat objects and objects nested under the provider type ({"aws": {...}}) are accepted.
+func ParseCredentials(providerType string, data []byte) (Credentials, error) {
+	var nested map[string]json.RawMessage
+	if err := json.Unmarshal(data, &nested); err != nil {
+		return Credentials{}, fmt.Errorf("decode credentials: %w", err)
+	}
+	if inner, ok := nested[providerType]; ok {
+		data = inner
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("decode %s credentials: %w", providerType, err)
+	}
+	return creds, nil
+}
+
+// Constructor builds a provider from decrypted credentials, the default region of the
+// cloud_providers row and its provider-specific config JSON
+type Constructor func(creds Credentials, region string, config json.RawMessage) (CloudProvider, error)
+
+// Factory builds CloudProviders by provider type (cloud_providers.provider_type)
+type Factory struct {
+	constructors map[string]Constructor
+	mu           sync.RWMutex
+}
+
+// NewFactory creates a factory that knows the built-in cloud provider types
+// The docker provider is not among them (see EnableDocker).
+func NewFactory() *Factory {
+	return &Factory{
+		constructors: map[string]Constructor{
+			"aws":          newAWSFromCredentials,
+			"digitalocean": newDigitalOceanFromCredentials,
+			"hetzner":      newHetznerFromCredentials,
+		},
+	}
+}
+
+// EnableDocker registers the docker provider, which runs workloads as containers on the given
+// engine. Only development and CI servers should call it: every team could then launch
+// containers on that engine.
+func (f *Factory) EnableDocker(hostConfig DockerHostConfig) error {
+	return f.Register("docker", func(_ Credentials, _ string, config json.RawMessage) (CloudProvider, error) {
+		var dockerConfig DockerConfig
+		if err := decodeConfig(config, &dockerConfig); err != nil {
+			return nil, err
+		}
+		return NewDockerProviderWithConfig(hostConfig, dockerConfig)
+	})
+}
+
+// NewFactoryFromConfig creates a factory for the server's configuration, enabling the docker
+// provider when DOCKER_PROVIDER_ENABLED is set. It refuses to do so in production.
+func NewFactoryFromConfig(cfg *config.Config) (*Factory, error) {
+	factory := NewFactory()
+	if !cfg.Docker.Enabled {
+		return factory, nil
+	}
+	if cfg.Server.Environment == "production" {
+		return nil, errors.New("docker provider cannot be enabled in production")
+	}
+
+	err := factory.EnableDocker(DockerHostConfig{
+		Host:              cfg.Docker.Host,
+		Network:           cfg.Docker.Network,
+		Privileged:        cfg.Docker.Privileged,
+		MountDockerSocket: cfg.Docker.MountDockerSocket,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return factory, nil
+}
+
+// Register adds a constructor for a provider type
+// Returns an error if the type is already registered or if inputs are invalid
+func (f *Factory) Register(providerType string, constructor Constructor) error {
+	if providerType == "" {
+		return errors.New("provider type cannot be empty")
+	}
+	if constructor == nil {
+		return errors.New("constructor cannot be nil")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.constructors[providerType]; exists {
+		return fmt.Errorf("provider type %q is already registered", providerType)
+	}
+
+	f.constructors[providerType] = constructor
+	return nil
+}
+
+// Build creates a provider of the given type bound to region (or the credentials' region if empty)
+// Returns ErrNotSupported for provider types without a constructor (e.g., "gcp")
+func (f *Factory) Build(providerType string, creds Credentials, region string, config json.RawMessage) (CloudProvider, error) {
+	f.mu.RLock()
+	constructor, exists := f.constructors[providerType]
+	f.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: provider type %q", ErrNotSupported, providerType)
+	}
+
+	// The requested region must win, or a provider built for one region would launch in another
+	if region == "" {
+		region = creds.Region
+	}
+	return constructor(creds, region, config)
+}
+
+// decodeConfig unmarshals provider config JSON, treating empty and null config as no settings
+func decodeConfig(config json.RawMessage, v any) error {
+	config = bytes.TrimSpace(config)
+	if len(config) == 0 || bytes.Equal(config, []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(config, v); err != nil {
+		return fmt.Errorf("decode provider config: %w", err)
+	}
+	return nil
+}
+
+func newAWSFromCredentials(creds Credentials, region string, config json.RawMessage) (CloudProvider, error) {
+	var awsConfig AWSConfig
+	if err := decodeConfig(config, &awsConfig); err != nil {
+		return nil, err
+	}
+	return NewAWSProviderWithConfig(creds.AccessKeyID, creds.SecretAccessKey, region, awsConfig)
+}
+
+func newDigitalOceanFromCredentials(creds Credentials, _ string, config json.RawMessage) (CloudProvider, error) {
+	var doConfig DigitalOceanConfig
+	if err := decodeConfig(config, &doConfig); err != nil {
+		return nil, err
+	}
+	return NewDigitalOceanProviderWithConfig(creds.APIToken, doConfig)
+}
+
+func newHetznerFromCredentials(creds Credentials, _ string, config json.RawMessage) (CloudProvider, error) {
+	var hetznerConfig HetznerConfig
+	if err := decodeConfig(config, &hetznerConfig); err != nil {
+		return nil, err
+	}
+	return NewHetznerProviderWithConfig(creds.APIToken, hetznerConfig)
+}
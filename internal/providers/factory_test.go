@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		providerType string
+		data         string
+		expected     Credentials
+		expectError  bool
+	}{
+		{
+			name:         "flat aws",
+			providerType: "aws",
+			data:         `{"access_key_id": "AKIA", "secret_access_key": "secret", "region": "eu-west-1"}`,
+			expected:     Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", Region: "eu-west-1"},
+		},
+		{
+			name:         "nested aws",
+			providerType: "aws",
+			data:         `{"aws": {"access_key_id": "AKIA", "secret_access_key": "secret"}}`,
+			expected:     Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"},
+		},
+		{
+			name:         "hetzner token",
+			providerType: "hetzner",
+			data:         `{"api_token": "token"}`,
+			expected:     Credentials{APIToken: "token"},
+		},
+		{
+			name:         "not json",
+			providerType: "aws",
+			data:         `AKIA:secret`,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := ParseCredentials(tt.providerType, []byte(tt.data))
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, creds)
+		})
+	}
+}
+
+func TestFactory_Build(t *testing.T) {
+	factory := NewFactory()
+
+	provider, err := factory.Build("aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, "us-east-1",
+		json.RawMessage(`{"amis": {"amd64": "ami-golden"}}`))
+	require.NoError(t, err)
+	awsProvider, ok := provider.(*AWSProvider)
+	require.True(t, ok)
+	assert.Equal(t, "us-east-1", awsProvider.region)
+	assert.Equal(t, "ami-golden", awsProvider.images.pinned["amd64"])
+
+	// The requested region wins; the credentials' region only fills in a missing one
+	provider, err = factory.Build("aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", Region: "eu-west-1"}, "us-east-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", provider.(*AWSProvider).region)
+
+	provider, err = factory.Build("aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", Region: "eu-west-1"}, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", provider.(*AWSProvider).region)
+
+	provider, err = factory.Build("hetzner", Credentials{APIToken: "token"}, "", json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "hetzner", provider.Name())
+
+	provider, err = factory.Build("digitalocean", Credentials{APIToken: "token"}, "nyc3", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "digitalocean", provider.Name())
+}
+
+func TestFactory_EnableDocker(t *testing.T) {
+	// Given - a factory without the docker provider
+	factory := NewFactory()
+	_, err := factory.Build("docker", Credentials{}, "local", nil)
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	// When
+	require.NoError(t, factory.EnableDocker(DockerHostConfig{Host: "tcp://127.0.0.1:2375", Network: "stagely"}))
+	provider, err := factory.Build("docker", Credentials{}, "local",
+		json.RawMessage(`{"image": "alpine:3.20", "host": "tcp://10.0.0.1:2375", "network": "host", "privileged": true, "mount_docker_socket": true}`))
+
+	// Then - Docker needs no credentials, and the engine settings never come from the team's config
+	require.NoError(t, err)
+	dockerProvider, ok := provider.(*DockerProvider)
+	require.True(t, ok)
+	assert.Equal(t, "alpine:3.20", dockerProvider.config.Image)
+	assert.Equal(t, DockerHostConfig{Host: "tcp://127.0.0.1:2375", Network: "stagely"}, dockerProvider.host)
+}
+
+func TestNewFactoryFromConfig(t *testing.T) {
+	dockerConfig := config.DockerProviderConfig{Enabled: true, Host: "tcp://127.0.0.1:2375", Network: "stagely", Privileged: true}
+
+	tests := []struct {
+		name        string
+		environment string
+		docker      config.DockerProviderConfig
+		wantDocker  bool
+		wantErr     bool
+	}{
+		{name: "disabled", environment: "development", docker: config.DockerProviderConfig{}, wantDocker: false},
+		{name: "development", environment: "development", docker: dockerConfig, wantDocker: true},
+		{name: "ci", environment: "ci", docker: dockerConfig, wantDocker: true},
+		{name: "production", environment: "production", docker: dockerConfig, wantErr: true},
+		{name: "production without docker", environment: "production", docker: config.DockerProviderConfig{}, wantDocker: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			cfg := &config.Config{Server: config.ServerConfig{Environment: tt.environment}, Docker: tt.docker}
+
+			// When
+			factory, err := NewFactoryFromConfig(cfg)
+
+			// Then
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, factory)
+				return
+			}
+			require.NoError(t, err)
+
+			provider, err := factory.Build("docker", Credentials{}, "local", nil)
+			if !tt.wantDocker {
+				assert.ErrorIs(t, err, ErrNotSupported)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, DockerHostConfig{Host: "tcp://127.0.0.1:2375", Network: "stagely", Privileged: true}, provider.(*DockerProvider).host)
+		})
+	}
+}
+
+func TestFactory_BuildCatalogOverrides(t *testing.T) {
+	factory := NewFactory()
+	config := json.RawMessage(`{"instance_types": {"large-mem": {"amd64": {"name": "%s", "vcpus": 8, "memory_gb": 64}}}}`)
+
+	tests := []struct {
+		providerType string
+		creds        Credentials
+		typeName     string
+	}{
+		{"aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, "r6i.2xlarge"},
+		{"digitalocean", Credentials{APIToken: "token"}, "m-8vcpu-64gb"},
+		{"hetzner", Credentials{APIToken: "token"}, "ccx33"},
+		{"docker", Credentials{}, "8cpu-64gb"},
+	}
+	require.NoError(t, factory.EnableDocker(DockerHostConfig{}))
+
+	for _, tt := range tests {
+		t.Run(tt.providerType, func(t *testing.T) {
+			provider, err := factory.Build(tt.providerType, tt.creds, "us-east-1",
+				json.RawMessage(fmt.Sprintf(string(config), tt.typeName)))
+			require.NoError(t, err)
+
+			catalog := provider.(interface{ Catalog() *Catalog }).Catalog()
+			instanceType, err := catalog.Lookup("large-mem", ArchAMD64)
+			require.NoError(t, err)
+			assert.Equal(t, InstanceType{Name: tt.typeName, VCPUs: 8, MemoryGB: 64}, instanceType)
+			assert.True(t, catalog.HasSize(SizeXLarge))
+
+			// Defaults are not affected by a team's overrides
+			assert.False(t, DefaultCatalog(tt.providerType).HasSize("large-mem"))
+		})
+	}
+
+	_, err := factory.Build("hetzner", Credentials{APIToken: "token"}, "",
+		json.RawMessage(`{"instance_types": {"Huge!": {"amd64": {"name": "ccx63"}}}}`))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestFactory_BuildTagConfig(t *testing.T) {
+	factory := NewFactory()
+	config := json.RawMessage(`{"tags": {"cost-center": "eng-42"}, "required_tags": ["team"]}`)
+	require.NoError(t, factory.EnableDocker(DockerHostConfig{}))
+
+	for _, providerType := range []string{"aws", "digitalocean", "hetzner", "docker"} {
+		t.Run(providerType, func(t *testing.T) {
+			provider, err := factory.Build(providerType, Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", APIToken: "token"}, "us-east-1", config)
+			require.NoError(t, err)
+
+			policy := provider.(interface{ TagPolicy() *TagPolicy }).TagPolicy()
+			tags, err := policy.Apply(map[string]string{TagTeam: "team-1"})
+			require.NoError(t, err)
+			assert.Equal(t, "eng-42", tags["cost-center"])
+			assert.Equal(t, ManagedByStagely, tags[TagManagedBy])
+
+			_, err = policy.Apply(nil)
+			assert.ErrorIs(t, err, ErrInvalidInput)
+		})
+	}
+
+	_, err := factory.Build("aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, "us-east-1",
+		json.RawMessage(`{"tags": {"team": "someone-else"}}`))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestFactory_BuildErrors(t *testing.T) {
+	factory := NewFactory()
+
+	_, err := factory.Build("gcp", Credentials{}, "us-central1", nil)
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	_, err = factory.Build("aws", Credentials{AccessKeyID: "AKIA"}, "us-east-1", nil)
+	assert.Error(t, err)
+
+	_, err = factory.Build("aws", Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, "us-east-1", json.RawMessage(`[1]`))
+	assert.Error(t, err)
+
+	_, err = factory.Build("hetzner", Credentials{}, "", nil)
+	assert.Error(t, err)
+}
+
+func TestFactory_Register(t *testing.T) {
+	factory := NewFactory()
+	mock := NewMockProvider()
+
+	err := factory.Register("mock", func(Credentials, string, json.RawMessage) (CloudProvider, error) {
+		return mock, nil
+	})
+	require.NoError(t, err)
+
+	provider, err := factory.Build("mock", Credentials{}, "", nil)
+	require.NoError(t, err)
+	assert.Same(t, mock, provider)
+
+	err = factory.Register("aws", func(Credentials, string, json.RawMessage) (CloudProvider, error) { return mock, nil })
+	assert.Contains(t, err.Error(), "already registered")
+	assert.Error(t, factory.Register("", nil))
+}
@@ -0,0 +1,155 @@
+package providers
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Firewall protocols
+const (
+	ProtocolTCP  = "tcp"
+	ProtocolUDP  = "udp"
+	ProtocolICMP = "icmp"
+	ProtocolAll  = "all"
+)
+
+// Firewall describes the network access of an instance.
+// Inbound traffic is denied unless a rule allows it; outbound traffic is allowed
+// everywhere unless Outbound is set, in which case only matching traffic is allowed.
+type Firewall struct {
+	Inbound  []FirewallRule `json:"inbound,omitempty"`
+	Outbound []FirewallRule `json:"outbound,omitempty"`
+}
+
+// FirewallRule allows traffic of one protocol from (inbound) or to (outbound) a set of networks
+type FirewallRule struct {
+	Protocol string   `json:"protocol"`        // "tcp", "udp", "icmp" or "all"
+	Ports    string   `json:"ports,omitempty"` // "443" or "3000-3999" (empty = all ports; tcp and udp only)
+	CIDRs    []string `json:"cidrs"`           // e.g., ["203.0.113.10/32", "2001:db8::/64"]
+}
+
+// FirewallManager is an optional capability for providers that can restrict the network
+// access of instances (security groups on AWS, cloud firewalls elsewhere).
+// Providers without it launch instances with their default rules and ignore InstanceSpec.Firewall.
+type FirewallManager interface {
+	// EnsureFirewall creates the Stagely-managed firewall for the rules in a region,
+	// or reuses an identical one, and returns its provider ID
+	EnsureFirewall(ctx context.Context, region string, firewall Firewall) (string, error)
+}
+
+// AsFirewallManager returns the provider's firewall capability, if it has one
+func AsFirewallManager(p CloudProvider) (FirewallManager, bool) {
+	return as[FirewallManager](p)
+}
+
+// EdgeFirewall returns a firewall that only lets the edge proxy reach an instance
+// (any TCP port, since previews listen on the ports their stagely.yaml declares)
+func EdgeFirewall(proxyCIDRs []string) *Firewall {
+	return &Firewall{
+		Inbound: []FirewallRule{{Protocol: ProtocolTCP, CIDRs: proxyCIDRs}},
+	}
+}
+
+// Validate checks that every rule is well-formed
+func (f Firewall) Validate() error {
+	for _, rule := range f.Inbound {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("inbound rule: %w", err)
+		}
+	}
+	for _, rule := range f.Outbound {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("outbound rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// Hash returns a short digest of the rules, used to name and reuse managed firewalls.
+// It does not depend on the order of the rules or of their CIDRs.
+func (f Firewall) Hash() string {
+	canonical := Firewall{Inbound: sortedRules(f.Inbound), Outbound: sortedRules(f.Outbound)}
+	// Marshaling a struct of slices and strings cannot fail
+	data, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// sortedRules returns a copy of the rules with their CIDRs sorted, in a stable order
+func sortedRules(rules []FirewallRule) []FirewallRule {
+	sorted := make([]FirewallRule, len(rules))
+	for i, rule := range rules {
+		rule.CIDRs = slices.Sorted(slices.Values(rule.CIDRs))
+		sorted[i] = rule
+	}
+	slices.SortFunc(sorted, func(a, b FirewallRule) int {
+		return cmp.Or(
+			cmp.Compare(a.Protocol, b.Protocol),
+			cmp.Compare(a.Ports, b.Ports),
+			slices.Compare(a.CIDRs, b.CIDRs),
+		)
+	})
+	return sorted
+}
+
+// Validate checks the protocol, port range and networks of the rule
+func (r FirewallRule) Validate() error {
+	switch r.Protocol {
+	case ProtocolTCP, ProtocolUDP:
+		if _, _, err := r.PortRange(); err != nil {
+			return err
+		}
+	case ProtocolICMP, ProtocolAll:
+		if r.Ports != "" {
+			return fmt.Errorf("%w: ports cannot be set for protocol %q", ErrInvalidInput, r.Protocol)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported protocol %q", ErrInvalidInput, r.Protocol)
+	}
+
+	if len(r.CIDRs) == 0 {
+		return fmt.Errorf("%w: at least one CIDR is required", ErrInvalidInput)
+	}
+	for _, cidr := range r.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("%w: invalid CIDR %q", ErrInvalidInput, cidr)
+		}
+		if prefix != prefix.Masked() {
+			return fmt.Errorf("%w: CIDR %q has host bits set (use %s)", ErrInvalidInput, cidr, prefix.Masked())
+		}
+	}
+	return nil
+}
+
+// PortRange returns the first and last port of the rule (1-65535 when Ports is empty)
+func (r FirewallRule) PortRange() (int, int, error) {
+	if r.Ports == "" {
+		return 1, 65535, nil
+	}
+
+	first, last, isRange := strings.Cut(r.Ports, "-")
+	from, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid ports %q", ErrInvalidInput, r.Ports)
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(last); err != nil {
+			return 0, 0, fmt.Errorf("%w: invalid ports %q", ErrInvalidInput, r.Ports)
+		}
+	}
+
+	if from < 1 || to > 65535 || from > to {
+		return 0, 0, fmt.Errorf("%w: invalid ports %q", ErrInvalidInput, r.Ports)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirewallRule_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        FirewallRule
+		expectError bool
+	}{
+		{"tcp all ports", FirewallRule{Protocol: ProtocolTCP, CIDRs: []string{"203.0.113.10/32"}}, false},
+		{"tcp single port", FirewallRule{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}}, false},
+		{"udp range", FirewallRule{Protocol: ProtocolUDP, Ports: "3000-3999", CIDRs: []string{"10.0.0.0/8"}}, false},
+		{"ipv6", FirewallRule{Protocol: ProtocolTCP, CIDRs: []string{"2001:db8::/64"}}, false},
+		{"icmp", FirewallRule{Protocol: ProtocolICMP, CIDRs: []string{"10.0.0.0/8"}}, false},
+		{"all", FirewallRule{Protocol: ProtocolAll, CIDRs: []string{"10.0.0.0/8"}}, false},
+		{"unknown protocol", FirewallRule{Protocol: "sctp", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"ports on icmp", FirewallRule{Protocol: ProtocolICMP, Ports: "8", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"port zero", FirewallRule{Protocol: ProtocolTCP, Ports: "0", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"port too high", FirewallRule{Protocol: ProtocolTCP, Ports: "65536", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"reversed range", FirewallRule{Protocol: ProtocolTCP, Ports: "4000-3000", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"malformed ports", FirewallRule{Protocol: ProtocolTCP, Ports: "http", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"no CIDRs", FirewallRule{Protocol: ProtocolTCP}, true},
+		{"bare address", FirewallRule{Protocol: ProtocolTCP, CIDRs: []string{"203.0.113.10"}}, true},
+		{"host bits set", FirewallRule{Protocol: ProtocolTCP, CIDRs: []string{"10.0.0.1/8"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFirewallRule_PortRange(t *testing.T) {
+	from, to, err := FirewallRule{Protocol: ProtocolTCP}.PortRange()
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{1, 65535}, [2]int{from, to})
+
+	from, to, err = FirewallRule{Protocol: ProtocolTCP, Ports: "8080"}.PortRange()
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{8080, 8080}, [2]int{from, to})
+
+	from, to, err = FirewallRule{Protocol: ProtocolTCP, Ports: "3000-3999"}.PortRange()
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{3000, 3999}, [2]int{from, to})
+}
+
+func TestFirewall_Hash(t *testing.T) {
+	edge := EdgeFirewall([]string{"203.0.113.10/32", "203.0.113.11/32"})
+	same := EdgeFirewall([]string{"203.0.113.10/32", "203.0.113.11/32"})
+	other := EdgeFirewall([]string{"203.0.113.12/32"})
+
+	assert.Len(t, edge.Hash(), 12)
+	assert.Equal(t, edge.Hash(), same.Hash())
+	assert.NotEqual(t, edge.Hash(), other.Hash())
+
+	restricted := *edge
+	restricted.Outbound = []FirewallRule{{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}}}
+	assert.NotEqual(t, edge.Hash(), restricted.Hash())
+
+	// The order of rules and CIDRs does not matter, but their direction does
+	web := FirewallRule{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"10.0.0.0/8", "192.0.2.0/24"}}
+	ping := FirewallRule{Protocol: ProtocolICMP, CIDRs: []string{"10.0.0.0/8"}}
+	reordered := FirewallRule{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"192.0.2.0/24", "10.0.0.0/8"}}
+	assert.Equal(t, Firewall{Inbound: []FirewallRule{web, ping}}.Hash(), Firewall{Inbound: []FirewallRule{ping, reordered}}.Hash())
+	assert.NotEqual(t, Firewall{Inbound: []FirewallRule{web}}.Hash(), Firewall{Outbound: []FirewallRule{web}}.Hash())
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.0/24"}, web.CIDRs)
+	assert.Equal(t, []string{"192.0.2.0/24", "10.0.0.0/8"}, reordered.CIDRs)
+}
+
+func TestFirewall_Validate(t *testing.T) {
+	assert.NoError(t, EdgeFirewall([]string{"203.0.113.10/32"}).Validate())
+
+	err := EdgeFirewall(nil).Validate()
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorContains(t, err, "inbound rule")
+
+	err = Firewall{Outbound: []FirewallRule{{Protocol: "gre", CIDRs: []string{"0.0.0.0/0"}}}}.Validate()
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorContains(t, err, "outbound rule")
+
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1", Firewall: EdgeFirewall(nil)}
+	assert.ErrorIs(t, spec.Validate(), ErrInvalidInput)
+}
@@ -0,0 +1,437 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stagely-dev/stagely/pkg/nanoid"
+)
+
+// Default server catalog: size + architecture -> Hetzner Cloud server type
+var hetznerCatalog = mustCatalog(map[string]map[string]InstanceType{
+	SizeSmall: {
+		ArchAMD64: {Name: "cx22", VCPUs: 2, MemoryGB: 4},
+		ArchARM64: {Name: "cax11", VCPUs: 2, MemoryGB: 4},
+	},
+	SizeMedium: {
+		ArchAMD64: {Name: "cx32", VCPUs: 4, MemoryGB: 8},
+		ArchARM64: {Name: "cax21", VCPUs: 4, MemoryGB: 8},
+	},
+	SizeLarge: {
+		ArchAMD64: {Name: "cx42", VCPUs: 8, MemoryGB: 16},
+		ArchARM64: {Name: "cax31", VCPUs: 8, MemoryGB: 16},
+	},
+	SizeXLarge: {
+		ArchAMD64: {Name: "cx52", VCPUs: 16, MemoryGB: 32},
+		ArchARM64: {Name: "cax41", VCPUs: 16, MemoryGB: 32},
+	},
+})
+
+// Hetzner resolves the image name to the variant matching the server type's architecture
+const hetznerImage = "ubuntu-22.04"
+
+const hetznerBaseURL = "https://api.hetzner.cloud"
+
+// hetznerLabelPattern matches characters that Hetzner rejects in label keys and values
+var hetznerLabelPattern = regexp.MustCompile(`[^a-zA-Z0-9_.\-]`)
+
+// HetznerAPI defines the Hetzner Cloud operations used by the provider (interface for mocking)
+type HetznerAPI interface {
+	CreateServer(ctx context.Context, req *HetznerServerCreateRequest) (*HetznerServer, error)
+	GetServer(ctx context.Context, serverID int64) (*HetznerServer, error)
+	DeleteServer(ctx context.Context, serverID int64) error
+	ListServers(ctx context.Context, labelSelector string, page, perPage int) ([]HetznerServer, error)
+}
+
+// HetznerServerCreateRequest is the body of a Hetzner create-server call
+type HetznerServerCreateRequest struct {
+	Name       string            `json:"name"`
+	ServerType string            `json:"server_type"`
+	Image      string            `json:"image"`
+	Location   string            `json:"location,omitempty"`
+	UserData   string            `json:"user_data,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// HetznerServer is the subset of the Hetzner server resource used by the provider
+type HetznerServer struct {
+	ID         int64               `json:"id"`
+	Name       string              `json:"name"`
+	Status     string              `json:"status"`
+	Created    time.Time           `json:"created"`
+	ServerType HetznerServerType   `json:"server_type"`
+	PublicNet  HetznerPublicNet    `json:"public_net"`
+	PrivateNet []HetznerPrivateNet `json:"private_net"`
+	Labels     map[string]string   `json:"labels"`
+}
+
+// HetznerServerType identifies the machine type of a server
+type HetznerServerType struct {
+	Name string `json:"name"`
+}
+
+// HetznerPublicNet holds the public addresses of a server
+type HetznerPublicNet struct {
+	IPv4 *HetznerIPv4 `json:"ipv4"`
+}
+
+// HetznerIPv4 is a public IPv4 assignment
+type HetznerIPv4 struct {
+	IP string `json:"ip"`
+}
+
+// HetznerPrivateNet is an attachment to a private network
+type HetznerPrivateNet struct {
+	IP string `json:"ip"`
+}
+
+// publicIP returns the server's public IPv4 address (empty if not assigned).
+func (s *HetznerServer) publicIP() string {
+	if s.PublicNet.IPv4 == nil {
+		return ""
+	}
+	return s.PublicNet.IPv4.IP
+}
+
+// privateIP returns the first private network address (empty if not attached).
+func (s *HetznerServer) privateIP() string {
+	if len(s.PrivateNet) == 0 {
+		return ""
+	}
+	return s.PrivateNet[0].IP
+}
+
+// HetznerAPIError is returned by the Hetzner client for non-2xx responses
+type HetznerAPIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *HetznerAPIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// HTTPStatus returns the response status code.
+func (e *HetznerAPIError) HTTPStatus() int { return e.StatusCode }
+
+// ErrorCode returns the Hetzner error code (e.g., "resource_limit_exceeded").
+func (e *HetznerAPIError) ErrorCode() string { return e.Code }
+
+// hetznerClient is a minimal HTTP client for the Hetzner Cloud v1 API
+type hetznerClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// newHetznerClient creates a Hetzner client for the given API base URL.
+func newHetznerClient(baseURL, token string, httpClient *http.Client) *hetznerClient {
+	return &hetznerClient{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+func (c *hetznerClient) CreateServer(ctx context.Context, req *HetznerServerCreateRequest) (*HetznerServer, error) {
+	var out struct {
+		Server HetznerServer `json:"server"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/servers", req, &out); err != nil {
+		return nil, err
+	}
+	return &out.Server, nil
+}
+
+func (c *hetznerClient) GetServer(ctx context.Context, serverID int64) (*HetznerServer, error) {
+	var out struct {
+		Server HetznerServer `json:"server"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/servers/"+strconv.FormatInt(serverID, 10), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out.Server, nil
+}
+
+func (c *hetznerClient) DeleteServer(ctx context.Context, serverID int64) error {
+	return c.do(ctx, http.MethodDelete, "/v1/servers/"+strconv.FormatInt(serverID, 10), nil, nil)
+}
+
+func (c *hetznerClient) ListServers(ctx context.Context, labelSelector string, page, perPage int) ([]HetznerServer, error) {
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("label_selector", labelSelector)
+	}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	var out struct {
+		Servers []HetznerServer `json:"servers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/servers?"+query.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Servers, nil
+}
+
+// do performs an authenticated JSON request and decodes the response into out (if non-nil).
+func (c *hetznerClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var envelope struct {
+			Error HetznerAPIError `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&envelope)
+		apiErr := envelope.Error
+		apiErr.StatusCode = resp.StatusCode
+		return &apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// HetznerProvider implements CloudProvider for Hetzner Cloud servers
+type HetznerProvider struct {
+	client  HetznerAPI
+	catalog *Catalog   // nil means hetznerCatalog
+	tags    *TagPolicy // nil means the default policy
+}
+
+// HetznerConfig holds the Hetzner-specific settings stored in cloud_providers.config
+type HetznerConfig struct {
+	CatalogConfig
+	TagConfig
+}
+
+// NewHetznerProvider creates a new Hetzner Cloud provider with the given API token.
+func NewHetznerProvider(apiToken string) (*HetznerProvider, error) {
+	return NewHetznerProviderWithConfig(apiToken, HetznerConfig{})
+}
+
+// NewHetznerProviderWithConfig creates a new Hetzner Cloud provider with provider-specific settings.
+func NewHetznerProviderWithConfig(apiToken string, hetznerConfig HetznerConfig) (*HetznerProvider, error) {
+	if apiToken == "" {
+		return nil, fmt.Errorf("api token is required")
+	}
+
+	catalog, err := hetznerCatalog.WithOverrides(hetznerConfig.InstanceTypes)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NewTagPolicy("hetzner", hetznerConfig.TagConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HetznerProvider{
+		client:  newHetznerClient(hetznerBaseURL, apiToken, &http.Client{Timeout: 30 * time.Second}),
+		catalog: catalog,
+		tags:    tags,
+	}, nil
+}
+
+// Name returns the provider identifier.
+func (h *HetznerProvider) Name() string {
+	return "hetzner"
+}
+
+// Catalog returns the sizes this provider can launch.
+func (h *HetznerProvider) Catalog() *Catalog {
+	if h.catalog == nil {
+		return hetznerCatalog
+	}
+	return h.catalog
+}
+
+// TagPolicy returns the policy that stamps and checks the tags of new instances.
+func (h *HetznerProvider) TagPolicy() *TagPolicy {
+	if h.tags == nil {
+		return mustTagPolicy("hetzner")
+	}
+	return h.tags
+}
+
+// ValidateCredentials verifies that the Hetzner API token is valid.
+func (h *HetznerProvider) ValidateCredentials(ctx context.Context) error {
+	_, err := h.client.ListServers(ctx, "", 1, 1)
+	return classifyHTTPError("hetzner", "list servers", err)
+}
+
+// CreateInstance provisions a new Hetzner Cloud server and waits for its public IP.
+func (h *HetznerProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+	return launchAndWait(ctx, h, spec, DefaultWaitOptions())
+}
+
+// LaunchInstance creates a new Hetzner Cloud server without waiting for it to boot.
+func (h *HetznerProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	catalog := h.Catalog()
+	if err := spec.ValidateFor(catalog); err != nil {
+		return "", err
+	}
+	serverType, err := catalog.Lookup(spec.Size, spec.Architecture)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := h.TagPolicy().Apply(spec.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		labels[hetznerLabel(k)] = hetznerLabel(v)
+	}
+
+	server, err := h.client.CreateServer(ctx, &HetznerServerCreateRequest{
+		// Server names must be unique per project
+		Name:       instanceName(tags) + "-" + nanoid.Generate(),
+		ServerType: serverType.Name,
+		Image:      hetznerImage,
+		Location:   spec.Region,
+		UserData:   spec.UserData,
+		Labels:     labels,
+	})
+	if err != nil {
+		return "", classifyHTTPError("hetzner", "create server", err)
+	}
+
+	return strconv.FormatInt(server.ID, 10), nil
+}
+
+// GetInstanceStatus returns the current status of a Hetzner Cloud server.
+func (h *HetznerProvider) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+	serverID, err := strconv.ParseInt(instanceID, 10, 64)
+	if err != nil {
+		return InstanceStatus{}, ErrInstanceNotFound
+	}
+
+	server, err := h.client.GetServer(ctx, serverID)
+	if err != nil {
+		if isHetznerNotFound(err) {
+			return InstanceStatus{}, ErrInstanceNotFound
+		}
+		return InstanceStatus{}, classifyHTTPError("hetzner", "get server", err)
+	}
+
+	return InstanceStatus{
+		State:        mapHetznerState(server.Status),
+		PublicIP:     server.publicIP(),
+		PrivateIP:    server.privateIP(),
+		LaunchedAt:   server.Created,
+		InstanceType: server.ServerType.Name,
+	}, nil
+}
+
+// ListInstances returns all Hetzner Cloud servers carrying every label in tagFilter.
+func (h *HetznerProvider) ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	selectors := make([]string, 0, len(tagFilter))
+	for k, v := range tagFilter {
+		selectors = append(selectors, hetznerLabel(k)+"="+hetznerLabel(v))
+	}
+	sort.Strings(selectors)
+	labelSelector := strings.Join(selectors, ",")
+
+	const perPage = 50
+	var summaries []InstanceSummary
+	for page := 1; ; page++ {
+		servers, err := h.client.ListServers(ctx, labelSelector, page, perPage)
+		if err != nil {
+			return nil, classifyHTTPError("hetzner", "list servers", err)
+		}
+
+		for _, server := range servers {
+			summaries = append(summaries, InstanceSummary{
+				ID:         strconv.FormatInt(server.ID, 10),
+				State:      mapHetznerState(server.Status),
+				Tags:       server.Labels,
+				LaunchedAt: server.Created,
+			})
+		}
+
+		if len(servers) < perPage {
+			return summaries, nil
+		}
+	}
+}
+
+func mapHetznerState(status string) string {
+	switch status {
+	case "initializing", "starting", "rebuilding", "migrating":
+		return StatePending
+	case "running":
+		return StateRunning
+	case "stopping", "off":
+		return StateStopped
+	case "deleting":
+		return StateTerminated
+	default:
+		return StatePending
+	}
+}
+
+// TerminateInstance deletes a Hetzner Cloud server (idempotent).
+func (h *HetznerProvider) TerminateInstance(ctx context.Context, instanceID string) error {
+	serverID, err := strconv.ParseInt(instanceID, 10, 64)
+	if err != nil {
+		// Not a server ID, so there is nothing to delete
+		return nil
+	}
+
+	if err := h.client.DeleteServer(ctx, serverID); err != nil {
+		if isHetznerNotFound(err) {
+			return nil
+		}
+		return classifyHTTPError("hetzner", "delete server", err)
+	}
+	return nil
+}
+
+func isHetznerNotFound(err error) bool {
+	var apiErr *HetznerAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// hetznerLabel replaces characters that are not allowed in Hetzner label keys/values.
+func hetznerLabel(s string) string {
+	return hetznerLabelPattern.ReplaceAllString(s, "_")
+}
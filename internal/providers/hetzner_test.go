@@ -0,0 +1,313 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time interface compliance check
+var _ CloudProvider = (*HetznerProvider)(nil)
+
+// fakeHetzner is an in-memory stand-in for the Hetzner Cloud servers API
+type fakeHetzner struct {
+	mu      sync.Mutex
+	token   string
+	nextID  int64
+	servers map[int64]*HetznerServer
+	created []HetznerServerCreateRequest
+}
+
+func newFakeHetzner(t *testing.T, token string) (*fakeHetzner, *httptest.Server) {
+	fake := &fakeHetzner{
+		token:   token,
+		nextID:  4000,
+		servers: make(map[int64]*HetznerServer),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeHetzner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		writeHetznerError(w, http.StatusUnauthorized, "unauthorized", "unable to authenticate")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/servers":
+		selector := make(map[string]string)
+		if raw := r.URL.Query().Get("label_selector"); raw != "" {
+			for _, part := range strings.Split(raw, ",") {
+				k, v, _ := strings.Cut(part, "=")
+				selector[k] = v
+			}
+		}
+		servers := make([]HetznerServer, 0, len(f.servers))
+		for _, s := range f.servers {
+			if matchesTags(s.Labels, selector) {
+				servers = append(servers, *s)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"servers": servers})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/servers":
+		var req HetznerServerCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeHetznerError(w, http.StatusBadRequest, "invalid_input", err.Error())
+			return
+		}
+		f.created = append(f.created, req)
+		f.nextID++
+		server := &HetznerServer{
+			ID:         f.nextID,
+			Name:       req.Name,
+			Status:     "running",
+			Created:    time.Now().UTC().Truncate(time.Second),
+			ServerType: HetznerServerType{Name: req.ServerType},
+			PublicNet:  HetznerPublicNet{IPv4: &HetznerIPv4{IP: "198.51.100.20"}},
+			PrivateNet: []HetznerPrivateNet{{IP: "10.0.0.2"}},
+			Labels:     req.Labels,
+		}
+		f.servers[server.ID] = server
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"server": server})
+
+	case strings.HasPrefix(r.URL.Path, "/v1/servers/"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/v1/servers/"), 10, 64)
+		server, ok := f.servers[id]
+		if !ok {
+			writeHetznerError(w, http.StatusNotFound, "not_found", "server not found")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.servers, id)
+			_ = json.NewEncoder(w).Encode(map[string]any{"action": map[string]any{"command": "delete_server"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"server": server})
+
+	default:
+		writeHetznerError(w, http.StatusNotFound, "not_found", "unknown route")
+	}
+}
+
+func writeHetznerError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
+
+func newTestHetznerProvider(server *httptest.Server, token string) *HetznerProvider {
+	return &HetznerProvider{
+		client: newHetznerClient(server.URL, token, server.Client()),
+	}
+}
+
+func TestNewHetznerProvider(t *testing.T) {
+	provider, err := NewHetznerProvider("hcloud-token")
+	require.NoError(t, err)
+	assert.Equal(t, "hetzner", provider.Name())
+
+	provider, err = NewHetznerProvider("")
+	assert.Error(t, err)
+	assert.Nil(t, provider)
+}
+
+func TestHetznerCatalog(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		arch        string
+		expected    string
+		expectError bool
+	}{
+		{"small amd64", SizeSmall, ArchAMD64, "cx22", false},
+		{"small arm64", SizeSmall, ArchARM64, "cax11", false},
+		{"medium amd64", SizeMedium, ArchAMD64, "cx32", false},
+		{"medium arm64", SizeMedium, ArchARM64, "cax21", false},
+		{"large amd64", SizeLarge, ArchAMD64, "cx42", false},
+		{"large arm64", SizeLarge, ArchARM64, "cax31", false},
+		{"xlarge amd64", SizeXLarge, ArchAMD64, "cx52", false},
+		{"xlarge arm64", SizeXLarge, ArchARM64, "cax41", false},
+		{"invalid size", "invalid", ArchAMD64, "", true},
+		{"invalid arch", SizeSmall, "invalid", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := hetznerCatalog.Lookup(tt.size, tt.arch)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidInput)
+				assert.Empty(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Name)
+			}
+		})
+	}
+}
+
+func TestMapHetznerState(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{"initializing", StatePending},
+		{"starting", StatePending},
+		{"running", StateRunning},
+		{"stopping", StateStopped},
+		{"off", StateStopped},
+		{"deleting", StateTerminated},
+		{"unknown", StatePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mapHetznerState(tt.status))
+		})
+	}
+}
+
+func TestHetznerProvider_ValidateCredentials(t *testing.T) {
+	_, server := newFakeHetzner(t, "good-token")
+
+	provider := newTestHetznerProvider(server, "good-token")
+	assert.NoError(t, provider.ValidateCredentials(context.Background()))
+
+	provider = newTestHetznerProvider(server, "bad-token")
+	assert.ErrorIs(t, provider.ValidateCredentials(context.Background()), ErrInvalidCredentials)
+}
+
+func TestHetznerProvider_CreateInstance(t *testing.T) {
+	fake, server := newFakeHetzner(t, "token")
+	provider := newTestHetznerProvider(server, "token")
+
+	spec := InstanceSpec{
+		Size:         SizeLarge,
+		Architecture: ArchARM64,
+		Region:       "fsn1",
+		UserData:     "#cloud-config\npackages: [docker.io]",
+		Tags:         map[string]string{"env": "test", "owner": "a_b"},
+	}
+
+	instanceID, publicIP, err := provider.CreateInstance(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, "4001", instanceID)
+	assert.Equal(t, "198.51.100.20", publicIP)
+
+	require.Len(t, fake.created, 1)
+	req := fake.created[0]
+	assert.Equal(t, "cax31", req.ServerType)
+	assert.Equal(t, "ubuntu-22.04", req.Image)
+	assert.Equal(t, "fsn1", req.Location)
+	assert.Equal(t, spec.UserData, req.UserData)
+	assert.True(t, strings.HasPrefix(req.Name, "stagely-vm-"))
+	assert.Equal(t, map[string]string{"env": "test", "owner": "a_b", "managed-by": "stagely"}, req.Labels)
+}
+
+func TestHetznerProvider_CreateInstance_InvalidSpec(t *testing.T) {
+	fake, server := newFakeHetzner(t, "token")
+	provider := newTestHetznerProvider(server, "token")
+
+	_, _, err := provider.CreateInstance(context.Background(), InstanceSpec{
+		Size:         "tiny",
+		Architecture: ArchAMD64,
+		Region:       "fsn1",
+	})
+	assert.Error(t, err)
+	assert.Empty(t, fake.created)
+}
+
+func TestHetznerProvider_GetInstanceStatus(t *testing.T) {
+	fake, server := newFakeHetzner(t, "token")
+	provider := newTestHetznerProvider(server, "token")
+	ctx := context.Background()
+
+	instanceID, _, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "nbg1",
+	})
+	require.NoError(t, err)
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+	assert.Equal(t, "198.51.100.20", status.PublicIP)
+	assert.Equal(t, "10.0.0.2", status.PrivateIP)
+	assert.Equal(t, "cx22", status.InstanceType)
+	assert.False(t, status.LaunchedAt.IsZero())
+
+	fake.mu.Lock()
+	fake.servers[4001].Status = "off"
+	fake.mu.Unlock()
+
+	status, err = provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateStopped, status.State)
+
+	_, err = provider.GetInstanceStatus(ctx, "999999")
+	assert.ErrorIs(t, err, ErrInstanceNotFound)
+}
+
+func TestHetznerProvider_TerminateInstance(t *testing.T) {
+	fake, server := newFakeHetzner(t, "token")
+	provider := newTestHetznerProvider(server, "token")
+	ctx := context.Background()
+
+	instanceID, _, err := provider.CreateInstance(ctx, InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "nbg1",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+	assert.Empty(t, fake.servers)
+
+	// 404 on delete is treated as success
+	assert.NoError(t, provider.TerminateInstance(ctx, instanceID))
+
+	unauthorized := newTestHetznerProvider(server, "wrong-token")
+	assert.Error(t, unauthorized.TerminateInstance(ctx, instanceID))
+}
+
+func TestHetznerProvider_ListInstances(t *testing.T) {
+	_, server := newFakeHetzner(t, "token")
+	provider := newTestHetznerProvider(server, "token")
+	ctx := context.Background()
+
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchARM64, Region: "hel1"}
+
+	spec.Tags = map[string]string{"env": "prod"}
+	prodID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	spec.Tags = map[string]string{"env": "dev"}
+	_, _, err = provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	all, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	prod, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely, "env": "prod"})
+	require.NoError(t, err)
+	require.Len(t, prod, 1)
+	assert.Equal(t, prodID, prod[0].ID)
+	assert.Equal(t, StateRunning, prod[0].State)
+	assert.Equal(t, "prod", prod[0].Tags["env"])
+}
@@ -9,10 +9,39 @@ import (
 )
 
 // MockProvider is an in-memory mock implementation of CloudProvider for testing
+// Failures can be scripted with FailNth, and every call is recorded (see Calls)
 type MockProvider struct {
-	instances map[string]*mockInstance
-	mu        sync.RWMutex
-	delay     time.Duration
+	instances  map[string]*mockInstance
+	mu         sync.RWMutex
+	delay      time.Duration
+	withholdIP bool
+	nextID     int
+	catalog    *Catalog // nil accepts any built-in size
+
+	faults map[string]map[int]error // Op -> call number -> error
+	counts map[string]int           // Op -> calls so far
+	calls  []MockCall
+	callMu sync.Mutex
+}
+
+// MockProvider operations, as recorded in MockCall.Op and targeted by FailNth
+const (
+	MockOpCreateInstance      = "CreateInstance"
+	MockOpLaunchInstance      = "LaunchInstance"
+	MockOpGetInstanceStatus   = "GetInstanceStatus"
+	MockOpTerminateInstance   = "TerminateInstance"
+	MockOpStopInstance        = "StopInstance"
+	MockOpStartInstance       = "StartInstance"
+	MockOpListInstances       = "ListInstances"
+	MockOpValidateCredentials = "ValidateCredentials"
+)
+
+// MockCall records a single call made to a MockProvider
+type MockCall struct {
+	Op         string       // Operation name (see MockOp* constants)
+	InstanceID string       // Instance the call targeted or created (empty if none)
+	Spec       InstanceSpec // Spec passed to CreateInstance/LaunchInstance
+	Err        error        // Error returned by the call
 }
 
 type mockInstance struct {
@@ -21,17 +50,19 @@ type mockInstance struct {
 	PrivateIP    string
 	State        string
 	LaunchedAt   time.Time
+	ReadyAt      time.Time
 	Size         string
+	InstanceType string
 	Architecture string
 	Region       string
+	Tags         map[string]string
+	Spot         bool
+	Reclaimed    bool
 }
 
 // NewMockProvider creates a new mock provider with no simulated delay
 func NewMockProvider() *MockProvider {
-	return &MockProvider{
-		instances: make(map[string]*mockInstance),
-		delay:     0,
-	}
+	return NewMockProviderWithDelay(0)
 }
 
 // NewMockProviderWithDelay creates a new mock provider with simulated provisioning delay
@@ -39,7 +70,94 @@ func NewMockProviderWithDelay(delay time.Duration) *MockProvider {
 	return &MockProvider{
 		instances: make(map[string]*mockInstance),
 		delay:     delay,
+		faults:    make(map[string]map[int]error),
+		counts:    make(map[string]int),
+	}
+}
+
+// FailNth makes the nth call (1-based, counted from the provider's creation) to op return err
+// instead of running; e.g. FailNth(MockOpCreateInstance, 2, ErrQuotaExceeded)
+func (m *MockProvider) FailNth(op string, n int, err error) {
+	m.callMu.Lock()
+	defer m.callMu.Unlock()
+
+	if m.faults[op] == nil {
+		m.faults[op] = make(map[int]error)
+	}
+	m.faults[op][n] = err
+}
+
+// SetBootDelay changes how long instances launched from now on stay pending
+func (m *MockProvider) SetBootDelay(delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delay = delay
+}
+
+// WithholdPublicIP makes instances launched from now on reach running without a public IP,
+// so they never become ready
+func (m *MockProvider) WithholdPublicIP(withhold bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.withholdIP = withhold
+}
+
+// SetCatalog restricts launches to the sizes of catalog (nil accepts any built-in size)
+func (m *MockProvider) SetCatalog(catalog *Catalog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.catalog = catalog
+}
+
+// ReclaimInstance terminates an instance out from under its owner, like a spot reclaim
+func (m *MockProvider) ReclaimInstance(instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		return ErrInstanceNotFound
 	}
+
+	instance.State = StateTerminated
+	instance.PublicIP = ""
+	instance.PrivateIP = ""
+	instance.Reclaimed = true
+
+	return nil
+}
+
+// Calls returns every call made so far, in completion order
+func (m *MockProvider) Calls() []MockCall {
+	m.callMu.Lock()
+	defer m.callMu.Unlock()
+
+	calls := make([]MockCall, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// CallCount returns how many times op has been called
+func (m *MockProvider) CallCount(op string) int {
+	m.callMu.Lock()
+	defer m.callMu.Unlock()
+	return m.counts[op]
+}
+
+// begin counts a call to op and returns the scripted failure for it, if any
+func (m *MockProvider) begin(op string) error {
+	m.callMu.Lock()
+	defer m.callMu.Unlock()
+
+	m.counts[op]++
+	return m.faults[op][m.counts[op]]
+}
+
+// record appends a finished call to the call log
+func (m *MockProvider) record(call MockCall) {
+	m.callMu.Lock()
+	defer m.callMu.Unlock()
+	m.calls = append(m.calls, call)
 }
 
 // Name returns the provider identifier
@@ -47,74 +165,174 @@ func (m *MockProvider) Name() string {
 	return "mock"
 }
 
-// CreateInstance provisions a new mock VM with the given specification
-func (m *MockProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+// CreateInstance provisions a new mock VM and waits until it is ready
+func (m *MockProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (instanceID string, publicIP string, err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpCreateInstance, InstanceID: instanceID, Spec: spec, Err: err})
+	}()
+	if err := m.begin(MockOpCreateInstance); err != nil {
+		return "", "", err
+	}
+
+	// Wait through the unrecorded operations so the call log shows a single CreateInstance
+	return launchAndWait(ctx, unrecordedMock{m}, spec, m.waitOptions())
+}
+
+// waitOptions polls often enough that CreateInstance returns shortly after the simulated delay
+func (m *MockProvider) waitOptions() WaitOptions {
+	opts := DefaultWaitOptions()
+	m.mu.RLock()
+	delay := m.delay
+	m.mu.RUnlock()
+	if delay > 0 {
+		opts.PollInterval = max(delay/10, time.Millisecond)
+		opts.Timeout = delay + opts.Timeout
+	}
+	return opts
+}
+
+// unrecordedMock exposes MockProvider's behaviour without call recording or fault injection
+type unrecordedMock struct {
+	*MockProvider
+}
+
+func (u unrecordedMock) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	return u.launch(ctx, spec)
+}
+
+func (u unrecordedMock) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+	return u.status(ctx, instanceID)
+}
+
+// LaunchInstance provisions a new mock VM without waiting for it
+// With a simulated delay, the instance stays pending without a public IP until the delay elapses
+func (m *MockProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (instanceID string, err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpLaunchInstance, InstanceID: instanceID, Spec: spec, Err: err})
+	}()
+	if err := m.begin(MockOpLaunchInstance); err != nil {
+		return "", err
+	}
+	return m.launch(ctx, spec)
+}
+
+func (m *MockProvider) launch(ctx context.Context, spec InstanceSpec) (string, error) {
 	// Check context cancellation
 	if ctx.Err() != nil {
-		return "", "", ctx.Err()
+		return "", ctx.Err()
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Validate spec
-	if err := spec.Validate(); err != nil {
-		return "", "", err
+	if err := spec.ValidateFor(m.catalog); err != nil {
+		return "", err
 	}
 
-	// Simulate provisioning delay
-	if m.delay > 0 {
-		select {
-		case <-time.After(m.delay):
-		case <-ctx.Done():
-			return "", "", ctx.Err()
-		}
+	// Generate mock instance (sequential IDs never collide, unlike timestamps)
+	m.nextID++
+	instanceID := fmt.Sprintf("mock-%d", m.nextID)
+	publicIP := fmt.Sprintf("192.0.2.%d", m.nextID%254+1)
+	privateIP := fmt.Sprintf("10.0.0.%d", m.nextID%254+1)
+
+	tags := make(map[string]string, len(spec.Tags)+1)
+	for k, v := range spec.Tags {
+		tags[k] = v
 	}
+	tags[TagManagedBy] = ManagedByStagely
 
-	// Generate mock instance
-	instanceID := fmt.Sprintf("mock-%d", time.Now().UnixNano())
-	publicIP := fmt.Sprintf("192.0.2.%d", rand.Intn(255))
-	privateIP := fmt.Sprintf("10.0.0.%d", rand.Intn(255))
+	// Without a catalog the logical size doubles as the instance type
+	instanceType := spec.Size
+	if m.catalog != nil {
+		resolved, _ := m.catalog.Lookup(spec.Size, spec.Architecture)
+		instanceType = resolved.Name
+	}
 
+	now := time.Now()
 	instance := &mockInstance{
 		ID:           instanceID,
 		PublicIP:     publicIP,
 		PrivateIP:    privateIP,
 		State:        StateRunning,
-		LaunchedAt:   time.Now(),
+		LaunchedAt:   now,
+		ReadyAt:      now.Add(m.delay),
 		Size:         spec.Size,
+		InstanceType: instanceType,
 		Architecture: spec.Architecture,
 		Region:       spec.Region,
+		Tags:         tags,
+		Spot:         spec.SpotInstance,
+	}
+	if m.delay > 0 {
+		instance.State = StatePending
+	}
+	if m.withholdIP {
+		instance.PublicIP = ""
 	}
 
-	m.mu.Lock()
 	m.instances[instanceID] = instance
-	m.mu.Unlock()
 
-	return instanceID, publicIP, nil
+	return instanceID, nil
 }
 
 // GetInstanceStatus returns the current status of a mock instance
-func (m *MockProvider) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+func (m *MockProvider) GetInstanceStatus(ctx context.Context, instanceID string) (status InstanceStatus, err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpGetInstanceStatus, InstanceID: instanceID, Err: err})
+	}()
+	if err := m.begin(MockOpGetInstanceStatus); err != nil {
+		return InstanceStatus{}, err
+	}
+	return m.status(ctx, instanceID)
+}
+
+func (m *MockProvider) status(ctx context.Context, instanceID string) (InstanceStatus, error) {
 	if ctx.Err() != nil {
 		return InstanceStatus{}, ctx.Err()
 	}
 
-	m.mu.RLock()
-	instance, exists := m.instances[instanceID]
-	m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	instance, exists := m.instances[instanceID]
 	if !exists {
 		return InstanceStatus{}, ErrInstanceNotFound
 	}
 
-	return InstanceStatus{
-		State:      instance.State,
-		PublicIP:   instance.PublicIP,
-		PrivateIP:  instance.PrivateIP,
-		LaunchedAt: instance.LaunchedAt,
-	}, nil
+	// Promote pending instances once their simulated boot delay has elapsed
+	if instance.State == StatePending && !time.Now().Before(instance.ReadyAt) {
+		instance.State = StateRunning
+	}
+
+	status := InstanceStatus{
+		State:        instance.State,
+		PublicIP:     instance.PublicIP,
+		PrivateIP:    instance.PrivateIP,
+		LaunchedAt:   instance.LaunchedAt,
+		InstanceType: instance.InstanceType,
+		Spot:         instance.Spot,
+		Reclaimed:    instance.Reclaimed,
+	}
+	if instance.State == StatePending {
+		status.PublicIP = ""
+	}
+
+	return status, nil
 }
 
 // TerminateInstance deletes a mock instance (idempotent)
-func (m *MockProvider) TerminateInstance(ctx context.Context, instanceID string) error {
+func (m *MockProvider) TerminateInstance(ctx context.Context, instanceID string) (err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpTerminateInstance, InstanceID: instanceID, Err: err})
+	}()
+	if err := m.begin(MockOpTerminateInstance); err != nil {
+		return err
+	}
+	return m.terminate(ctx, instanceID)
+}
+
+func (m *MockProvider) terminate(ctx context.Context, instanceID string) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
@@ -136,11 +354,123 @@ func (m *MockProvider) TerminateInstance(ctx context.Context, instanceID string)
 	return nil
 }
 
-// ValidateCredentials verifies that stored credentials are valid
-// Mock provider always returns success
-func (m *MockProvider) ValidateCredentials(ctx context.Context) error {
+// StopInstance stops a mock instance (idempotent)
+// Like EC2, the public IP is released while the instance is stopped
+func (m *MockProvider) StopInstance(ctx context.Context, instanceID string) (err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpStopInstance, InstanceID: instanceID, Err: err})
+	}()
+	if err := m.begin(MockOpStopInstance); err != nil {
+		return err
+	}
+	return m.stop(ctx, instanceID)
+}
+
+func (m *MockProvider) stop(ctx context.Context, instanceID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		return ErrInstanceNotFound
+	}
+	if instance.State == StateTerminated {
+		return fmt.Errorf("%w: instance %s is terminated", ErrInvalidInput, instanceID)
+	}
+
+	instance.State = StateStopped
+	instance.PublicIP = ""
+
+	return nil
+}
+
+// StartInstance resumes a stopped mock instance with a new public IP (idempotent)
+func (m *MockProvider) StartInstance(ctx context.Context, instanceID string) (err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpStartInstance, InstanceID: instanceID, Err: err})
+	}()
+	if err := m.begin(MockOpStartInstance); err != nil {
+		return err
+	}
+	return m.start(ctx, instanceID)
+}
+
+func (m *MockProvider) start(ctx context.Context, instanceID string) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		return ErrInstanceNotFound
+	}
+	if instance.State == StateTerminated {
+		return fmt.Errorf("%w: instance %s is terminated", ErrInvalidInput, instanceID)
+	}
+	if instance.State == StateRunning {
+		return nil
+	}
+
+	instance.State = StateRunning
+	instance.PublicIP = fmt.Sprintf("192.0.2.%d", rand.Intn(255))
+
 	return nil
 }
+
+// ListInstances returns all mock instances carrying every tag in tagFilter
+func (m *MockProvider) ListInstances(ctx context.Context, tagFilter map[string]string) (summaries []InstanceSummary, err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpListInstances, Err: err})
+	}()
+	if err := m.begin(MockOpListInstances); err != nil {
+		return nil, err
+	}
+	return m.list(ctx, tagFilter)
+}
+
+func (m *MockProvider) list(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var summaries []InstanceSummary
+	for _, instance := range m.instances {
+		if !matchesTags(instance.Tags, tagFilter) {
+			continue
+		}
+		tags := make(map[string]string, len(instance.Tags))
+		for k, v := range instance.Tags {
+			tags[k] = v
+		}
+		summaries = append(summaries, InstanceSummary{
+			ID:         instance.ID,
+			State:      instance.State,
+			Tags:       tags,
+			LaunchedAt: instance.LaunchedAt,
+		})
+	}
+
+	return summaries, nil
+}
+
+// ValidateCredentials verifies that stored credentials are valid
+// Mock provider always returns success
+func (m *MockProvider) ValidateCredentials(ctx context.Context) (err error) {
+	defer func() {
+		m.record(MockCall{Op: MockOpValidateCredentials, Err: err})
+	}()
+	if err := m.begin(MockOpValidateCredentials); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
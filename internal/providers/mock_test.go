@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -202,3 +203,194 @@ func TestMockProvider_DelaySimulation(t *testing.T) {
 	require.NoError(t, err)
 	assert.GreaterOrEqual(t, duration, 50*time.Millisecond)
 }
+
+func TestMockProvider_StopStartInstance(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+
+	spec := InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "us-east-1",
+	}
+	instanceID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	// Stop releases the public IP
+	require.NoError(t, provider.StopInstance(ctx, instanceID))
+	require.NoError(t, provider.StopInstance(ctx, instanceID))
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateStopped, status.State)
+	assert.Empty(t, status.PublicIP)
+
+	// Start brings it back with a public IP
+	require.NoError(t, provider.StartInstance(ctx, instanceID))
+	require.NoError(t, provider.StartInstance(ctx, instanceID))
+	status, err = provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+
+	// Terminated instances cannot be resumed
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+	assert.ErrorIs(t, provider.StartInstance(ctx, instanceID), ErrInvalidInput)
+	assert.ErrorIs(t, provider.StopInstance(ctx, "nonexistent"), ErrInstanceNotFound)
+}
+
+func TestMockProvider_ListInstances(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+
+	spec := InstanceSpec{
+		Size:         SizeSmall,
+		Architecture: ArchAMD64,
+		Region:       "us-east-1",
+		Tags:         map[string]string{"team": "a"},
+	}
+	idA, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	spec.Tags = map[string]string{"team": "b"}
+	_, _, err = provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	all, err := provider.ListInstances(ctx, map[string]string{TagManagedBy: ManagedByStagely})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	teamA, err := provider.ListInstances(ctx, map[string]string{"team": "a"})
+	require.NoError(t, err)
+	require.Len(t, teamA, 1)
+	assert.Equal(t, idA, teamA[0].ID)
+	assert.Equal(t, StateRunning, teamA[0].State)
+
+	none, err := provider.ListInstances(ctx, map[string]string{"team": "c"})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestMockProvider_UniqueIDsUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	var wg sync.WaitGroup
+	ids := make([]string, 50)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := provider.LaunchInstance(ctx, spec)
+			assert.NoError(t, err)
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		assert.False(t, seen[id], "duplicate instance ID %s", id)
+		seen[id] = true
+	}
+}
+
+func TestMockProvider_FailNth(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+	provider.FailNth(MockOpCreateInstance, 2, ErrQuotaExceeded)
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	_, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	_, _, err = provider.CreateInstance(ctx, spec)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	_, _, err = provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+
+	// Failed calls do not create instances
+	all, err := provider.ListInstances(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestMockProvider_CallRecording(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+	provider.FailNth(MockOpTerminateInstance, 1, ErrNetworkFailure)
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	instanceID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+	_, err = provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Error(t, provider.TerminateInstance(ctx, instanceID))
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+
+	// CreateInstance is recorded once, not as the launch and polls it is built from
+	calls := provider.Calls()
+	require.Len(t, calls, 4)
+	assert.Equal(t, MockCall{Op: MockOpCreateInstance, InstanceID: instanceID, Spec: spec}, calls[0])
+	assert.Equal(t, MockOpGetInstanceStatus, calls[1].Op)
+	assert.Equal(t, MockCall{Op: MockOpTerminateInstance, InstanceID: instanceID, Err: ErrNetworkFailure}, calls[2])
+	assert.NoError(t, calls[3].Err)
+
+	assert.Equal(t, 1, provider.CallCount(MockOpCreateInstance))
+	assert.Equal(t, 0, provider.CallCount(MockOpLaunchInstance))
+	assert.Equal(t, 2, provider.CallCount(MockOpTerminateInstance))
+}
+
+func TestMockProvider_PendingToRunning(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	provider.SetBootDelay(30 * time.Millisecond)
+	instanceID, err := provider.LaunchInstance(ctx, spec)
+	require.NoError(t, err)
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StatePending, status.State)
+
+	time.Sleep(30 * time.Millisecond)
+	status, err = provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+}
+
+func TestMockProvider_ReclaimInstance(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1", SpotInstance: true}
+
+	instanceID, _, err := provider.CreateInstance(ctx, spec)
+	require.NoError(t, err)
+	require.NoError(t, provider.ReclaimInstance(instanceID))
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateTerminated, status.State)
+	assert.Empty(t, status.PublicIP)
+
+	assert.ErrorIs(t, provider.ReclaimInstance("nonexistent"), ErrInstanceNotFound)
+}
+
+func TestMockProvider_WithholdPublicIP(t *testing.T) {
+	provider := NewMockProvider()
+	provider.WithholdPublicIP(true)
+	spec := InstanceSpec{Size: SizeSmall, Architecture: ArchAMD64, Region: "us-east-1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	instanceID, _, err := provider.CreateInstance(ctx, spec)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotEmpty(t, instanceID)
+
+	status, err := provider.GetInstanceStatus(context.Background(), instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, status.State)
+	assert.Empty(t, status.PublicIP)
+}
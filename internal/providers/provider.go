@@ -4,6 +4,7 @@ package providers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -13,9 +14,13 @@ type CloudProvider interface {
 	Name() string
 
 	// CreateInstance provisions a new VM with the given specification
-	// Returns instanceID and publicIP (or error if provisioning fails)
+	// Blocks until the VM is ready; returns instanceID and publicIP (or error if provisioning fails)
 	CreateInstance(ctx context.Context, spec InstanceSpec) (instanceID string, publicIP string, err error)
 
+	// LaunchInstance starts provisioning a VM and returns its ID without waiting for it to boot
+	// Use WaitForReady to wait for a public IP
+	LaunchInstance(ctx context.Context, spec InstanceSpec) (instanceID string, err error)
+
 	// GetInstanceStatus returns the current status of an instance
 	GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error)
 
@@ -25,23 +30,92 @@ type CloudProvider interface {
 	// ValidateCredentials verifies that stored credentials are valid
 	// Should make a lightweight API call (e.g., list regions)
 	ValidateCredentials(ctx context.Context) error
+
+	// ListInstances returns all instances carrying every tag in tagFilter
+	// Used to find VMs that exist at the provider but are not tracked by Core
+	ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error)
+}
+
+// InstanceStopper is an optional capability for providers that can stop an instance
+// and later resume it with its disk intact (e.g., parking idle stagelets overnight)
+type InstanceStopper interface {
+	// StopInstance powers off a running instance (idempotent - no error if already stopped)
+	StopInstance(ctx context.Context, instanceID string) error
+
+	// StartInstance resumes a stopped instance (idempotent - no error if already running)
+	// The public IP may change across a stop/start cycle
+	StartInstance(ctx context.Context, instanceID string) error
+}
+
+// AsStopper returns the provider's stop/start capability, if it has one
+func AsStopper(p CloudProvider) (InstanceStopper, bool) {
+	return as[InstanceStopper](p)
+}
+
+// Wrapper is implemented by decorators (see WithResilience and WithSpotFallback)
+// so capability probes can reach the provider they wrap
+type Wrapper interface {
+	Unwrap() CloudProvider
+}
+
+// as returns the first provider in p's decorator chain that implements T.
+// A decorator that implements T itself (e.g., to retry it) takes precedence over the provider it wraps.
+func as[T any](p CloudProvider) (T, bool) {
+	for p != nil {
+		if capability, ok := p.(T); ok {
+			return capability, true
+		}
+		wrapper, ok := p.(Wrapper)
+		if !ok {
+			break
+		}
+		p = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// CatalogOf returns the sizes a provider can launch, falling back to the default catalog of
+// its type for providers without their own (e.g., MockProvider)
+func CatalogOf(p CloudProvider) *Catalog {
+	if cataloged, ok := as[interface{ Catalog() *Catalog }](p); ok {
+		return cataloged.Catalog()
+	}
+	return DefaultCatalog(p.Name())
+}
+
+// TagPolicyOf returns the policy a provider applies to the tags of new instances,
+// falling back to the policy of its type without team settings
+func TagPolicyOf(p CloudProvider) *TagPolicy {
+	if tagged, ok := as[interface{ TagPolicy() *TagPolicy }](p); ok {
+		return tagged.TagPolicy()
+	}
+	return mustTagPolicy(p.Name())
 }
 
 // InstanceSpec specifies what kind of VM to provision
 type InstanceSpec struct {
-	Size         string            // "small", "medium", "large"
+	Size         string            // Catalog size (e.g., "small", "xlarge", or a team-defined size)
 	Architecture string            // "amd64", "arm64"
 	Region       string            // Provider-specific (e.g., "us-east-1", "nyc3")
-	UserData     string            // Cloud-init script (base64 NOT required)
+	UserData     string            // Cloud-init user data, usually from cloudinit.Render (base64 NOT required)
 	Tags         map[string]string // Instance tags/labels
 	SpotInstance bool              // Request spot/preemptible instance
+	Firewall     *Firewall         // Network access rules (nil = provider defaults; see FirewallManager)
 }
 
-// Instance size constants
+// Sizes offered by every built-in catalog
 const (
 	SizeSmall  = "small"
 	SizeMedium = "medium"
 	SizeLarge  = "large"
+	SizeXLarge = "xlarge"
+)
+
+// Tag stamped on every instance Stagely provisions, so they can be found again
+const (
+	TagManagedBy     = "managed-by"
+	ManagedByStagely = "stagely"
 )
 
 // Architecture constants
@@ -50,24 +124,43 @@ const (
 	ArchARM64 = "arm64"
 )
 
-// Validate checks that the instance spec is valid
+// Validate checks that the instance spec is valid for at least one built-in catalog
+// Providers validate against their own (possibly team-customized) catalog with ValidateFor.
 func (s *InstanceSpec) Validate() error {
+	return s.ValidateFor(nil)
+}
+
+// ValidateFor checks that the instance spec is valid and its size and architecture are in catalog
+// A nil catalog accepts any size offered by a built-in catalog.
+func (s *InstanceSpec) ValidateFor(catalog *Catalog) error {
 	if s.Size == "" {
-		return errors.New("size is required")
+		return fmt.Errorf("%w: size is required", ErrInvalidInput)
 	}
-	if s.Size != SizeSmall && s.Size != SizeMedium && s.Size != SizeLarge {
-		return errors.New("size must be small, medium, or large")
+	if catalog == nil && !knownSize(s.Size) {
+		return fmt.Errorf("%w: unsupported size %q", ErrInvalidInput, s.Size)
 	}
 
 	if s.Architecture == "" {
-		return errors.New("architecture is required")
+		return fmt.Errorf("%w: architecture is required", ErrInvalidInput)
 	}
 	if s.Architecture != ArchAMD64 && s.Architecture != ArchARM64 {
-		return errors.New("architecture must be amd64 or arm64")
+		return fmt.Errorf("%w: architecture must be amd64 or arm64", ErrInvalidInput)
 	}
 
 	if s.Region == "" {
-		return errors.New("region is required")
+		return fmt.Errorf("%w: region is required", ErrInvalidInput)
+	}
+
+	if catalog != nil {
+		if _, err := catalog.Lookup(s.Size, s.Architecture); err != nil {
+			return err
+		}
+	}
+
+	if s.Firewall != nil {
+		if err := s.Firewall.Validate(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -75,10 +168,31 @@ func (s *InstanceSpec) Validate() error {
 
 // InstanceStatus represents normalized instance state
 type InstanceStatus struct {
-	State      string    // "pending", "running", "stopped", "terminated"
-	PublicIP   string    // Empty if not yet assigned
-	PrivateIP  string    // Empty if not applicable
-	LaunchedAt time.Time // Instance creation timestamp
+	State        string    // "pending", "running", "stopped", "terminated"
+	PublicIP     string    // Empty if not yet assigned
+	PrivateIP    string    // Empty if not applicable
+	LaunchedAt   time.Time // Instance creation timestamp
+	InstanceType string    // Provider instance type (e.g., "c5.xlarge", "cx32")
+	Spot         bool      // Running on spot/preemptible capacity
+	Reclaimed    bool      // Terminated by the provider (e.g., spot interruption) rather than by Stagely
+}
+
+// InstanceSummary describes an instance returned by ListInstances
+type InstanceSummary struct {
+	ID         string
+	State      string            // Normalized state (see State* constants)
+	Tags       map[string]string // Instance tags/labels
+	LaunchedAt time.Time         // Instance creation timestamp
+}
+
+// matchesTags reports whether tags contain every key/value pair in filter
+func matchesTags(tags, filter map[string]string) bool {
+	for k, v := range filter {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
 }
 
 // Instance state constants
@@ -96,9 +210,12 @@ func (s *InstanceStatus) IsReady() bool {
 
 // Common provider errors
 var (
-	ErrInvalidCredentials = errors.New("invalid or expired credentials")
-	ErrQuotaExceeded      = errors.New("quota exceeded")
-	ErrNetworkFailure     = errors.New("network failure")
-	ErrInvalidInput       = errors.New("invalid input")
-	ErrInstanceNotFound   = errors.New("instance not found")
+	ErrInvalidCredentials   = errors.New("invalid or expired credentials")
+	ErrQuotaExceeded        = errors.New("quota exceeded")
+	ErrNetworkFailure       = errors.New("network failure")
+	ErrInvalidInput         = errors.New("invalid input")
+	ErrInstanceNotFound     = errors.New("instance not found")
+	ErrNotSupported         = errors.New("operation not supported by provider")
+	ErrInsufficientCapacity = errors.New("insufficient capacity")
+	ErrRateLimited          = errors.New("rate limited")
 )
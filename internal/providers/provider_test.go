@@ -34,6 +34,15 @@ func TestInstanceSpec_Validation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid spec with xlarge amd64",
+			spec: InstanceSpec{
+				Size:         "xlarge",
+				Architecture: "amd64",
+				Region:       "fsn1",
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid size",
 			spec: InstanceSpec{
@@ -129,3 +138,17 @@ func TestInstanceStatus_IsReady(t *testing.T) {
 		})
 	}
 }
+
+func TestAsStopper(t *testing.T) {
+	stopper, ok := AsStopper(NewMockProvider())
+	assert.True(t, ok)
+	assert.NotNil(t, stopper)
+
+	_, ok = AsStopper(&HetznerProvider{})
+	assert.False(t, ok)
+}
+
+func TestInstanceSpec_ValidationErrorsAreInvalidInput(t *testing.T) {
+	spec := InstanceSpec{Size: "tiny", Architecture: "amd64", Region: "us-east-1"}
+	assert.ErrorIs(t, spec.Validate(), ErrInvalidInput)
+}
@@ -0,0 +1,263 @@
+// Package providertest provides a conformance suite for CloudProvider implementations
+package providertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a fresh provider for a single conformance subtest
+type Factory func(t *testing.T) providers.CloudProvider
+
+// Config holds the provider-specific values the suite needs
+type Config struct {
+	Region            string                // Region to launch test instances in (default "us-east-1")
+	UnknownInstanceID string                // Well-formed ID that does not exist (default "does-not-exist")
+	Wait              providers.WaitOptions // Readiness polling (default 10ms polls, 30s timeout)
+}
+
+// withDefaults fills zero-valued fields with defaults suitable for in-memory fakes
+func (c Config) withDefaults() Config {
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.UnknownInstanceID == "" {
+		c.UnknownInstanceID = "does-not-exist"
+	}
+	if c.Wait.PollInterval <= 0 {
+		c.Wait.PollInterval = 10 * time.Millisecond
+	}
+	if c.Wait.Timeout <= 0 {
+		c.Wait.Timeout = 30 * time.Second
+	}
+	return c
+}
+
+// RunConformance checks that the providers built by factory honour the documented
+// CloudProvider semantics, using the default Config
+func RunConformance(t *testing.T, factory Factory) {
+	RunConformanceWithConfig(t, factory, Config{})
+}
+
+// RunConformanceWithConfig checks that the providers built by factory honour the documented
+// CloudProvider semantics
+func RunConformanceWithConfig(t *testing.T, factory Factory, cfg Config) {
+	cfg = cfg.withDefaults()
+	s := &suite{factory: factory, cfg: cfg}
+
+	t.Run("Name", s.testName)
+	t.Run("ValidateEnforced", s.testValidateEnforced)
+	t.Run("Lifecycle", s.testLifecycle)
+	t.Run("TerminateIdempotent", s.testTerminateIdempotent)
+	t.Run("StatusNotFound", s.testStatusNotFound)
+	t.Run("ListInstances", s.testListInstances)
+	t.Run("StopStart", s.testStopStart)
+	t.Run("ContextCancellation", s.testContextCancellation)
+}
+
+type suite struct {
+	factory Factory
+	cfg     Config
+}
+
+func (s *suite) spec() providers.InstanceSpec {
+	return providers.InstanceSpec{
+		Size:         providers.SizeSmall,
+		Architecture: providers.ArchAMD64,
+		Region:       s.cfg.Region,
+		Tags:         map[string]string{"conformance": "true"},
+	}
+}
+
+// launch starts an instance and registers its cleanup
+func (s *suite) launch(t *testing.T, p providers.CloudProvider) string {
+	t.Helper()
+
+	instanceID, err := p.LaunchInstance(context.Background(), s.spec())
+	require.NoError(t, err)
+	require.NotEmpty(t, instanceID)
+
+	t.Cleanup(func() {
+		_ = p.TerminateInstance(context.Background(), instanceID)
+	})
+	return instanceID
+}
+
+func (s *suite) testName(t *testing.T) {
+	assert.NotEmpty(t, s.factory(t).Name())
+}
+
+func (s *suite) testValidateEnforced(t *testing.T) {
+	valid := s.spec()
+
+	tests := []struct {
+		name   string
+		mutate func(*providers.InstanceSpec)
+	}{
+		{"missing size", func(spec *providers.InstanceSpec) { spec.Size = "" }},
+		{"unknown size", func(spec *providers.InstanceSpec) { spec.Size = "gigantic" }},
+		{"missing architecture", func(spec *providers.InstanceSpec) { spec.Architecture = "" }},
+		{"unknown architecture", func(spec *providers.InstanceSpec) { spec.Architecture = "riscv64" }},
+		{"missing region", func(spec *providers.InstanceSpec) { spec.Region = "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := s.factory(t)
+			spec := valid
+			tt.mutate(&spec)
+
+			_, err := p.LaunchInstance(context.Background(), spec)
+			assert.ErrorIs(t, err, providers.ErrInvalidInput, "LaunchInstance")
+
+			_, _, err = p.CreateInstance(context.Background(), spec)
+			assert.ErrorIs(t, err, providers.ErrInvalidInput, "CreateInstance")
+		})
+	}
+}
+
+func (s *suite) testLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := s.factory(t)
+
+	instanceID := s.launch(t, p)
+
+	status, err := p.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Contains(t, []string{providers.StatePending, providers.StateRunning}, status.State)
+
+	status, err = providers.WaitForReady(ctx, p, instanceID, s.cfg.Wait)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+	assert.False(t, status.LaunchedAt.IsZero())
+
+	require.NoError(t, p.TerminateInstance(ctx, instanceID))
+	s.assertGone(t, p, instanceID)
+}
+
+func (s *suite) testTerminateIdempotent(t *testing.T) {
+	ctx := context.Background()
+	p := s.factory(t)
+
+	instanceID := s.launch(t, p)
+	require.NoError(t, p.TerminateInstance(ctx, instanceID))
+	assert.NoError(t, p.TerminateInstance(ctx, instanceID), "second terminate")
+	assert.NoError(t, p.TerminateInstance(ctx, s.cfg.UnknownInstanceID), "unknown instance")
+}
+
+func (s *suite) testStatusNotFound(t *testing.T) {
+	_, err := s.factory(t).GetInstanceStatus(context.Background(), s.cfg.UnknownInstanceID)
+	assert.ErrorIs(t, err, providers.ErrInstanceNotFound)
+}
+
+func (s *suite) testListInstances(t *testing.T) {
+	ctx := context.Background()
+	p := s.factory(t)
+
+	instanceID := s.launch(t, p)
+
+	managed, err := p.ListInstances(ctx, map[string]string{providers.TagManagedBy: providers.ManagedByStagely})
+	require.NoError(t, err)
+	summary, ok := findSummary(managed, instanceID)
+	require.True(t, ok, "launched instance must carry the managed-by tag")
+	assert.Equal(t, "true", summary.Tags["conformance"])
+
+	tagged, err := p.ListInstances(ctx, map[string]string{"conformance": "true"})
+	require.NoError(t, err)
+	_, ok = findSummary(tagged, instanceID)
+	assert.True(t, ok, "spec tags must be applied")
+
+	other, err := p.ListInstances(ctx, map[string]string{"conformance": "other"})
+	require.NoError(t, err)
+	_, ok = findSummary(other, instanceID)
+	assert.False(t, ok, "tag filter must exclude non-matching instances")
+}
+
+func (s *suite) testStopStart(t *testing.T) {
+	ctx := context.Background()
+	p := s.factory(t)
+
+	stopper, ok := providers.AsStopper(p)
+	if !ok {
+		t.Skip("provider does not implement InstanceStopper")
+	}
+
+	instanceID := s.launch(t, p)
+	_, err := providers.WaitForReady(ctx, p, instanceID, s.cfg.Wait)
+	require.NoError(t, err)
+
+	require.NoError(t, stopper.StopInstance(ctx, instanceID))
+	require.NoError(t, stopper.StopInstance(ctx, instanceID), "second stop")
+
+	require.NoError(t, stopper.StartInstance(ctx, instanceID))
+	require.NoError(t, stopper.StartInstance(ctx, instanceID), "second start")
+
+	status, err := providers.WaitForReady(ctx, p, instanceID, s.cfg.Wait)
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+}
+
+func (s *suite) testContextCancellation(t *testing.T) {
+	p := s.factory(t)
+	instanceID := s.launch(t, p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"CreateInstance": func() error {
+			_, _, err := p.CreateInstance(ctx, s.spec())
+			return err
+		},
+		"LaunchInstance": func() error {
+			_, err := p.LaunchInstance(ctx, s.spec())
+			return err
+		},
+		"GetInstanceStatus": func() error {
+			_, err := p.GetInstanceStatus(ctx, instanceID)
+			return err
+		},
+		"TerminateInstance": func() error {
+			return p.TerminateInstance(ctx, instanceID)
+		},
+		"ListInstances": func() error {
+			_, err := p.ListInstances(ctx, nil)
+			return err
+		},
+		"ValidateCredentials": func() error {
+			return p.ValidateCredentials(ctx)
+		},
+	}
+
+	for name, call := range calls {
+		err := call()
+		assert.True(t, errors.Is(err, context.Canceled), "%s returned %v, want context.Canceled", name, err)
+	}
+}
+
+// assertGone checks that a terminated instance is reported as terminated or not found
+func (s *suite) assertGone(t *testing.T, p providers.CloudProvider, instanceID string) {
+	t.Helper()
+
+	status, err := p.GetInstanceStatus(context.Background(), instanceID)
+	if errors.Is(err, providers.ErrInstanceNotFound) {
+		return
+	}
+	require.NoError(t, err)
+	assert.NotEqual(t, providers.StateRunning, status.State, "terminated instance must not be reported as running")
+}
+
+func findSummary(summaries []providers.InstanceSummary, instanceID string) (providers.InstanceSummary, bool) {
+	for _, summary := range summaries {
+		if summary.ID == instanceID {
+			return summary, true
+		}
+	}
+	return providers.InstanceSummary{}, false
+}
@@ -8,17 +8,20 @@ import (
 
 // Registry manages CloudProvider instances with thread-safe access
 type Registry struct {
-	providers map[string]CloudProvider
-	mu        sync.RWMutex
+	providers  map[string]CloudProvider
+	decorators []Decorator
+	mu         sync.RWMutex
 }
 
 // DefaultRegistry is the global provider registry instance
 var DefaultRegistry = NewRegistry()
 
 // NewRegistry creates a new provider registry
-func NewRegistry() *Registry {
+// Decorators (e.g., Resilience) are applied in order to every provider as it is registered
+func NewRegistry(decorators ...Decorator) *Registry {
 	return &Registry{
-		providers: make(map[string]CloudProvider),
+		providers:  make(map[string]CloudProvider),
+		decorators: decorators,
 	}
 }
 
@@ -40,6 +43,10 @@ func (r *Registry) Register(name string, provider CloudProvider) error {
 		return fmt.Errorf("provider %q is already registered", name)
 	}
 
+	for _, decorate := range r.decorators {
+		provider = decorate(provider)
+	}
+
 	r.providers[name] = provider
 	return nil
 }
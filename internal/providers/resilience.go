@@ -0,0 +1,354 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ResilienceOptions controls the retry, rate limiting and circuit breaking added by WithResilience
+type ResilienceOptions struct {
+	MaxAttempts      int           // Total attempts per call, including the first (default 3)
+	BaseDelay        time.Duration // Backoff before the first retry, doubled on each retry (default 500ms)
+	MaxDelay         time.Duration // Upper bound for a single backoff, including provider hints (default 30s)
+	RateLimit        float64       // Sustained provider API calls per second (default 10)
+	Burst            int           // Calls allowed in a burst above RateLimit (default 10)
+	BreakerThreshold int           // Consecutive network failures that open the circuit (default 5)
+	BreakerCooldown  time.Duration // Time the circuit stays open before a trial call (default 30s)
+}
+
+// DefaultResilienceOptions returns the resilience settings used for registered providers
+func DefaultResilienceOptions() ResilienceOptions {
+	return ResilienceOptions{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		RateLimit:        10,
+		Burst:            10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// withDefaults fills zero-valued fields from DefaultResilienceOptions
+func (o ResilienceOptions) withDefaults() ResilienceOptions {
+	defaults := DefaultResilienceOptions()
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaults.MaxAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = defaults.BaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = defaults.MaxDelay
+	}
+	if o.MaxDelay < o.BaseDelay {
+		o.MaxDelay = o.BaseDelay
+	}
+	if o.RateLimit <= 0 {
+		o.RateLimit = defaults.RateLimit
+	}
+	if o.Burst <= 0 {
+		o.Burst = defaults.Burst
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = defaults.BreakerThreshold
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = defaults.BreakerCooldown
+	}
+	return o
+}
+
+// Decorator wraps a CloudProvider with additional behaviour
+type Decorator func(CloudProvider) CloudProvider
+
+// Resilience returns a Decorator that applies WithResilience with the given options
+func Resilience(opts ResilienceOptions) Decorator {
+	return func(p CloudProvider) CloudProvider {
+		return WithResilience(p, opts)
+	}
+}
+
+// WithResilience wraps a provider so every API call is rate limited, retried with jittered
+// exponential backoff when the error is retryable (see IsRetryable), and short-circuited with
+// ErrCircuitOpen after repeated network failures.
+// Launches are only retried when the provider rejected the request (see retryableLaunch), so a
+// timeout after the VM was created cannot launch a second one.
+// The wrapper implements InstanceStopper only if p does; other capabilities are reached through Unwrap.
+func WithResilience(p CloudProvider, opts ResilienceOptions) CloudProvider {
+	opts = opts.withDefaults()
+	r := &resilientProvider{
+		inner:   p,
+		opts:    opts,
+		limiter: newTokenBucket(opts.RateLimit, opts.Burst),
+		breaker: newCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
+	}
+	if stopper, ok := AsStopper(p); ok {
+		return &resilientStopper{resilientProvider: r, stopper: stopper}
+	}
+	return r
+}
+
+// resilientProvider is the CloudProvider returned by WithResilience
+type resilientProvider struct {
+	inner   CloudProvider
+	opts    ResilienceOptions
+	limiter *tokenBucket
+	breaker *circuitBreaker
+}
+
+// Name returns the wrapped provider's identifier
+func (r *resilientProvider) Name() string {
+	return r.inner.Name()
+}
+
+// CreateInstance provisions a VM through the wrapped provider and waits until it is ready.
+// The launch and the readiness polls go through the wrapper, so polls are rate limited and
+// retried like any other call. Only rejected launches are retried (see LaunchInstance), so a
+// VM that launched but never became ready is not launched a second time.
+func (r *resilientProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+	return launchAndWait(ctx, r, spec, waitOptionsOf(r.inner))
+}
+
+// LaunchInstance starts provisioning a VM through the wrapped provider.
+// Only rejected launches are retried (see retryableLaunch).
+func (r *resilientProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	var instanceID string
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		instanceID, err = r.inner.LaunchInstance(ctx, spec)
+		if err != nil && !retryableLaunch(err) {
+			return permanent(err)
+		}
+		return err
+	})
+	return instanceID, err
+}
+
+// retryableLaunch reports whether a failed launch certainly created no VM and may be retried.
+// Network failures and server errors are ambiguous: the provider may have launched the VM before
+// the response was lost, and a retry would leak it.
+func retryableLaunch(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrInsufficientCapacity)
+}
+
+// GetInstanceStatus returns the instance status from the wrapped provider.
+func (r *resilientProvider) GetInstanceStatus(ctx context.Context, instanceID string) (InstanceStatus, error) {
+	var status InstanceStatus
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		status, err = r.inner.GetInstanceStatus(ctx, instanceID)
+		return err
+	})
+	return status, err
+}
+
+// TerminateInstance deletes an instance through the wrapped provider.
+func (r *resilientProvider) TerminateInstance(ctx context.Context, instanceID string) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		return r.inner.TerminateInstance(ctx, instanceID)
+	})
+}
+
+// ValidateCredentials verifies credentials through the wrapped provider.
+func (r *resilientProvider) ValidateCredentials(ctx context.Context) error {
+	return r.do(ctx, r.inner.ValidateCredentials)
+}
+
+// ListInstances lists instances through the wrapped provider.
+func (r *resilientProvider) ListInstances(ctx context.Context, tagFilter map[string]string) ([]InstanceSummary, error) {
+	var summaries []InstanceSummary
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		summaries, err = r.inner.ListInstances(ctx, tagFilter)
+		return err
+	})
+	return summaries, err
+}
+
+// Unwrap returns the wrapped provider
+func (r *resilientProvider) Unwrap() CloudProvider {
+	return r.inner
+}
+
+// resilientStopper is a resilientProvider whose wrapped provider supports stop/start
+type resilientStopper struct {
+	*resilientProvider
+	stopper InstanceStopper
+}
+
+// StopInstance stops an instance through the wrapped provider.
+func (r *resilientStopper) StopInstance(ctx context.Context, instanceID string) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		return r.stopper.StopInstance(ctx, instanceID)
+	})
+}
+
+// StartInstance starts an instance through the wrapped provider.
+func (r *resilientStopper) StartInstance(ctx context.Context, instanceID string) error {
+	return r.do(ctx, func(ctx context.Context) error {
+		return r.stopper.StartInstance(ctx, instanceID)
+	})
+}
+
+// permanentError marks an error that must not be retried even if it is retryable
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// do runs call with rate limiting, circuit breaking and retries
+func (r *resilientProvider) do(ctx context.Context, call func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := r.limiter.wait(ctx); err != nil {
+			return err
+		}
+		if !r.breaker.allow() {
+			return fmt.Errorf("%s: %w", r.inner.Name(), ErrCircuitOpen)
+		}
+
+		err := call(ctx)
+		r.breaker.record(err)
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if err == nil || !IsRetryable(err) || attempt >= r.opts.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		if err := sleepContext(ctx, r.backoff(attempt, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the delay before the retry following the given attempt.
+// The exponential delay is jittered to spread out retries from concurrent callers,
+// and raised to the provider's RetryAfter hint, capped at MaxDelay.
+func (r *resilientProvider) backoff(attempt int, err error) time.Duration {
+	delay := r.opts.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.opts.MaxDelay {
+		delay = r.opts.MaxDelay
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if hint := RetryAfter(err); hint > delay {
+		delay = hint
+	}
+	return min(delay, r.opts.MaxDelay)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tokenBucket limits calls to a sustained rate with bursts up to its capacity
+type tokenBucket struct {
+	rate     float64 // Tokens added per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+	mu       sync.Mutex
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available or ctx is done
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// circuitBreaker opens after consecutive network failures and lets a single
+// trial call through once the cooldown has elapsed (half-open)
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool // A half-open trial call is in flight
+	mu        sync.Mutex
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may proceed
+func (c *circuitBreaker) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures < c.threshold {
+		return true
+	}
+	if time.Now().Before(c.openUntil) || c.trial {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call.
+// Only network failures count; any other outcome shows the provider is reachable.
+func (c *circuitBreaker) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trial = false
+	if err != nil && errors.Is(err, ErrNetworkFailure) {
+		c.failures++
+		if c.failures >= c.threshold {
+			c.openUntil = time.Now().Add(c.cooldown)
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	c.failures = 0
+}
@@ -0,0 +1,258 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyProvider returns a MockProvider whose first calls to op fail with the given errors
+func flakyProvider(op string, faults ...error) *MockProvider {
+	provider := NewMockProvider()
+	for i, fault := range faults {
+		provider.FailNth(op, i+1, fault)
+	}
+	return provider
+}
+
+// fastResilience keeps test delays in the millisecond range
+var fastResilience = ResilienceOptions{
+	MaxAttempts:      3,
+	BaseDelay:        time.Millisecond,
+	MaxDelay:         5 * time.Millisecond,
+	RateLimit:        1000,
+	Burst:            100,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Hour,
+}
+
+func networkFault() error {
+	return &ProviderError{Provider: "mock", Op: "test", Kind: ErrNetworkFailure, Retryable: true, RetryAfter: time.Hour}
+}
+
+func capacityFault() error {
+	return &ProviderError{Provider: "mock", Op: "test", Kind: ErrInsufficientCapacity, Retryable: true, RetryAfter: time.Hour}
+}
+
+func TestWithResilience_RetriesRetryableErrors(t *testing.T) {
+	inner := flakyProvider(MockOpListInstances, networkFault(), networkFault())
+	provider := WithResilience(inner, fastResilience)
+
+	_, err := provider.ListInstances(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, inner.CallCount(MockOpListInstances), "RetryAfter hint must be capped at MaxDelay")
+}
+
+func TestWithResilience_GivesUpAfterMaxAttempts(t *testing.T) {
+	inner := flakyProvider(MockOpListInstances, networkFault(), networkFault(), networkFault(), networkFault())
+	provider := WithResilience(inner, fastResilience)
+
+	_, err := provider.ListInstances(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrNetworkFailure)
+	assert.Equal(t, 3, inner.CallCount(MockOpListInstances))
+}
+
+func TestWithResilience_RetriesOnlyRejectedLaunches(t *testing.T) {
+	tests := []struct {
+		name          string
+		fault         error
+		expectedCalls int
+	}{
+		{"capacity", capacityFault(), 2},
+		{"rate limited", &ProviderError{Provider: "mock", Op: "test", Kind: ErrRateLimited, Retryable: true}, 2},
+		{"network failure", networkFault(), 1},
+		{"server error", &ProviderError{Provider: "mock", Op: "test", Kind: ErrNetworkFailure, Retryable: true, Code: "InternalError"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// Given - the first launch fails
+			launcher := flakyProvider(MockOpLaunchInstance, tt.fault)
+			creator := flakyProvider(MockOpLaunchInstance, tt.fault)
+
+			// When
+			_, launchErr := WithResilience(launcher, fastResilience).LaunchInstance(ctx, waitTestSpec)
+			_, _, createErr := WithResilience(creator, fastResilience).CreateInstance(ctx, waitTestSpec)
+
+			// Then - an ambiguous failure may have launched a VM, so it is not launched again
+			assert.Equal(t, tt.expectedCalls, launcher.CallCount(MockOpLaunchInstance))
+			assert.Equal(t, tt.expectedCalls, creator.CallCount(MockOpLaunchInstance))
+			if tt.expectedCalls == 1 {
+				assert.ErrorIs(t, launchErr, tt.fault)
+				assert.ErrorIs(t, createErr, tt.fault)
+			} else {
+				assert.NoError(t, launchErr)
+				assert.NoError(t, createErr)
+			}
+		})
+	}
+}
+
+func TestWithResilience_DoesNotRetryPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault error
+	}{
+		{"quota", &ProviderError{Provider: "mock", Op: "test", Kind: ErrQuotaExceeded}},
+		{"invalid input", ErrInvalidInput},
+		{"plain error", errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := flakyProvider(MockOpValidateCredentials, tt.fault)
+			provider := WithResilience(inner, fastResilience)
+
+			err := provider.ValidateCredentials(context.Background())
+			assert.ErrorIs(t, err, tt.fault)
+			assert.Equal(t, 1, inner.CallCount(MockOpValidateCredentials))
+		})
+	}
+}
+
+func TestWithResilience_CircuitBreaker(t *testing.T) {
+	inner := flakyProvider(MockOpValidateCredentials, networkFault(), networkFault(), networkFault(), networkFault())
+
+	opts := fastResilience
+	opts.MaxAttempts = 1
+	opts.BreakerThreshold = 3
+	opts.BreakerCooldown = 20 * time.Millisecond
+	provider := WithResilience(inner, opts)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, provider.ValidateCredentials(ctx), ErrNetworkFailure)
+	}
+
+	// Open: calls fail fast without reaching the provider
+	err := provider.ValidateCredentials(ctx)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 3, inner.CallCount(MockOpValidateCredentials))
+
+	// Half-open after the cooldown: a failed trial re-opens the circuit
+	time.Sleep(opts.BreakerCooldown)
+	assert.ErrorIs(t, provider.ValidateCredentials(ctx), ErrNetworkFailure)
+	assert.ErrorIs(t, provider.ValidateCredentials(ctx), ErrCircuitOpen)
+
+	// A successful trial closes it again
+	time.Sleep(opts.BreakerCooldown)
+	require.NoError(t, provider.ValidateCredentials(ctx))
+	require.NoError(t, provider.ValidateCredentials(ctx))
+}
+
+func TestWithResilience_RateLimit(t *testing.T) {
+	opts := fastResilience
+	opts.RateLimit = 100
+	opts.Burst = 1
+	provider := WithResilience(NewMockProvider(), opts)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		require.NoError(t, provider.ValidateCredentials(ctx))
+	}
+	// One call from the burst, then 5 calls at 10ms intervals
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestWithResilience_RateLimitsReadinessPolls(t *testing.T) {
+	// Given - a VM that boots in 50ms, polled every 5ms, and one call allowed every 10ms
+	inner := NewMockProviderWithDelay(50 * time.Millisecond)
+	inner.FailNth(MockOpGetInstanceStatus, 1, networkFault())
+	opts := fastResilience
+	opts.RateLimit = 100
+	opts.Burst = 1
+	provider := WithResilience(inner, opts)
+
+	// When
+	start := time.Now()
+	_, publicIP, err := provider.CreateInstance(context.Background(), waitTestSpec)
+	elapsed := time.Since(start)
+
+	// Then - the polls went through the wrapper: the failed one was retried, and they
+	// were spaced by the rate limit rather than the poll interval
+	require.NoError(t, err)
+	assert.NotEmpty(t, publicIP)
+	polls := inner.CallCount(MockOpGetInstanceStatus)
+	assert.GreaterOrEqual(t, polls, 2)
+	assert.LessOrEqual(t, polls, int(elapsed/(10*time.Millisecond))+2)
+}
+
+func TestWithResilience_ContextCancelledDuringBackoff(t *testing.T) {
+	inner := flakyProvider(MockOpLaunchInstance, capacityFault(), capacityFault())
+	opts := fastResilience
+	opts.BaseDelay = time.Hour
+	opts.MaxDelay = time.Hour
+	provider := WithResilience(inner, opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := provider.LaunchInstance(ctx, waitTestSpec)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, inner.CallCount(MockOpLaunchInstance))
+}
+
+func TestWithResilience_PreservesStopper(t *testing.T) {
+	provider := WithResilience(NewMockProvider(), fastResilience)
+	stopper, ok := AsStopper(provider)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	instanceID, _, err := provider.CreateInstance(ctx, waitTestSpec)
+	require.NoError(t, err)
+	require.NoError(t, stopper.StopInstance(ctx, instanceID))
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StateStopped, status.State)
+
+	// Providers without stop/start must not gain the capability by being wrapped
+	_, ok = AsStopper(WithResilience(&HetznerProvider{}, fastResilience))
+	assert.False(t, ok)
+}
+
+func TestDecorators_ForwardCapabilities(t *testing.T) {
+	// Given - a provider with a firewall, a team catalog and tag settings, wrapped twice
+	catalog := mustCatalog(map[string]map[string]InstanceType{
+		"gpu": {ArchAMD64: {Name: "g5.xlarge", VCPUs: 4, MemoryGB: 16}},
+	})
+	tags, err := NewTagPolicy("aws", TagConfig{Tags: map[string]string{"cost-center": "eng"}})
+	require.NoError(t, err)
+	inner := &AWSProvider{region: "us-east-1", catalog: catalog, tags: tags}
+	provider := WithSpotFallback(WithResilience(inner, fastResilience), SpotPolicy{})
+
+	// When
+	_, isFirewall := AsFirewallManager(provider)
+	_, isStopper := AsStopper(provider)
+
+	// Then
+	assert.True(t, isFirewall)
+	assert.True(t, isStopper)
+	assert.Same(t, catalog, CatalogOf(provider))
+	assert.Same(t, tags, TagPolicyOf(provider))
+
+	// Providers without their own catalog fall back to the default of their type
+	assert.Equal(t, DefaultCatalog("mock").Sizes(), CatalogOf(WithResilience(NewMockProvider(), fastResilience)).Sizes())
+	_, isFirewall = AsFirewallManager(WithResilience(NewMockProvider(), fastResilience))
+	assert.False(t, isFirewall)
+}
+
+func TestRegistry_Decorators(t *testing.T) {
+	registry := NewRegistry(Resilience(fastResilience))
+	inner := flakyProvider(MockOpValidateCredentials, networkFault())
+	require.NoError(t, registry.Register("mock", inner))
+
+	provider, err := registry.Get("mock")
+	require.NoError(t, err)
+	assert.Equal(t, "mock", provider.Name())
+
+	require.NoError(t, provider.ValidateCredentials(context.Background()))
+	assert.Equal(t, 2, inner.CallCount(MockOpValidateCredentials))
+}
@@ -0,0 +1,242 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// securityGroupDescription is shown in the EC2 console for managed groups
+const securityGroupDescription = "Managed by Stagely, do not edit"
+
+// securityGroupManager creates and reuses Stagely-managed security groups.
+// Groups are named after a hash of their rules ("stagely-<hash>"), so identical firewalls
+// share a group per VPC and a changed firewall gets a new group instead of mutating one in use.
+type securityGroupManager struct {
+	client      EC2API
+	cache       map[string]string // "region/vpc/name" -> group ID
+	defaultVPCs map[string]string // region -> default VPC ID
+	mu          sync.Mutex
+}
+
+// newSecurityGroupManager creates a manager backed by the given EC2 client.
+func newSecurityGroupManager(client EC2API) *securityGroupManager {
+	return &securityGroupManager{
+		client:      client,
+		cache:       make(map[string]string),
+		defaultVPCs: make(map[string]string),
+	}
+}
+
+// Ensure returns the ID of the managed security group for the firewall in a region
+// and VPC (empty for the region's default VPC), creating it if needed.
+func (m *securityGroupManager) Ensure(ctx context.Context, region, vpcID string, firewall Firewall) (string, error) {
+	if err := firewall.Validate(); err != nil {
+		return "", err
+	}
+
+	if vpcID == "" {
+		var err error
+		if vpcID, err = m.defaultVPC(ctx, region); err != nil {
+			return "", err
+		}
+	}
+
+	name := "stagely-" + firewall.Hash()
+	key := region + "/" + vpcID + "/" + name
+
+	m.mu.Lock()
+	groupID, ok := m.cache[key]
+	m.mu.Unlock()
+	if ok {
+		return groupID, nil
+	}
+
+	groupID, err := m.find(ctx, region, vpcID, name)
+	if err != nil {
+		return "", err
+	}
+	if groupID == "" {
+		groupID, err = m.create(ctx, region, vpcID, name, firewall)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	m.mu.Lock()
+	m.cache[key] = groupID
+	m.mu.Unlock()
+
+	return groupID, nil
+}
+
+// defaultVPC returns the ID of the region's default VPC.
+func (m *securityGroupManager) defaultVPC(ctx context.Context, region string) (string, error) {
+	m.mu.Lock()
+	vpcID, ok := m.defaultVPCs[region]
+	m.mu.Unlock()
+	if ok {
+		return vpcID, nil
+	}
+
+	result, err := m.client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		Filters: []types.Filter{{Name: aws.String("is-default"), Values: []string{"true"}}},
+	}, withRegion(region))
+	if err != nil {
+		return "", classifyAWSError("describe vpcs", err)
+	}
+	if len(result.Vpcs) == 0 {
+		return "", fmt.Errorf("%w: region %s has no default VPC, configure a network for it", ErrInvalidInput, region)
+	}
+
+	vpcID = aws.ToString(result.Vpcs[0].VpcId)
+	m.mu.Lock()
+	m.defaultVPCs[region] = vpcID
+	m.mu.Unlock()
+
+	return vpcID, nil
+}
+
+// find returns the ID of the managed group with the given name (empty if there is none).
+func (m *securityGroupManager) find(ctx context.Context, region, vpcID, name string) (string, error) {
+	result, err := m.client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("group-name"), Values: []string{name}},
+			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			{Name: aws.String("tag:" + TagManagedBy), Values: []string{ManagedByStagely}},
+		},
+	}, withRegion(region))
+	if err != nil {
+		return "", classifyAWSError("describe security groups", err)
+	}
+	if len(result.SecurityGroups) == 0 {
+		return "", nil
+	}
+	return aws.ToString(result.SecurityGroups[0].GroupId), nil
+}
+
+// create creates the group and its rules. A group left without its rules is deleted again,
+// since it would otherwise be reused as is.
+func (m *securityGroupManager) create(ctx context.Context, region, vpcID, name string, firewall Firewall) (string, error) {
+	result, err := m.client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(name),
+		Description: aws.String(securityGroupDescription),
+		VpcId:       aws.String(vpcID),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeSecurityGroup,
+				Tags: []types.Tag{
+					{Key: aws.String("Name"), Value: aws.String(name)},
+					{Key: aws.String(TagManagedBy), Value: aws.String(ManagedByStagely)},
+				},
+			},
+		},
+	}, withRegion(region))
+	if err != nil {
+		if isSecurityGroupDuplicate(err) {
+			// Created concurrently by another launch
+			return m.find(ctx, region, vpcID, name)
+		}
+		return "", classifyAWSError("create security group", err)
+	}
+	groupID := aws.ToString(result.GroupId)
+
+	if err := m.authorize(ctx, region, groupID, firewall); err != nil {
+		if _, deleteErr := m.client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{
+			GroupId: aws.String(groupID),
+		}, withRegion(region)); deleteErr != nil {
+			err = errors.Join(err, classifyAWSError("delete security group", deleteErr))
+		}
+		return "", err
+	}
+
+	return groupID, nil
+}
+
+// authorize adds the firewall's rules to a new group. New groups allow all outbound
+// traffic, which is replaced when the firewall restricts it.
+func (m *securityGroupManager) authorize(ctx context.Context, region, groupID string, firewall Firewall) error {
+	if len(firewall.Inbound) > 0 {
+		if _, err := m.client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: ipPermissions(firewall.Inbound),
+		}, withRegion(region)); err != nil {
+			return classifyAWSError("authorize security group ingress", err)
+		}
+	}
+
+	if len(firewall.Outbound) > 0 {
+		if _, err := m.client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+			GroupId: aws.String(groupID),
+			IpPermissions: []types.IpPermission{{
+				IpProtocol: aws.String("-1"),
+				IpRanges:   []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			}},
+		}, withRegion(region)); err != nil {
+			return classifyAWSError("revoke security group egress", err)
+		}
+		if _, err := m.client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: ipPermissions(firewall.Outbound),
+		}, withRegion(region)); err != nil {
+			return classifyAWSError("authorize security group egress", err)
+		}
+	}
+
+	return nil
+}
+
+// ipPermissions converts validated firewall rules to EC2 permissions.
+// EC2 only matches ICMP for IPv4, so the IPv6 networks of an ICMP rule get an ICMPv6 permission.
+func ipPermissions(rules []FirewallRule) []types.IpPermission {
+	permissions := make([]types.IpPermission, 0, len(rules))
+	for _, rule := range rules {
+		permission := types.IpPermission{IpProtocol: aws.String(rule.Protocol)}
+		switch rule.Protocol {
+		case ProtocolTCP, ProtocolUDP:
+			from, to, _ := rule.PortRange()
+			permission.FromPort = aws.Int32(int32(from))
+			permission.ToPort = aws.Int32(int32(to))
+		case ProtocolICMP:
+			// All ICMP types and codes
+			permission.FromPort = aws.Int32(-1)
+			permission.ToPort = aws.Int32(-1)
+		case ProtocolAll:
+			permission.IpProtocol = aws.String("-1")
+		}
+
+		for _, cidr := range rule.CIDRs {
+			if netip.MustParsePrefix(cidr).Addr().Is4() {
+				permission.IpRanges = append(permission.IpRanges, types.IpRange{CidrIp: aws.String(cidr)})
+			} else {
+				permission.Ipv6Ranges = append(permission.Ipv6Ranges, types.Ipv6Range{CidrIpv6: aws.String(cidr)})
+			}
+		}
+
+		if rule.Protocol == ProtocolICMP && len(permission.Ipv6Ranges) > 0 {
+			icmpv6 := permission
+			icmpv6.IpProtocol = aws.String("icmpv6")
+			icmpv6.IpRanges = nil
+			permission.Ipv6Ranges = nil
+			if len(permission.IpRanges) > 0 {
+				permissions = append(permissions, permission)
+			}
+			permissions = append(permissions, icmpv6)
+			continue
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions
+}
+
+func isSecurityGroupDuplicate(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidGroup.Duplicate"
+}
@@ -0,0 +1,217 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecurityGroups is an in-memory EC2 security group store for the mock client.
+type fakeSecurityGroups struct {
+	groups   map[string]string // "vpc/name" -> group ID
+	ingress  map[string][]types.IpPermission
+	egress   map[string][]types.IpPermission
+	revoked  map[string]bool
+	deleted  []string
+	creates  int
+	describe int
+}
+
+func newFakeSecurityGroups() *fakeSecurityGroups {
+	return &fakeSecurityGroups{
+		groups:  make(map[string]string),
+		ingress: make(map[string][]types.IpPermission),
+		egress:  make(map[string][]types.IpPermission),
+		revoked: make(map[string]bool),
+	}
+}
+
+// install wires the fake into a mock EC2 client with a default VPC "vpc-default".
+func (f *fakeSecurityGroups) install(client *mockEC2Client) *mockEC2Client {
+	client.describeVpcsFunc = func(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+		return &ec2.DescribeVpcsOutput{Vpcs: []types.Vpc{{VpcId: aws.String("vpc-default")}}}, nil
+	}
+	client.describeSecurityGroupsFunc = func(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+		f.describe++
+		filters := make(map[string]string)
+		for _, filter := range params.Filters {
+			filters[aws.ToString(filter.Name)] = filter.Values[0]
+		}
+		if filters["tag:"+TagManagedBy] != ManagedByStagely {
+			return nil, errors.New("managed-by filter missing")
+		}
+		groupID, ok := f.groups[filters["vpc-id"]+"/"+filters["group-name"]]
+		if !ok {
+			return &ec2.DescribeSecurityGroupsOutput{}, nil
+		}
+		return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []types.SecurityGroup{{GroupId: aws.String(groupID)}}}, nil
+	}
+	client.createSecurityGroupFunc = func(ctx context.Context, params *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+		key := aws.ToString(params.VpcId) + "/" + aws.ToString(params.GroupName)
+		if _, ok := f.groups[key]; ok {
+			return nil, &smithy.GenericAPIError{Code: "InvalidGroup.Duplicate"}
+		}
+		f.creates++
+		groupID := fmt.Sprintf("sg-%d", f.creates)
+		f.groups[key] = groupID
+		return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(groupID)}, nil
+	}
+	client.authorizeSecurityGroupIngressFunc = func(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+		f.ingress[aws.ToString(params.GroupId)] = params.IpPermissions
+		return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+	}
+	client.revokeSecurityGroupEgressFunc = func(ctx context.Context, params *ec2.RevokeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+		f.revoked[aws.ToString(params.GroupId)] = true
+		return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+	}
+	client.authorizeSecurityGroupEgressFunc = func(ctx context.Context, params *ec2.AuthorizeSecurityGroupEgressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+		f.egress[aws.ToString(params.GroupId)] = params.IpPermissions
+		return &ec2.AuthorizeSecurityGroupEgressOutput{}, nil
+	}
+	client.deleteSecurityGroupFunc = func(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+		f.deleted = append(f.deleted, aws.ToString(params.GroupId))
+		return &ec2.DeleteSecurityGroupOutput{}, nil
+	}
+	return client
+}
+
+func TestSecurityGroupManager_CreatesAndReuses(t *testing.T) {
+	fake := newFakeSecurityGroups()
+	manager := newSecurityGroupManager(fake.install(&mockEC2Client{}))
+	ctx := context.Background()
+	edge := *EdgeFirewall([]string{"203.0.113.10/32", "2001:db8::/64"})
+
+	groupID, err := manager.Ensure(ctx, "us-east-1", "", edge)
+	require.NoError(t, err)
+	assert.Equal(t, "sg-1", groupID)
+	assert.Contains(t, fake.groups, "vpc-default/stagely-"+edge.Hash())
+
+	require.Len(t, fake.ingress[groupID], 1)
+	permission := fake.ingress[groupID][0]
+	assert.Equal(t, "tcp", aws.ToString(permission.IpProtocol))
+	assert.Equal(t, int32(1), aws.ToInt32(permission.FromPort))
+	assert.Equal(t, int32(65535), aws.ToInt32(permission.ToPort))
+	assert.Equal(t, "203.0.113.10/32", aws.ToString(permission.IpRanges[0].CidrIp))
+	assert.Equal(t, "2001:db8::/64", aws.ToString(permission.Ipv6Ranges[0].CidrIpv6))
+
+	// Outbound traffic stays unrestricted
+	assert.False(t, fake.revoked[groupID])
+	assert.Empty(t, fake.egress[groupID])
+
+	// Cached in this process
+	again, err := manager.Ensure(ctx, "us-east-1", "", edge)
+	require.NoError(t, err)
+	assert.Equal(t, groupID, again)
+	assert.Equal(t, 1, fake.describe)
+
+	// Found by name from another process
+	reused, err := newSecurityGroupManager(fake.install(&mockEC2Client{})).Ensure(ctx, "us-east-1", "", edge)
+	require.NoError(t, err)
+	assert.Equal(t, groupID, reused)
+	assert.Equal(t, 1, fake.creates)
+
+	// Same rules in another VPC get their own group
+	other, err := manager.Ensure(ctx, "us-east-1", "vpc-team", edge)
+	require.NoError(t, err)
+	assert.NotEqual(t, groupID, other)
+}
+
+func TestSecurityGroupManager_RestrictsEgress(t *testing.T) {
+	fake := newFakeSecurityGroups()
+	manager := newSecurityGroupManager(fake.install(&mockEC2Client{}))
+
+	firewall := Firewall{
+		Inbound: []FirewallRule{{Protocol: ProtocolTCP, Ports: "3000-3999", CIDRs: []string{"203.0.113.10/32"}}},
+		Outbound: []FirewallRule{
+			{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"0.0.0.0/0"}},
+			{Protocol: ProtocolAll, CIDRs: []string{"10.0.0.0/8"}},
+		},
+	}
+	groupID, err := manager.Ensure(context.Background(), "us-east-1", "vpc-team", firewall)
+	require.NoError(t, err)
+
+	assert.True(t, fake.revoked[groupID])
+	require.Len(t, fake.egress[groupID], 2)
+	assert.Equal(t, int32(443), aws.ToInt32(fake.egress[groupID][0].FromPort))
+	assert.Equal(t, "-1", aws.ToString(fake.egress[groupID][1].IpProtocol))
+	assert.Nil(t, fake.egress[groupID][1].FromPort)
+}
+
+func TestSecurityGroupManager_DuplicateCreate(t *testing.T) {
+	fake := newFakeSecurityGroups()
+	client := fake.install(&mockEC2Client{})
+	edge := *EdgeFirewall([]string{"203.0.113.10/32"})
+
+	// Another launch creates the group between our describe and create
+	describe := client.describeSecurityGroupsFunc
+	client.describeSecurityGroupsFunc = func(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+		if fake.describe == 0 {
+			fake.groups["vpc-team/stagely-"+edge.Hash()] = "sg-concurrent"
+			fake.describe++
+			return &ec2.DescribeSecurityGroupsOutput{}, nil
+		}
+		return describe(ctx, params, optFns...)
+	}
+
+	groupID, err := newSecurityGroupManager(client).Ensure(context.Background(), "us-east-1", "vpc-team", edge)
+	require.NoError(t, err)
+	assert.Equal(t, "sg-concurrent", groupID)
+	assert.Zero(t, fake.creates)
+}
+
+func TestSecurityGroupManager_Errors(t *testing.T) {
+	ctx := context.Background()
+	edge := *EdgeFirewall([]string{"203.0.113.10/32"})
+
+	// Invalid rules never reach EC2
+	_, err := newSecurityGroupManager(&mockEC2Client{}).Ensure(ctx, "us-east-1", "", *EdgeFirewall([]string{"nope"}))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	// No default VPC
+	_, err = newSecurityGroupManager(&mockEC2Client{}).Ensure(ctx, "us-east-1", "", edge)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	// A group left without its rules is deleted
+	fake := newFakeSecurityGroups()
+	client := fake.install(&mockEC2Client{})
+	client.authorizeSecurityGroupIngressFunc = func(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "RulesPerSecurityGroupLimitExceeded"}
+	}
+	_, err = newSecurityGroupManager(client).Ensure(ctx, "us-east-1", "vpc-team", edge)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"sg-1"}, fake.deleted)
+}
+
+func TestIPPermissions_ICMPv6(t *testing.T) {
+	// Given
+	rules := []FirewallRule{
+		{Protocol: ProtocolICMP, CIDRs: []string{"203.0.113.0/24", "2001:db8::/64"}},
+		{Protocol: ProtocolICMP, CIDRs: []string{"2001:db8:1::/64"}},
+		{Protocol: ProtocolTCP, Ports: "443", CIDRs: []string{"203.0.113.0/24", "2001:db8::/64"}},
+	}
+
+	// When
+	permissions := ipPermissions(rules)
+
+	// Then - IPv6 networks of ICMP rules get their own ICMPv6 permission
+	require.Len(t, permissions, 4)
+	assert.Equal(t, "icmp", aws.ToString(permissions[0].IpProtocol))
+	assert.Len(t, permissions[0].IpRanges, 1)
+	assert.Empty(t, permissions[0].Ipv6Ranges)
+	assert.Equal(t, "icmpv6", aws.ToString(permissions[1].IpProtocol))
+	assert.Empty(t, permissions[1].IpRanges)
+	assert.Equal(t, "2001:db8::/64", aws.ToString(permissions[1].Ipv6Ranges[0].CidrIpv6))
+	assert.Equal(t, int32(-1), aws.ToInt32(permissions[1].FromPort))
+	assert.Equal(t, "icmpv6", aws.ToString(permissions[2].IpProtocol))
+	assert.Equal(t, "tcp", aws.ToString(permissions[3].IpProtocol))
+	assert.Len(t, permissions[3].IpRanges, 1)
+	assert.Len(t, permissions[3].Ipv6Ranges, 1)
+}
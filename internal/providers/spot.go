@@ -0,0 +1,348 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TagMarket records whether an instance was launched on spot or on-demand capacity
+const (
+	TagMarket      = "market"
+	MarketSpot     = "spot"
+	MarketOnDemand = "on-demand"
+)
+
+// SpotPolicy controls how spot requests are handled by WithSpotFallback and SpotWatcher
+type SpotPolicy struct {
+	FallbackToOnDemand bool         // Retry on-demand when a spot launch fails for lack of capacity or quota
+	Reprovision        bool         // Re-provision workloads whose spot VM was reclaimed
+	MaxReprovisions    int          // Re-provisions per workload before giving up (default 3)
+	MaxConcurrent      int          // Re-provisions running at once, across workloads (default 4)
+	Metrics            *SpotMetrics // Counters for spot usage (optional)
+}
+
+// SpotMetrics counts spot launches, fallbacks and reclaims so savings can be reported
+type SpotMetrics struct {
+	spotLaunches      atomic.Int64
+	onDemandFallbacks atomic.Int64
+	reclaims          atomic.Int64
+	reprovisions      atomic.Int64
+}
+
+// SpotStats is a snapshot of SpotMetrics
+type SpotStats struct {
+	SpotLaunches      int64 // Instances launched on spot capacity
+	OnDemandFallbacks int64 // Spot launches that fell back to on-demand
+	Reclaims          int64 // Spot instances reclaimed while in use
+	Reprovisions      int64 // Reclaimed workloads successfully re-provisioned
+}
+
+// Stats returns the current counter values
+func (m *SpotMetrics) Stats() SpotStats {
+	return SpotStats{
+		SpotLaunches:      m.spotLaunches.Load(),
+		OnDemandFallbacks: m.onDemandFallbacks.Load(),
+		Reclaims:          m.reclaims.Load(),
+		Reprovisions:      m.reprovisions.Load(),
+	}
+}
+
+// add increments a counter, tolerating a nil *SpotMetrics
+func (m *SpotMetrics) add(counter func(*SpotMetrics) *atomic.Int64) {
+	if m != nil {
+		counter(m).Add(1)
+	}
+}
+
+// WithSpotFallback wraps a provider so spot launches that fail with ErrInsufficientCapacity,
+// ErrQuotaExceeded or ErrNotSupported are retried on-demand (if policy allows).
+// Every launched instance is tagged with TagMarket so cost reports can tell them apart.
+// The wrapper implements InstanceStopper only if p does; other capabilities are reached through Unwrap.
+func WithSpotFallback(p CloudProvider, policy SpotPolicy) CloudProvider {
+	s := &spotProvider{CloudProvider: p, policy: policy}
+	if stopper, ok := AsStopper(p); ok {
+		return &spotStopper{spotProvider: s, InstanceStopper: stopper}
+	}
+	return s
+}
+
+// spotProvider is the CloudProvider returned by WithSpotFallback
+type spotProvider struct {
+	CloudProvider
+	policy SpotPolicy
+}
+
+// Unwrap returns the wrapped provider
+func (s *spotProvider) Unwrap() CloudProvider {
+	return s.CloudProvider
+}
+
+// spotStopper is a spotProvider whose wrapped provider supports stop/start
+type spotStopper struct {
+	*spotProvider
+	InstanceStopper
+}
+
+// CreateInstance provisions a VM, falling back to on-demand if spot capacity is unavailable.
+func (s *spotProvider) CreateInstance(ctx context.Context, spec InstanceSpec) (string, string, error) {
+	if !spec.SpotInstance {
+		return s.CloudProvider.CreateInstance(ctx, withMarketTag(spec))
+	}
+
+	instanceID, publicIP, err := s.CloudProvider.CreateInstance(ctx, withMarketTag(spec))
+	if err == nil || instanceID != "" || !s.shouldFallback(err) {
+		if err == nil {
+			s.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.spotLaunches })
+		}
+		return instanceID, publicIP, err
+	}
+
+	s.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.onDemandFallbacks })
+	spec.SpotInstance = false
+	return s.CloudProvider.CreateInstance(ctx, withMarketTag(spec))
+}
+
+// LaunchInstance starts a VM, falling back to on-demand if spot capacity is unavailable.
+func (s *spotProvider) LaunchInstance(ctx context.Context, spec InstanceSpec) (string, error) {
+	if !spec.SpotInstance {
+		return s.CloudProvider.LaunchInstance(ctx, withMarketTag(spec))
+	}
+
+	instanceID, err := s.CloudProvider.LaunchInstance(ctx, withMarketTag(spec))
+	if err == nil || !s.shouldFallback(err) {
+		if err == nil {
+			s.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.spotLaunches })
+		}
+		return instanceID, err
+	}
+
+	s.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.onDemandFallbacks })
+	spec.SpotInstance = false
+	return s.CloudProvider.LaunchInstance(ctx, withMarketTag(spec))
+}
+
+// shouldFallback reports whether a failed spot launch should be retried on-demand
+func (s *spotProvider) shouldFallback(err error) bool {
+	return s.policy.FallbackToOnDemand &&
+		(errors.Is(err, ErrInsufficientCapacity) || errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrNotSupported))
+}
+
+// withMarketTag returns a copy of spec tagged with its capacity market
+func withMarketTag(spec InstanceSpec) InstanceSpec {
+	tags := make(map[string]string, len(spec.Tags)+1)
+	for k, v := range spec.Tags {
+		tags[k] = v
+	}
+	tags[TagMarket] = MarketOnDemand
+	if spec.SpotInstance {
+		tags[TagMarket] = MarketSpot
+	}
+	spec.Tags = tags
+	return spec
+}
+
+// SpotWorkload is a unit of work (build job or environment) running on a spot VM
+type SpotWorkload struct {
+	Kind         string       // "build_job" or "environment"
+	ID           string       // Build job or environment ID
+	InstanceID   string       // Current VM
+	Spec         InstanceSpec // Spec used to (re-)provision the VM
+	Reprovisions int          // Times the workload has been moved after a reclaim
+}
+
+// ReclaimHandler is notified when a workload's spot VM has been reclaimed
+type ReclaimHandler interface {
+	// Reprovisioned is called once a replacement VM is ready; it should record the new
+	// VM and replay the workload's last build or deploy on it
+	Reprovisioned(ctx context.Context, workload SpotWorkload, previousInstanceID, publicIP string) error
+
+	// Lost is called when the workload could not be moved (re-provisioning disabled,
+	// exhausted or failed) so it can be marked failed
+	Lost(ctx context.Context, workload SpotWorkload, err error)
+}
+
+// ErrSpotReclaimed is passed to ReclaimHandler.Lost when a reclaimed workload is not re-provisioned
+var ErrSpotReclaimed = errors.New("spot instance reclaimed")
+
+// SpotWatcher polls tracked spot VMs and re-provisions workloads whose VM was reclaimed.
+// Re-provisioning runs in the background, so a slow launch does not delay reclaim detection.
+type SpotWatcher struct {
+	provider  CloudProvider
+	policy    SpotPolicy
+	handler   ReclaimHandler
+	workloads map[string]SpotWorkload // Instance ID -> workload
+	slots     chan struct{}           // Limits concurrent re-provisions (MaxConcurrent)
+	inflight  sync.WaitGroup
+	mu        sync.Mutex
+}
+
+// NewSpotWatcher creates a watcher; provider should normally be wrapped with WithSpotFallback
+// so a replacement can still be found when spot capacity is gone
+func NewSpotWatcher(provider CloudProvider, policy SpotPolicy, handler ReclaimHandler) *SpotWatcher {
+	if policy.MaxReprovisions <= 0 {
+		policy.MaxReprovisions = 3
+	}
+	if policy.MaxConcurrent <= 0 {
+		policy.MaxConcurrent = 4
+	}
+	return &SpotWatcher{
+		provider:  provider,
+		policy:    policy,
+		handler:   handler,
+		workloads: make(map[string]SpotWorkload),
+		slots:     make(chan struct{}, policy.MaxConcurrent),
+	}
+}
+
+// Track starts watching a workload's VM (on-demand VMs are ignored)
+func (w *SpotWatcher) Track(workload SpotWorkload) {
+	if !workload.Spec.SpotInstance {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.workloads[workload.InstanceID] = workload
+}
+
+// Untrack stops watching a VM, e.g. before Stagely terminates it on purpose (idempotent)
+func (w *SpotWatcher) Untrack(instanceID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.workloads, instanceID)
+}
+
+// Check polls every tracked VM once and returns the workloads whose VM the provider reclaimed.
+// They are handed to the handler in the background, at most MaxConcurrent at a time (see Wait).
+// VMs that are gone for any other reason (e.g., terminated by another Core instance or by hand)
+// are no longer watched, but not re-provisioned.
+func (w *SpotWatcher) Check(ctx context.Context) ([]SpotWorkload, error) {
+	w.mu.Lock()
+	workloads := make([]SpotWorkload, 0, len(w.workloads))
+	for _, workload := range w.workloads {
+		workloads = append(workloads, workload)
+	}
+	w.mu.Unlock()
+
+	var reclaimed []SpotWorkload
+	for _, workload := range workloads {
+		status, err := w.provider.GetInstanceStatus(ctx, workload.InstanceID)
+		if err != nil && !errors.Is(err, ErrInstanceNotFound) {
+			if ctx.Err() != nil {
+				return reclaimed, ctx.Err()
+			}
+			log.Printf("spot: failed to check instance %s of %s %s: %v", workload.InstanceID, workload.Kind, workload.ID, err)
+			continue
+		}
+		if err == nil && status.State != StateTerminated {
+			continue
+		}
+
+		// Skip workloads untracked while we were polling (terminated on purpose)
+		w.mu.Lock()
+		_, tracked := w.workloads[workload.InstanceID]
+		delete(w.workloads, workload.InstanceID)
+		w.mu.Unlock()
+		if !tracked {
+			continue
+		}
+
+		if err != nil || !status.Reclaimed {
+			log.Printf("spot: instance %s of %s %s is gone but was not reclaimed, no longer watching it", workload.InstanceID, workload.Kind, workload.ID)
+			continue
+		}
+
+		log.Printf("spot: instance %s of %s %s was reclaimed", workload.InstanceID, workload.Kind, workload.ID)
+		w.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.reclaims })
+		reclaimed = append(reclaimed, workload)
+		w.startReprovision(ctx, workload)
+	}
+
+	return reclaimed, nil
+}
+
+// Wait blocks until the re-provisions started by Check have finished
+func (w *SpotWatcher) Wait() {
+	w.inflight.Wait()
+}
+
+// startReprovision re-provisions a workload in the background once a slot is free.
+// A workload still waiting for a slot when ctx is done is reported lost.
+func (w *SpotWatcher) startReprovision(ctx context.Context, workload SpotWorkload) {
+	w.inflight.Add(1)
+	go func() {
+		defer w.inflight.Done()
+
+		select {
+		case w.slots <- struct{}{}:
+		case <-ctx.Done():
+			w.handler.Lost(context.WithoutCancel(ctx), workload, fmt.Errorf("%w: %s (not re-provisioned: %v)", ErrSpotReclaimed, workload.InstanceID, ctx.Err()))
+			return
+		}
+		defer func() { <-w.slots }()
+
+		w.reprovision(ctx, workload)
+	}()
+}
+
+// reprovision moves a reclaimed workload to a new VM and hands it to the handler
+func (w *SpotWatcher) reprovision(ctx context.Context, workload SpotWorkload) {
+	if !w.policy.Reprovision {
+		w.handler.Lost(ctx, workload, fmt.Errorf("%w: %s", ErrSpotReclaimed, workload.InstanceID))
+		return
+	}
+	if workload.Reprovisions >= w.policy.MaxReprovisions {
+		w.handler.Lost(ctx, workload, fmt.Errorf("%w: %s (re-provisioned %d times)", ErrSpotReclaimed, workload.InstanceID, workload.Reprovisions))
+		return
+	}
+
+	previousID := workload.InstanceID
+	instanceID, publicIP, err := w.provider.CreateInstance(ctx, workload.Spec)
+	if err != nil {
+		if instanceID != "" {
+			_ = w.provider.TerminateInstance(ctx, instanceID)
+		}
+		w.handler.Lost(ctx, workload, fmt.Errorf("re-provision after spot reclaim: %w", err))
+		return
+	}
+
+	workload.InstanceID = instanceID
+	workload.Reprovisions++
+	w.policy.Metrics.add(func(m *SpotMetrics) *atomic.Int64 { return &m.reprovisions })
+
+	if err := w.handler.Reprovisioned(ctx, workload, previousID, publicIP); err != nil {
+		_ = w.provider.TerminateInstance(ctx, instanceID)
+		w.handler.Lost(ctx, workload, fmt.Errorf("replay after spot reclaim: %w", err))
+		return
+	}
+
+	// Keep watching the replacement if it landed on spot again
+	if status, err := w.provider.GetInstanceStatus(ctx, instanceID); err == nil && status.Spot {
+		w.Track(workload)
+	}
+}
+
+// Run checks tracked VMs on every tick until the context is cancelled, then waits for
+// re-provisions in flight
+func (w *SpotWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer w.Wait()
+
+	for {
+		if _, err := w.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("spot: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var spotSpec = InstanceSpec{
+	Size:         SizeSmall,
+	Architecture: ArchAMD64,
+	Region:       "us-east-1",
+	SpotInstance: true,
+	Tags:         map[string]string{"stagely-env": "env-1"},
+}
+
+func TestWithSpotFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        SpotPolicy
+		fault         error
+		expectErr     error
+		expectCalls   int
+		expectMarket  string
+		expectedStats SpotStats
+	}{
+		{
+			name:          "spot launch succeeds",
+			policy:        SpotPolicy{FallbackToOnDemand: true},
+			expectCalls:   1,
+			expectMarket:  MarketSpot,
+			expectedStats: SpotStats{SpotLaunches: 1},
+		},
+		{
+			name:          "falls back on insufficient capacity",
+			policy:        SpotPolicy{FallbackToOnDemand: true},
+			fault:         ErrInsufficientCapacity,
+			expectCalls:   2,
+			expectMarket:  MarketOnDemand,
+			expectedStats: SpotStats{OnDemandFallbacks: 1},
+		},
+		{
+			name:          "falls back on spot quota",
+			policy:        SpotPolicy{FallbackToOnDemand: true},
+			fault:         ErrQuotaExceeded,
+			expectCalls:   2,
+			expectMarket:  MarketOnDemand,
+			expectedStats: SpotStats{OnDemandFallbacks: 1},
+		},
+		{
+			name:        "fallback disabled",
+			policy:      SpotPolicy{},
+			fault:       ErrInsufficientCapacity,
+			expectErr:   ErrInsufficientCapacity,
+			expectCalls: 1,
+		},
+		{
+			name:        "other errors are returned",
+			policy:      SpotPolicy{FallbackToOnDemand: true},
+			fault:       ErrInvalidCredentials,
+			expectErr:   ErrInvalidCredentials,
+			expectCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewMockProvider()
+			if tt.fault != nil {
+				inner.FailNth(MockOpCreateInstance, 1, tt.fault)
+			}
+			tt.policy.Metrics = &SpotMetrics{}
+			provider := WithSpotFallback(inner, tt.policy)
+
+			instanceID, _, err := provider.CreateInstance(context.Background(), spotSpec)
+
+			assert.Equal(t, tt.expectCalls, inner.CallCount(MockOpCreateInstance))
+			assert.Equal(t, tt.expectedStats, tt.policy.Metrics.Stats())
+			if tt.expectErr != nil {
+				assert.ErrorIs(t, err, tt.expectErr)
+				return
+			}
+
+			require.NoError(t, err)
+			calls := inner.Calls()
+			last := calls[len(calls)-1].Spec
+			assert.Equal(t, tt.expectMarket == MarketSpot, last.SpotInstance)
+			assert.Equal(t, tt.expectMarket, last.Tags[TagMarket])
+			assert.Equal(t, "env-1", last.Tags["stagely-env"])
+
+			status, err := inner.GetInstanceStatus(context.Background(), instanceID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectMarket == MarketSpot, status.Spot)
+		})
+	}
+
+	// The caller's tags are not modified
+	assert.NotContains(t, spotSpec.Tags, TagMarket)
+}
+
+func TestWithSpotFallback_LaunchAndStopper(t *testing.T) {
+	inner := NewMockProvider()
+	inner.FailNth(MockOpLaunchInstance, 1, ErrInsufficientCapacity)
+	provider := WithSpotFallback(inner, SpotPolicy{FallbackToOnDemand: true})
+
+	instanceID, err := provider.LaunchInstance(context.Background(), spotSpec)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.CallCount(MockOpLaunchInstance))
+
+	stopper, ok := AsStopper(provider)
+	require.True(t, ok)
+	require.NoError(t, stopper.StopInstance(context.Background(), instanceID))
+
+	_, ok = AsStopper(WithSpotFallback(&nonStopper{inner}, SpotPolicy{}))
+	assert.False(t, ok)
+}
+
+// nonStopper hides the mock's stop/start support
+type nonStopper struct {
+	CloudProvider
+}
+
+// recordingHandler records reclaim notifications
+type recordingHandler struct {
+	mu        sync.Mutex
+	moved     []SpotWorkload
+	previous  []string
+	lost      []error
+	replayErr error
+}
+
+func (h *recordingHandler) Reprovisioned(ctx context.Context, workload SpotWorkload, previousInstanceID, publicIP string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.moved = append(h.moved, workload)
+	h.previous = append(h.previous, previousInstanceID)
+	return h.replayErr
+}
+
+func (h *recordingHandler) Lost(ctx context.Context, workload SpotWorkload, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lost = append(h.lost, err)
+}
+
+// trackSpot launches a spot VM on inner and tracks it as a build job
+func trackSpot(t *testing.T, inner *MockProvider, watcher *SpotWatcher) SpotWorkload {
+	t.Helper()
+	instanceID, _, err := inner.CreateInstance(context.Background(), spotSpec)
+	require.NoError(t, err)
+
+	workload := SpotWorkload{Kind: "build_job", ID: "job-1", InstanceID: instanceID, Spec: spotSpec}
+	watcher.Track(workload)
+	return workload
+}
+
+func TestSpotWatcher_ReprovisionsReclaimedInstance(t *testing.T) {
+	inner := NewMockProvider()
+	metrics := &SpotMetrics{}
+	policy := SpotPolicy{FallbackToOnDemand: true, Reprovision: true, Metrics: metrics}
+	handler := &recordingHandler{}
+	watcher := NewSpotWatcher(WithSpotFallback(inner, policy), policy, handler)
+	ctx := context.Background()
+
+	workload := trackSpot(t, inner, watcher)
+
+	// Nothing happens while the VM is running
+	reclaimed, err := watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed)
+
+	// Spot capacity is gone when the VM is reclaimed, so the replacement is on-demand
+	require.NoError(t, inner.ReclaimInstance(workload.InstanceID))
+	inner.FailNth(MockOpCreateInstance, 2, ErrInsufficientCapacity)
+
+	reclaimed, err = watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	require.Len(t, reclaimed, 1)
+
+	require.Len(t, handler.moved, 1)
+	assert.Equal(t, workload.InstanceID, handler.previous[0])
+	assert.NotEqual(t, workload.InstanceID, handler.moved[0].InstanceID)
+	assert.Equal(t, 1, handler.moved[0].Reprovisions)
+	assert.Empty(t, handler.lost)
+
+	assert.Equal(t, SpotStats{OnDemandFallbacks: 1, Reclaims: 1, Reprovisions: 1}, metrics.Stats())
+
+	// The on-demand replacement is no longer watched
+	require.NoError(t, inner.ReclaimInstance(handler.moved[0].InstanceID))
+	reclaimed, err = watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed)
+}
+
+func TestSpotWatcher_KeepsWatchingSpotReplacement(t *testing.T) {
+	inner := NewMockProvider()
+	policy := SpotPolicy{Reprovision: true, MaxReprovisions: 1}
+	handler := &recordingHandler{}
+	watcher := NewSpotWatcher(WithSpotFallback(inner, policy), policy, handler)
+	ctx := context.Background()
+
+	workload := trackSpot(t, inner, watcher)
+	require.NoError(t, inner.ReclaimInstance(workload.InstanceID))
+	_, err := watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	require.Len(t, handler.moved, 1)
+
+	// Reclaimed again: the re-provision budget is spent
+	require.NoError(t, inner.ReclaimInstance(handler.moved[0].InstanceID))
+	reclaimed, err := watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	assert.Len(t, reclaimed, 1)
+	require.Len(t, handler.lost, 1)
+	assert.ErrorIs(t, handler.lost[0], ErrSpotReclaimed)
+}
+
+func TestSpotWatcher_LostWorkloads(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    SpotPolicy
+		fault     error
+		replayErr error
+		expectErr error
+	}{
+		{
+			name:      "re-provisioning disabled",
+			policy:    SpotPolicy{},
+			expectErr: ErrSpotReclaimed,
+		},
+		{
+			name:      "re-provisioning fails",
+			policy:    SpotPolicy{Reprovision: true},
+			fault:     ErrInvalidCredentials,
+			expectErr: ErrInvalidCredentials,
+		},
+		{
+			name:      "replay fails",
+			policy:    SpotPolicy{Reprovision: true},
+			replayErr: errors.New("build runner unavailable"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewMockProvider()
+			handler := &recordingHandler{replayErr: tt.replayErr}
+			watcher := NewSpotWatcher(inner, tt.policy, handler)
+			ctx := context.Background()
+
+			workload := trackSpot(t, inner, watcher)
+			require.NoError(t, inner.ReclaimInstance(workload.InstanceID))
+			if tt.fault != nil {
+				inner.FailNth(MockOpCreateInstance, 2, tt.fault)
+			}
+
+			_, err := watcher.Check(ctx)
+			watcher.Wait()
+			require.NoError(t, err)
+			require.Len(t, handler.lost, 1)
+			if tt.expectErr != nil {
+				assert.ErrorIs(t, handler.lost[0], tt.expectErr)
+			}
+
+			// The replacement of a failed replay is cleaned up
+			if tt.replayErr != nil {
+				status, err := inner.GetInstanceStatus(ctx, handler.moved[0].InstanceID)
+				require.NoError(t, err)
+				assert.Equal(t, StateTerminated, status.State)
+			}
+		})
+	}
+}
+
+func TestSpotWatcher_ReprovisionsInBackground(t *testing.T) {
+	// Given - two reclaimed VMs whose replacements never become ready, and one re-provision at a time
+	inner := NewMockProvider()
+	handler := &recordingHandler{}
+	watcher := NewSpotWatcher(inner, SpotPolicy{Reprovision: true, MaxConcurrent: 1}, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := trackSpot(t, inner, watcher)
+	second := trackSpot(t, inner, watcher)
+	require.NoError(t, inner.ReclaimInstance(first.InstanceID))
+	require.NoError(t, inner.ReclaimInstance(second.InstanceID))
+	inner.SetBootDelay(time.Hour)
+
+	// When
+	start := time.Now()
+	reclaimed, err := watcher.Check(ctx)
+
+	// Then - detection does not wait for the launches, which run one at a time
+	require.NoError(t, err)
+	assert.Len(t, reclaimed, 2)
+	assert.Less(t, time.Since(start), time.Second)
+	require.Eventually(t, func() bool { return inner.CallCount(MockOpCreateInstance) == 3 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 3, inner.CallCount(MockOpCreateInstance), "the second launch waits for a slot")
+
+	// Both are lost once the watcher stops
+	cancel()
+	watcher.Wait()
+	assert.Len(t, handler.lost, 2)
+	assert.Empty(t, handler.moved)
+}
+
+func TestSpotWatcher_IgnoresUntrackedAndOnDemand(t *testing.T) {
+	inner := NewMockProvider()
+	handler := &recordingHandler{}
+	watcher := NewSpotWatcher(inner, SpotPolicy{Reprovision: true}, handler)
+	ctx := context.Background()
+
+	workload := trackSpot(t, inner, watcher)
+	watcher.Untrack(workload.InstanceID)
+	require.NoError(t, inner.TerminateInstance(ctx, workload.InstanceID))
+
+	onDemand := spotSpec
+	onDemand.SpotInstance = false
+	watcher.Track(SpotWorkload{Kind: "environment", ID: "env-1", InstanceID: "mock-99", Spec: onDemand})
+
+	reclaimed, err := watcher.Check(ctx)
+	watcher.Wait()
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed)
+	assert.Empty(t, handler.moved)
+	assert.Empty(t, handler.lost)
+}
+
+func TestSpotWatcher_IgnoresVMsGoneForOtherReasons(t *testing.T) {
+	inner := NewMockProvider()
+	handler := &recordingHandler{}
+	watcher := NewSpotWatcher(inner, SpotPolicy{Reprovision: true}, handler)
+	ctx := context.Background()
+
+	// Given - one VM terminated without untracking it (e.g., by another Core instance), one unknown
+	terminated := trackSpot(t, inner, watcher)
+	require.NoError(t, inner.TerminateInstance(ctx, terminated.InstanceID))
+	watcher.Track(SpotWorkload{Kind: "environment", ID: "env-1", InstanceID: "mock-99", Spec: spotSpec})
+
+	// When
+	reclaimed, err := watcher.Check(ctx)
+	watcher.Wait()
+
+	// Then - nothing is re-provisioned, and neither VM is watched any more
+	require.NoError(t, err)
+	assert.Empty(t, reclaimed)
+	assert.Empty(t, handler.moved)
+	assert.Empty(t, handler.lost)
+	assert.Equal(t, 1, inner.CallCount(MockOpCreateInstance))
+
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	assert.Empty(t, watcher.workloads)
+}
@@ -0,0 +1,258 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Tags stamped on every VM Stagely launches for a workload.
+// The reconciler and cost-allocation reports rely on them, so teams cannot override them.
+const (
+	TagTeam        = "team"
+	TagProject     = "project"
+	TagEnvironment = "environment"
+	TagBuildJob    = "build-job"
+	TagWorkflowRun = "workflow-run"
+	TagCreatedBy   = "created-by"
+	TagExpiresAt   = "expires-at" // Unix seconds, so the value is valid on every provider
+)
+
+// reservedTags cannot be set through TagConfig
+var reservedTags = map[string]bool{
+	TagManagedBy:   true,
+	TagTeam:        true,
+	TagProject:     true,
+	TagEnvironment: true,
+	TagBuildJob:    true,
+	TagWorkflowRun: true,
+	TagCreatedBy:   true,
+	TagExpiresAt:   true,
+}
+
+// WorkloadTags identifies what a VM is launched for
+type WorkloadTags struct {
+	TeamID        string
+	ProjectID     string
+	EnvironmentID string // Set for environment VMs
+	BuildJobID    string // Set for build VMs
+	WorkflowRunID string
+	CreatedBy     string    // ID of the user (or "system") that triggered the launch
+	ExpiresAt     time.Time // When the VM should be gone at the latest (zero if unbounded)
+}
+
+// Tags returns the standard tags for the non-empty fields
+func (w WorkloadTags) Tags() map[string]string {
+	tags := make(map[string]string, 7)
+	for key, value := range map[string]string{
+		TagTeam:        w.TeamID,
+		TagProject:     w.ProjectID,
+		TagEnvironment: w.EnvironmentID,
+		TagBuildJob:    w.BuildJobID,
+		TagWorkflowRun: w.WorkflowRunID,
+		TagCreatedBy:   w.CreatedBy,
+	} {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	if !w.ExpiresAt.IsZero() {
+		tags[TagExpiresAt] = strconv.FormatInt(w.ExpiresAt.Unix(), 10)
+	}
+	return tags
+}
+
+// ExpiresAt returns the expiry stamped on an instance (false if it has none)
+func ExpiresAt(tags map[string]string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(tags[TagExpiresAt], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// TagLimits are a provider's constraints on instance tags
+type TagLimits struct {
+	MaxTags        int            // Tags per instance (0 = unlimited)
+	MaxKeyLength   int            // Characters per key (0 = unlimited)
+	MaxValueLength int            // Characters per value (0 = unlimited)
+	MaxTagLength   int            // Characters of "key:value" for providers with flat tags (0 = unlimited)
+	Charset        *regexp.Regexp // Keys and values must match (nil = anything)
+	ReservedKeys   []string       // Keys set by the provider itself
+	ReservedPrefix string         // Case-insensitive key prefix reserved by the provider
+}
+
+// Tag limits by provider type
+var tagLimits = map[string]TagLimits{
+	"aws": {
+		MaxTags:        49, // 50 per resource, one is the Name tag
+		MaxKeyLength:   128,
+		MaxValueLength: 256,
+		Charset:        regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`),
+		ReservedKeys:   []string{"Name"},
+		ReservedPrefix: "aws:",
+	},
+	"digitalocean": {
+		MaxTagLength: 255,
+		Charset:      regexp.MustCompile(`^[a-zA-Z0-9_\-]*$`), // Tags are "key:value", so neither may hold a colon
+	},
+	"hetzner": {
+		MaxKeyLength:   63,
+		MaxValueLength: 63,
+		Charset:        regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9_.\-]*[a-zA-Z0-9])?)?$`),
+	},
+}
+
+// TagLimitsFor returns the tag limits of a provider type (none for unknown types)
+func TagLimitsFor(providerType string) TagLimits {
+	return tagLimits[providerType]
+}
+
+// Validate checks tags against the limits
+func (l TagLimits) Validate(tags map[string]string) error {
+	if l.MaxTags > 0 && len(tags) > l.MaxTags {
+		return fmt.Errorf("%w: %d tags (max %d)", ErrInvalidInput, len(tags), l.MaxTags)
+	}
+
+	for _, key := range sortedKeys(tags) {
+		value := tags[key]
+		switch {
+		case key == "":
+			return fmt.Errorf("%w: empty tag key", ErrInvalidInput)
+		case l.MaxKeyLength > 0 && utf8.RuneCountInString(key) > l.MaxKeyLength:
+			return fmt.Errorf("%w: tag key %q is longer than %d characters", ErrInvalidInput, key, l.MaxKeyLength)
+		case l.MaxValueLength > 0 && utf8.RuneCountInString(value) > l.MaxValueLength:
+			return fmt.Errorf("%w: value of tag %q is longer than %d characters", ErrInvalidInput, key, l.MaxValueLength)
+		case l.MaxTagLength > 0 && utf8.RuneCountInString(key)+1+utf8.RuneCountInString(value) > l.MaxTagLength:
+			return fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidInput, key, l.MaxTagLength)
+		case l.Charset != nil && (!l.Charset.MatchString(key) || !l.Charset.MatchString(value)):
+			return fmt.Errorf("%w: tag %q contains characters the provider does not allow", ErrInvalidInput, key)
+		case l.ReservedPrefix != "" && strings.HasPrefix(strings.ToLower(key), l.ReservedPrefix):
+			return fmt.Errorf("%w: tag prefix %q is reserved", ErrInvalidInput, l.ReservedPrefix)
+		}
+		for _, reserved := range l.ReservedKeys {
+			if key == reserved {
+				return fmt.Errorf("%w: tag %q is set by the provider", ErrInvalidInput, key)
+			}
+		}
+	}
+	return nil
+}
+
+// TagConfig holds a team's tagging settings from cloud_providers.config
+type TagConfig struct {
+	// Tags are stamped on every VM and take precedence over the caller's tags
+	// (e.g., {"cost-center": "eng-42"})
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// RequiredTags must be present on every VM, either from the caller or from Tags
+	RequiredTags []string `json:"required_tags,omitempty"`
+}
+
+// TagPolicy builds the final tag set of a VM: caller tags, team tags and managed-by,
+// checked against the provider's limits. It is immutable and safe for concurrent use.
+type TagPolicy struct {
+	limits   TagLimits
+	tags     map[string]string
+	required []string
+}
+
+// NewTagPolicy creates the tag policy of a provider type with a team's settings
+func NewTagPolicy(providerType string, config TagConfig) (*TagPolicy, error) {
+	limits := TagLimitsFor(providerType)
+
+	for key := range config.Tags {
+		if reservedTags[key] {
+			return nil, fmt.Errorf("%w: tag %q is set by Stagely and cannot be configured", ErrInvalidInput, key)
+		}
+	}
+	if err := limits.Validate(config.Tags); err != nil {
+		return nil, err
+	}
+	for _, key := range config.RequiredTags {
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty required tag", ErrInvalidInput)
+		}
+	}
+
+	tags := make(map[string]string, len(config.Tags))
+	for k, v := range config.Tags {
+		tags[k] = v
+	}
+	return &TagPolicy{
+		limits:   limits,
+		tags:     tags,
+		required: append([]string(nil), config.RequiredTags...),
+	}, nil
+}
+
+// mustTagPolicy creates a policy without team settings, which cannot fail
+func mustTagPolicy(providerType string) *TagPolicy {
+	policy, err := NewTagPolicy(providerType, TagConfig{})
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// Apply returns the tags to launch a VM with
+func (p *TagPolicy) Apply(tags map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(tags)+len(p.tags)+1)
+	for k, v := range tags {
+		result[k] = v
+	}
+	for k, v := range p.tags {
+		result[k] = v
+	}
+	result[TagManagedBy] = ManagedByStagely
+
+	for _, key := range p.required {
+		if result[key] == "" {
+			return nil, fmt.Errorf("%w: tag %q is required", ErrInvalidInput, key)
+		}
+	}
+	if value, ok := result[TagExpiresAt]; ok {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: tag %q must be a Unix timestamp, got %q", ErrInvalidInput, TagExpiresAt, value)
+		}
+	}
+
+	if err := p.limits.Validate(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// instanceNamePattern matches characters that are not valid in a hostname
+var instanceNamePattern = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// instanceName names a VM after its workload ("stagely-env-<id>", "stagely-build-<id>"),
+// falling back to "stagely-vm". The name is a valid hostname label prefix (at most 48 characters).
+func instanceName(tags map[string]string) string {
+	name := "stagely-vm"
+	switch {
+	case tags[TagEnvironment] != "":
+		name = "stagely-env-" + tags[TagEnvironment]
+	case tags[TagBuildJob] != "":
+		name = "stagely-build-" + tags[TagBuildJob]
+	}
+
+	name = strings.Trim(instanceNamePattern.ReplaceAllString(name, "-"), "-")
+	if len(name) > 48 {
+		name = strings.TrimRight(name[:48], "-")
+	}
+	return name
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
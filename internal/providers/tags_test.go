@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkloadTags(t *testing.T) {
+	expiresAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tags := WorkloadTags{
+		TeamID:        "team-1",
+		ProjectID:     "proj-1",
+		EnvironmentID: "env-1",
+		WorkflowRunID: "run-1",
+		CreatedBy:     "user-1",
+		ExpiresAt:     expiresAt,
+	}.Tags()
+
+	assert.Equal(t, map[string]string{
+		TagTeam:        "team-1",
+		TagProject:     "proj-1",
+		TagEnvironment: "env-1",
+		TagWorkflowRun: "run-1",
+		TagCreatedBy:   "user-1",
+		TagExpiresAt:   "1772366400",
+	}, tags)
+
+	parsed, ok := ExpiresAt(tags)
+	require.True(t, ok)
+	assert.True(t, expiresAt.Equal(parsed))
+
+	_, ok = ExpiresAt(WorkloadTags{TeamID: "team-1"}.Tags())
+	assert.False(t, ok)
+}
+
+func TestTagLimits_Validate(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i < 50; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	tests := []struct {
+		name         string
+		providerType string
+		tags         map[string]string
+		expectError  bool
+	}{
+		{"aws valid", "aws", map[string]string{"cost-center": "eng 42", "team": "a/b@c"}, false},
+		{"aws too many", "aws", tooMany, true},
+		{"aws long key", "aws", map[string]string{strings.Repeat("k", 129): "v"}, true},
+		{"aws long value", "aws", map[string]string{"k": strings.Repeat("v", 257)}, true},
+		{"aws invalid character", "aws", map[string]string{"owner": "a;b"}, true},
+		{"aws reserved prefix", "aws", map[string]string{"AWS:foo": "bar"}, true},
+		{"aws Name", "aws", map[string]string{"Name": "vm"}, true},
+		{"empty key", "aws", map[string]string{"": "v"}, true},
+		{"hetzner long value", "hetzner", map[string]string{"k": strings.Repeat("v", 64)}, true},
+		{"hetzner valid", "hetzner", map[string]string{"cost-center": "eng_42.a", "note": ""}, false},
+		{"hetzner invalid character", "hetzner", map[string]string{"owner": "a b"}, true},
+		{"hetzner non-alphanumeric end", "hetzner", map[string]string{"owner": "team-"}, true},
+		{"digitalocean valid", "digitalocean", map[string]string{"cost-center": "eng_42"}, false},
+		{"digitalocean colon", "digitalocean", map[string]string{"owner": "a:b"}, true},
+		{"digitalocean invalid character", "digitalocean", map[string]string{"owner": "a.b"}, true},
+		{"digitalocean long tag", "digitalocean", map[string]string{"key": strings.Repeat("v", 252)}, true},
+		{"digitalocean at limit", "digitalocean", map[string]string{"key": strings.Repeat("v", 251)}, false},
+		{"docker unlimited", "docker", tooMany, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := TagLimitsFor(tt.providerType).Validate(tt.tags)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTagPolicy_Apply(t *testing.T) {
+	policy, err := NewTagPolicy("aws", TagConfig{
+		Tags:         map[string]string{"cost-center": "eng-42"},
+		RequiredTags: []string{TagTeam, "cost-center"},
+	})
+	require.NoError(t, err)
+
+	// When
+	tags, err := policy.Apply(map[string]string{
+		TagTeam:       "team-1",
+		"cost-center": "overridden-by-team",
+		TagManagedBy:  "someone-else",
+	})
+
+	// Then - team tags and managed-by win
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		TagTeam:       "team-1",
+		"cost-center": "eng-42",
+		TagManagedBy:  ManagedByStagely,
+	}, tags)
+
+	_, err = policy.Apply(map[string]string{"env": "test"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorContains(t, err, `"team"`)
+
+	_, err = policy.Apply(map[string]string{TagTeam: "team-1", TagExpiresAt: "2026-03-01T12:00:00Z"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = policy.Apply(map[string]string{TagTeam: "team-1", "owner": "a;b"})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestNewTagPolicy_Errors(t *testing.T) {
+	_, err := NewTagPolicy("aws", TagConfig{Tags: map[string]string{TagTeam: "team-1"}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewTagPolicy("hetzner", TagConfig{Tags: map[string]string{"cost-center": strings.Repeat("x", 64)}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewTagPolicy("aws", TagConfig{RequiredTags: []string{""}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestInstanceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		expected string
+	}{
+		{"no workload", nil, "stagely-vm"},
+		{"environment", map[string]string{TagEnvironment: "env_xk82j9s7d6f5"}, "stagely-env-env-xk82j9s7d6f5"},
+		{"build job", map[string]string{TagBuildJob: "3f2c9a7e-1b4d-4e8f-9a6b-2c5d7e9f1a3b"}, "stagely-build-3f2c9a7e-1b4d-4e8f-9a6b-2c5d7e9f1a"},
+		{"environment wins", map[string]string{TagEnvironment: "env-1", TagBuildJob: "job-1"}, "stagely-env-env-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := instanceName(tt.tags)
+			assert.Equal(t, tt.expected, name)
+			assert.LessOrEqual(t, len(name), 48)
+		})
+	}
+}
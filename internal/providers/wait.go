@@ -0,0 +1,152 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WaitOptions controls how WaitForReady polls an instance
+type WaitOptions struct {
+	PollInterval time.Duration // Delay before the first re-check (default 5s)
+	MaxInterval  time.Duration // Upper bound for the delay between checks (default 30s)
+	Backoff      float64       // Multiplier applied to the delay after each check (default 1, i.e. constant)
+	Timeout      time.Duration // Total time to wait for readiness (default 5m)
+}
+
+// DefaultWaitOptions returns the polling behaviour used by CreateInstance
+func DefaultWaitOptions() WaitOptions {
+	return WaitOptions{
+		PollInterval: 5 * time.Second,
+		MaxInterval:  30 * time.Second,
+		Backoff:      1,
+		Timeout:      5 * time.Minute,
+	}
+}
+
+// waitOptionsOf returns the polling behaviour of a provider's CreateInstance: DefaultWaitOptions,
+// unless the provider polls differently (MockProvider follows its simulated boot delay)
+func waitOptionsOf(p CloudProvider) WaitOptions {
+	if waiter, ok := as[interface{ waitOptions() WaitOptions }](p); ok {
+		return waiter.waitOptions()
+	}
+	return DefaultWaitOptions()
+}
+
+// withDefaults fills zero-valued fields from DefaultWaitOptions
+func (o WaitOptions) withDefaults() WaitOptions {
+	defaults := DefaultWaitOptions()
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaults.PollInterval
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = defaults.MaxInterval
+	}
+	if o.MaxInterval < o.PollInterval {
+		o.MaxInterval = o.PollInterval
+	}
+	if o.Backoff < 1 {
+		o.Backoff = defaults.Backoff
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaults.Timeout
+	}
+	return o
+}
+
+// WaitTimeoutError is returned when an instance does not become ready in time
+type WaitTimeoutError struct {
+	InstanceID string
+	Timeout    time.Duration
+	LastStatus InstanceStatus // Last status observed (zero if none could be read)
+	LastErr    error          // Error of the last status check (nil if it succeeded)
+}
+
+func (e *WaitTimeoutError) Error() string {
+	msg := fmt.Sprintf("timeout waiting for instance %s to become ready after %s (last state: %q)", e.InstanceID, e.Timeout, e.LastStatus.State)
+	if e.LastErr != nil {
+		msg += ": " + e.LastErr.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error of the last status check
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.LastErr
+}
+
+// ErrInstanceTerminated is returned when an instance terminates while waiting for it
+var ErrInstanceTerminated = errors.New("instance terminated before becoming ready")
+
+// WaitForReady polls GetInstanceStatus until the instance is running with a public IP.
+// Retryable status errors (network failures, throttling; see IsRetryable) are retried until the
+// timeout; any other error (e.g., invalid credentials, instance not found) is returned at once.
+func WaitForReady(ctx context.Context, p CloudProvider, instanceID string, opts WaitOptions) (InstanceStatus, error) {
+	opts = opts.withDefaults()
+
+	timeout := time.NewTimer(opts.Timeout)
+	defer timeout.Stop()
+
+	var last InstanceStatus
+	var lastErr error
+	interval := opts.PollInterval
+
+	for {
+		status, err := p.GetInstanceStatus(ctx, instanceID)
+		lastErr = err
+		if err == nil {
+			last = status
+			if status.IsReady() {
+				return status, nil
+			}
+			if status.State == StateTerminated {
+				return status, fmt.Errorf("%w: %s", ErrInstanceTerminated, instanceID)
+			}
+		} else if ctx.Err() != nil {
+			return last, ctx.Err()
+		} else if !retryableStatus(err) {
+			return last, err
+		}
+
+		poll := time.NewTimer(interval)
+		select {
+		case <-timeout.C:
+			poll.Stop()
+			return last, &WaitTimeoutError{InstanceID: instanceID, Timeout: opts.Timeout, LastStatus: last, LastErr: lastErr}
+		case <-ctx.Done():
+			poll.Stop()
+			return last, ctx.Err()
+		case <-poll.C:
+		}
+
+		interval = time.Duration(float64(interval) * opts.Backoff)
+		if interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// retryableStatus reports whether a failed status check may succeed when repeated
+func retryableStatus(err error) bool {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	return IsRetryable(err)
+}
+
+// launchAndWait launches an instance and blocks until it is ready (shared CreateInstance implementation)
+func launchAndWait(ctx context.Context, p CloudProvider, spec InstanceSpec, opts WaitOptions) (string, string, error) {
+	instanceID, err := p.LaunchInstance(ctx, spec)
+	if err != nil {
+		return "", "", err
+	}
+
+	status, err := WaitForReady(ctx, p, instanceID, opts)
+	if err != nil {
+		return instanceID, "", fmt.Errorf("wait for public IP: %w", err)
+	}
+
+	return instanceID, status.PublicIP, nil
+}
@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var waitTestSpec = InstanceSpec{
+	Size:         SizeSmall,
+	Architecture: ArchAMD64,
+	Region:       "us-east-1",
+}
+
+func TestWaitOptions_Defaults(t *testing.T) {
+	opts := WaitOptions{}.withDefaults()
+	assert.Equal(t, DefaultWaitOptions(), opts)
+
+	opts = WaitOptions{PollInterval: time.Minute, MaxInterval: time.Second, Backoff: 0.5}.withDefaults()
+	assert.Equal(t, time.Minute, opts.PollInterval)
+	assert.Equal(t, time.Minute, opts.MaxInterval)
+	assert.Equal(t, float64(1), opts.Backoff)
+}
+
+func TestWaitForReady_AlreadyReady(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider()
+
+	instanceID, err := provider.LaunchInstance(ctx, waitTestSpec)
+	require.NoError(t, err)
+
+	status, err := WaitForReady(ctx, provider, instanceID, WaitOptions{PollInterval: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+}
+
+func TestWaitForReady_PollsUntilReady(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProviderWithDelay(30 * time.Millisecond)
+
+	instanceID, err := provider.LaunchInstance(ctx, waitTestSpec)
+	require.NoError(t, err)
+
+	status, err := provider.GetInstanceStatus(ctx, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, StatePending, status.State)
+	assert.Empty(t, status.PublicIP)
+
+	status, err = WaitForReady(ctx, provider, instanceID, WaitOptions{
+		PollInterval: 2 * time.Millisecond,
+		MaxInterval:  10 * time.Millisecond,
+		Backoff:      2,
+		Timeout:      time.Second,
+	})
+	require.NoError(t, err)
+	assert.True(t, status.IsReady())
+}
+
+func TestWaitForReady_Timeout(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProviderWithDelay(time.Hour)
+
+	instanceID, err := provider.LaunchInstance(ctx, waitTestSpec)
+	require.NoError(t, err)
+
+	_, err = WaitForReady(ctx, provider, instanceID, WaitOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+	})
+
+	var timeoutErr *WaitTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, instanceID, timeoutErr.InstanceID)
+	assert.Equal(t, StatePending, timeoutErr.LastStatus.State)
+}
+
+func TestWaitForReady_Terminated(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProviderWithDelay(time.Hour)
+
+	instanceID, err := provider.LaunchInstance(ctx, waitTestSpec)
+	require.NoError(t, err)
+	require.NoError(t, provider.TerminateInstance(ctx, instanceID))
+
+	_, err = WaitForReady(ctx, provider, instanceID, WaitOptions{PollInterval: time.Millisecond})
+	assert.ErrorIs(t, err, ErrInstanceTerminated)
+}
+
+func TestWaitForReady_ContextCancelled(t *testing.T) {
+	provider := NewMockProviderWithDelay(time.Hour)
+
+	instanceID, err := provider.LaunchInstance(context.Background(), waitTestSpec)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = WaitForReady(ctx, provider, instanceID, WaitOptions{PollInterval: time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForReady_PermanentError(t *testing.T) {
+	// Given - an instance deleted out of band
+	provider := NewMockProviderWithDelay(time.Hour)
+	instanceID, err := provider.LaunchInstance(context.Background(), waitTestSpec)
+	require.NoError(t, err)
+	deleted := &ProviderError{Provider: "mock", Op: "describe instance", Kind: ErrInstanceNotFound, Err: errors.New("gone")}
+	provider.FailNth(MockOpGetInstanceStatus, 1, deleted)
+
+	// When
+	start := time.Now()
+	_, err = WaitForReady(context.Background(), provider, instanceID, WaitOptions{PollInterval: time.Millisecond, Timeout: time.Minute})
+
+	// Then - it fails at once instead of waiting for the timeout
+	assert.ErrorIs(t, err, ErrInstanceNotFound)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, provider.CallCount(MockOpGetInstanceStatus))
+}
+
+func TestWaitForReady_TimeoutKeepsLastError(t *testing.T) {
+	// Given - status checks keep failing with a retryable error
+	provider := NewMockProviderWithDelay(time.Hour)
+	instanceID, err := provider.LaunchInstance(context.Background(), waitTestSpec)
+	require.NoError(t, err)
+	throttled := &ProviderError{Provider: "mock", Op: "describe instance", Kind: ErrRateLimited, Retryable: true, Err: errors.New("slow down")}
+	for n := 1; n <= 1000; n++ {
+		provider.FailNth(MockOpGetInstanceStatus, n, throttled)
+	}
+
+	// When
+	_, err = WaitForReady(context.Background(), provider, instanceID, WaitOptions{PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond})
+
+	// Then
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Greater(t, provider.CallCount(MockOpGetInstanceStatus), 1)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Same(t, throttled, timeoutErr.LastErr)
+	assert.ErrorContains(t, err, "slow down")
+}
@@ -0,0 +1,150 @@
+// Package reconciler terminates provider-side VMs that Core no longer tracks or that outlived their expiry
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"gorm.io/gorm"
+)
+
+// DefaultGracePeriod is how long an untracked VM may live before it is treated as an orphan.
+// It covers the window between RunInstances and persisting vm_id.
+const DefaultGracePeriod = 15 * time.Minute
+
+// Tracker reports which VM IDs Core currently considers in use
+type Tracker interface {
+	TrackedInstanceIDs(ctx context.Context) (map[string]bool, error)
+}
+
+// DBTracker reads tracked VM IDs from the environments and build_jobs tables
+type DBTracker struct {
+	db *gorm.DB
+}
+
+// NewDBTracker creates a tracker backed by the given database
+func NewDBTracker(db *gorm.DB) *DBTracker {
+	return &DBTracker{db: db}
+}
+
+// TrackedInstanceIDs returns the vm_id of every live environment and active build job
+func (t *DBTracker) TrackedInstanceIDs(ctx context.Context) (map[string]bool, error) {
+	var ids []string
+	err := t.db.WithContext(ctx).Raw(`
+		SELECT vm_id FROM environments
+		WHERE vm_id IS NOT NULL AND vm_status IS DISTINCT FROM 'terminated'
+		UNION
+		SELECT vm_id FROM build_jobs
+		WHERE vm_id IS NOT NULL AND status IN ('queued', 'provisioning', 'running')
+	`).Scan(&ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("query tracked instances: %w", err)
+	}
+
+	tracked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		tracked[id] = true
+	}
+	return tracked, nil
+}
+
+// Result summarizes a single reconciliation pass
+type Result struct {
+	Orphans    []string // Untracked instances older than the grace period
+	Expired    []string // Instances past their expires-at tag, tracked or not
+	Terminated []string // Orphans and expired instances successfully terminated
+	Failed     []string // Orphans and expired instances whose termination failed
+}
+
+// Reconciler compares stagely-tagged provider instances against Core's records
+type Reconciler struct {
+	provider    providers.CloudProvider
+	tracker     Tracker
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+// New creates a reconciler for a single provider
+// A non-positive gracePeriod falls back to DefaultGracePeriod
+func New(provider providers.CloudProvider, tracker Tracker, gracePeriod time.Duration) *Reconciler {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+	return &Reconciler{
+		provider:    provider,
+		tracker:     tracker,
+		gracePeriod: gracePeriod,
+		now:         time.Now,
+	}
+}
+
+// Reconcile terminates every stagely-tagged instance that is past its expires-at tag, or that is
+// neither tracked nor within the grace period. Termination failures are reported in the result,
+// not as an error.
+func (r *Reconciler) Reconcile(ctx context.Context) (Result, error) {
+	var result Result
+
+	instances, err := r.provider.ListInstances(ctx, map[string]string{
+		providers.TagManagedBy: providers.ManagedByStagely,
+	})
+	if err != nil {
+		return result, fmt.Errorf("list instances: %w", err)
+	}
+
+	// Load tracked IDs after listing so a VM persisted in between is not mistaken for an orphan
+	tracked, err := r.tracker.TrackedInstanceIDs(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	now := r.now()
+	cutoff := now.Add(-r.gracePeriod)
+	for _, instance := range instances {
+		if instance.State == providers.StateTerminated {
+			continue
+		}
+
+		// An expired VM goes even if Core still tracks it: expiry bounds what a stuck workload costs
+		reason := "orphan"
+		if expiresAt, ok := providers.ExpiresAt(instance.Tags); ok && !now.Before(expiresAt) {
+			reason = "expired instance"
+			result.Expired = append(result.Expired, instance.ID)
+		} else {
+			if tracked[instance.ID] || instance.LaunchedAt.After(cutoff) {
+				continue
+			}
+			result.Orphans = append(result.Orphans, instance.ID)
+		}
+
+		if err := r.provider.TerminateInstance(ctx, instance.ID); err != nil {
+			log.Printf("reconciler: failed to terminate %s %s/%s: %v", reason, r.provider.Name(), instance.ID, err)
+			result.Failed = append(result.Failed, instance.ID)
+			continue
+		}
+		log.Printf("reconciler: terminated %s %s/%s (launched %s)", reason, r.provider.Name(), instance.ID, instance.LaunchedAt.Format(time.RFC3339))
+		result.Terminated = append(result.Terminated, instance.ID)
+	}
+
+	return result, nil
+}
+
+// Run reconciles on every tick until the context is cancelled
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("reconciler: %s: %v", r.provider.Name(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stagely-dev/stagely/internal/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticTracker struct {
+	ids map[string]bool
+	err error
+}
+
+func (s *staticTracker) TrackedInstanceIDs(ctx context.Context) (map[string]bool, error) {
+	return s.ids, s.err
+}
+
+func createInstance(t *testing.T, provider *providers.MockProvider) string {
+	t.Helper()
+	return createInstanceWithTags(t, provider, nil)
+}
+
+func createInstanceWithTags(t *testing.T, provider *providers.MockProvider, tags map[string]string) string {
+	t.Helper()
+	id, _, err := provider.CreateInstance(context.Background(), providers.InstanceSpec{
+		Size:         providers.SizeSmall,
+		Architecture: providers.ArchAMD64,
+		Region:       "us-east-1",
+		Tags:         tags,
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func TestReconcile_TerminatesOrphansAfterGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	provider := providers.NewMockProvider()
+
+	tracked := createInstance(t, provider)
+	orphan := createInstance(t, provider)
+
+	r := New(provider, &staticTracker{ids: map[string]bool{tracked: true}}, time.Minute)
+
+	// Within the grace period nothing is touched
+	result, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, result.Orphans)
+
+	// After the grace period the untracked instance is terminated
+	r.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	result, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphan}, result.Orphans)
+	assert.Equal(t, []string{orphan}, result.Terminated)
+	assert.Empty(t, result.Failed)
+
+	status, err := provider.GetInstanceStatus(ctx, orphan)
+	require.NoError(t, err)
+	assert.Equal(t, providers.StateTerminated, status.State)
+
+	status, err = provider.GetInstanceStatus(ctx, tracked)
+	require.NoError(t, err)
+	assert.Equal(t, providers.StateRunning, status.State)
+
+	// Already-terminated orphans are skipped on the next pass
+	result, err = r.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, result.Orphans)
+}
+
+func TestReconcile_TerminatesExpiredInstances(t *testing.T) {
+	// Given - tracked instances, one of them with an expiry, and a recent untracked one
+	ctx := context.Background()
+	provider := providers.NewMockProvider()
+	expiresAt := time.Now().Add(time.Hour)
+
+	expiring := createInstanceWithTags(t, provider, providers.WorkloadTags{TeamID: "team-1", ExpiresAt: expiresAt}.Tags())
+	unbounded := createInstanceWithTags(t, provider, providers.WorkloadTags{TeamID: "team-1"}.Tags())
+	recent := createInstanceWithTags(t, provider, providers.WorkloadTags{TeamID: "team-1", ExpiresAt: expiresAt}.Tags())
+
+	r := New(provider, &staticTracker{ids: map[string]bool{expiring: true, unbounded: true}}, 3*time.Hour)
+
+	// When - before the expiry
+	result, err := r.Reconcile(ctx)
+
+	// Then
+	require.NoError(t, err)
+	assert.Empty(t, result.Expired)
+	assert.Empty(t, result.Terminated)
+
+	// When - past the expiry, still within the grace period
+	r.now = func() time.Time { return expiresAt.Add(time.Second) }
+	result, err = r.Reconcile(ctx)
+
+	// Then - expired instances are terminated whether Core tracks them or not
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{expiring, recent}, result.Expired)
+	assert.ElementsMatch(t, []string{expiring, recent}, result.Terminated)
+	assert.Empty(t, result.Orphans)
+
+	status, err := provider.GetInstanceStatus(ctx, unbounded)
+	require.NoError(t, err)
+	assert.Equal(t, providers.StateRunning, status.State)
+}
+
+func TestReconcile_TrackerError(t *testing.T) {
+	provider := providers.NewMockProvider()
+	createInstance(t, provider)
+
+	r := New(provider, &staticTracker{err: errors.New("db down")}, time.Minute)
+	r.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	_, err := r.Reconcile(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNew_DefaultGracePeriod(t *testing.T) {
+	r := New(providers.NewMockProvider(), &staticTracker{}, 0)
+	assert.Equal(t, DefaultGracePeriod, r.gracePeriod)
+}
@@ -0,0 +1,91 @@
+// Package secrets encrypts and decrypts the values of secrets rows
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+)
+
+// Secret identifies a secrets row
+// Its value is bound to ProjectID, Key and Scope (the row's unique key), so changing
+// any of them requires re-encrypting the value.
+type Secret struct {
+	TeamID    string // Owner of the project; selects the data key
+	ProjectID string
+	Key       string
+	Scope     string // "global" or a service name
+}
+
+// AssociatedData returns the data a secret's value is bound to
+func AssociatedData(projectID, key, scope string) []byte {
+	return aead.AssociatedData("secrets", projectID, key, scope)
+}
+
+// Cipher encrypts secret values with their team's data key
+type Cipher struct {
+	envelope *crypto.Envelope
+}
+
+// NewCipher creates a cipher using the given envelope
+func NewCipher(envelope *crypto.Envelope) *Cipher {
+	return &Cipher{envelope: envelope}
+}
+
+// Encrypt encrypts a value for storage in the secret's encrypted_value
+func (c *Cipher) Encrypt(ctx context.Context, secret Secret, value string) (string, error) {
+	encrypted, err := c.envelope.Encrypt(ctx, secret.TeamID, value, AssociatedData(secret.ProjectID, secret.Key, secret.Scope))
+	if err != nil {
+		return "", fmt.Errorf("encrypt secret %s: %w", secret.Key, err)
+	}
+	return encrypted, nil
+}
+
+// Decrypt decrypts the secret's encrypted_value
+// A value copied from another row fails authentication.
+func (c *Cipher) Decrypt(ctx context.Context, secret Secret, encryptedValue string) (string, error) {
+	value, err := c.envelope.Decrypt(ctx, secret.TeamID, encryptedValue, AssociatedData(secret.ProjectID, secret.Key, secret.Scope))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret %s: %w", secret.Key, err)
+	}
+	return value, nil
+}
+
+// EncryptFile returns a writer that encrypts the content of a file secret to dst chunk by chunk,
+// so large files (e.g., TLS bundles) are never held in memory. Close must be called once the
+// content is written. The stream is stored in the secret's encrypted_file.
+func (c *Cipher) EncryptFile(ctx context.Context, secret Secret, dst io.Writer) (io.WriteCloser, error) {
+	w, err := c.envelope.EncryptStream(ctx, secret.TeamID, dst, AssociatedData(secret.ProjectID, secret.Key, secret.Scope))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt secret %s: %w", secret.Key, err)
+	}
+	return w, nil
+}
+
+// DecryptFile returns a reader over the content of a file secret encrypted with EncryptFile.
+// Reading fails if the stream was truncated, reordered or copied from another row.
+func (c *Cipher) DecryptFile(ctx context.Context, secret Secret, src io.Reader) (io.Reader, error) {
+	r, err := c.envelope.DecryptStream(ctx, secret.TeamID, src, AssociatedData(secret.ProjectID, secret.Key, secret.Scope))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secret %s: %w", secret.Key, err)
+	}
+	return r, nil
+}
+
+// ParseFileMode parses a secret's file_permissions (octal, e.g. "0600").
+// Empty permissions default to 0600; special bits (setuid, setgid, sticky) are rejected.
+func ParseFileMode(permissions string) (os.FileMode, error) {
+	if permissions == "" {
+		return 0o600, nil
+	}
+	mode, err := strconv.ParseUint(permissions, 8, 32)
+	if err != nil || mode&^0o777 != 0 {
+		return 0, fmt.Errorf("invalid file permissions %q: expected octal like 0600", permissions)
+	}
+	return os.FileMode(mode), nil
+}
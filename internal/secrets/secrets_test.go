@@ -0,0 +1,172 @@
+package secrets_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stagely-dev/stagely/internal/crypto"
+	"github.com/stagely-dev/stagely/internal/crypto/aead"
+	"github.com/stagely-dev/stagely/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryDataKeys is an in-memory crypto.DataKeyStore
+type memoryDataKeys map[string]string
+
+func (s memoryDataKeys) GetDataKey(ctx context.Context, teamID string) (string, error) {
+	wrapped, ok := s[teamID]
+	if !ok {
+		return "", crypto.ErrNoDataKey
+	}
+	return wrapped, nil
+}
+
+func (s memoryDataKeys) CreateDataKey(ctx context.Context, teamID, wrappedKey string) (string, error) {
+	if existing, ok := s[teamID]; ok {
+		return existing, nil
+	}
+	s[teamID] = wrappedKey
+	return wrappedKey, nil
+}
+
+func (s memoryDataKeys) DeleteDataKey(ctx context.Context, teamID string) error {
+	delete(s, teamID)
+	return nil
+}
+
+func setup(t *testing.T) (*secrets.Cipher, *crypto.Keyring) {
+	t.Helper()
+	key, err := aead.GenerateKey()
+	require.NoError(t, err)
+	master, err := crypto.NewKeyring("k1", map[string][]byte{"k1": key})
+	require.NoError(t, err)
+	return secrets.NewCipher(crypto.NewEnvelope(master, master, memoryDataKeys{}, 0)), master
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	// Given
+	cipher, _ := setup(t)
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "global"}
+	ctx := context.Background()
+
+	// When
+	encrypted, err := cipher.Encrypt(ctx, secret, "hunter2")
+	require.NoError(t, err)
+	value, err := cipher.Decrypt(ctx, secret, encrypted)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+	assert.True(t, crypto.IsDataKeyCiphertext(encrypted))
+	assert.True(t, crypto.IsBound(encrypted))
+}
+
+func TestCipher_SwappedRow(t *testing.T) {
+	// Given - a value stored in one secrets row
+	cipher, _ := setup(t)
+	ctx := context.Background()
+	source := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "global"}
+	encrypted, err := cipher.Encrypt(ctx, source, "hunter2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		target secrets.Secret
+	}{
+		{"other key", secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "API_KEY", Scope: "global"}},
+		{"other scope", secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "backend"}},
+		{"other project", secrets.Secret{TeamID: "team-a", ProjectID: "project-2", Key: "DB_PASSWORD", Scope: "global"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When - the value is copied into another row
+			_, err := cipher.Decrypt(ctx, tt.target, encrypted)
+
+			// Then
+			assert.ErrorContains(t, err, "authentication failed")
+		})
+	}
+}
+
+func TestCipher_MasterKeyValue(t *testing.T) {
+	// Given - a value written before data keys and associated data existed
+	cipher, master := setup(t)
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "DB_PASSWORD", Scope: "global"}
+	encrypted, err := master.Encrypt("hunter2")
+	require.NoError(t, err)
+
+	// When
+	value, err := cipher.Decrypt(context.Background(), secret, encrypted)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+}
+
+func TestCipher_FileRoundTrip(t *testing.T) {
+	// Given - a file secret larger than one chunk
+	cipher, _ := setup(t)
+	ctx := context.Background()
+	secret := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "TLS_BUNDLE", Scope: "backend"}
+	content := strings.Repeat("-----BEGIN CERTIFICATE-----\n", 5000)
+
+	// When
+	var stored bytes.Buffer
+	w, err := cipher.EncryptFile(ctx, secret, &stored)
+	require.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := cipher.DecryptFile(ctx, secret, bytes.NewReader(stored.Bytes()))
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(r)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, content, string(decrypted))
+	assert.Greater(t, len(content), aead.StreamChunkSize)
+
+	// A stream copied into another row fails authentication
+	other := secrets.Secret{TeamID: "team-a", ProjectID: "project-1", Key: "TLS_BUNDLE", Scope: "frontend"}
+	r, err = cipher.DecryptFile(ctx, other, bytes.NewReader(stored.Bytes()))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorContains(t, err, "authentication failed")
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		permissions string
+		want        os.FileMode
+		wantErr     bool
+	}{
+		{"0600", 0o600, false},
+		{"644", 0o644, false},
+		{"", 0o600, false},
+		{"4755", 0, true},
+		{"0999", 0, true},
+		{"rw-r--r--", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permissions, func(t *testing.T) {
+			// When
+			mode, err := secrets.ParseFileMode(tt.permissions)
+
+			// Then
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, mode)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+-- Track credential validation health on cloud_providers
+ALTER TABLE cloud_providers
+    ADD COLUMN IF NOT EXISTS credentials_valid BOOLEAN,
+    ADD COLUMN IF NOT EXISTS validation_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS is_healthy BOOLEAN NOT NULL DEFAULT true;
+
+-- Allow audit entries about cloud providers
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS valid_resource_type;
+ALTER TABLE audit_logs ADD CONSTRAINT valid_resource_type
+    CHECK (resource_type IN ('team', 'project', 'environment', 'secret', 'user', 'workflow_run', 'cloud_provider'));
+
+-- Comments
+COMMENT ON COLUMN cloud_providers.credentials_valid IS 'Result of the last conclusive credential validation (NULL if never validated)';
+COMMENT ON COLUMN cloud_providers.validation_failures IS 'Consecutive failed validations, reset on success';
+COMMENT ON COLUMN cloud_providers.is_healthy IS 'False once validation_failures reaches the validator threshold';
@@ -0,0 +1,11 @@
+-- Record where each VM actually landed (provider account and region)
+ALTER TABLE build_jobs
+    ADD COLUMN IF NOT EXISTS vm_region VARCHAR(50);
+
+ALTER TABLE environments
+    ADD COLUMN IF NOT EXISTS cloud_provider_id UUID REFERENCES cloud_providers(id),
+    ADD COLUMN IF NOT EXISTS vm_region VARCHAR(50);
+
+-- Comments
+COMMENT ON COLUMN build_jobs.vm_region IS 'Region the build VM was placed in (may differ from the preferred region after failover)';
+COMMENT ON COLUMN environments.vm_region IS 'Region the environment VM was placed in (may differ from the preferred region after failover)';
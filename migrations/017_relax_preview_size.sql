@@ -0,0 +1,9 @@
+-- Sizes come from the provider's instance-type catalog, which teams can extend
+-- through cloud_providers.config, so only the format of the size name is checked
+ALTER TABLE projects DROP CONSTRAINT IF EXISTS valid_size;
+ALTER TABLE projects ADD CONSTRAINT valid_size
+    CHECK (default_preview_size ~ '^[a-z0-9][a-z0-9-]{0,19}$');
+
+-- Comments
+COMMENT ON COLUMN projects.default_preview_size IS 'Instance size from the cloud provider catalog (e.g., small, xlarge, or a team-defined size)';
+COMMENT ON COLUMN cloud_providers.config IS 'Provider-specific settings (JSON), e.g. AMIs and instance_types catalog overrides';
@@ -0,0 +1,28 @@
+-- Inputs and results of the cost meter
+ALTER TABLE environments
+    ADD COLUMN IF NOT EXISTS vm_launched_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS vm_instance_type VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS vm_spot BOOLEAN,
+    ADD COLUMN IF NOT EXISTS hourly_rate_usd DECIMAL(10, 6),
+    ADD COLUMN IF NOT EXISTS cost_final BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS cost_updated_at TIMESTAMPTZ;
+
+ALTER TABLE build_jobs
+    ADD COLUMN IF NOT EXISTS vm_launched_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS vm_instance_type VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS vm_spot BOOLEAN,
+    ADD COLUMN IF NOT EXISTS hourly_rate_usd DECIMAL(10, 6),
+    ADD COLUMN IF NOT EXISTS estimated_cost_usd DECIMAL(10, 4) DEFAULT 0.0,
+    ADD COLUMN IF NOT EXISTS cost_final BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS cost_updated_at TIMESTAMPTZ;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_environments_cost_open ON environments(id) WHERE vm_id IS NOT NULL AND NOT cost_final;
+CREATE INDEX IF NOT EXISTS idx_build_jobs_cost_open ON build_jobs(id) WHERE vm_id IS NOT NULL AND NOT cost_final;
+
+-- Comments
+COMMENT ON COLUMN environments.estimated_cost_usd IS 'Cost accrued from vm_launched_at until termination at hourly_rate_usd (NULL if the VM could not be priced)';
+COMMENT ON COLUMN environments.hourly_rate_usd IS 'Price table rate for vm_instance_type (spot or on-demand), fixed on first metering';
+COMMENT ON COLUMN environments.cost_final IS 'True once the VM is gone and estimated_cost_usd no longer changes';
+COMMENT ON COLUMN build_jobs.estimated_cost_usd IS 'Cost accrued from vm_launched_at until completion at hourly_rate_usd (NULL if the VM could not be priced)';
+COMMENT ON COLUMN build_jobs.cost_final IS 'True once the job completed and estimated_cost_usd no longer changes';
@@ -0,0 +1,18 @@
+-- Create team_data_keys table (per-team data keys for envelope encryption)
+CREATE TABLE IF NOT EXISTS team_data_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+
+    -- Data key wrapped with the master key (local keyring, Vault Transit or AWS KMS)
+    wrapped_key TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT unique_team_data_key UNIQUE(team_id)
+);
+
+-- Comments
+COMMENT ON TABLE team_data_keys IS 'Per-team data keys; deleting a row (or the team) makes the team''s secrets and cloud credentials unrecoverable';
+COMMENT ON COLUMN team_data_keys.wrapped_key IS 'Random 32-byte AES key wrapped with the master key: "<key ID>:ad:" for a local keyring, "vault:" or "kms:" for a KMS';
+COMMENT ON COLUMN secrets.encrypted_value IS 'Encrypted with the team data key ("dek:" prefix) or, for older values, the master key';
+COMMENT ON COLUMN cloud_providers.encrypted_credentials IS 'Encrypted with the team data key ("dek:" prefix) or, for older values, the master key';
@@ -0,0 +1,14 @@
+-- Cost of the VMs an environment or build job ran on before its current vm_id.
+-- When vm_id changes, the metering columns restart for the new VM and what the
+-- previous one accrued is kept here.
+ALTER TABLE environments
+    ADD COLUMN IF NOT EXISTS replaced_vms_cost_usd DECIMAL(10, 4) DEFAULT 0.0;
+
+ALTER TABLE build_jobs
+    ADD COLUMN IF NOT EXISTS replaced_vms_cost_usd DECIMAL(10, 4) DEFAULT 0.0;
+
+-- Comments
+COMMENT ON COLUMN environments.replaced_vms_cost_usd IS 'Cost of previous VMs, included in estimated_cost_usd (NULL if one could not be priced)';
+COMMENT ON COLUMN build_jobs.replaced_vms_cost_usd IS 'Cost of previous VMs, included in estimated_cost_usd (NULL if one could not be priced)';
+COMMENT ON COLUMN environments.vm_launched_at IS 'Launch time of vm_id; stopped time is billed as running';
+COMMENT ON COLUMN build_jobs.vm_launched_at IS 'Launch time of vm_id; stopped time is billed as running';
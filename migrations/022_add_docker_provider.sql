@@ -0,0 +1,8 @@
+-- Allow local Docker containers as a provider (development and CI)
+-- Rows of this type only work on servers started with DOCKER_PROVIDER_ENABLED
+ALTER TABLE cloud_providers DROP CONSTRAINT IF EXISTS valid_provider;
+ALTER TABLE cloud_providers ADD CONSTRAINT valid_provider
+    CHECK (provider_type IN ('aws', 'gcp', 'digitalocean', 'hetzner', 'linode', 'docker'));
+
+-- Comments
+COMMENT ON COLUMN cloud_providers.provider_type IS 'Provider type: aws, gcp, digitalocean, hetzner, linode, or docker (local containers, no credentials, development and CI servers only)';
@@ -0,0 +1,14 @@
+-- Store file secrets as encrypted streams (aead.NewEncryptWriter) instead of a single string
+ALTER TABLE secrets ADD COLUMN IF NOT EXISTS encrypted_file BYTEA;
+ALTER TABLE secrets ALTER COLUMN encrypted_value DROP NOT NULL;
+
+-- A secret holds its value in exactly one column; only file secrets hold streams
+-- (file secrets written before streams keep their content in encrypted_value)
+ALTER TABLE secrets ADD CONSTRAINT one_encrypted_value
+    CHECK ((encrypted_value IS NULL) <> (encrypted_file IS NULL));
+ALTER TABLE secrets ADD CONSTRAINT encrypted_file_is_file
+    CHECK (encrypted_file IS NULL OR secret_type = 'file');
+
+-- Comments
+COMMENT ON COLUMN secrets.encrypted_value IS 'AES-256-GCM encrypted value (NULL when encrypted_file is set)';
+COMMENT ON COLUMN secrets.encrypted_file IS 'File secret content as an encrypted stream under the team data key (no prefix; see secrets.Cipher.EncryptFile). Not rewritten by key rotation: only the data key is re-wrapped';